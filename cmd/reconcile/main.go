@@ -0,0 +1,99 @@
+// Команда reconcile пересчитывает балансы пользователей по журналу проводок, истории транзакций
+// и партиям монет, проверяет сохранение монет и выводит расхождения в JSON или CSV:
+//
+//	go run ./cmd/reconcile -format csv -out report.csv
+//
+// Код выхода 1 означает, что расхождения найдены. Одобренный отчёт в JSON применяется
+// корректирующими проводками от имени hr-admin:
+//
+//	go run ./cmd/reconcile -apply report.json -admin hr -reason "сверка за март"
+package main
+
+import (
+	"avito-intern/internal/reconcile"
+	"avito-intern/pkg/db"
+	"avito-intern/storage"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+func main() {
+	var (
+		format = flag.String("format", string(reconcile.JSON), "report format: json or csv")
+		out    = flag.String("out", "", "report file, stdout by default")
+		apply  = flag.String("apply", "", "approved JSON report to post correcting entries for")
+		admin  = flag.String("admin", "", "hr-admin username approving the corrections")
+		reason = flag.String("reason", "", "reason recorded with the corrections")
+	)
+	flag.Parse()
+
+	ok, err := run(context.Background(), *format, *out, *apply, *admin, *reason)
+	if err != nil {
+		slog.Error("reconciliation failed", "error", err)
+		os.Exit(2)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, format, out, apply, admin, reason string) (bool, error) {
+	var cfg db.Config
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return false, err
+	}
+	database, err := db.NewDB(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+	svc := reconcile.NewService(storage.NewRepo(database, nil))
+
+	if apply != "" {
+		return true, correct(ctx, svc, apply, admin, reason)
+	}
+
+	report, err := svc.Check(ctx)
+	if err != nil {
+		return false, err
+	}
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := reconcile.WriteReport(w, report, reconcile.Format(format)); err != nil {
+		return false, err
+	}
+	slog.Info("reconciliation finished",
+		"users", report.Users,
+		"discrepancies", len(report.Discrepancies),
+		"conservation_holds", report.Conservation.Holds())
+	return report.OK(), nil
+}
+
+func correct(ctx context.Context, svc reconcile.Service, path, admin, reason string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	report, err := reconcile.ReadReport(f)
+	if err != nil {
+		return fmt.Errorf("failed to read report: %w", err)
+	}
+	corrections, err := svc.Correct(ctx, admin, reason, report.Discrepancies)
+	for _, c := range corrections {
+		slog.Info("balance corrected", "user", c.Balance.Username, "diff", c.Balance.Diff())
+	}
+	return err
+}
@@ -4,12 +4,21 @@ import (
 	"avito-intern/internal/auth"
 	"avito-intern/internal/coin"
 	"avito-intern/internal/config"
+	"avito-intern/internal/idempotency"
+	"avito-intern/internal/leaderboard"
 	"avito-intern/internal/merch"
 	migration "avito-intern/internal/migrations"
+	"avito-intern/internal/outbox"
+	"avito-intern/internal/reconcile"
+	"avito-intern/internal/scheduler"
+	"avito-intern/internal/statement"
+	"avito-intern/internal/webhook"
 	"avito-intern/pkg/db"
 	"avito-intern/server"
 	"avito-intern/storage"
 	"context"
+	"log/slog"
+	"time"
 )
 
 func main() {
@@ -22,22 +31,92 @@ func main() {
 
 	readyFn := migration.Migrate(database)
 
-	pg := storage.NewRepo(database)
-	authService := auth.NewService(&cfg.Auth, pg)
+	identities := auth.NewIdentityCache(cfg.Auth.IdentityCacheSize, cfg.Auth.IdentityCacheTTL)
+	revocations := auth.NewRevocationCache(cfg.Auth.RevocationCacheSize, cfg.Auth.RevocationCacheTTL)
+	pg := storage.NewRepo(database, identities)
+	keys, err := auth.NewKeySetFromConfig(&cfg.Auth)
+	if err != nil {
+		panic(err)
+	}
+	go keys.RunReload(ctx, cfg.Auth.JWTKeysReloadInterval)
+
+	registrationPolicy, err := auth.NewRegistrationPolicy(&cfg.Auth, pg)
+	if err != nil {
+		panic(err)
+	}
+	authService := auth.NewService(&cfg.Auth, keys, registrationPolicy, pg, pg, pg, database, identities, revocations)
 	authHandlers := auth.NewAuthHandlers(authService)
+	go authService.RunCleanup(ctx)
+	go func() {
+		// администратор создаётся после миграций, иначе таблицы ролей ещё нет.
+		for !readyFn() {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err := authService.BootstrapAdmin(ctx); err != nil {
+			slog.Error("failed to bootstrap admin", "error", err)
+		}
+	}()
+	jwksHandlers := auth.NewJWKSHandlers(keys)
 	router := server.New(cfg.HTTP, func() bool {
 		return readyFn()
 	})
 
-	coinService := coin.NewService(authService, pg)
-	coinHandlers := coin.NewCoinHandler(coinService, authHandlers)
+	idempotencyService := idempotency.NewService(&cfg.Idempotency, pg)
+	idempotencyHandlers := idempotency.NewIdempotencyHandler(idempotencyService, database)
+	go idempotencyService.RunCleanup(ctx)
+
+	coinService := coin.NewService(&cfg.Coin, authService, pg, database, coin.NewTransferPolicy(&cfg.Coin, pg))
+	go coinService.RunExpiry(ctx)
+	go coinService.RunCoinExpiry(ctx)
+	coinHandlers := coin.NewCoinHandler(coinService, authHandlers, idempotencyHandlers)
+
+	merchService := merch.NewService(authService, coinService, pg, database, merch.NewCatalogCache(cfg.Merch.CatalogTTL, cfg.Merch.CatalogVersionTTL))
+	merchHandlers := merch.NewMerchHandler(merchService, authHandlers, idempotencyHandlers)
+
+	leaderboardHandlers := leaderboard.NewLeaderboardHandler(leaderboard.NewService(&cfg.Leaderboard, pg), authHandlers)
+
+	statementHandlers := statement.NewStatementHandler(statement.NewService(pg), authHandlers)
+
+	schedulerService := scheduler.NewService(&cfg.Scheduler, pg, pg, map[scheduler.Kind]scheduler.Runner{
+		coin.GrantJob: coin.NewGrantRunner(pg),
+		reconcile.Job: reconcile.NewRunner(reconcile.NewService(pg)),
+	})
+	go schedulerService.Run(ctx)
+	schedulerHandlers := scheduler.NewSchedulerHandler(schedulerService, authHandlers)
+
+	webhookService := webhook.NewService(&cfg.Webhook, pg, webhook.NewClient(cfg.Webhook.Timeout))
+	go webhookService.RunDelivery(ctx)
+	webhookHandlers := webhook.NewWebhookHandler(webhookService, authHandlers)
 
-	merchService := merch.NewService(authService, coinService, pg)
-	merchHandlers := merch.NewMerchHandler(merchService, authHandlers)
+	// подписки на вебхуки и приёмник из конфигурации получают события независимо друг от друга.
+	consumers := []outbox.Consumer{{
+		Name:      webhook.ConsumerName,
+		LockKey:   cfg.Webhook.RelayLockKey,
+		Publisher: webhook.NewDispatcher(pg),
+	}}
+	sink, err := outbox.NewPublisher(&cfg.Outbox)
+	if err != nil {
+		panic(err)
+	}
+	if sink != nil {
+		consumers = append(consumers, outbox.Consumer{
+			Name:      outbox.SinkConsumer,
+			LockKey:   cfg.Outbox.LockKey,
+			Publisher: sink,
+		})
+	}
+	relay := outbox.NewRelay(&cfg.Outbox, pg, database, consumers...)
+	go relay.Run(ctx)
+	go relay.RunCleanup(ctx)
 
+	router.AddRoot(jwksHandlers)
 	router.Add(authHandlers)
 	router.Add(coinHandlers)
 	router.Add(merchHandlers)
+	router.Add(leaderboardHandlers)
+	router.Add(statementHandlers)
+	router.Add(schedulerHandlers)
+	router.Add(webhookHandlers)
 	if err := router.Run(); err != nil {
 		panic(err)
 	}
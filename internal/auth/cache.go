@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"avito-intern/pkg/cache"
+	"slices"
+	"time"
+)
+
+// IdentityCache хранит идентичность пользователей (имя и роли) для Verify, чтобы не ходить
+// в БД на каждый запрос. Баланс и хэш пароля не кэшируются.
+// Записи сбрасываются при изменении пользователя на этой реплике, на остальных — по TTL.
+type IdentityCache struct {
+	users *cache.Cache[UserID, User]
+}
+
+// NewIdentityCache создает кэш на size пользователей. При size <= 0 кэш выключен.
+func NewIdentityCache(size int, ttl time.Duration) *IdentityCache {
+	return &IdentityCache{users: cache.New[UserID, User](size, ttl)}
+}
+
+// Get возвращает копию пользователя, которую можно изменять.
+func (c *IdentityCache) Get(id UserID) (*User, bool) {
+	if c == nil {
+		return nil, false
+	}
+	u, ok := c.users.Get(id)
+	if !ok {
+		return nil, false
+	}
+	u.Roles = slices.Clone(u.Roles)
+	return &u, true
+}
+
+func (c *IdentityCache) Set(u *User) {
+	if c == nil {
+		return
+	}
+	c.users.Set(u.ID, *u.Identity())
+}
+
+// Invalidate сбрасывает пользователя после изменения его данных.
+func (c *IdentityCache) Invalidate(id UserID) {
+	if c == nil {
+		return
+	}
+	c.users.Delete(id)
+}
+
+// RevocationCache хранит результат проверки отзыва токенов, чтобы Verify не ходил в БД
+// на каждый запрос. Отзыв на этой реплике (Logout, повторное использование refresh токена)
+// попадает в кэш сразу, на остальных репликах — по истечении TTL.
+type RevocationCache struct {
+	tokens   *cache.Cache[string, bool]
+	sessions *cache.Cache[SessionID, struct{}]
+}
+
+// NewRevocationCache создает кэш на size токенов и сессий. При size <= 0 кэш выключен.
+func NewRevocationCache(size int, ttl time.Duration) *RevocationCache {
+	return &RevocationCache{
+		tokens:   cache.New[string, bool](size, ttl),
+		sessions: cache.New[SessionID, struct{}](size, ttl),
+	}
+}
+
+// Get возвращает закэшированный результат проверки; ok == false, если его нужно читать из БД.
+func (c *RevocationCache) Get(jti string, sessionID SessionID) (revoked, ok bool) {
+	if c == nil || jti == "" {
+		return false, false
+	}
+	if sessionID != "" {
+		if _, revoked := c.sessions.Get(sessionID); revoked {
+			return true, true
+		}
+	}
+	return c.tokens.Get(jti)
+}
+
+// Set запоминает результат проверки токена из БД.
+func (c *RevocationCache) Set(jti string, revoked bool) {
+	if c == nil || jti == "" {
+		return
+	}
+	c.tokens.Set(jti, revoked)
+}
+
+// RevokeToken помечает токен отозванным после фиксации отзыва.
+func (c *RevocationCache) RevokeToken(jti string) {
+	c.Set(jti, true)
+}
+
+// RevokeSession помечает отозванными все токены сессии после фиксации отзыва.
+func (c *RevocationCache) RevokeSession(sessionID SessionID) {
+	if c == nil || sessionID == "" {
+		return
+	}
+	c.sessions.Set(sessionID, struct{}{})
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingUserRepo считает обращения к GetUserByID и имитирует задержку запроса к БД.
+func countingUserRepo(user *User, latency time.Duration, calls *atomic.Int64) *fakeUserRepo {
+	return &fakeUserRepo{
+		getUserByIDFunc: func(_ context.Context, _ UserID) (*User, error) {
+			calls.Add(1)
+			if latency > 0 {
+				time.Sleep(latency)
+			}
+			u := *user
+			return &u, nil
+		},
+	}
+}
+
+// countingSessionRepo считает обращения к IsTokenRevoked и имитирует задержку запроса к БД.
+type countingSessionRepo struct {
+	*fakeSessionRepo
+	latency time.Duration
+	calls   *atomic.Int64
+}
+
+func (r countingSessionRepo) IsTokenRevoked(ctx context.Context, jti string, sessionID SessionID) (bool, error) {
+	r.calls.Add(1)
+	if r.latency > 0 {
+		time.Sleep(r.latency)
+	}
+	return r.fakeSessionRepo.IsTokenRevoked(ctx, jti, sessionID)
+}
+
+func newCachedTestService(repo UserRepo, identities *IdentityCache) Service {
+	cfg := &Config{TokenExpireDuration: time.Hour}
+	return NewService(cfg, testKeys, testPolicy, repo, newFakeSessionRepo(), newFakeThrottleRepo(), fakeUnitOfWork{}, identities, nil)
+}
+
+func TestIdentityCache_GetUserFromToken(t *testing.T) {
+	user := &User{ID: 20, Username: "cached", Password: "hash", CoinBalance: 500, Roles: []Role{RoleEmployee, RoleAuditor}}
+	var calls atomic.Int64
+	identities := NewIdentityCache(10, time.Minute)
+	svc := newCachedTestService(countingUserRepo(user, 0, &calls), identities)
+	token, err := NewToken(testKeys, time.Hour, user, "")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		u, err := svc.GetUserFromToken(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, user.Username, u.Username)
+		assert.Equal(t, user.Roles, u.Roles)
+		assert.Empty(t, u.Password)
+		assert.Zero(t, u.CoinBalance, "баланс не должен браться из кэша")
+	}
+	assert.Equal(t, int64(1), calls.Load())
+
+	// изменение пользователя сбрасывает запись.
+	identities.Invalidate(user.ID)
+	_, err = svc.GetUserFromToken(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), calls.Load())
+}
+
+func TestIdentityCache_RolesNotShared(t *testing.T) {
+	identities := NewIdentityCache(10, time.Minute)
+	identities.Set(&User{ID: 21, Roles: []Role{RoleEmployee, RoleHRAdmin}})
+
+	u, ok := identities.Get(21)
+	require.True(t, ok)
+	u.Roles[1] = RoleShopAdmin
+
+	u, _ = identities.Get(21)
+	assert.Equal(t, []Role{RoleEmployee, RoleHRAdmin}, u.Roles)
+}
+
+func TestIdentityCache_Nil(t *testing.T) {
+	var identities *IdentityCache
+	identities.Set(&User{ID: 22})
+	identities.Invalidate(22)
+	_, ok := identities.Get(22)
+	assert.False(t, ok)
+}
+
+func TestRevocationCache_GetUserFromToken(t *testing.T) {
+	user := &User{ID: 24, Username: "revoked", Roles: []Role{RoleEmployee}}
+	var userCalls, revokedCalls atomic.Int64
+	sessions := countingSessionRepo{fakeSessionRepo: newFakeSessionRepo(), calls: &revokedCalls}
+	sessions.sessions["s1"] = &Session{ID: "s1", UserID: user.ID}
+	cfg := &Config{TokenExpireDuration: time.Hour}
+	svc := NewService(cfg, testKeys, testPolicy, countingUserRepo(user, 0, &userCalls), sessions,
+		newFakeThrottleRepo(), fakeUnitOfWork{}, nil, NewRevocationCache(10, time.Minute))
+	token, err := NewToken(testKeys, time.Hour, user, "s1")
+	require.NoError(t, err)
+	other, err := NewToken(testKeys, time.Hour, user, "s1")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := svc.GetUserFromToken(context.Background(), token)
+		require.NoError(t, err)
+	}
+	_, err = svc.GetUserFromToken(context.Background(), other)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), revokedCalls.Load())
+
+	// Logout сразу отзывает закэшированные токены сессии без обращения к БД.
+	require.NoError(t, svc.Logout(context.Background(), token))
+	_, err = svc.GetUserFromToken(context.Background(), token)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = svc.GetUserFromToken(context.Background(), other)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Equal(t, int64(2), revokedCalls.Load())
+}
+
+func TestRevocationCache_Nil(t *testing.T) {
+	var revocations *RevocationCache
+	revocations.Set("jti", false)
+	revocations.RevokeToken("jti")
+	revocations.RevokeSession("s1")
+	_, ok := revocations.Get("jti", "s1")
+	assert.False(t, ok)
+
+	// токены без jti не кэшируются, иначе они делили бы одну запись.
+	revocations = NewRevocationCache(10, time.Minute)
+	revocations.Set("", false)
+	_, ok = revocations.Get("", "")
+	assert.False(t, ok)
+}
+
+// BenchmarkGetUserFromToken сравнивает проверку токена с запросами в БД (пользователь и отзыв
+// токена, задержка 200µs — типичный round-trip до PostgreSQL в той же сети) и с кэшами.
+func BenchmarkGetUserFromToken(b *testing.B) {
+	user := &User{ID: 23, Username: "bench", Roles: []Role{RoleEmployee}}
+	token, err := NewToken(testKeys, time.Hour, user, "")
+	require.NoError(b, err)
+
+	cases := []struct {
+		name        string
+		identities  *IdentityCache
+		revocations *RevocationCache
+	}{
+		{"uncached", nil, nil},
+		{"cached", NewIdentityCache(1000, time.Minute), NewRevocationCache(1000, time.Minute)},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			var calls atomic.Int64
+			sessions := countingSessionRepo{fakeSessionRepo: newFakeSessionRepo(), latency: 200 * time.Microsecond, calls: &calls}
+			cfg := &Config{TokenExpireDuration: time.Hour}
+			svc := NewService(cfg, testKeys, testPolicy, countingUserRepo(user, 200*time.Microsecond, &calls), sessions,
+				newFakeThrottleRepo(), fakeUnitOfWork{}, tc.identities, tc.revocations)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := svc.GetUserFromToken(context.Background(), token); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(calls.Load())/float64(b.N), "db-calls/op")
+		})
+	}
+}
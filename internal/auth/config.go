@@ -3,6 +3,54 @@ package auth
 import "time"
 
 type Config struct {
-	JWTSecret           string        `env:"JWT_SECRET" envDefault:"superSecret"`
-	TokenExpireDuration time.Duration `env:"TOKEN_EXPIRE_DURATION" envDefault:"24h"`
+	// JWTKeysDir — каталог с PEM ключами подписи (RSA или Ed25519), имя файла — kid.
+	JWTKeysDir string `env:"JWT_KEYS_DIR"`
+	// JWTKeyActivationDelay — через сколько после появления файла ключ начинает подписывать токены.
+	JWTKeyActivationDelay time.Duration `env:"JWT_KEY_ACTIVATION_DELAY" env-default:"1h"`
+	// JWTKeysReloadInterval — период перечитывания каталога ключей.
+	JWTKeysReloadInterval time.Duration `env:"JWT_KEYS_RELOAD_INTERVAL" env-default:"5m"`
+	// TokenExpireDuration — время жизни access токена. Токен короткий: сессия продлевается refresh токеном.
+	TokenExpireDuration time.Duration `env:"TOKEN_EXPIRE_DURATION" env-default:"15m"`
+	// RefreshTokenExpireDuration — время жизни refresh токена.
+	RefreshTokenExpireDuration time.Duration `env:"REFRESH_TOKEN_EXPIRE_DURATION" env-default:"720h"`
+	// RevokedTokensCleanupInterval — период удаления отозванных access токенов, срок которых истёк.
+	RevokedTokensCleanupInterval time.Duration `env:"REVOKED_TOKENS_CLEANUP_INTERVAL" env-default:"1h"`
+	// BootstrapAdminUsername и BootstrapAdminPassword задают администратора, который создаётся
+	// при запуске со всеми ролями. Существующий пользователь получает роли, только если его пароль
+	// совпадает с BootstrapAdminPassword.
+	BootstrapAdminUsername string `env:"BOOTSTRAP_ADMIN_USERNAME"`
+	BootstrapAdminPassword string `env:"BOOTSTRAP_ADMIN_PASSWORD"`
+
+	// RegistrationMode — open, allow-list или closed.
+	RegistrationMode string `env:"REGISTRATION_MODE" env-default:"open"`
+	// RegistrationAllowListFile — файл со списком имён для режима allow-list; если не задан, список читается из БД.
+	RegistrationAllowListFile string `env:"REGISTRATION_ALLOW_LIST_FILE"`
+	// AutoRegistration — создавать неизвестных пользователей при входе через /api/auth.
+	// Если выключено, регистрация доступна только через /api/register.
+	AutoRegistration bool   `env:"AUTO_REGISTRATION" env-default:"true"`
+	UsernamePattern  string `env:"USERNAME_PATTERN" env-default:"^[a-zA-Z0-9._-]{3,64}$"`
+	// StartingCoins — начисление новому пользователю при регистрации.
+	StartingCoins int `env:"STARTING_COINS" env-default:"1000"`
+
+	// IdentityCacheSize и IdentityCacheTTL — кэш пользователей для Verify, 0 — без кэша.
+	// TTL — задержка, с которой снятые роли перестают действовать на других репликах.
+	IdentityCacheSize int           `env:"IDENTITY_CACHE_SIZE" env-default:"10000"`
+	IdentityCacheTTL  time.Duration `env:"IDENTITY_CACHE_TTL" env-default:"30s"`
+	// RevocationCacheSize и RevocationCacheTTL — кэш проверки отзыва токенов, 0 — без кэша.
+	// TTL — задержка отзыва между репликами: после выхода на одной реплике остальные ещё
+	// столько принимают токен и сессию. Увеличивать его стоит, только если такая задержка допустима.
+	RevocationCacheSize int           `env:"REVOCATION_CACHE_SIZE" env-default:"10000"`
+	RevocationCacheTTL  time.Duration `env:"REVOCATION_CACHE_TTL" env-default:"5s"`
+
+	// LoginMaxFailures и LoginMaxFailuresPerIP — сколько неудачных входов допускается
+	// для имени пользователя и для IP адреса за LoginFailureWindow до блокировки.
+	LoginMaxFailures      int           `env:"LOGIN_MAX_FAILURES" env-default:"5"`
+	LoginMaxFailuresPerIP int           `env:"LOGIN_MAX_FAILURES_PER_IP" env-default:"50"`
+	LoginFailureWindow    time.Duration `env:"LOGIN_FAILURE_WINDOW" env-default:"24h"`
+	// LoginLockoutBase — первая блокировка, каждая следующая неудача удваивает её до LoginLockoutMax.
+	LoginLockoutBase time.Duration `env:"LOGIN_LOCKOUT_BASE" env-default:"1m"`
+	LoginLockoutMax  time.Duration `env:"LOGIN_LOCKOUT_MAX" env-default:"1h"`
+	// RegistrationsPerIP — сколько пользователей можно создать с одного IP за RegistrationWindow, 0 — без ограничения.
+	RegistrationsPerIP int           `env:"REGISTRATIONS_PER_IP" env-default:"10"`
+	RegistrationWindow time.Duration `env:"REGISTRATION_WINDOW" env-default:"1h"`
 }
@@ -3,6 +3,7 @@ package auth
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
@@ -10,8 +11,52 @@ var (
 	ErrInvalidToken = fmt.Errorf("%v: недопустимый токен", Err)
 	ErrUserNotFound = fmt.Errorf("%v: пользователь не найден", Err)
 	ErrUnauthorized = fmt.Errorf("%v: пользователь не авторизован", Err)
+	ErrTokenRevoked = fmt.Errorf("%v: токен отозван", Err)
+	ErrTokenReused  = fmt.Errorf("%v: refresh токен использован повторно, сессия отозвана", Err)
+	ErrForbidden    = fmt.Errorf("%v: недостаточно прав", Err)
+	ErrUserExists   = fmt.Errorf("%v: пользователь уже существует", Err)
+
+	ErrRegistrationClosed     = fmt.Errorf("%v: регистрация закрыта", Err)
+	ErrRegistrationNotAllowed = fmt.Errorf("%v: пользователя нет в списке разрешённых", Err)
 )
 
+func NewErrInvalidUsername(username string) error {
+	return ErrInvalidUsername{username: username}
+}
+
+type ErrInvalidUsername struct {
+	username string
+}
+
+func (e ErrInvalidUsername) Error() string {
+	return fmt.Sprintf("%v: недопустимое имя пользователя %q", Err, e.username)
+}
+
+func NewErrTooManyAttempts(retryAfter time.Duration) error {
+	return ErrTooManyAttempts{RetryAfter: retryAfter}
+}
+
+// ErrTooManyAttempts — вход или регистрация временно заблокированы.
+type ErrTooManyAttempts struct {
+	RetryAfter time.Duration
+}
+
+func (e ErrTooManyAttempts) Error() string {
+	return fmt.Sprintf("%v: слишком много попыток, повторите через %s", Err, e.RetryAfter)
+}
+
+func NewErrInvalidRole(role string) error {
+	return ErrInvalidRole{role: role}
+}
+
+type ErrInvalidRole struct {
+	role string
+}
+
+func (e ErrInvalidRole) Error() string {
+	return fmt.Sprintf("%v: недопустимая роль %q", Err, e.role)
+}
+
 func NewErrInvalidUserID(userID int64) error {
 	return ErrInvalidUserID{userID: userID}
 }
@@ -60,3 +105,17 @@ type ErrInternal struct {
 func (e ErrInternal) Error() string {
 	return fmt.Sprintf("%v: internal err: %v", Err, e.err)
 }
+
+func NewErrUnsupportedKey(keyID string, err error) error {
+	return ErrUnsupportedKey{keyID: keyID, err: err}
+}
+
+// ErrUnsupportedKey — ключ подписи не удалось загрузить.
+type ErrUnsupportedKey struct {
+	keyID string
+	err   error
+}
+
+func (e ErrUnsupportedKey) Error() string {
+	return fmt.Sprintf("%v: не удалось загрузить ключ %q: %v", Err, e.keyID, e.err)
+}
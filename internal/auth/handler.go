@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"log"
+	"math"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -21,10 +23,29 @@ func GetUser(ctx context.Context) (*User, bool) {
 	return nil, false
 }
 
+type clientIPKey struct{}
+
+// SetClientIP сохраняет адрес клиента для ограничения попыток входа.
+func SetClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func GetClientIP(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(string)
+	return ip, ok
+}
+
 type Service interface {
-	AuthUser(ctx context.Context, username, password string) (Token, error)
+	AuthUser(ctx context.Context, username, password string) (*TokenPair, error)
+	Register(ctx context.Context, username, password string) (*TokenPair, error)
+	Refresh(ctx context.Context, refreshToken RefreshToken) (*TokenPair, error)
+	Logout(ctx context.Context, rawToken Token) error
 	GetUserFromToken(ctx context.Context, rawToken Token) (*User, error)
 	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	SetRoles(ctx context.Context, username string, roles []Role) (*User, error)
+	BootstrapAdmin(ctx context.Context) error
+	// RunCleanup периодически удаляет истёкшие отозванные токены, пока не отменён ctx.
+	RunCleanup(ctx context.Context)
 }
 
 type Handlers struct {
@@ -39,6 +60,10 @@ func NewAuthHandlers(svc Service) *Handlers {
 
 func (h *Handlers) Init(router fiber.Router) {
 	router.Post("/auth", h.auth)
+	router.Post("/register", h.register)
+	router.Post("/auth/refresh", h.refresh)
+	router.Post("/auth/logout", h.Verify, h.logout)
+	router.Put("/admin/users/:username/roles", h.Verify, h.RequireRole(RoleHRAdmin), h.setRoles)
 }
 
 // TokenRequest represents the expected request body.
@@ -49,7 +74,24 @@ type TokenRequest struct {
 
 // TokenResponse is the response containing the JWT token.
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// RolesRequest represents the request body for role assignment.
+type RolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// RolesResponse lists the roles of the user.
+type RolesResponse struct {
+	Username string `json:"username"`
+	Roles    []Role `json:"roles"`
+}
+
+// RefreshRequest represents the refresh request body.
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 // auth Аутентификация и получение JWT-токена.
@@ -68,8 +110,24 @@ func (h *Handlers) auth(c *fiber.Ctx) error {
 		})
 	}
 
-	token, err := h.svc.AuthUser(c.Context(), req.Username, req.Password)
+	ctx := SetClientIP(c.Context(), c.IP())
+	pair, err := h.svc.AuthUser(ctx, req.Username, req.Password)
 	if err != nil {
+		var tooManyErr ErrTooManyAttempts
+		if errors.As(err, &tooManyErr) {
+			return tooManyAttempts(c, tooManyErr)
+		}
+		var invalidNameErr ErrInvalidUsername
+		if errors.As(err, &invalidNameErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		}
+		if errors.Is(err, ErrRegistrationClosed) || errors.Is(err, ErrRegistrationNotAllowed) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		}
 		if errors.Is(err, ErrUnauthorized) {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"errors": "Не авторизован",
@@ -92,7 +150,165 @@ func (h *Handlers) auth(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(TokenResponse{Token: string(token)})
+	return c.Status(fiber.StatusOK).JSON(TokenResponse{
+		Token:        string(pair.AccessToken),
+		RefreshToken: string(pair.RefreshToken),
+	})
+}
+
+// register Явная регистрация пользователя по политике регистрации.
+func (h *Handlers) register(c *fiber.Ctx) error {
+	var req TokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "Неверный запрос: " + err.Error(),
+		})
+	}
+
+	if req.Username == "" || req.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "Поля username и password обязательны",
+		})
+	}
+
+	ctx := SetClientIP(c.Context(), c.IP())
+	pair, err := h.svc.Register(ctx, req.Username, req.Password)
+	if err != nil {
+		var (
+			tooManyErr     ErrTooManyAttempts
+			invalidNameErr ErrInvalidUsername
+		)
+		switch {
+		case errors.As(err, &tooManyErr):
+			return tooManyAttempts(c, tooManyErr)
+		case errors.As(err, &invalidNameErr):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		case errors.Is(err, ErrUserExists):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"errors": "Пользователь уже существует",
+			})
+		case errors.Is(err, ErrRegistrationClosed), errors.Is(err, ErrRegistrationNotAllowed):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		}
+		log.Printf("register error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": "Внутренняя ошибка сервера",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(TokenResponse{
+		Token:        string(pair.AccessToken),
+		RefreshToken: string(pair.RefreshToken),
+	})
+}
+
+// tooManyAttempts отвечает 429 с Retry-After в целых секундах.
+func tooManyAttempts(c *fiber.Ctx, err ErrTooManyAttempts) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"errors": "Слишком много попыток, повторите позже",
+	})
+}
+
+// refresh Обмен refresh токена на новую пару токенов.
+func (h *Handlers) refresh(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "Неверный запрос: " + err.Error(),
+		})
+	}
+	if req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "Поле refreshToken обязательно",
+		})
+	}
+
+	pair, err := h.svc.Refresh(c.Context(), RefreshToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrTokenReused) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"errors": "Не авторизован",
+			})
+		}
+		log.Printf("refresh error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": "Внутренняя ошибка сервера",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(TokenResponse{
+		Token:        string(pair.AccessToken),
+		RefreshToken: string(pair.RefreshToken),
+	})
+}
+
+// logout Отзыв текущего токена и его сессии.
+func (h *Handlers) logout(c *fiber.Ctx) error {
+	token, _ := bearerToken(c)
+	if err := h.svc.Logout(c.Context(), token); err != nil {
+		if errors.Is(err, ErrUnauthorized) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"errors": "Не авторизован",
+			})
+		}
+		log.Printf("logout error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": "Внутренняя ошибка сервера",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// setRoles Назначение ролей пользователю.
+func (h *Handlers) setRoles(c *fiber.Ctx) error {
+	var req RolesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "Неверный запрос: " + err.Error(),
+		})
+	}
+	roles := make([]Role, 0, len(req.Roles))
+	for _, raw := range req.Roles {
+		role, err := NewRole(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		}
+		roles = append(roles, role)
+	}
+
+	user, err := h.svc.SetRoles(c.Context(), c.Params("username"), roles)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"errors": "Пользователь не найден",
+			})
+		}
+		log.Printf("set roles error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": "Внутренняя ошибка сервера",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(RolesResponse{
+		Username: user.Username,
+		Roles:    user.Roles,
+	})
+}
+
+// bearerToken извлекает токен из заголовка Authorization.
+func bearerToken(c *fiber.Ctx) (Token, bool) {
+	const prefix = "Bearer "
+	authHeader := c.Get("Authorization")
+	if len(authHeader) < len(prefix) || authHeader[:len(prefix)] != prefix {
+		return "", false
+	}
+	return Token(authHeader[len(prefix):]), true
 }
 
 // verify middleware.
@@ -105,17 +321,15 @@ func (h *Handlers) Verify(c *fiber.Ctx) error {
 		})
 	}
 
-	const prefix = "Bearer "
-	if len(authHeader) < len(prefix) || authHeader[:len(prefix)] != prefix {
+	token, ok := bearerToken(c)
+	if !ok {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"errors": "Неверный формат токена",
 		})
 	}
 
-	token := authHeader[len(prefix):]
-
 	// Проверка токена через метод сервиса.
-	user, err := h.svc.GetUserFromToken(c.Context(), Token(token))
+	user, err := h.svc.GetUserFromToken(c.Context(), token)
 	if err != nil || user == nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"errors": "Пользователь не прошел проверку",
@@ -125,3 +339,42 @@ func (h *Handlers) Verify(c *fiber.Ctx) error {
 	c.SetUserContext(ctx)
 	return c.Next()
 }
+
+// RequireRole middleware пропускает запрос, только если у пользователя есть хотя бы одна из ролей.
+// Должен стоять после Verify.
+func (h *Handlers) RequireRole(roles ...Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := GetUser(c.UserContext())
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"errors": "Не авторизован",
+			})
+		}
+		if !user.HasRole(roles...) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"errors": ErrForbidden.Error(),
+			})
+		}
+		return c.Next()
+	}
+}
+
+// JWKSHandlers публикует публичные ключи для офлайн-проверки токенов другими сервисами.
+type JWKSHandlers struct {
+	keys *KeySet
+}
+
+func NewJWKSHandlers(keys *KeySet) *JWKSHandlers {
+	return &JWKSHandlers{
+		keys: keys,
+	}
+}
+
+func (h *JWKSHandlers) Init(router fiber.Router) {
+	router.Get("/.well-known/jwks.json", h.jwks)
+}
+
+func (h *JWKSHandlers) jwks(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.keys.JWKS())
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
@@ -17,14 +18,30 @@ import (
 // fakeService mocks the methods used by the handler.
 type fakeService struct {
 	// authUserFunc simulates behavior of authUser.
-	authUserFunc         func(ctx context.Context, username, password string) (Token, error)
+	authUserFunc         func(ctx context.Context, username, password string) (*TokenPair, error)
+	registerFunc         func(ctx context.Context, username, password string) (*TokenPair, error)
+	refreshFunc          func(ctx context.Context, refreshToken RefreshToken) (*TokenPair, error)
+	logoutFunc           func(ctx context.Context, token Token) error
 	getUserFromTokenFunc func(ctx context.Context, token Token) (*User, error)
+	setRolesFunc         func(ctx context.Context, username string, roles []Role) (*User, error)
 }
 
-func (f *fakeService) AuthUser(ctx context.Context, username, password string) (Token, error) {
+func (f *fakeService) AuthUser(ctx context.Context, username, password string) (*TokenPair, error) {
 	return f.authUserFunc(ctx, username, password)
 }
 
+func (f *fakeService) Register(ctx context.Context, username, password string) (*TokenPair, error) {
+	return f.registerFunc(ctx, username, password)
+}
+
+func (f *fakeService) Refresh(ctx context.Context, refreshToken RefreshToken) (*TokenPair, error) {
+	return f.refreshFunc(ctx, refreshToken)
+}
+
+func (f *fakeService) Logout(ctx context.Context, token Token) error {
+	return f.logoutFunc(ctx, token)
+}
+
 func (f *fakeService) GetUserFromToken(ctx context.Context, token Token) (*User, error) {
 	if f.getUserFromTokenFunc != nil {
 		return f.getUserFromTokenFunc(ctx, token)
@@ -37,12 +54,25 @@ func (f *fakeService) GetUserByUsername(_ context.Context, _ string) (*User, err
 	return nil, nil
 }
 
+func (f *fakeService) SetRoles(ctx context.Context, username string, roles []Role) (*User, error) {
+	return f.setRolesFunc(ctx, username, roles)
+}
+
+func (f *fakeService) BootstrapAdmin(_ context.Context) error {
+	return nil
+}
+
+func (f *fakeService) RunCleanup(_ context.Context) {}
+
 // setupTestHandler initializes a Fiber app with our auth handler.
 func setupTestHandler(svc *fakeService) *fiber.App {
 	app := fiber.New()
 	handlers := NewAuthHandlers(svc)
-	// register the auth endpoint
+	// register the auth endpoints
 	app.Post("/auth", handlers.auth)
+	app.Post("/register", handlers.register)
+	app.Post("/auth/refresh", handlers.refresh)
+	app.Post("/auth/logout", handlers.Verify, handlers.logout)
 	return app
 }
 
@@ -58,8 +88,8 @@ func setupVerifyTestHandler(svc Service) *fiber.App {
 
 func TestAuth_Success(t *testing.T) {
 	fakeSvc := &fakeService{
-		authUserFunc: func(_ context.Context, _, _ string) (Token, error) {
-			return "valid-token", nil
+		authUserFunc: func(_ context.Context, _, _ string) (*TokenPair, error) {
+			return &TokenPair{AccessToken: "valid-token", RefreshToken: "refresh-token"}, nil
 		},
 	}
 	app := setupTestHandler(fakeSvc)
@@ -82,12 +112,13 @@ func TestAuth_Success(t *testing.T) {
 	err = json.NewDecoder(resp.Body).Decode(&res)
 	assert.NoError(t, err)
 	assert.Equal(t, "valid-token", res.Token)
+	assert.Equal(t, "refresh-token", res.RefreshToken)
 }
 
 func TestAuth_Unauthorized(t *testing.T) {
 	fakeSvc := &fakeService{
-		authUserFunc: func(_ context.Context, _, _ string) (Token, error) {
-			return "", ErrUnauthorized
+		authUserFunc: func(_ context.Context, _, _ string) (*TokenPair, error) {
+			return nil, ErrUnauthorized
 		},
 	}
 	app := setupTestHandler(fakeSvc)
@@ -108,8 +139,8 @@ func TestAuth_Unauthorized(t *testing.T) {
 
 func TestAuth_UserNotFound(t *testing.T) {
 	fakeSvc := &fakeService{
-		authUserFunc: func(_ context.Context, _, _ string) (Token, error) {
-			return "", ErrUserNotFound
+		authUserFunc: func(_ context.Context, _, _ string) (*TokenPair, error) {
+			return nil, ErrUserNotFound
 		},
 	}
 	app := setupTestHandler(fakeSvc)
@@ -130,8 +161,8 @@ func TestAuth_UserNotFound(t *testing.T) {
 
 func TestAuth_InternalError(t *testing.T) {
 	fakeSvc := &fakeService{
-		authUserFunc: func(_ context.Context, _, _ string) (Token, error) {
-			return "", NewErrInternal(errors.New("database error"))
+		authUserFunc: func(_ context.Context, _, _ string) (*TokenPair, error) {
+			return nil, NewErrInternal(errors.New("database error"))
 		},
 	}
 	app := setupTestHandler(fakeSvc)
@@ -150,10 +181,70 @@ func TestAuth_InternalError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 }
 
+func TestAuth_TooManyAttempts(t *testing.T) {
+	fakeSvc := &fakeService{
+		authUserFunc: func(ctx context.Context, _, _ string) (*TokenPair, error) {
+			ip, ok := GetClientIP(ctx)
+			assert.True(t, ok)
+			assert.NotEmpty(t, ip)
+			return nil, NewErrTooManyAttempts(90*time.Second + time.Millisecond)
+		},
+	}
+	app := setupTestHandler(fakeSvc)
+
+	bodyBytes, _ := json.Marshal(TokenRequest{Username: "user", Password: "pass"})
+	req := httptest.NewRequest("POST", "/auth", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "91", resp.Header.Get("Retry-After"))
+}
+
+func TestRegister_StatusCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"created", nil, http.StatusCreated},
+		{"exists", ErrUserExists, http.StatusConflict},
+		{"not allowed", ErrRegistrationNotAllowed, http.StatusForbidden},
+		{"closed", ErrRegistrationClosed, http.StatusForbidden},
+		{"invalid username", NewErrInvalidUsername("x"), http.StatusBadRequest},
+		{"too many", NewErrTooManyAttempts(time.Minute), http.StatusTooManyRequests},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeSvc := &fakeService{
+				registerFunc: func(_ context.Context, _, _ string) (*TokenPair, error) {
+					if tc.err != nil {
+						return nil, tc.err
+					}
+					return &TokenPair{AccessToken: "access", RefreshToken: "refresh"}, nil
+				},
+			}
+			app := setupTestHandler(fakeSvc)
+
+			bodyBytes, _ := json.Marshal(TokenRequest{Username: "user", Password: "pass"})
+			req := httptest.NewRequest("POST", "/register", bytes.NewReader(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req, -1)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tc.expected, resp.StatusCode)
+		})
+	}
+}
+
 func TestAuth_BadRequest(t *testing.T) {
 	fakeSvc := &fakeService{
-		authUserFunc: func(_ context.Context, _, _ string) (Token, error) {
-			return "", nil
+		authUserFunc: func(_ context.Context, _, _ string) (*TokenPair, error) {
+			return nil, nil
 		},
 	}
 	app := setupTestHandler(fakeSvc)
@@ -257,3 +348,168 @@ func TestVerify_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "next called", bodyBytes.String())
 }
+
+func TestRefresh_Success(t *testing.T) {
+	fakeSvc := &fakeService{
+		refreshFunc: func(_ context.Context, refreshToken RefreshToken) (*TokenPair, error) {
+			assert.Equal(t, RefreshToken("old-refresh"), refreshToken)
+			return &TokenPair{AccessToken: "new-access", RefreshToken: "new-refresh"}, nil
+		},
+	}
+	app := setupTestHandler(fakeSvc)
+
+	bodyBytes, _ := json.Marshal(RefreshRequest{RefreshToken: "old-refresh"})
+	req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res TokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-access", res.Token)
+	assert.Equal(t, "new-refresh", res.RefreshToken)
+}
+
+func TestRefresh_Reused(t *testing.T) {
+	fakeSvc := &fakeService{
+		refreshFunc: func(_ context.Context, _ RefreshToken) (*TokenPair, error) {
+			return nil, ErrTokenReused
+		},
+	}
+	app := setupTestHandler(fakeSvc)
+
+	bodyBytes, _ := json.Marshal(RefreshRequest{RefreshToken: "stolen"})
+	req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestRefresh_MissingToken(t *testing.T) {
+	app := setupTestHandler(&fakeService{})
+
+	req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestLogout_Success(t *testing.T) {
+	var loggedOut Token
+	fakeSvc := &fakeService{
+		getUserFromTokenFunc: func(_ context.Context, _ Token) (*User, error) {
+			return &User{ID: 1, Username: "validUser"}, nil
+		},
+		logoutFunc: func(_ context.Context, token Token) error {
+			loggedOut = token
+			return nil
+		},
+	}
+	app := setupTestHandler(fakeSvc)
+
+	req := httptest.NewRequest("POST", "/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, Token("valid-token"), loggedOut)
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		roles    []Role
+		expected int
+	}{
+		{"employee", []Role{RoleEmployee}, http.StatusForbidden},
+		{"hr-admin", []Role{RoleEmployee, RoleHRAdmin}, http.StatusOK},
+		{"auditor", []Role{RoleAuditor}, http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &fakeService{
+				getUserFromTokenFunc: func(_ context.Context, _ Token) (*User, error) {
+					return &User{ID: 1, Username: "user", Roles: tc.roles}, nil
+				},
+			}
+			app := fiber.New()
+			handlers := NewAuthHandlers(svc)
+			app.Get("/admin", handlers.Verify, handlers.RequireRole(RoleHRAdmin, RoleAuditor), func(c *fiber.Ctx) error {
+				return c.SendString("next called")
+			})
+
+			req := httptest.NewRequest("GET", "/admin", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			resp, err := app.Test(req, -1)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tc.expected, resp.StatusCode)
+		})
+	}
+}
+
+func TestSetRoles(t *testing.T) {
+	svc := &fakeService{
+		getUserFromTokenFunc: func(_ context.Context, _ Token) (*User, error) {
+			return &User{ID: 1, Username: "admin", Roles: []Role{RoleEmployee, RoleHRAdmin}}, nil
+		},
+		setRolesFunc: func(_ context.Context, username string, roles []Role) (*User, error) {
+			assert.Equal(t, "manager", username)
+			return &User{Username: username, Roles: append([]Role{RoleEmployee}, roles...)}, nil
+		},
+	}
+	app := fiber.New()
+	NewAuthHandlers(svc).Init(app)
+
+	t.Run("success", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/admin/users/manager/roles", bytes.NewReader([]byte(`{"roles":["shop-admin"]}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer valid-token")
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var res RolesResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Equal(t, []Role{RoleEmployee, RoleShopAdmin}, res.Roles)
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/admin/users/manager/roles", bytes.NewReader([]byte(`{"roles":["root"]}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer valid-token")
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	app := fiber.New()
+	NewJWKSHandlers(testKeys).Init(app)
+
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body JWKS
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	assert.NoError(t, err)
+	assert.Equal(t, testKeys.JWKS(), body)
+}
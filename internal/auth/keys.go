@@ -0,0 +1,310 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// notBeforeHeader — необязательный заголовок PEM блока с временем активации ключа в RFC 3339.
+const notBeforeHeader = "Not-Before"
+
+// SigningKey — ключ подписи токенов. Публичная часть публикуется в JWKS сразу после загрузки,
+// а подписывать токены ключ начинает с ActiveFrom.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	Private    crypto.Signer
+	ActiveFrom time.Time
+}
+
+// NewSigningKey создает ключ подписи. Алгоритм определяется типом ключа: RS256 для RSA, EdDSA для Ed25519.
+func NewSigningKey(id string, private crypto.Signer, activeFrom time.Time) (*SigningKey, error) {
+	var method jwt.SigningMethod
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, NewErrUnsupportedKey(id, fmt.Errorf("unsupported key type %T", private))
+	}
+	return &SigningKey{
+		ID:         id,
+		Method:     method,
+		Private:    private,
+		ActiveFrom: activeFrom,
+	}, nil
+}
+
+// GenerateSigningKey создает случайный Ed25519 ключ с идентификатором по отпечатку публичного ключа.
+func GenerateSigningKey() (*SigningKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(public)
+	return NewSigningKey(hex.EncodeToString(sum[:8]), private, time.Time{})
+}
+
+// KeySet — набор ключей подписи. Токены подписываются самым новым активным ключом,
+// а проверяются любым ключом набора по заголовку kid.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []*SigningKey
+
+	dir             string
+	activationDelay time.Duration
+	now             func() time.Time
+}
+
+// NewKeySet создает набор из заданных ключей.
+func NewKeySet(keys ...*SigningKey) *KeySet {
+	ks := &KeySet{now: time.Now}
+	ks.set(keys)
+	return ks
+}
+
+// NewKeySetFromConfig загружает ключи из cfg.JWTKeysDir.
+// Если каталог не задан, генерируется временный ключ: такие токены
+// не переживут перезапуск и не проверяются другими репликами.
+func NewKeySetFromConfig(cfg *Config) (*KeySet, error) {
+	if cfg.JWTKeysDir == "" {
+		slog.Warn("JWT_KEYS_DIR is not set, using an ephemeral signing key")
+		key, err := GenerateSigningKey()
+		if err != nil {
+			return nil, err
+		}
+		return NewKeySet(key), nil
+	}
+	ks := &KeySet{
+		dir:             cfg.JWTKeysDir,
+		activationDelay: cfg.JWTKeyActivationDelay,
+		now:             time.Now,
+	}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload перечитывает PEM файлы каталога ключей. Идентификатор ключа — имя файла без расширения.
+// Время активации берется из заголовка Not-Before, иначе — время изменения файла плюс задержка активации,
+// чтобы новый ключ успел попасть в JWKS проверяющих сервисов до того, как им начнут подписывать.
+func (ks *KeySet) Reload() error {
+	paths, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := ks.loadKey(path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return NewErrUnsupportedKey(ks.dir, fmt.Errorf("no *.pem keys found"))
+	}
+	ks.set(keys)
+	return nil
+}
+
+// RunReload периодически перечитывает каталог ключей, пока не отменён ctx.
+func (ks *KeySet) RunReload(ctx context.Context, interval time.Duration) {
+	if ks.dir == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Reload(); err != nil {
+				slog.Error("failed to reload jwt keys", "error", err)
+			}
+		}
+	}
+}
+
+func (ks *KeySet) loadKey(path string) (*SigningKey, error) {
+	id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, NewErrUnsupportedKey(id, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, NewErrUnsupportedKey(id, fmt.Errorf("no PEM block"))
+	}
+
+	var private any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, NewErrUnsupportedKey(id, err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, NewErrUnsupportedKey(id, fmt.Errorf("unsupported key type %T", private))
+	}
+
+	var activeFrom time.Time
+	if notBefore, ok := block.Headers[notBeforeHeader]; ok {
+		activeFrom, err = time.Parse(time.RFC3339, notBefore)
+		if err != nil {
+			return nil, NewErrUnsupportedKey(id, err)
+		}
+	} else {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, NewErrUnsupportedKey(id, err)
+		}
+		activeFrom = info.ModTime().Add(ks.activationDelay)
+	}
+	return NewSigningKey(id, signer, activeFrom)
+}
+
+func (ks *KeySet) set(keys []*SigningKey) {
+	sorted := append([]*SigningKey(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActiveFrom.Before(sorted[j].ActiveFrom)
+	})
+	ks.mu.Lock()
+	ks.keys = sorted
+	ks.mu.Unlock()
+}
+
+// signingKey возвращает самый новый из уже активных ключей.
+// Если активных ключей нет, используется самый ранний, чтобы сервис мог выпускать токены.
+func (ks *KeySet) signingKey() (*SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if len(ks.keys) == 0 {
+		return nil, fmt.Errorf("empty key set")
+	}
+	now := ks.now()
+	current := ks.keys[0]
+	for _, key := range ks.keys[1:] {
+		if key.ActiveFrom.After(now) {
+			break
+		}
+		current = key
+	}
+	return current, nil
+}
+
+func (ks *KeySet) key(id string) (*SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, key := range ks.keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Sign подписывает claims текущим ключом и указывает его идентификатор в заголовке kid.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, err := ks.signingKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Keyfunc возвращает публичный ключ для проверки токена по его kid.
+// Алгоритм токена должен совпадать с алгоритмом ключа.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.key(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %q for key %q", token.Method.Alg(), kid)
+	}
+	return key.Private.Public(), nil
+}
+
+// ValidMethods — алгоритмы подписи, которые принимает парсер токенов.
+func (ks *KeySet) ValidMethods() []string {
+	return []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+}
+
+// JWK — публичный ключ в формате RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519.
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS — набор публичных ключей для проверки токенов.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает публичные части всех ключей набора, включая ещё не активные.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		jwk := JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.Method.Alg(),
+		}
+		switch public := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
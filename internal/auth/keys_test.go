@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRSAKey(t *testing.T, dir, kid string, headers map[string]string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Headers: headers, Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600))
+	return key
+}
+
+func writeEd25519Key(t *testing.T, dir, kid string, headers map[string]string) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	block := &pem.Block{Type: "PRIVATE KEY", Headers: headers, Bytes: der}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600))
+	return key
+}
+
+func tokenKeyID(t *testing.T, token Token) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(string(token), &Claims{})
+	require.NoError(t, err)
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestKeySetFromConfig_LoadsPEMKeys(t *testing.T) {
+	dir := t.TempDir()
+	past := map[string]string{notBeforeHeader: time.Now().Add(-time.Hour).Format(time.RFC3339)}
+	writeRSAKey(t, dir, "rsa-1", past)
+	writeEd25519Key(t, dir, "ed-1", past)
+
+	keys, err := NewKeySetFromConfig(&Config{JWTKeysDir: dir})
+	require.NoError(t, err)
+
+	rsaKey, ok := keys.key("rsa-1")
+	require.True(t, ok)
+	assert.Equal(t, jwt.SigningMethodRS256, rsaKey.Method)
+	edKey, ok := keys.key("ed-1")
+	require.True(t, ok)
+	assert.Equal(t, jwt.SigningMethodEdDSA, edKey.Method)
+}
+
+func TestKeySetFromConfig_EmptyDir(t *testing.T) {
+	_, err := NewKeySetFromConfig(&Config{JWTKeysDir: t.TempDir()})
+	var keyErr ErrUnsupportedKey
+	assert.ErrorAs(t, err, &keyErr)
+}
+
+func TestKeySetFromConfig_InvalidPEM(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a key"), 0o600))
+
+	_, err := NewKeySetFromConfig(&Config{JWTKeysDir: dir})
+	var keyErr ErrUnsupportedKey
+	assert.ErrorAs(t, err, &keyErr)
+}
+
+func TestKeySetFromConfig_EphemeralKey(t *testing.T) {
+	keys, err := NewKeySetFromConfig(&Config{})
+	require.NoError(t, err)
+
+	token, err := NewToken(keys, time.Minute, &User{ID: 1}, "")
+	require.NoError(t, err)
+	uid, err := token.UserID(keys)
+	require.NoError(t, err)
+	assert.Equal(t, UserID(1), uid)
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeEd25519Key(t, dir, "old", map[string]string{notBeforeHeader: now.Add(-48 * time.Hour).Format(time.RFC3339)})
+	writeRSAKey(t, dir, "next", map[string]string{notBeforeHeader: now.Add(time.Hour).Format(time.RFC3339)})
+
+	keys, err := NewKeySetFromConfig(&Config{JWTKeysDir: dir})
+	require.NoError(t, err)
+	user := &User{ID: 7}
+
+	// новый ключ уже опубликован, но до активации подписывает старый.
+	oldToken, err := NewToken(keys, time.Hour, user, "")
+	require.NoError(t, err)
+	assert.Equal(t, "old", tokenKeyID(t, oldToken))
+	assert.Len(t, keys.JWKS().Keys, 2)
+
+	// после активации подписывает новый ключ, а старые токены продолжают проверяться.
+	keys.now = func() time.Time { return now.Add(2 * time.Hour) }
+	newToken, err := NewToken(keys, time.Hour, user, "")
+	require.NoError(t, err)
+	assert.Equal(t, "next", tokenKeyID(t, newToken))
+
+	uid, err := oldToken.UserID(keys)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, uid)
+	uid, err = newToken.UserID(keys)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, uid)
+
+	// удалённый из каталога ключ перестаёт приниматься после перечитывания.
+	require.NoError(t, os.Remove(filepath.Join(dir, "old.pem")))
+	require.NoError(t, keys.Reload())
+	_, err = oldToken.UserID(keys)
+	assert.Error(t, err)
+	_, err = newToken.UserID(keys)
+	assert.NoError(t, err)
+}
+
+func TestKeySet_ActivationDelayFromModTime(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "first", nil)
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "first.pem"), old, old))
+	writeEd25519Key(t, dir, "second", nil)
+
+	keys, err := NewKeySetFromConfig(&Config{JWTKeysDir: dir, JWTKeyActivationDelay: time.Hour})
+	require.NoError(t, err)
+
+	token, err := NewToken(keys, time.Minute, &User{ID: 1}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "first", tokenKeyID(t, token))
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := writeRSAKey(t, dir, "rsa-1", nil)
+	edKey := writeEd25519Key(t, dir, "ed-1", nil)
+
+	keys, err := NewKeySetFromConfig(&Config{JWTKeysDir: dir})
+	require.NoError(t, err)
+
+	byID := make(map[string]JWK)
+	for _, jwk := range keys.JWKS().Keys {
+		byID[jwk.KeyID] = jwk
+	}
+	require.Len(t, byID, 2)
+
+	rsaJWK := byID["rsa-1"]
+	assert.Equal(t, "RSA", rsaJWK.KeyType)
+	assert.Equal(t, "RS256", rsaJWK.Algorithm)
+	assert.Equal(t, "sig", rsaJWK.Use)
+	n, err := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	require.NoError(t, err)
+	assert.Equal(t, 0, new(big.Int).SetBytes(n).Cmp(rsaKey.N))
+	assert.Equal(t, "AQAB", rsaJWK.E)
+
+	edJWK := byID["ed-1"]
+	assert.Equal(t, "OKP", edJWK.KeyType)
+	assert.Equal(t, "Ed25519", edJWK.Curve)
+	assert.Equal(t, "EdDSA", edJWK.Algorithm)
+	x, err := base64.RawURLEncoding.DecodeString(edJWK.X)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(edKey.Public().(ed25519.PublicKey)), x)
+}
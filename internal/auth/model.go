@@ -1,6 +1,11 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
 	"time"
 
 	jwt "github.com/golang-jwt/jwt/v5"
@@ -16,54 +21,124 @@ func NewUserID(userID int64) (UserID, error) {
 	return UserID(userID), nil
 }
 
+// Role — роль пользователя, определяющая доступ к административным операциям.
+type Role string
+
+const (
+	RoleEmployee  Role = "employee"
+	RoleHRAdmin   Role = "hr-admin"
+	RoleShopAdmin Role = "shop-admin"
+	RoleAuditor   Role = "auditor"
+)
+
+// AllRoles — все роли системы, их получает администратор, созданный при запуске.
+var AllRoles = []Role{RoleEmployee, RoleHRAdmin, RoleShopAdmin, RoleAuditor}
+
+func NewRole(raw string) (Role, error) {
+	role := Role(raw)
+	if !slices.Contains(AllRoles, role) {
+		return "", NewErrInvalidRole(raw)
+	}
+	return role, nil
+}
+
 // User — представление пользователя в системе.
 type User struct {
 	ID          UserID
 	Username    string
 	Password    string
+	Roles       []Role
 	CoinBalance int
 	CreatedAt   time.Time
 }
 
-// Claims определяет наши собственные JWT claims, включая идентификатор пользователя.
+// Identity возвращает копию пользователя без баланса и хэша пароля.
+func (u *User) Identity() *User {
+	return &User{
+		ID:        u.ID,
+		Username:  u.Username,
+		Roles:     slices.Clone(u.Roles),
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+// HasRole сообщает, есть ли у пользователя хотя бы одна из ролей.
+func (u *User) HasRole(roles ...Role) bool {
+	for _, role := range roles {
+		if slices.Contains(u.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionID — идентификатор сессии, объединяющей цепочку refresh токенов одного входа.
+type SessionID string
+
+// Session — сессия пользователя. Отзыв сессии делает недействительными
+// все выпущенные в ней access и refresh токены.
+type Session struct {
+	ID        SessionID
+	UserID    UserID
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// Claims определяет наши собственные JWT claims, включая идентификатор пользователя, сессии и роли.
+// Идентификатор токена (jti) хранится в RegisteredClaims.ID.
 type Claims struct {
-	UserID int64
+	UserID    int64
+	SessionID SessionID `json:"sid,omitempty"`
+	Roles     []Role    `json:"roles,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // Token представляет JWT токен для аутентификации пользователя.
 type Token string
 
-// NewToken создает подписанный JWT токен с идентификатором пользователя.
-// Токен будет действителен в течение 24 часов.
-func NewToken(secretKey string, expireTime time.Duration, user *User) (Token, error) {
+// NewToken создает подписанный текущим ключом набора JWT токен с идентификатором пользователя и сессии.
+// Токен будет действителен в течение expireTime.
+func NewToken(keys *KeySet, expireTime time.Duration, user *User, sessionID SessionID) (Token, error) {
+	jti, err := randomString(16)
+	if err != nil {
+		return "", NewErrUnableToSignToken(err)
+	}
 	claims := &Claims{
-		UserID: int64(user.ID),
+		UserID:    int64(user.ID),
+		SessionID: sessionID,
+		Roles:     user.Roles,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireTime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secretKey))
+	tokenString, err := keys.Sign(claims)
 	if err != nil {
 		return "", NewErrUnableToSignToken(err)
 	}
 	return Token(tokenString), nil
 }
 
-// UserID парсит JWT токен и возвращает содержащийся в нем идентификатор пользователя.
-func (t Token) UserID(secretKey string) (UserID, error) {
-	token, err := jwt.ParseWithClaims(string(t), &Claims{}, func(_ *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	})
+// Claims проверяет подпись JWT токена ключом из набора и возвращает его claims.
+func (t Token) Claims(keys *KeySet) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(string(t), &Claims{}, keys.Keyfunc, jwt.WithValidMethods(keys.ValidMethods()))
 	if err != nil {
-		return 0, NewErrUnableToParseToken(err)
+		return nil, NewErrUnableToParseToken(err)
 	}
 
 	claims, ok := token.Claims.(*Claims)
 	if !ok || !token.Valid {
-		return 0, ErrInvalidToken
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// UserID парсит JWT токен и возвращает содержащийся в нем идентификатор пользователя.
+func (t Token) UserID(keys *KeySet) (UserID, error) {
+	claims, err := t.Claims(keys)
+	if err != nil {
+		return 0, err
 	}
 	userID, err := NewUserID(claims.UserID)
 	if err != nil {
@@ -72,3 +147,56 @@ func (t Token) UserID(secretKey string) (UserID, error) {
 
 	return userID, nil
 }
+
+// RefreshToken — непрозрачный токен для получения новой пары токенов.
+// В базе хранится только его хеш.
+type RefreshToken string
+
+// NewRefreshToken генерирует случайный refresh токен.
+func NewRefreshToken() (RefreshToken, error) {
+	raw, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	return RefreshToken(raw), nil
+}
+
+// Hash возвращает SHA-256 хеш токена для хранения и поиска.
+func (t RefreshToken) Hash() string {
+	sum := sha256.Sum256([]byte(t))
+	return hex.EncodeToString(sum[:])
+}
+
+// RefreshTokenRecord — сохранённый refresh токен. UsedAt заполняется при ротации;
+// повторное предъявление использованного токена означает его утечку.
+type RefreshTokenRecord struct {
+	Hash      string
+	SessionID SessionID
+	UserID    UserID
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	// SessionRevoked — отозвана ли сессия, к которой относится токен.
+	SessionRevoked bool
+}
+
+// Throttle — счётчик попыток по ключу (имя пользователя или IP) в окне, начатом в WindowStart.
+type Throttle struct {
+	Key         string
+	Attempts    int
+	WindowStart time.Time
+	LockedUntil *time.Time
+}
+
+// TokenPair — короткоживущий access токен и refresh токен для его обновления.
+type TokenPair struct {
+	AccessToken  Token
+	RefreshToken RefreshToken
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/ed25519"
 	"errors"
 	"testing"
 	"time"
@@ -10,10 +11,17 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-const (
-	testExpireDuration = time.Second * 1
-	testSecretKey      = "testsecret"
-)
+const testExpireDuration = time.Second * 1
+
+var testKeys = mustGenerateKeySet()
+
+func mustGenerateKeySet() *KeySet {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		panic(err)
+	}
+	return NewKeySet(key)
+}
 
 func TestNewUserIDValid(t *testing.T) {
 	validID := int64(10)
@@ -45,46 +53,44 @@ func TestValidToken(t *testing.T) {
 		Username: "testuser",
 	}
 
-	token, err := NewToken(testSecretKey, testExpireDuration, user)
+	token, err := NewToken(testKeys, testExpireDuration, user, "")
 	require.NoError(t, err, "ошибка при создании токена")
 
-	uid, err := token.UserID(testSecretKey)
+	uid, err := token.UserID(testKeys)
 	require.NoError(t, err, "ошибка при разборе токена")
 	assert.Equal(t, user.ID, uid, "ожидался userID %d, получен %d", user.ID, uid)
 }
 
-func TestInvalidSecret(t *testing.T) {
-	wrongSecret := "wrongsecret"
+func TestUnknownKey(t *testing.T) {
+	otherKeys := mustGenerateKeySet()
 	user := &User{
 		ID:       2,
 		Username: "anotheruser",
 	}
 
-	token, err := NewToken(testSecretKey, testExpireDuration, user)
+	token, err := NewToken(testKeys, testExpireDuration, user, "")
 	require.NoError(t, err, "ошибка при создании токена")
 
-	_, err = token.UserID(wrongSecret)
-	assert.Error(t, err, "ожидалась ошибка при разборе токена с некорректным секретом")
+	_, err = token.UserID(otherKeys)
+	assert.Error(t, err, "ожидалась ошибка при разборе токена, подписанного неизвестным ключом")
 
 	var parseErr ErrUnableToParseToken
 	assert.True(t, errors.As(err, &parseErr), "ошибка не того типа, ожидалась ErrUnableToParseToken")
 }
 
 func TestExpiredToken(t *testing.T) {
-	secretKey := "testsecret"
-
 	user := &User{
 		ID:       3,
 		Username: "expireduser",
 	}
 
-	token, err := NewToken(secretKey, testExpireDuration, user)
+	token, err := NewToken(testKeys, testExpireDuration, user, "")
 	require.NoError(t, err, "ошибка при подписании токена")
 
 	// Ждем, пока токен истечет.
 	time.Sleep(2 * testExpireDuration)
 
-	_, err = token.UserID(secretKey)
+	_, err = token.UserID(testKeys)
 	assert.Error(t, err, "ожидалась ошибка при разборе истекшего токена")
 	assert.ErrorAs(t, err, &ErrInvalidToken)
 }
@@ -92,7 +98,7 @@ func TestExpiredToken(t *testing.T) {
 func TestInvalidToken(t *testing.T) {
 	// Используем строку, не являющуюся корректным JWT.
 	token := Token("invalid.token")
-	_, err := token.UserID(testSecretKey)
+	_, err := token.UserID(testKeys)
 	assert.Error(t, err, "ожидалась ошибка при разборе поврежденного токена")
 
 	var parseErr ErrUnableToParseToken
@@ -107,12 +113,11 @@ func TestInvalidClaimsToken(t *testing.T) {
 		"exp":          time.Now().Add(24 * time.Hour).Unix(),
 		"iat":          time.Now().Unix(),
 	}
-	tokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims)
-	tokenString, err := tokenObj.SignedString([]byte(testSecretKey))
+	tokenString, err := testKeys.Sign(mapClaims)
 	require.NoError(t, err, "ошибка при подписании токена")
 	token := Token(tokenString)
 
-	uid, err := token.UserID(testSecretKey)
+	uid, err := token.UserID(testKeys)
 	assert.Error(t, err)
 	assert.Equal(t, UserID(0), uid, "ожидался uid == 0, получено %d", uid)
 
@@ -121,9 +126,65 @@ func TestInvalidClaimsToken(t *testing.T) {
 
 func TestEmptyToken(t *testing.T) {
 	token := Token("")
-	_, err := token.UserID(testSecretKey)
+	_, err := token.UserID(testKeys)
 	assert.Error(t, err, "ожидалась ошибка при разборе пустого токена")
 
 	var parseErr ErrUnableToParseToken
 	assert.True(t, errors.As(err, &parseErr), "ошибка не того типа, ожидалась ErrUnableToParseToken")
 }
+
+func TestTokenClaims(t *testing.T) {
+	user := &User{
+		ID:       4,
+		Username: "sessionuser",
+	}
+
+	first, err := NewToken(testKeys, time.Minute, user, "session-1")
+	require.NoError(t, err, "ошибка при создании токена")
+	second, err := NewToken(testKeys, time.Minute, user, "session-1")
+	require.NoError(t, err, "ошибка при создании токена")
+
+	firstClaims, err := first.Claims(testKeys)
+	require.NoError(t, err, "ошибка при разборе токена")
+	secondClaims, err := second.Claims(testKeys)
+	require.NoError(t, err, "ошибка при разборе токена")
+
+	assert.Equal(t, int64(user.ID), firstClaims.UserID)
+	assert.Equal(t, SessionID("session-1"), firstClaims.SessionID)
+	assert.NotEmpty(t, firstClaims.ID, "ожидался jti")
+	assert.NotEqual(t, firstClaims.ID, secondClaims.ID, "jti должен быть уникальным")
+}
+
+func TestRefreshToken(t *testing.T) {
+	first, err := NewRefreshToken()
+	require.NoError(t, err)
+	second, err := NewRefreshToken()
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, first.Hash(), first.Hash(), "хеш должен быть детерминированным")
+	assert.NotEqual(t, first.Hash(), second.Hash())
+	assert.NotContains(t, first.Hash(), string(first))
+}
+
+func TestHMACTokenRejected(t *testing.T) {
+	// Токен подписан HS256 публичным ключом как секретом — классическая подмена алгоритма.
+	key, err := testKeys.signingKey()
+	require.NoError(t, err)
+	public, ok := key.Private.Public().(ed25519.PublicKey)
+	require.True(t, ok)
+
+	claims := &Claims{
+		UserID: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	tokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenObj.Header["kid"] = key.ID
+	tokenString, err := tokenObj.SignedString([]byte(public))
+	require.NoError(t, err)
+
+	_, err = Token(tokenString).UserID(testKeys)
+	assert.Error(t, err, "токен с алгоритмом HS256 должен отклоняться")
+}
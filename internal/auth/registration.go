@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// RegistrationMode определяет, кто может зарегистрироваться.
+type RegistrationMode string
+
+const (
+	// RegistrationOpen — любой пользователь с допустимым именем.
+	RegistrationOpen RegistrationMode = "open"
+	// RegistrationAllowList — только сотрудники из списка.
+	RegistrationAllowList RegistrationMode = "allow-list"
+	// RegistrationClosed — регистрация отключена, пользователей создает администратор.
+	RegistrationClosed RegistrationMode = "closed"
+)
+
+// RegistrationPolicy решает, можно ли зарегистрировать пользователя с таким именем.
+type RegistrationPolicy interface {
+	Allow(ctx context.Context, username string) error
+}
+
+// NewRegistrationPolicy создает политику по cfg.RegistrationMode.
+// Список для режима allow-list читается из cfg.RegistrationAllowListFile, а если файл не задан — из repo.
+func NewRegistrationPolicy(cfg *Config, repo AllowListRepo) (RegistrationPolicy, error) {
+	pattern, err := regexp.Compile(cfg.UsernamePattern)
+	if err != nil {
+		return nil, fmt.Errorf("%v: invalid USERNAME_PATTERN: %w", Err, err)
+	}
+	base := usernamePolicy{pattern: pattern}
+
+	switch RegistrationMode(cfg.RegistrationMode) {
+	case RegistrationOpen, "":
+		return base, nil
+	case RegistrationClosed:
+		return closedPolicy{}, nil
+	case RegistrationAllowList:
+		if cfg.RegistrationAllowListFile == "" {
+			return allowListPolicy{usernamePolicy: base, list: repo}, nil
+		}
+		list, err := loadAllowListFile(cfg.RegistrationAllowListFile)
+		if err != nil {
+			return nil, err
+		}
+		return allowListPolicy{usernamePolicy: base, list: list}, nil
+	default:
+		return nil, fmt.Errorf("%v: unknown REGISTRATION_MODE %q", Err, cfg.RegistrationMode)
+	}
+}
+
+// usernamePolicy проверяет только формат имени.
+type usernamePolicy struct {
+	pattern *regexp.Regexp
+}
+
+func (p usernamePolicy) Allow(_ context.Context, username string) error {
+	if !p.pattern.MatchString(username) {
+		return NewErrInvalidUsername(username)
+	}
+	return nil
+}
+
+type closedPolicy struct{}
+
+func (closedPolicy) Allow(_ context.Context, _ string) error {
+	return ErrRegistrationClosed
+}
+
+type allowListPolicy struct {
+	usernamePolicy
+	list AllowListRepo
+}
+
+func (p allowListPolicy) Allow(ctx context.Context, username string) error {
+	if err := p.usernamePolicy.Allow(ctx, username); err != nil {
+		return err
+	}
+	allowed, err := p.list.IsUsernameAllowed(ctx, username)
+	if err != nil {
+		return NewErrInternal(err)
+	}
+	if !allowed {
+		return ErrRegistrationNotAllowed
+	}
+	return nil
+}
+
+// fileAllowList — список из файла: одно имя на строку, пустые строки и строки с # пропускаются.
+type fileAllowList map[string]struct{}
+
+func loadAllowListFile(path string) (fileAllowList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%v: failed to open allow list: %w", Err, err)
+	}
+	defer f.Close()
+
+	list := make(fileAllowList)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[line] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%v: failed to read allow list: %w", Err, err)
+	}
+	return list, nil
+}
+
+func (l fileAllowList) IsUsernameAllowed(_ context.Context, username string) (bool, error) {
+	_, ok := l[username]
+	return ok, nil
+}
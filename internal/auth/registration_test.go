@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAllowList map[string]bool
+
+func (f fakeAllowList) IsUsernameAllowed(_ context.Context, username string) (bool, error) {
+	return f[username], nil
+}
+
+const testUsernamePattern = `^[a-z0-9._-]{3,64}$`
+
+func TestRegistrationPolicy_Open(t *testing.T) {
+	policy, err := NewRegistrationPolicy(&Config{RegistrationMode: "open", UsernamePattern: testUsernamePattern}, nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, policy.Allow(context.Background(), "ivan.petrov"))
+	var invalidErr ErrInvalidUsername
+	assert.ErrorAs(t, policy.Allow(context.Background(), "a"), &invalidErr)
+	assert.ErrorAs(t, policy.Allow(context.Background(), "Robert'); DROP TABLE users;--"), &invalidErr)
+}
+
+func TestRegistrationPolicy_Closed(t *testing.T) {
+	policy, err := NewRegistrationPolicy(&Config{RegistrationMode: "closed", UsernamePattern: testUsernamePattern}, nil)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, policy.Allow(context.Background(), "ivan.petrov"), ErrRegistrationClosed)
+}
+
+func TestRegistrationPolicy_AllowListRepo(t *testing.T) {
+	cfg := &Config{RegistrationMode: "allow-list", UsernamePattern: testUsernamePattern}
+	policy, err := NewRegistrationPolicy(cfg, fakeAllowList{"ivan.petrov": true})
+	require.NoError(t, err)
+
+	assert.NoError(t, policy.Allow(context.Background(), "ivan.petrov"))
+	assert.ErrorIs(t, policy.Allow(context.Background(), "intruder"), ErrRegistrationNotAllowed)
+}
+
+func TestRegistrationPolicy_AllowListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "employees.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# HR export\nivan.petrov\n\n  anna.ivanova  \n"), 0o600))
+
+	cfg := &Config{RegistrationMode: "allow-list", RegistrationAllowListFile: path, UsernamePattern: testUsernamePattern}
+	policy, err := NewRegistrationPolicy(cfg, nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, policy.Allow(context.Background(), "ivan.petrov"))
+	assert.NoError(t, policy.Allow(context.Background(), "anna.ivanova"))
+	assert.ErrorIs(t, policy.Allow(context.Background(), "# HR export"), NewErrInvalidUsername("# HR export"))
+	assert.ErrorIs(t, policy.Allow(context.Background(), "intruder"), ErrRegistrationNotAllowed)
+}
+
+func TestRegistrationPolicy_InvalidConfig(t *testing.T) {
+	_, err := NewRegistrationPolicy(&Config{RegistrationMode: "sometimes", UsernamePattern: testUsernamePattern}, nil)
+	assert.Error(t, err)
+
+	_, err = NewRegistrationPolicy(&Config{UsernamePattern: "("}, nil)
+	assert.Error(t, err)
+
+	_, err = NewRegistrationPolicy(&Config{
+		RegistrationMode:          "allow-list",
+		RegistrationAllowListFile: filepath.Join(t.TempDir(), "missing.txt"),
+		UsernamePattern:           testUsernamePattern,
+	}, nil)
+	assert.Error(t, err)
+}
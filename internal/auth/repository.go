@@ -1,9 +1,41 @@
 package auth
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type UserRepo interface {
 	CreateUser(ctx context.Context, username, password string, coins int) (*User, error)
 	GetUserByUsername(ctx context.Context, username string) (*User, error)
 	GetUserByID(ctx context.Context, userID UserID) (*User, error)
+	SetUserRoles(ctx context.Context, userID UserID, roles []Role) error
+}
+
+type SessionRepo interface {
+	CreateSession(ctx context.Context, session *Session) error
+	SaveRefreshToken(ctx context.Context, rec *RefreshTokenRecord) error
+	// GetRefreshTokenForUpdate возвращает токен по хешу и блокирует его до конца транзакции.
+	GetRefreshTokenForUpdate(ctx context.Context, hash string) (*RefreshTokenRecord, error)
+	MarkRefreshTokenUsed(ctx context.Context, hash string, usedAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID SessionID, revokedAt time.Time) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string, sessionID SessionID) (bool, error)
+	// DeleteExpiredRevokedTokens удаляет отозванные токены, истёкшие до before: они и так не принимаются.
+	DeleteExpiredRevokedTokens(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ThrottleRepo хранит счётчики неудачных входов и регистраций.
+type ThrottleRepo interface {
+	// HitThrottle атомарно увеличивает счётчик ключа. Если окно истекло, счётчик начинается заново.
+	HitThrottle(ctx context.Context, key string, now time.Time, window time.Duration) (*Throttle, error)
+	LockThrottle(ctx context.Context, key string, until time.Time) error
+	ResetThrottle(ctx context.Context, key string) error
+	// GetThrottleLockedUntil возвращает самую позднюю блокировку среди ключей или нулевое время.
+	GetThrottleLockedUntil(ctx context.Context, keys []string) (time.Time, error)
+}
+
+// AllowListRepo — список сотрудников, которым разрешена регистрация.
+type AllowListRepo interface {
+	IsUsernameAllowed(ctx context.Context, username string) (bool, error)
 }
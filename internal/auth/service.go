@@ -1,15 +1,29 @@
 package auth
 
 import (
+	"avito-intern/internal/common"
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type service struct {
-	users UserRepo
-	cfg   *Config
+	keys     *KeySet
+	policy   RegistrationPolicy
+	users    UserRepo
+	sessions SessionRepo
+	throttle ThrottleRepo
+	uow      common.UnitOfWork
+	// identities может быть nil, тогда пользователь читается из БД на каждый запрос.
+	identities *IdentityCache
+	// revocations может быть nil, тогда отзыв токена проверяется в БД на каждый запрос.
+	revocations *RevocationCache
+	cfg         *Config
 }
 
 func hashPassword(password string) (string, error) {
@@ -25,51 +39,410 @@ func checkPassword(hashedPassword, password string) bool {
 	return err == nil
 }
 
-func NewService(cfg *Config, ur UserRepo) Service {
+func NewService(
+	cfg *Config,
+	keys *KeySet,
+	policy RegistrationPolicy,
+	ur UserRepo,
+	sr SessionRepo,
+	tr ThrottleRepo,
+	uow common.UnitOfWork,
+	identities *IdentityCache,
+	revocations *RevocationCache,
+) Service {
 	return &service{
-		cfg:   cfg,
-		users: ur,
+		cfg:         cfg,
+		keys:        keys,
+		policy:      policy,
+		users:       ur,
+		sessions:    sr,
+		throttle:    tr,
+		uow:         uow,
+		identities:  identities,
+		revocations: revocations,
 	}
 }
 
-func (s *service) AuthUser(ctx context.Context, username, password string) (Token, error) {
-	user, err := s.users.GetUserByUsername(ctx, username)
-	if err == nil && !checkPassword(user.Password, password) {
-		return "", ErrUnauthorized
+func (s *service) AuthUser(ctx context.Context, username, password string) (*TokenPair, error) {
+	now := time.Now()
+	ip, _ := GetClientIP(ctx)
+	keys := []string{"login:user:" + username}
+	if ip != "" {
+		keys = append(keys, "login:ip:"+ip)
+	}
+	// блокировка проверяется до bcrypt, чтобы перебор не нагружал сервис.
+	lockedUntil, err := s.throttle.GetThrottleLockedUntil(ctx, keys)
+	if err != nil {
+		return nil, NewErrInternal(err)
+	}
+	if lockedUntil.After(now) {
+		return nil, NewErrTooManyAttempts(lockedUntil.Sub(now))
 	}
 
+	user, err := s.users.GetUserByUsername(ctx, username)
 	if errors.Is(err, ErrUserNotFound) {
-		var errCreate error
-		hashed, errCreate := hashPassword(password)
-		if errCreate != nil {
-			return "", NewErrInternal(errCreate)
+		if !s.cfg.AutoRegistration {
+			return nil, ErrUnauthorized
+		}
+		user, err = s.register(ctx, username, password, ip, now)
+		switch {
+		case errors.Is(err, ErrUserExists):
+			// параллельный первый вход успел создать пользователя: проверяем пароль как обычно.
+			user, err = s.users.GetUserByUsername(ctx, username)
+		case err != nil:
+			return nil, err
+		default:
+			return s.startSession(ctx, user)
+		}
+	}
+	switch {
+	case err != nil:
+		return nil, NewErrInternal(err)
+	case !checkPassword(user.Password, password):
+		if err := s.loginFailed(ctx, keys, now); err != nil {
+			return nil, NewErrInternal(err)
+		}
+		return nil, ErrUnauthorized
+	default:
+		if err := s.throttle.ResetThrottle(ctx, keys[0]); err != nil {
+			return nil, NewErrInternal(err)
 		}
+	}
+	return s.startSession(ctx, user)
+}
+
+// Register явно регистрирует пользователя по политике регистрации и открывает сессию.
+func (s *service) Register(ctx context.Context, username, password string) (*TokenPair, error) {
+	ip, _ := GetClientIP(ctx)
+	_, err := s.users.GetUserByUsername(ctx, username)
+	if err == nil {
+		return nil, ErrUserExists
+	}
+	if !errors.Is(err, ErrUserNotFound) {
+		return nil, NewErrInternal(err)
+	}
+	user, err := s.register(ctx, username, password, ip, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.startSession(ctx, user)
+}
+
+// register создает пользователя со стартовым начислением, если это разрешают политика и лимит регистраций.
+func (s *service) register(ctx context.Context, username, password, ip string, now time.Time) (*User, error) {
+	if err := s.policy.Allow(ctx, username); err != nil {
+		return nil, err
+	}
+	if err := s.registrationAllowed(ctx, ip, now); err != nil {
+		return nil, err
+	}
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return nil, NewErrInternal(err)
+	}
+	user, err := s.users.CreateUser(ctx, username, hashed, s.cfg.StartingCoins)
+	if errors.Is(err, ErrUserExists) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, NewErrInternal(err)
+	}
+	return user, nil
+}
 
-		user, errCreate = s.users.CreateUser(ctx, username, hashed, 1000)
-		if errCreate != nil {
-			return "", NewErrInternal(errCreate)
+func (s *service) startSession(ctx context.Context, user *User) (*TokenPair, error) {
+	var pair *TokenPair
+	err := s.uow.RunInTransaction(ctx, func(ctx context.Context) error {
+		sessionID, err := randomString(16)
+		if err != nil {
+			return err
 		}
+		session := &Session{
+			ID:        SessionID(sessionID),
+			UserID:    user.ID,
+			CreatedAt: time.Now(),
+		}
+		if err := s.sessions.CreateSession(ctx, session); err != nil {
+			return err
+		}
+		pair, err = s.issueTokens(ctx, user, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, NewErrInternal(err)
+	}
+	return pair, nil
+}
+
+// loginFailed учитывает неудачный вход для каждого ключа. После лимита ключ блокируется
+// на LoginLockoutBase, и каждая следующая неудача в окне удваивает блокировку до LoginLockoutMax.
+func (s *service) loginFailed(ctx context.Context, keys []string, now time.Time) error {
+	for i, key := range keys {
+		limit := s.cfg.LoginMaxFailures
+		if i > 0 {
+			limit = s.cfg.LoginMaxFailuresPerIP
+		}
+		t, err := s.throttle.HitThrottle(ctx, key, now, s.cfg.LoginFailureWindow)
+		if err != nil {
+			return err
+		}
+		if limit <= 0 || t.Attempts < limit {
+			continue
+		}
+		if err := s.throttle.LockThrottle(ctx, key, now.Add(s.lockout(t.Attempts-limit))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) lockout(exceeded int) time.Duration {
+	d := s.cfg.LoginLockoutBase
+	for i := 0; i < exceeded && d < s.cfg.LoginLockoutMax; i++ {
+		d *= 2
 	}
-	token, err := NewToken(s.cfg.JWTSecret, s.cfg.TokenExpireDuration, user)
+	return min(d, s.cfg.LoginLockoutMax)
+}
+
+// registrationAllowed ограничивает автоматическую регистрацию с одного IP за окно.
+func (s *service) registrationAllowed(ctx context.Context, ip string, now time.Time) error {
+	if ip == "" || s.cfg.RegistrationsPerIP <= 0 {
+		return nil
+	}
+	t, err := s.throttle.HitThrottle(ctx, "register:ip:"+ip, now, s.cfg.RegistrationWindow)
 	if err != nil {
-		return "", ErrUnauthorized
+		return NewErrInternal(err)
+	}
+	if t.Attempts > s.cfg.RegistrationsPerIP {
+		return NewErrTooManyAttempts(t.WindowStart.Add(s.cfg.RegistrationWindow).Sub(now))
 	}
-	return token, nil
+	return nil
+}
+
+// Refresh обменивает refresh токен на новую пару токенов той же сессии.
+// Предъявленный токен становится использованным; повторное его предъявление
+// считается утечкой и отзывает всю сессию.
+func (s *service) Refresh(ctx context.Context, refreshToken RefreshToken) (*TokenPair, error) {
+	var (
+		pair          *TokenPair
+		reusedSession SessionID
+		reused        bool
+	)
+	err := s.uow.RunInTransaction(ctx, func(ctx context.Context) error {
+		rec, err := s.sessions.GetRefreshTokenForUpdate(ctx, refreshToken.Hash())
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if rec.SessionRevoked {
+			return ErrTokenRevoked
+		}
+		if rec.UsedAt != nil {
+			// отзыв сессии должен зафиксироваться, поэтому транзакция завершается без ошибки.
+			reused, reusedSession = true, rec.SessionID
+			return s.sessions.RevokeSession(ctx, rec.SessionID, now)
+		}
+		if !now.Before(rec.ExpiresAt) {
+			return ErrUnauthorized
+		}
+		if err := s.sessions.MarkRefreshTokenUsed(ctx, rec.Hash, now); err != nil {
+			return err
+		}
+
+		user, err := s.users.GetUserByID(ctx, rec.UserID)
+		if err != nil {
+			return err
+		}
+		pair, err = s.issueTokens(ctx, user, rec.SessionID)
+		return err
+	})
+	if reused {
+		if err == nil {
+			s.revocations.RevokeSession(reusedSession)
+		}
+		return nil, ErrTokenReused
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTokenRevoked) ||
+			errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrUserNotFound) {
+			return nil, ErrUnauthorized
+		}
+		return nil, NewErrInternal(err)
+	}
+	return pair, nil
+}
+
+// Logout отзывает access токен и сессию, в которой он выпущен.
+func (s *service) Logout(ctx context.Context, rawToken Token) error {
+	claims, err := rawToken.Claims(s.keys)
+	if err != nil {
+		return ErrUnauthorized
+	}
+	err = s.uow.RunInTransaction(ctx, func(ctx context.Context) error {
+		if claims.ID != "" && claims.ExpiresAt != nil {
+			if err := s.sessions.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+				return err
+			}
+		}
+		if claims.SessionID != "" {
+			return s.sessions.RevokeSession(ctx, claims.SessionID, time.Now())
+		}
+		return nil
+	})
+	if err != nil {
+		return NewErrInternal(err)
+	}
+	s.revocations.RevokeToken(claims.ID)
+	s.revocations.RevokeSession(claims.SessionID)
+	return nil
+}
+
+func (s *service) issueTokens(ctx context.Context, user *User, sessionID SessionID) (*TokenPair, error) {
+	refreshToken, err := NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	err = s.sessions.SaveRefreshToken(ctx, &RefreshTokenRecord{
+		Hash:      refreshToken.Hash(),
+		SessionID: sessionID,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.cfg.RefreshTokenExpireDuration),
+	})
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := NewToken(s.keys, s.cfg.TokenExpireDuration, user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
 }
 
 // GetUserFromToken интерфейс для получение данных пользователя из jwt токена.
+// Токены, отозванные по jti или вместе с сессией, не принимаются.
+// Возвращается только идентичность пользователя, возможно из кэша:
+// CoinBalance не заполняется, баланс читают сервисы, которым он нужен.
 func (s *service) GetUserFromToken(ctx context.Context, rawToken Token) (*User, error) {
-	uid, err := rawToken.UserID(s.cfg.JWTSecret)
+	claims, err := rawToken.Claims(s.keys)
 	if err != nil {
 		return nil, ErrUnauthorized
 	}
-	u, err := s.users.GetUserByID(ctx, uid)
+	uid, err := NewUserID(claims.UserID)
 	if err != nil {
 		return nil, ErrUnauthorized
 	}
+	revoked, ok := s.revocations.Get(claims.ID, claims.SessionID)
+	if !ok {
+		revoked, err = s.sessions.IsTokenRevoked(ctx, claims.ID, claims.SessionID)
+		if err != nil {
+			return nil, ErrUnauthorized
+		}
+		s.revocations.Set(claims.ID, revoked)
+	}
+	if revoked {
+		return nil, ErrUnauthorized
+	}
+	u, ok := s.identities.Get(uid)
+	if !ok {
+		loaded, err := s.users.GetUserByID(ctx, uid)
+		if err != nil {
+			return nil, ErrUnauthorized
+		}
+		u = loaded.Identity()
+		s.identities.Set(u)
+	}
+	// действуют только роли, которые есть и в токене, и у пользователя сейчас:
+	// отозванная роль перестаёт работать сразу, а выданная — после обновления токена.
+	roles := make([]Role, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		if slices.Contains(claims.Roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	u.Roles = roles
 	return u, nil
 }
 
+// SetRoles заменяет роли пользователя. Роль employee сохраняется всегда.
+func (s *service) SetRoles(ctx context.Context, username string, roles []Role) (*User, error) {
+	user, err := s.users.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	updated := []Role{RoleEmployee}
+	for _, role := range roles {
+		if !slices.Contains(updated, role) {
+			updated = append(updated, role)
+		}
+	}
+	if err := s.users.SetUserRoles(ctx, user.ID, updated); err != nil {
+		return nil, NewErrInternal(err)
+	}
+	user.Roles = updated
+	return user, nil
+}
+
+// BootstrapAdmin создает администратора из конфигурации со всеми ролями. Существующему
+// пользователю роли выдаются, только если его пароль совпадает с BOOTSTRAP_ADMIN_PASSWORD:
+// иначе имя мог занять кто угодно через открытую регистрацию.
+func (s *service) BootstrapAdmin(ctx context.Context) error {
+	username := s.cfg.BootstrapAdminUsername
+	if username == "" {
+		return nil
+	}
+	if s.cfg.BootstrapAdminPassword == "" {
+		return fmt.Errorf("%v: BOOTSTRAP_ADMIN_PASSWORD is required for %q", Err, username)
+	}
+	created := false
+	user, err := s.users.GetUserByUsername(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		hashed, err := hashPassword(s.cfg.BootstrapAdminPassword)
+		if err != nil {
+			return NewErrInternal(err)
+		}
+		user, err = s.users.CreateUser(ctx, username, hashed, 0)
+		switch {
+		case errors.Is(err, ErrUserExists):
+			// пользователя зарегистрировали между чтением и созданием.
+			user, err = s.users.GetUserByUsername(ctx, username)
+			if err != nil {
+				return NewErrInternal(err)
+			}
+		case err != nil:
+			return NewErrInternal(err)
+		default:
+			created = true
+		}
+	} else if err != nil {
+		return NewErrInternal(err)
+	}
+	if !created && !checkPassword(user.Password, s.cfg.BootstrapAdminPassword) {
+		return fmt.Errorf("%v: user %q already exists with another password, roles are not assigned", Err, username)
+	}
+	if err := s.users.SetUserRoles(ctx, user.ID, AllRoles); err != nil {
+		return NewErrInternal(err)
+	}
+	return nil
+}
+
+func (s *service) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.RevokedTokensCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.sessions.DeleteExpiredRevokedTokens(ctx, time.Now())
+			if err != nil {
+				slog.Error("failed to delete expired revoked tokens", "error", err)
+				continue
+			}
+			slog.Debug("deleted expired revoked tokens", "count", deleted)
+		}
+	}
+}
+
 func (s *service) GetUserByUsername(ctx context.Context, username string) (*User, error) {
 	return s.users.GetUserByUsername(ctx, username)
 }
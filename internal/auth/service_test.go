@@ -3,6 +3,9 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
+	"regexp"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,6 +20,7 @@ type fakeUserRepo struct {
 	getUserByUsernameFunc func(ctx context.Context, username string) (*User, error)
 	createUserFunc        func(ctx context.Context, username, password string, coins int) (*User, error)
 	getUserByIDFunc       func(ctx context.Context, userID UserID) (*User, error)
+	setUserRolesFunc      func(ctx context.Context, userID UserID, roles []Role) error
 }
 
 func (f *fakeUserRepo) GetUserByUsername(ctx context.Context, username string) (*User, error) {
@@ -40,18 +44,182 @@ func (f *fakeUserRepo) GetUserByID(ctx context.Context, userID UserID) (*User, e
 	return nil, errors.New("user not found")
 }
 
+func (f *fakeUserRepo) SetUserRoles(ctx context.Context, userID UserID, roles []Role) error {
+	if f.setUserRolesFunc != nil {
+		return f.setUserRolesFunc(ctx, userID, roles)
+	}
+	return errors.New("not implemented")
+}
+
+// fakeSessionRepo implements the SessionRepo interface in memory.
+type fakeSessionRepo struct {
+	mu            sync.Mutex
+	sessions      map[SessionID]*Session
+	refreshTokens map[string]*RefreshTokenRecord
+	revokedTokens map[string]time.Time
+}
+
+func newFakeSessionRepo() *fakeSessionRepo {
+	return &fakeSessionRepo{
+		sessions:      make(map[SessionID]*Session),
+		refreshTokens: make(map[string]*RefreshTokenRecord),
+		revokedTokens: make(map[string]time.Time),
+	}
+}
+
+func (f *fakeSessionRepo) CreateSession(_ context.Context, session *Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := *session
+	f.sessions[session.ID] = &s
+	return nil
+}
+
+func (f *fakeSessionRepo) SaveRefreshToken(_ context.Context, rec *RefreshTokenRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := *rec
+	f.refreshTokens[rec.Hash] = &r
+	return nil
+}
+
+func (f *fakeSessionRepo) GetRefreshTokenForUpdate(_ context.Context, hash string) (*RefreshTokenRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rec, ok := f.refreshTokens[hash]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	r := *rec
+	r.SessionRevoked = f.sessions[rec.SessionID].RevokedAt != nil
+	return &r, nil
+}
+
+func (f *fakeSessionRepo) MarkRefreshTokenUsed(_ context.Context, hash string, usedAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refreshTokens[hash].UsedAt = &usedAt
+	return nil
+}
+
+func (f *fakeSessionRepo) RevokeSession(_ context.Context, sessionID SessionID, revokedAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.sessions[sessionID]; ok && s.RevokedAt == nil {
+		s.RevokedAt = &revokedAt
+	}
+	return nil
+}
+
+func (f *fakeSessionRepo) RevokeAccessToken(_ context.Context, jti string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (f *fakeSessionRepo) IsTokenRevoked(_ context.Context, jti string, sessionID SessionID) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.revokedTokens[jti]; ok {
+		return true, nil
+	}
+	s, ok := f.sessions[sessionID]
+	return ok && s.RevokedAt != nil, nil
+}
+
+func (f *fakeSessionRepo) DeleteExpiredRevokedTokens(_ context.Context, before time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var deleted int64
+	for jti, expiresAt := range f.revokedTokens {
+		if expiresAt.Before(before) {
+			delete(f.revokedTokens, jti)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// fakeThrottleRepo implements the ThrottleRepo interface in memory.
+type fakeThrottleRepo struct {
+	mu        sync.Mutex
+	throttles map[string]*Throttle
+}
+
+func newFakeThrottleRepo() *fakeThrottleRepo {
+	return &fakeThrottleRepo{throttles: make(map[string]*Throttle)}
+}
+
+func (f *fakeThrottleRepo) HitThrottle(_ context.Context, key string, now time.Time, window time.Duration) (*Throttle, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.throttles[key]
+	if !ok {
+		t = &Throttle{Key: key}
+		f.throttles[key] = t
+	}
+	if t.WindowStart.After(now.Add(-window)) {
+		t.Attempts++
+	} else {
+		t.Attempts = 1
+		t.WindowStart = now
+	}
+	res := *t
+	return &res, nil
+}
+
+func (f *fakeThrottleRepo) LockThrottle(_ context.Context, key string, until time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.throttles[key].LockedUntil = &until
+	return nil
+}
+
+func (f *fakeThrottleRepo) ResetThrottle(_ context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.throttles, key)
+	return nil
+}
+
+func (f *fakeThrottleRepo) GetThrottleLockedUntil(_ context.Context, keys []string) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var lockedUntil time.Time
+	for _, key := range keys {
+		if t, ok := f.throttles[key]; ok && t.LockedUntil != nil && t.LockedUntil.After(lockedUntil) {
+			lockedUntil = *t.LockedUntil
+		}
+	}
+	return lockedUntil, nil
+}
+
+// fakeUnitOfWork runs fn without a real transaction.
+type fakeUnitOfWork struct{}
+
+func (fakeUnitOfWork) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
 // createValidToken is a helper to create a token for testing using NewToken.
 func createValidToken(t *testing.T, cfg *Config, user *User) Token {
-	token, err := NewToken(cfg.JWTSecret, cfg.TokenExpireDuration, user)
+	token, err := NewToken(testKeys, cfg.TokenExpireDuration, user, "")
 	require.NoError(t, err)
 	return token
 }
 
+// testPolicy разрешает регистрацию с любым непустым именем.
+var testPolicy = usernamePolicy{pattern: regexp.MustCompile(`^.+$`)}
+
+func newTestService(cfg *Config, repo UserRepo) Service {
+	return NewService(cfg, testKeys, testPolicy, repo, newFakeSessionRepo(), newFakeThrottleRepo(), fakeUnitOfWork{}, nil, nil)
+}
+
 // --- Tests ---
 
 func TestAuthUser_CorrectPassword(t *testing.T) {
 	cfg := &Config{
-		JWTSecret:           testSecretKey,
 		TokenExpireDuration: time.Minute,
 	}
 
@@ -75,15 +243,15 @@ func TestAuthUser_CorrectPassword(t *testing.T) {
 		},
 	}
 
-	svc := NewService(cfg, repo)
-	token, err := svc.AuthUser(context.Background(), user.Username, plainPassword)
+	svc := newTestService(cfg, repo)
+	pair, err := svc.AuthUser(context.Background(), user.Username, plainPassword)
 	require.NoError(t, err, "authUser should succeed")
-	assert.NotEmpty(t, token, "expected token not to be empty")
+	assert.NotEmpty(t, pair.AccessToken, "expected token not to be empty")
+	assert.NotEmpty(t, pair.RefreshToken, "expected refresh token not to be empty")
 }
 
 func TestAuthUser_WrongPassword(t *testing.T) {
 	cfg := &Config{
-		JWTSecret:           testSecretKey,
 		TokenExpireDuration: time.Minute,
 	}
 
@@ -104,17 +272,17 @@ func TestAuthUser_WrongPassword(t *testing.T) {
 		},
 	}
 
-	svc := NewService(cfg, repo)
-	token, err := svc.AuthUser(context.Background(), user.Username, "wrongPass")
+	svc := newTestService(cfg, repo)
+	pair, err := svc.AuthUser(context.Background(), user.Username, "wrongPass")
 	assert.Error(t, err, "expected error for wrong password")
 	assert.Equal(t, ErrUnauthorized, err, "error should be ErrUnauthorized")
-	assert.Empty(t, token, "expected token to be empty")
+	assert.Nil(t, pair, "expected token to be empty")
 }
 
 func TestAuthUser_UserNotFound_CreateUserSuccess(t *testing.T) {
 	cfg := &Config{
-		JWTSecret:           testSecretKey,
 		TokenExpireDuration: time.Minute,
+		AutoRegistration:    true,
 	}
 
 	username := "newuser"
@@ -133,16 +301,16 @@ func TestAuthUser_UserNotFound_CreateUserSuccess(t *testing.T) {
 		},
 	}
 
-	svc := NewService(cfg, repo)
-	token, err := svc.AuthUser(context.Background(), username, plainPassword)
+	svc := newTestService(cfg, repo)
+	pair, err := svc.AuthUser(context.Background(), username, plainPassword)
 	require.NoError(t, err, "expected authUser to create user successfully")
-	assert.NotEmpty(t, token, "expected token not to be empty")
+	assert.NotEmpty(t, pair.AccessToken, "expected token not to be empty")
 }
 
 func TestAuthUser_CreateUserFailure(t *testing.T) {
 	cfg := &Config{
-		JWTSecret:           testSecretKey,
 		TokenExpireDuration: time.Minute,
+		AutoRegistration:    true,
 	}
 
 	username := "failuser"
@@ -157,15 +325,49 @@ func TestAuthUser_CreateUserFailure(t *testing.T) {
 		},
 	}
 
-	svc := NewService(cfg, repo)
-	token, err := svc.AuthUser(context.Background(), username, plainPassword)
+	svc := newTestService(cfg, repo)
+	pair, err := svc.AuthUser(context.Background(), username, plainPassword)
 	assert.Error(t, err, "expected error creating user")
-	assert.Empty(t, token, "token should be empty on error")
+	assert.Nil(t, pair, "token should be empty on error")
+}
+
+func TestAuthUser_ConcurrentRegistrationFallsBackToLogin(t *testing.T) {
+	cfg := &Config{
+		TokenExpireDuration: time.Minute,
+		AutoRegistration:    true,
+	}
+
+	hashed, err := hashPassword("racepass")
+	require.NoError(t, err)
+	winner := &User{ID: 4, Username: "raceuser", Password: hashed}
+
+	// Первый поиск не находит пользователя, но параллельный вход создает его раньше нас.
+	lookups := 0
+	repo := &fakeUserRepo{
+		getUserByUsernameFunc: func(_ context.Context, _ string) (*User, error) {
+			lookups++
+			if lookups%2 == 1 {
+				return nil, ErrUserNotFound
+			}
+			return winner, nil
+		},
+		createUserFunc: func(_ context.Context, _, _ string, _ int) (*User, error) {
+			return nil, ErrUserExists
+		},
+	}
+
+	svc := newTestService(cfg, repo)
+	pair, err := svc.AuthUser(context.Background(), winner.Username, "racepass")
+	require.NoError(t, err)
+	assert.NotEmpty(t, pair.AccessToken)
+
+	pair, err = svc.AuthUser(context.Background(), winner.Username, "wrongpass")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Nil(t, pair)
 }
 
 func TestValidateToken_Success(t *testing.T) {
 	cfg := &Config{
-		JWTSecret:           testSecretKey,
 		TokenExpireDuration: time.Minute,
 	}
 
@@ -192,20 +394,21 @@ func TestValidateToken_Success(t *testing.T) {
 		},
 	}
 
-	svc := NewService(cfg, repo)
+	svc := newTestService(cfg, repo)
 	retUser, err := svc.GetUserFromToken(context.Background(), token)
 	require.NoError(t, err, "ValidateToken should succeed with a valid token")
-	assert.Equal(t, user, retUser, "the returned user should match")
+	assert.Equal(t, user.ID, retUser.ID, "the returned user should match")
+	assert.Equal(t, user.Username, retUser.Username)
+	assert.Empty(t, retUser.Password, "password hash must not leave the auth service")
 }
 
 func TestValidateToken_InvalidToken(t *testing.T) {
 	cfg := &Config{
-		JWTSecret:           testSecretKey,
 		TokenExpireDuration: time.Minute,
 	}
 
 	repo := &fakeUserRepo{}
-	svc := NewService(cfg, repo)
+	svc := newTestService(cfg, repo)
 
 	// Use an obviously invalid token.
 	invalidToken := Token("this.is.invalid")
@@ -216,7 +419,6 @@ func TestValidateToken_InvalidToken(t *testing.T) {
 
 func TestValidateToken_UserNotFound(t *testing.T) {
 	cfg := &Config{
-		JWTSecret:           testSecretKey,
 		TokenExpireDuration: time.Minute,
 	}
 
@@ -240,8 +442,375 @@ func TestValidateToken_UserNotFound(t *testing.T) {
 		},
 	}
 
-	svc := NewService(cfg, repo)
+	svc := newTestService(cfg, repo)
 	u, err := svc.GetUserFromToken(context.Background(), token)
 	assert.Error(t, err, "expected error when user not found")
 	assert.Nil(t, u, "expected nil user when lookup fails")
 }
+
+// loginTestUser creates a service with a single user and logs them in.
+func loginTestUser(t *testing.T) (Service, *fakeSessionRepo, *TokenPair) {
+	t.Helper()
+	cfg := &Config{
+		TokenExpireDuration:        time.Minute,
+		RefreshTokenExpireDuration: time.Hour,
+	}
+	hashed, err := hashPassword("secret")
+	require.NoError(t, err)
+	user := &User{ID: 6, Username: "sessionuser", Password: hashed}
+	repo := &fakeUserRepo{
+		getUserByUsernameFunc: func(_ context.Context, _ string) (*User, error) {
+			return user, nil
+		},
+		getUserByIDFunc: func(_ context.Context, _ UserID) (*User, error) {
+			return user, nil
+		},
+	}
+	sessions := newFakeSessionRepo()
+	revocations := NewRevocationCache(10, time.Minute)
+	svc := NewService(cfg, testKeys, testPolicy, repo, sessions, newFakeThrottleRepo(), fakeUnitOfWork{}, nil, revocations)
+
+	pair, err := svc.AuthUser(context.Background(), user.Username, "secret")
+	require.NoError(t, err)
+	return svc, sessions, pair
+}
+
+func TestRefresh_RotatesToken(t *testing.T) {
+	svc, _, pair := loginTestUser(t)
+	ctx := context.Background()
+
+	refreshed, err := svc.Refresh(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, pair.RefreshToken, refreshed.RefreshToken)
+
+	u, err := svc.GetUserFromToken(ctx, refreshed.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, UserID(6), u.ID)
+
+	// новый refresh токен продолжает цепочку.
+	_, err = svc.Refresh(ctx, refreshed.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestRefresh_ReuseRevokesSession(t *testing.T) {
+	svc, _, pair := loginTestUser(t)
+	ctx := context.Background()
+
+	refreshed, err := svc.Refresh(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+
+	// повторное предъявление уже использованного токена.
+	_, err = svc.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, ErrTokenReused)
+
+	// вся сессия отозвана: и свежий refresh, и выпущенные в ней access токены.
+	_, err = svc.Refresh(ctx, refreshed.RefreshToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = svc.GetUserFromToken(ctx, refreshed.AccessToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = svc.GetUserFromToken(ctx, pair.AccessToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestRefresh_UnknownToken(t *testing.T) {
+	svc, _, _ := loginTestUser(t)
+
+	_, err := svc.Refresh(context.Background(), RefreshToken("unknown"))
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestRefresh_Expired(t *testing.T) {
+	svc, sessions, pair := loginTestUser(t)
+	sessions.refreshTokens[pair.RefreshToken.Hash()].ExpiresAt = time.Now().Add(-time.Second)
+
+	_, err := svc.Refresh(context.Background(), pair.RefreshToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestLogout_RevokesTokenAndSession(t *testing.T) {
+	svc, sessions, pair := loginTestUser(t)
+	ctx := context.Background()
+
+	require.NoError(t, svc.Logout(ctx, pair.AccessToken))
+
+	claims, err := pair.AccessToken.Claims(testKeys)
+	require.NoError(t, err)
+	assert.Contains(t, sessions.revokedTokens, claims.ID)
+
+	_, err = svc.GetUserFromToken(ctx, pair.AccessToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = svc.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestLogout_InvalidToken(t *testing.T) {
+	svc, _, _ := loginTestUser(t)
+
+	err := svc.Logout(context.Background(), Token("invalid.token"))
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestGetUserFromToken_RolesIntersection(t *testing.T) {
+	cfg := &Config{
+		TokenExpireDuration: time.Minute,
+	}
+	// в токене роли на момент выпуска, у пользователя — текущие.
+	token := createValidToken(t, cfg, &User{ID: 7, Roles: []Role{RoleEmployee, RoleHRAdmin}})
+	repo := &fakeUserRepo{
+		getUserByIDFunc: func(_ context.Context, _ UserID) (*User, error) {
+			return &User{ID: 7, Roles: []Role{RoleEmployee, RoleShopAdmin}}, nil
+		},
+	}
+
+	svc := newTestService(cfg, repo)
+	u, err := svc.GetUserFromToken(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, []Role{RoleEmployee}, u.Roles)
+	assert.False(t, u.HasRole(RoleHRAdmin, RoleShopAdmin))
+}
+
+func TestSetRoles_KeepsEmployee(t *testing.T) {
+	var saved []Role
+	repo := &fakeUserRepo{
+		getUserByUsernameFunc: func(_ context.Context, username string) (*User, error) {
+			return &User{ID: 8, Username: username}, nil
+		},
+		setUserRolesFunc: func(_ context.Context, _ UserID, roles []Role) error {
+			saved = roles
+			return nil
+		},
+	}
+
+	svc := newTestService(&Config{}, repo)
+	u, err := svc.SetRoles(context.Background(), "manager", []Role{RoleAuditor, RoleAuditor})
+	require.NoError(t, err)
+	assert.Equal(t, []Role{RoleEmployee, RoleAuditor}, saved)
+	assert.Equal(t, saved, u.Roles)
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		svc := newTestService(&Config{}, &fakeUserRepo{})
+		assert.NoError(t, svc.BootstrapAdmin(context.Background()))
+	})
+
+	t.Run("creates user", func(t *testing.T) {
+		var (
+			created *User
+			saved   []Role
+		)
+		repo := &fakeUserRepo{
+			createUserFunc: func(_ context.Context, username, password string, coins int) (*User, error) {
+				assert.True(t, checkPassword(password, "admin-pass"))
+				assert.Zero(t, coins)
+				created = &User{ID: 9, Username: username}
+				return created, nil
+			},
+			setUserRolesFunc: func(_ context.Context, userID UserID, roles []Role) error {
+				assert.Equal(t, UserID(9), userID)
+				saved = roles
+				return nil
+			},
+		}
+		cfg := &Config{BootstrapAdminUsername: "admin", BootstrapAdminPassword: "admin-pass"}
+
+		svc := newTestService(cfg, repo)
+		require.NoError(t, svc.BootstrapAdmin(context.Background()))
+		require.NotNil(t, created)
+		assert.Equal(t, AllRoles, saved)
+	})
+
+	t.Run("promotes existing user with the configured password", func(t *testing.T) {
+		hashed, err := hashPassword("admin-pass")
+		require.NoError(t, err)
+		var saved []Role
+		repo := &fakeUserRepo{
+			getUserByUsernameFunc: func(_ context.Context, username string) (*User, error) {
+				return &User{ID: 10, Username: username, Password: hashed}, nil
+			},
+			setUserRolesFunc: func(_ context.Context, _ UserID, roles []Role) error {
+				saved = roles
+				return nil
+			},
+		}
+		cfg := &Config{BootstrapAdminUsername: "admin", BootstrapAdminPassword: "admin-pass"}
+
+		svc := newTestService(cfg, repo)
+		require.NoError(t, svc.BootstrapAdmin(context.Background()))
+		assert.Equal(t, AllRoles, saved)
+	})
+
+	t.Run("does not promote user registered with another password", func(t *testing.T) {
+		hashed, err := hashPassword("chosen-by-someone-else")
+		require.NoError(t, err)
+		repo := &fakeUserRepo{
+			getUserByUsernameFunc: func(_ context.Context, username string) (*User, error) {
+				return &User{ID: 10, Username: username, Password: hashed}, nil
+			},
+			setUserRolesFunc: func(_ context.Context, _ UserID, _ []Role) error {
+				t.Fatal("roles must not be assigned")
+				return nil
+			},
+		}
+		cfg := &Config{BootstrapAdminUsername: "admin", BootstrapAdminPassword: "admin-pass"}
+
+		svc := newTestService(cfg, repo)
+		assert.Error(t, svc.BootstrapAdmin(context.Background()))
+	})
+
+	t.Run("missing password", func(t *testing.T) {
+		cfg := &Config{BootstrapAdminUsername: "admin"}
+		svc := newTestService(cfg, &fakeUserRepo{})
+		assert.Error(t, svc.BootstrapAdmin(context.Background()))
+	})
+}
+
+func newThrottleTestService(t *testing.T) (Service, *fakeThrottleRepo) {
+	t.Helper()
+	cfg := &Config{
+		TokenExpireDuration:        time.Minute,
+		RefreshTokenExpireDuration: time.Hour,
+		AutoRegistration:           true,
+		LoginMaxFailures:           3,
+		LoginMaxFailuresPerIP:      10,
+		LoginFailureWindow:         time.Hour,
+		LoginLockoutBase:           time.Minute,
+		LoginLockoutMax:            3 * time.Minute,
+		RegistrationsPerIP:         2,
+		RegistrationWindow:         time.Hour,
+	}
+	hashed, err := hashPassword("secret")
+	require.NoError(t, err)
+	repo := &fakeUserRepo{
+		getUserByUsernameFunc: func(_ context.Context, username string) (*User, error) {
+			if username == "victim" {
+				return &User{ID: 11, Username: username, Password: hashed}, nil
+			}
+			return nil, ErrUserNotFound
+		},
+		createUserFunc: func(_ context.Context, username, password string, _ int) (*User, error) {
+			return &User{ID: 12, Username: username, Password: password}, nil
+		},
+	}
+	throttle := newFakeThrottleRepo()
+	return NewService(cfg, testKeys, testPolicy, repo, newFakeSessionRepo(), throttle, fakeUnitOfWork{}, nil, nil), throttle
+}
+
+func TestAuthUser_Lockout(t *testing.T) {
+	svc, throttle := newThrottleTestService(t)
+	ctx := SetClientIP(context.Background(), "10.0.0.1")
+
+	for i := 0; i < 3; i++ {
+		_, err := svc.AuthUser(ctx, "victim", "wrong")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	}
+
+	// даже верный пароль не проверяется, пока действует блокировка.
+	_, err := svc.AuthUser(ctx, "victim", "secret")
+	var tooMany ErrTooManyAttempts
+	require.ErrorAs(t, err, &tooMany)
+	assert.InDelta(t, time.Minute, tooMany.RetryAfter, float64(time.Second))
+
+	// блокировка касается имени пользователя, а не только адреса.
+	_, err = svc.AuthUser(SetClientIP(context.Background(), "10.0.0.2"), "victim", "secret")
+	assert.ErrorAs(t, err, &tooMany)
+
+	// после истечения блокировки следующая неудача удваивает её.
+	past := time.Now().Add(-time.Second)
+	throttle.throttles["login:user:victim"].LockedUntil = &past
+	_, err = svc.AuthUser(ctx, "victim", "wrong")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = svc.AuthUser(ctx, "victim", "secret")
+	require.ErrorAs(t, err, &tooMany)
+	assert.InDelta(t, 2*time.Minute, tooMany.RetryAfter, float64(time.Second))
+}
+
+func TestAuthUser_SuccessResetsFailures(t *testing.T) {
+	svc, throttle := newThrottleTestService(t)
+	ctx := SetClientIP(context.Background(), "10.0.0.1")
+
+	for i := 0; i < 2; i++ {
+		_, err := svc.AuthUser(ctx, "victim", "wrong")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	}
+	_, err := svc.AuthUser(ctx, "victim", "secret")
+	require.NoError(t, err)
+	assert.NotContains(t, throttle.throttles, "login:user:victim")
+	// счётчик по IP не сбрасывается успешным входом.
+	assert.Equal(t, 2, throttle.throttles["login:ip:10.0.0.1"].Attempts)
+}
+
+func TestAuthUser_RegistrationLimit(t *testing.T) {
+	svc, _ := newThrottleTestService(t)
+	ctx := SetClientIP(context.Background(), "10.0.0.1")
+
+	for i := 0; i < 2; i++ {
+		_, err := svc.AuthUser(ctx, fmt.Sprintf("new-%d", i), "secret")
+		require.NoError(t, err)
+	}
+	_, err := svc.AuthUser(ctx, "new-2", "secret")
+	var tooMany ErrTooManyAttempts
+	require.ErrorAs(t, err, &tooMany)
+	assert.InDelta(t, time.Hour, tooMany.RetryAfter, float64(time.Second))
+
+	// с другого адреса регистрация доступна.
+	_, err = svc.AuthUser(SetClientIP(context.Background(), "10.0.0.2"), "new-2", "secret")
+	assert.NoError(t, err)
+}
+
+func TestAuthUser_AutoRegistrationDisabled(t *testing.T) {
+	repo := &fakeUserRepo{
+		createUserFunc: func(_ context.Context, _, _ string, _ int) (*User, error) {
+			t.Fatal("user must not be created")
+			return nil, nil
+		},
+	}
+	svc := newTestService(&Config{TokenExpireDuration: time.Minute}, repo)
+
+	_, err := svc.AuthUser(context.Background(), "stranger", "secret")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestRegister(t *testing.T) {
+	cfg := &Config{
+		TokenExpireDuration:        time.Minute,
+		RefreshTokenExpireDuration: time.Hour,
+		StartingCoins:              500,
+	}
+	repo := &fakeUserRepo{
+		getUserByUsernameFunc: func(_ context.Context, username string) (*User, error) {
+			if username == "existing" {
+				return &User{ID: 13, Username: username}, nil
+			}
+			return nil, ErrUserNotFound
+		},
+		createUserFunc: func(_ context.Context, username, _ string, coins int) (*User, error) {
+			assert.Equal(t, 500, coins)
+			return &User{ID: 14, Username: username, CoinBalance: coins}, nil
+		},
+	}
+	svc := newTestService(cfg, repo)
+
+	pair, err := svc.Register(context.Background(), "newcomer", "secret")
+	require.NoError(t, err)
+	assert.NotEmpty(t, pair.AccessToken)
+
+	_, err = svc.Register(context.Background(), "existing", "secret")
+	assert.ErrorIs(t, err, ErrUserExists)
+}
+
+func TestRegister_PolicyDenied(t *testing.T) {
+	repo := &fakeUserRepo{
+		createUserFunc: func(_ context.Context, _, _ string, _ int) (*User, error) {
+			t.Fatal("user must not be created")
+			return nil, nil
+		},
+	}
+	cfg := &Config{AutoRegistration: true}
+	svc := NewService(cfg, testKeys, closedPolicy{}, repo, newFakeSessionRepo(), newFakeThrottleRepo(), fakeUnitOfWork{}, nil, nil)
+
+	_, err := svc.Register(context.Background(), "newcomer", "secret")
+	assert.ErrorIs(t, err, ErrRegistrationClosed)
+	_, err = svc.AuthUser(context.Background(), "newcomer", "secret")
+	assert.ErrorIs(t, err, ErrRegistrationClosed)
+}
@@ -0,0 +1,26 @@
+package coin
+
+import (
+	"avito-intern/internal/auth"
+	"time"
+)
+
+// MaxBatchSize — максимальное число получателей в одном пакетном переводе.
+const MaxBatchSize = 100
+
+// BatchID — идентификатор пакетного перевода, общий для всех его переводов.
+type BatchID int64
+
+// TransferLeg — один получатель пакетного перевода.
+type TransferLeg struct {
+	To     *auth.User
+	Amount int
+}
+
+// Batch — пакетный перевод: переводы всем получателям, проведённые в одной транзакции БД.
+type Batch struct {
+	ID        BatchID
+	From      *auth.User
+	Transfers []*Transaction
+	CreatedAt time.Time
+}
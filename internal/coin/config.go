@@ -0,0 +1,33 @@
+package coin
+
+import "time"
+
+type Config struct {
+	// InfoHistoryLimit ограничивает число последних входящих и исходящих переводов в /api/info, 0 — без ограничения.
+	// Полная история доступна постранично в /api/transactions.
+	InfoHistoryLimit int `env:"INFO_HISTORY_LIMIT" env-default:"0"`
+
+	// Правила переводов, нулевое значение отключает правило.
+	TransferMinAmount         int           `env:"TRANSFER_MIN_AMOUNT" env-default:"1"`
+	TransferMaxAmount         int           `env:"TRANSFER_MAX_AMOUNT" env-default:"0"`
+	TransferDailyCap          int           `env:"TRANSFER_DAILY_CAP" env-default:"0"`
+	TransferWeeklyCap         int           `env:"TRANSFER_WEEKLY_CAP" env-default:"0"`
+	TransferRecipientDailyCap int           `env:"TRANSFER_RECIPIENT_DAILY_CAP" env-default:"0"`
+	TransferCooldown          time.Duration `env:"TRANSFER_COOLDOWN" env-default:"0s"`
+
+	// PendingTTL — сколько перевод с подтверждением ждёт получателя, затем монеты возвращаются отправителю.
+	PendingTTL time.Duration `env:"PENDING_TRANSFER_TTL" env-default:"72h"`
+	// PendingExpiryInterval — период проверки истекших переводов и сборов.
+	PendingExpiryInterval time.Duration `env:"PENDING_EXPIRY_INTERVAL" env-default:"1m"`
+	// PendingExpiryBatch — сколько истекших переводов возвращается и сборов закрывается за одну проверку.
+	PendingExpiryBatch int `env:"PENDING_EXPIRY_BATCH" env-default:"100"`
+
+	// CoinExpiryMonths — через сколько месяцев после получения сгорают монеты, 0 — не сгорают.
+	CoinExpiryMonths int `env:"COIN_EXPIRY_MONTHS" env-default:"12"`
+	// CoinExpiryNotice — за сколько до сгорания монеты показываются в /api/info.
+	CoinExpiryNotice time.Duration `env:"COIN_EXPIRY_NOTICE" env-default:"720h"`
+	// CoinExpiryInterval — период проверки сгоревших монет.
+	CoinExpiryInterval time.Duration `env:"COIN_EXPIRY_INTERVAL" env-default:"1h"`
+	// CoinExpiryBatch — у скольких пользователей списываются сгоревшие монеты за одну проверку.
+	CoinExpiryBatch int `env:"COIN_EXPIRY_BATCH" env-default:"100"`
+}
@@ -3,22 +3,199 @@ package coin
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
-	Err                 = errors.New("coin")
-	ErrInvalidRecipient = fmt.Errorf("%v: invalid recipient users can't send coins to them self", Err)
-	ErrNotEnoughCoins   = fmt.Errorf("%v: not enough coins for transfer", Err)
+	Err                    = errors.New("coin")
+	ErrInvalidRecipient    = fmt.Errorf("%v: invalid recipient users can't send coins to them self", Err)
+	ErrNotEnoughCoins      = fmt.Errorf("%v: not enough coins for transfer", Err)
+	ErrInvalidTransaction  = fmt.Errorf("%v: invalid transaction", Err)
+	ErrInvalidMessage      = fmt.Errorf("%v: message must be valid UTF-8 up to %d characters", Err, MaxMessageLength)
+	ErrTooManyTags         = fmt.Errorf("%v: no more than %d tags allowed", Err, MaxTags)
+	ErrInvalidCursor       = fmt.Errorf("%v: invalid cursor", Err)
+	ErrInvalidAmount       = fmt.Errorf("%v: amount must be positive", Err)
+	ErrDuplicateRecipient  = fmt.Errorf("%v: duplicate recipient in batch", Err)
+	ErrEmptyBatch          = fmt.Errorf("%v: batch must contain from 1 to %d transfers", Err, MaxBatchSize)
+	ErrTransactionNotFound = fmt.Errorf("%v: transaction not found", Err)
+	ErrNotReversible       = fmt.Errorf("%v: only transfers can be reversed", Err)
+	ErrAlreadyReversed     = fmt.Errorf("%v: transaction is already fully reversed", Err)
+	ErrReversalTooLarge    = fmt.Errorf("%v: amount exceeds the part of the transaction that is not reversed yet", Err)
+	ErrNothingToReverse    = fmt.Errorf("%v: recipient has no coins left to reverse", Err)
+	ErrNotPending          = fmt.Errorf("%v: transaction is not pending", Err)
+	ErrNotRecipient        = fmt.Errorf("%v: only the recipient can accept or decline the transfer", Err)
+	ErrInvalidReason       = fmt.Errorf("%v: reason is required, up to %d characters", Err, MaxMessageLength)
+	ErrAlreadyGranted      = fmt.Errorf("%v: user already got the grant for this period", Err)
+	ErrPoolNotFound        = fmt.Errorf("%v: pool not found", Err)
+	ErrPoolClosed          = fmt.Errorf("%v: pool is closed or past its deadline", Err)
+	ErrPoolEmpty           = fmt.Errorf("%v: pool has no contributions to pay out", Err)
+	ErrNotOrganizer        = fmt.Errorf("%v: only the organizer can close or cancel the pool", Err)
+	ErrPoolRecipient       = fmt.Errorf("%v: recipient can't contribute to their own pool", Err)
+	ErrInvalidPoolTitle    = fmt.Errorf("%v: title is required, up to %d characters", Err, MaxMessageLength)
+	ErrInvalidGoal         = fmt.Errorf("%v: goal must not be negative", Err)
+	ErrInvalidDeadline     = fmt.Errorf("%v: deadline must be in the future", Err)
 )
 
+// ErrInvalidTag — тег не является категорией или эмодзи.
+type ErrInvalidTag struct {
+	tag string
+}
+
+func (e ErrInvalidTag) Error() string {
+	return fmt.Sprintf("%v: invalid tag %q", Err, e.tag)
+}
+
+func NewErrInvalidTag(tag string) error {
+	return ErrInvalidTag{tag: tag}
+}
+
+// ErrInvalidBatch — ошибки отдельных переводов пакета по их индексам, пакет не проведён.
+type ErrInvalidBatch struct {
+	Legs map[int]error
+}
+
+func (e ErrInvalidBatch) Error() string {
+	return fmt.Sprintf("%v: %d of batch transfers are invalid", Err, len(e.Legs))
+}
+
+func NewErrInvalidBatch(legs map[int]error) error {
+	return ErrInvalidBatch{Legs: legs}
+}
+
+// PolicyViolation — нарушение правила перевода с машиночитаемым кодом.
+type PolicyViolation interface {
+	error
+	Code() string
+}
+
+// RateLimited — нарушение, которое пройдёт само через RetryAfter.
+type RateLimited interface {
+	PolicyViolation
+	RetryAfter() time.Duration
+}
+
+// ErrAmountTooSmall — сумма перевода меньше минимальной.
+type ErrAmountTooSmall struct {
+	min int
+}
+
+func (e ErrAmountTooSmall) Error() string {
+	return fmt.Sprintf("%v: amount must be at least %d", Err, e.min)
+}
+
+func (e ErrAmountTooSmall) Code() string { return "amount_too_small" }
+
+func NewErrAmountTooSmall(minAmount int) error {
+	return ErrAmountTooSmall{min: minAmount}
+}
+
+// ErrAmountTooLarge — сумма перевода больше максимальной.
+type ErrAmountTooLarge struct {
+	max int
+}
+
+func (e ErrAmountTooLarge) Error() string {
+	return fmt.Sprintf("%v: amount must be at most %d", Err, e.max)
+}
+
+func (e ErrAmountTooLarge) Code() string { return "amount_too_large" }
+
+func NewErrAmountTooLarge(maxAmount int) error {
+	return ErrAmountTooLarge{max: maxAmount}
+}
+
+// ErrCapExceeded — превышен лимит исходящих переводов за период.
+type ErrCapExceeded struct {
+	period     Period
+	cap        int
+	remaining  int
+	retryAfter time.Duration
+}
+
+func (e ErrCapExceeded) Error() string {
+	return fmt.Sprintf("%v: %s transfer cap of %d exceeded, %d left", Err, e.period, e.cap, e.remaining)
+}
+
+func (e ErrCapExceeded) Code() string { return string(e.period) + "_cap_exceeded" }
+
+func (e ErrCapExceeded) RetryAfter() time.Duration { return e.retryAfter }
+
+func NewErrCapExceeded(period Period, limit, remaining int, retryAfter time.Duration) error {
+	return ErrCapExceeded{period: period, cap: limit, remaining: remaining, retryAfter: retryAfter}
+}
+
+// ErrRecipientCapExceeded — превышен дневной лимит переводов одному получателю.
+type ErrRecipientCapExceeded struct {
+	recipient  string
+	cap        int
+	remaining  int
+	retryAfter time.Duration
+}
+
+func (e ErrRecipientCapExceeded) Error() string {
+	return fmt.Sprintf("%v: daily cap of %d for recipient %q exceeded, %d left", Err, e.cap, e.recipient, e.remaining)
+}
+
+func (e ErrRecipientCapExceeded) Code() string { return "recipient_cap_exceeded" }
+
+func (e ErrRecipientCapExceeded) RetryAfter() time.Duration { return e.retryAfter }
+
+func NewErrRecipientCapExceeded(recipient string, limit, remaining int, retryAfter time.Duration) error {
+	return ErrRecipientCapExceeded{recipient: recipient, cap: limit, remaining: remaining, retryAfter: retryAfter}
+}
+
+// ErrCooldown — перевод раньше, чем истёк интервал после предыдущего.
+type ErrCooldown struct {
+	retryAfter time.Duration
+}
+
+func (e ErrCooldown) Error() string {
+	return fmt.Sprintf("%v: transfer cooldown, retry after %s", Err, e.retryAfter.Round(time.Second))
+}
+
+func (e ErrCooldown) Code() string { return "cooldown" }
+
+func (e ErrCooldown) RetryAfter() time.Duration { return e.retryAfter }
+
+func NewErrCooldown(retryAfter time.Duration) error {
+	return ErrCooldown{retryAfter: retryAfter}
+}
+
+// ErrInvalidTransactionID — в пути запроса недопустимый ID транзакции.
 type ErrInvalidTransactionID struct {
-	itemID int64
+	id int64
 }
 
 func (e ErrInvalidTransactionID) Error() string {
-	return fmt.Sprintf("%v: недопустимый transaction %d: должен быть > 0", Err, e.itemID)
+	return fmt.Sprintf("%v: недопустимый ID транзакции %d: должен быть > 0", Err, e.id)
+}
+
+func NewErrInvalidTransactionID(id int64) error {
+	return &ErrInvalidTransactionID{id: id}
+}
+
+// ErrInvalidPoolID — в пути запроса недопустимый ID сбора.
+type ErrInvalidPoolID struct {
+	id int64
+}
+
+func (e ErrInvalidPoolID) Error() string {
+	return fmt.Sprintf("%v: недопустимый ID сбора %d: должен быть > 0", Err, e.id)
+}
+
+func NewErrInvalidPoolID(id int64) error {
+	return &ErrInvalidPoolID{id: id}
+}
+
+// ErrInvalidFilter — недопустимый параметр фильтра истории.
+type ErrInvalidFilter struct {
+	field string
+}
+
+func (e ErrInvalidFilter) Error() string {
+	return fmt.Sprintf("%v: invalid history filter %q", Err, e.field)
 }
 
-func NewErrInvalidItemID(itemID int64) error {
-	return &ErrInvalidTransactionID{itemID: itemID}
+func NewErrInvalidFilter(field string) error {
+	return ErrInvalidFilter{field: field}
 }
@@ -0,0 +1,104 @@
+package coin
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/scheduler"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GrantJob — задача планировщика, начисляющая монеты сотрудникам.
+const GrantJob scheduler.Kind = "grant"
+
+// GrantParams — параметры задачи GrantJob.
+type GrantParams struct {
+	// Amount — сколько монет начислить каждому сотруднику.
+	Amount int `json:"amount"`
+	// ActiveDays — начислять только тем, кто входил в систему за последние ActiveDays дней, 0 — всем.
+	ActiveDays int `json:"activeDays"`
+}
+
+// ScheduledGrant — начисление пользователю по задаче за период.
+type ScheduledGrant struct {
+	JobID       scheduler.JobID
+	Period      time.Time
+	Transaction *Transaction
+}
+
+type GrantRepo interface {
+	// ListGrantRecipients возвращает сотрудников, входивших в систему после activeSince,
+	// нулевой activeSince — всех сотрудников.
+	ListGrantRecipients(ctx context.Context, activeSince time.Time) ([]*auth.User, error)
+	// SaveScheduledGrant проводит начисление, если пользователь ещё не получил его за этот
+	// период, иначе ErrAlreadyGranted.
+	SaveScheduledGrant(ctx context.Context, grant *ScheduledGrant) error
+}
+
+type grantRunner struct {
+	repo GrantRepo
+}
+
+// NewGrantRunner создаёт исполнитель задач GrantJob. Повторный запуск за тот же период
+// начисляет только тем, кто не получил монеты в прошлый раз.
+func NewGrantRunner(repo GrantRepo) scheduler.Runner {
+	return &grantRunner{repo: repo}
+}
+
+func (r *grantRunner) Validate(raw json.RawMessage) error {
+	_, err := parseGrantParams(raw)
+	return err
+}
+
+func (r *grantRunner) Run(ctx context.Context, job *scheduler.Job, period time.Time) (string, error) {
+	params, err := parseGrantParams(job.Params)
+	if err != nil {
+		return "", err
+	}
+	var activeSince time.Time
+	if params.ActiveDays > 0 {
+		activeSince = period.AddDate(0, 0, -params.ActiveDays)
+	}
+	users, err := r.repo.ListGrantRecipients(ctx, activeSince)
+	if err != nil {
+		return "", err
+	}
+	var granted, skipped int
+	for _, user := range users {
+		err := r.repo.SaveScheduledGrant(ctx, &ScheduledGrant{
+			JobID:  job.ID,
+			Period: period,
+			Transaction: &Transaction{
+				ToUser: user,
+				Amount: params.Amount,
+				Type:   Grant,
+				Note:   Note{Message: job.Name},
+			},
+		})
+		switch {
+		case errors.Is(err, ErrAlreadyGranted):
+			skipped++
+		case err != nil:
+			return fmt.Sprintf("granted %d users before failure", granted), err
+		default:
+			granted++
+		}
+	}
+	return fmt.Sprintf("granted %d coins to %d users, %d already granted", params.Amount, granted, skipped), nil
+}
+
+func parseGrantParams(raw json.RawMessage) (GrantParams, error) {
+	var params GrantParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return params, scheduler.NewErrInvalidParams(err.Error())
+	}
+	if params.Amount <= 0 {
+		return params, scheduler.NewErrInvalidParams("amount must be positive")
+	}
+	if params.ActiveDays < 0 {
+		return params, scheduler.NewErrInvalidParams("activeDays must not be negative")
+	}
+	return params, nil
+}
@@ -0,0 +1,71 @@
+package coin
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/scheduler"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type grantKey struct {
+	period time.Time
+	userID auth.UserID
+}
+
+// fakeGrantRepo хранит начисления в памяти.
+type fakeGrantRepo struct {
+	users       []*auth.User
+	activeSince time.Time
+	grants      map[grantKey]int
+}
+
+func (r *fakeGrantRepo) ListGrantRecipients(_ context.Context, activeSince time.Time) ([]*auth.User, error) {
+	r.activeSince = activeSince
+	return r.users, nil
+}
+
+func (r *fakeGrantRepo) SaveScheduledGrant(_ context.Context, grant *ScheduledGrant) error {
+	key := grantKey{grant.Period, grant.Transaction.ToUser.ID}
+	if _, ok := r.grants[key]; ok {
+		return ErrAlreadyGranted
+	}
+	r.grants[key] = grant.Transaction.Amount
+	return nil
+}
+
+func TestGrantRunner_Validate(t *testing.T) {
+	runner := NewGrantRunner(&fakeGrantRepo{})
+	assert.NoError(t, runner.Validate(json.RawMessage(`{"amount":200,"activeDays":90}`)))
+	assert.NoError(t, runner.Validate(json.RawMessage(`{"amount":200}`)))
+	assert.Error(t, runner.Validate(json.RawMessage(`{"amount":0}`)))
+	assert.Error(t, runner.Validate(json.RawMessage(`{"amount":10,"activeDays":-1}`)))
+	assert.Error(t, runner.Validate(json.RawMessage(`{"amount":"10"}`)))
+}
+
+func TestGrantRunner_Run(t *testing.T) {
+	repo := &fakeGrantRepo{
+		users:  []*auth.User{{ID: 1}, {ID: 2}},
+		grants: make(map[grantKey]int),
+	}
+	runner := NewGrantRunner(repo)
+	period := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	job := &scheduler.Job{ID: 1, Name: "monthly-allowance", Kind: GrantJob, Params: json.RawMessage(`{"amount":200,"activeDays":30}`)}
+
+	result, err := runner.Run(context.Background(), job, period)
+	require.NoError(t, err)
+	assert.Equal(t, "granted 200 coins to 2 users, 0 already granted", result)
+	assert.Equal(t, period.AddDate(0, 0, -30), repo.activeSince)
+	assert.Equal(t, 200, repo.grants[grantKey{period, 2}])
+
+	// повтор за тот же период начисляет только новым сотрудникам.
+	repo.users = append(repo.users, &auth.User{ID: 3})
+	result, err = runner.Run(context.Background(), job, period)
+	require.NoError(t, err)
+	assert.Equal(t, "granted 200 coins to 1 users, 2 already granted", result)
+	assert.Len(t, repo.grants, 3)
+}
@@ -4,41 +4,73 @@ import (
 	"avito-intern/internal/auth"
 	"context"
 	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type Service interface {
 	GetUserByUsername(ctx context.Context, username string) (*auth.User, error)
-	Transfer(ctx context.Context, from, to *auth.User, amount int) (*Transaction, error)
+	Transfer(ctx context.Context, from, to *auth.User, amount int, note Note) (*Transaction, error)
+	TransferPending(ctx context.Context, from, to *auth.User, amount int, note Note) (*Transaction, error)
+	Accept(ctx context.Context, user *auth.User, id TransactionID) (*Transaction, error)
+	Decline(ctx context.Context, user *auth.User, id TransactionID) (*Transaction, error)
+	ListPending(ctx context.Context, user *auth.User) (incoming, outgoing []*Transaction, err error)
+	RunExpiry(ctx context.Context)
+	TransferBatch(ctx context.Context, from *auth.User, legs []TransferLeg, note Note) (*Batch, error)
 	Purchase(ctx context.Context, buyer *auth.User, amount int) (*Transaction, error)
 	ListTransfers(ctx context.Context, user *auth.User) (incoming, outgoing []*Transaction, err error)
+	GetBalance(ctx context.Context, user *auth.User) (int, error)
+	ExpiringCoins(ctx context.Context, user *auth.User) ([]ExpiringCoins, error)
+	RunCoinExpiry(ctx context.Context)
+	History(ctx context.Context, user *auth.User, filter HistoryFilter) (*HistoryPage, error)
+	Reverse(ctx context.Context, admin *auth.User, id TransactionID, amount int, reason string) (*ReversalRecord, error)
+	CreatePool(ctx context.Context, organizer, recipient *auth.User, title string, goal int, deadline time.Time) (*Pool, error)
+	GetPool(ctx context.Context, user *auth.User, id PoolID) (*Pool, []*Transaction, error)
+	ListPools(ctx context.Context, user *auth.User) ([]*Pool, error)
+	Contribute(ctx context.Context, user *auth.User, id PoolID, amount int, note Note) (*Transaction, error)
+	ClosePool(ctx context.Context, user *auth.User, id PoolID) (*Pool, error)
+	CancelPool(ctx context.Context, user *auth.User, id PoolID) (*Pool, error)
 }
 
 type Handler struct {
 	svc          Service
 	authHandlers AuthHandler
+	idempotency  IdempotencyHandler
 }
 
 type AuthHandler interface {
 	Verify(c *fiber.Ctx) error
+	RequireRole(roles ...auth.Role) fiber.Handler
 }
 
-func NewCoinHandler(svc Service, authHandler AuthHandler) *Handler {
+type IdempotencyHandler interface {
+	Handle(c *fiber.Ctx) error
+}
+
+func NewCoinHandler(svc Service, authHandler AuthHandler, idempotencyHandler IdempotencyHandler) *Handler {
 	return &Handler{
 		svc:          svc,
 		authHandlers: authHandler,
+		idempotency:  idempotencyHandler,
 	}
 }
 
 type ReceivedTx struct {
 	FromUser string `json:"fromUser"`
 	Amount   int    `json:"amount"`
+	Message  string `json:"message,omitempty"`
+	Tags     []Tag  `json:"tags,omitempty"`
 }
 
 type SentTx struct {
 	FromUser string `json:"fromUser"`
 	Amount   int    `json:"amount"`
+	Message  string `json:"message,omitempty"`
+	Tags     []Tag  `json:"tags,omitempty"`
 }
 type History struct {
 	Received []ReceivedTx `json:"received"`
@@ -53,10 +85,144 @@ type InfoResponse struct {
 type SendCoinRequest struct {
 	ToUsername string `json:"toUser"`
 	Amount     int    `json:"amount"`
+	// Message — необязательная благодарность получателю.
+	Message string   `json:"message,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	// RequireAccept — перевод с подтверждением: монеты ждут в escrow, пока получатель его не примет.
+	RequireAccept bool `json:"requireAccept,omitempty"`
 }
 
 func (h *Handler) Init(router fiber.Router) {
-	router.Post("/sendCoin", h.authHandlers.Verify, h.sendCoin)
+	router.Post("/sendCoin", h.authHandlers.Verify, h.idempotency.Handle, h.sendCoin)
+	router.Post("/sendCoin/batch", h.authHandlers.Verify, h.idempotency.Handle, h.sendCoinBatch)
+	router.Get("/transactions", h.authHandlers.Verify, h.transactions)
+	router.Get("/transfers/pending", h.authHandlers.Verify, h.pending)
+	router.Post("/transfers/:id/accept", h.authHandlers.Verify, h.idempotency.Handle, h.accept)
+	router.Post("/transfers/:id/decline", h.authHandlers.Verify, h.idempotency.Handle, h.decline)
+	router.Post("/pools", h.authHandlers.Verify, h.idempotency.Handle, h.createPool)
+	router.Get("/pools", h.authHandlers.Verify, h.listPools)
+	router.Get("/pools/:id", h.authHandlers.Verify, h.getPool)
+	router.Post("/pools/:id/contribute", h.authHandlers.Verify, h.idempotency.Handle, h.contribute)
+	router.Post("/pools/:id/close", h.authHandlers.Verify, h.idempotency.Handle, h.closePool)
+	router.Post("/pools/:id/cancel", h.authHandlers.Verify, h.idempotency.Handle, h.cancelPool)
+	router.Post("/admin/transactions/:id/reverse",
+		h.authHandlers.Verify, h.authHandlers.RequireRole(auth.RoleHRAdmin), h.idempotency.Handle, h.reverse)
+}
+
+// HistoryItem — транзакция в истории пользователя.
+type HistoryItem struct {
+	ID           TransactionID `json:"id"`
+	Type         Type          `json:"type"`
+	Direction    Direction     `json:"direction"`
+	Amount       int           `json:"amount"`
+	BatchID      BatchID       `json:"batchId,omitempty"`
+	Reverses     *int64        `json:"reverses,omitempty"`
+	PoolID       PoolID        `json:"poolId,omitempty"`
+	Status       Status        `json:"status,omitempty"`
+	Counterparty string        `json:"counterparty,omitempty"`
+	Message      string        `json:"message,omitempty"`
+	Tags         []Tag         `json:"tags,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+type HistoryResponse struct {
+	Items      []HistoryItem `json:"items"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// transactions Постраничная история транзакций пользователя.
+//
+//	GET /api/transactions?direction=in&type=transfer,grant&from=2025-01-01T00:00:00Z&to=...
+//	    &counterparty=alice&order=asc&limit=50&cursor=...
+func (h *Handler) transactions(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+
+	page, err := h.svc.History(ctx, user, filter)
+	if err != nil {
+		var filterErr ErrInvalidFilter
+		if errors.As(err, &filterErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+
+	items := make([]HistoryItem, len(page.Entries))
+	for i, e := range page.Entries {
+		items[i] = HistoryItem{
+			ID:        e.ID,
+			Type:      e.Type,
+			Direction: e.Direction,
+			Amount:    e.Amount,
+			BatchID:   e.BatchID,
+			Reverses:  e.PrevTransaction,
+			PoolID:    e.PoolID,
+			Status:    e.Status,
+			Message:   e.Message,
+			Tags:      e.Tags,
+			CreatedAt: e.CreatedAt,
+		}
+		switch {
+		case e.Direction == Incoming && e.FromUser != nil:
+			items[i].Counterparty = e.FromUser.Username
+		case e.Direction == Outgoing && e.ToUser != nil:
+			items[i].Counterparty = e.ToUser.Username
+		}
+	}
+	return c.JSON(HistoryResponse{
+		Items:      items,
+		NextCursor: page.NextCursor.String(),
+	})
+}
+
+func parseHistoryFilter(c *fiber.Ctx) (HistoryFilter, error) {
+	filter := HistoryFilter{
+		Direction:    Direction(c.Query("direction")),
+		Counterparty: c.Query("counterparty"),
+		Order:        SortOrder(c.Query("order")),
+	}
+	if raw := c.Query("type"); raw != "" {
+		for _, t := range strings.Split(raw, ",") {
+			filter.Types = append(filter.Types, Type(t))
+		}
+	}
+	for name, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := c.Query(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return filter, NewErrInvalidFilter(name)
+			}
+			*dst = t
+		}
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return filter, NewErrInvalidFilter("limit")
+		}
+		filter.Limit = limit
+	}
+	cursor, err := ParseCursor(c.Query("cursor"))
+	if err != nil {
+		return filter, err
+	}
+	filter.After = cursor
+	return filter, nil
 }
 
 func (h *Handler) sendCoin(c *fiber.Ctx) error {
@@ -73,6 +239,12 @@ func (h *Handler) sendCoin(c *fiber.Ctx) error {
 			"errors": err.Error(),
 		})
 	}
+	note, err := NewNote(coinReq.Message, coinReq.Tags)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
 	to, err := h.svc.GetUserByUsername(ctx, coinReq.ToUsername)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -80,9 +252,20 @@ func (h *Handler) sendCoin(c *fiber.Ctx) error {
 		})
 	}
 
-	_, err = h.svc.Transfer(ctx, from, to, coinReq.Amount)
+	transfer := h.svc.Transfer
+	if coinReq.RequireAccept {
+		transfer = h.svc.TransferPending
+	}
+	tx, err := transfer(ctx, from, to, coinReq.Amount, note)
 	if err != nil {
-		if errors.Is(err, ErrInvalidRecipient) || errors.Is(err, ErrNotEnoughCoins) {
+		var violation PolicyViolation
+		if errors.As(err, &violation) {
+			return c.Status(violationStatus(c, err)).JSON(fiber.Map{
+				"errors": err.Error(),
+				"code":   violation.Code(),
+			})
+		}
+		if errors.Is(err, ErrInvalidRecipient) || errors.Is(err, ErrNotEnoughCoins) || errors.Is(err, ErrInvalidAmount) {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"errors": err.Error(),
 			})
@@ -91,6 +274,554 @@ func (h *Handler) sendCoin(c *fiber.Ctx) error {
 			"errors": err.Error(),
 		})
 	}
+	if coinReq.RequireAccept {
+		return c.Status(fiber.StatusAccepted).JSON(newPendingTransfer(tx))
+	}
 	c.Status(fiber.StatusOK)
 	return nil
 }
+
+type BatchTransferRequest struct {
+	ToUsername string `json:"toUser"`
+	Amount     int    `json:"amount"`
+}
+
+type SendCoinBatchRequest struct {
+	Transfers []BatchTransferRequest `json:"transfers"`
+	Message   string                 `json:"message"`
+	Tags      []string               `json:"tags"`
+}
+
+// BatchTransferResult — результат перевода одному получателю: ID транзакции или ошибка.
+type BatchTransferResult struct {
+	ToUsername    string        `json:"toUser"`
+	Amount        int           `json:"amount"`
+	TransactionID TransactionID `json:"transactionId,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+type SendCoinBatchResponse struct {
+	BatchID BatchID               `json:"batchId,omitempty"`
+	Total   int                   `json:"total"`
+	Results []BatchTransferResult `json:"results"`
+	Errors  string                `json:"errors,omitempty"`
+	Code    string                `json:"code,omitempty"`
+}
+
+// sendCoinBatch Перевод монет нескольким получателям одним запросом: проводятся все переводы или ни один.
+func (h *Handler) sendCoinBatch(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	from, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	var req SendCoinBatchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	if len(req.Transfers) == 0 || len(req.Transfers) > MaxBatchSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": ErrEmptyBatch.Error(),
+		})
+	}
+	note, err := NewNote(req.Message, req.Tags)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+
+	resp := SendCoinBatchResponse{Results: make([]BatchTransferResult, len(req.Transfers))}
+	legs := make([]TransferLeg, len(req.Transfers))
+	for i, t := range req.Transfers {
+		resp.Results[i] = BatchTransferResult{ToUsername: t.ToUsername, Amount: t.Amount}
+		resp.Total += t.Amount
+		to, err := h.svc.GetUserByUsername(ctx, t.ToUsername)
+		if err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		}
+		// неизвестный получатель остаётся nil, сервис вернёт ошибку для него вместе с остальными.
+		legs[i] = TransferLeg{To: to, Amount: t.Amount}
+	}
+
+	batch, err := h.svc.TransferBatch(ctx, from, legs, note)
+	if err != nil {
+		var (
+			batchErr  ErrInvalidBatch
+			violation PolicyViolation
+		)
+		status := fiber.StatusBadRequest
+		switch {
+		case errors.As(err, &batchErr):
+			for i, legErr := range batchErr.Legs {
+				resp.Results[i].Error = legErr.Error()
+			}
+		case errors.As(err, &violation):
+			resp.Code = violation.Code()
+			status = violationStatus(c, err)
+		case errors.Is(err, ErrNotEnoughCoins), errors.Is(err, ErrEmptyBatch):
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		}
+		resp.Errors = err.Error()
+		return c.Status(status).JSON(resp)
+	}
+
+	resp.BatchID = batch.ID
+	for i, t := range batch.Transfers {
+		resp.Results[i].TransactionID = t.ID
+	}
+	return c.JSON(resp)
+}
+
+// violationStatus возвращает статус для нарушения правила перевода:
+// 429 с Retry-After в целых секундах для лимитов, которые пройдут со временем, иначе 400.
+func violationStatus(c *fiber.Ctx, err error) int {
+	var limited RateLimited
+	if !errors.As(err, &limited) {
+		return fiber.StatusBadRequest
+	}
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(limited.RetryAfter().Seconds()))))
+	return fiber.StatusTooManyRequests
+}
+
+type ReverseRequest struct {
+	// Amount — сумма возврата, 0 — весь невозвращённый остаток перевода.
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+type ReverseResponse struct {
+	TransactionID TransactionID `json:"transactionId"`
+	Reverses      TransactionID `json:"reverses"`
+	Amount        int           `json:"amount"`
+	Requested     int           `json:"requested"`
+	Remaining     int           `json:"remaining"`
+	Partial       bool          `json:"partial"`
+}
+
+// reverse Возврат перевода отправителю администратором.
+func (h *Handler) reverse(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	admin, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": NewErrInvalidTransactionID(int64(id)).Error(),
+		})
+	}
+	var req ReverseRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+
+	reversal, err := h.svc.Reverse(ctx, admin, TransactionID(id), req.Amount, req.Reason)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrTransactionNotFound):
+			status = fiber.StatusNotFound
+		case errors.Is(err, ErrAlreadyReversed), errors.Is(err, ErrNothingToReverse):
+			status = fiber.StatusConflict
+		case errors.Is(err, ErrNotReversible), errors.Is(err, ErrReversalTooLarge),
+			errors.Is(err, ErrInvalidReason), errors.Is(err, ErrInvalidAmount):
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+
+	return c.JSON(ReverseResponse{
+		TransactionID: reversal.Transaction.ID,
+		Reverses:      reversal.Original.ID,
+		Amount:        reversal.Transaction.Amount,
+		Requested:     reversal.Requested,
+		Remaining:     reversal.Remaining,
+		Partial:       reversal.Partial(),
+	})
+}
+
+// PendingTransfer — перевод, ожидающий решения получателя.
+type PendingTransfer struct {
+	ID        TransactionID `json:"id"`
+	FromUser  string        `json:"fromUser"`
+	ToUser    string        `json:"toUser"`
+	Amount    int           `json:"amount"`
+	Status    Status        `json:"status"`
+	Message   string        `json:"message,omitempty"`
+	Tags      []Tag         `json:"tags,omitempty"`
+	CreatedAt time.Time     `json:"createdAt"`
+	ExpiresAt time.Time     `json:"expiresAt"`
+}
+
+func newPendingTransfer(t *Transaction) PendingTransfer {
+	p := PendingTransfer{
+		ID:        t.ID,
+		Amount:    t.Amount,
+		Status:    t.Status,
+		Message:   t.Message,
+		Tags:      t.Tags,
+		CreatedAt: t.CreatedAt,
+		ExpiresAt: t.ExpiresAt,
+	}
+	if t.FromUser != nil {
+		p.FromUser = t.FromUser.Username
+	}
+	if t.ToUser != nil {
+		p.ToUser = t.ToUser.Username
+	}
+	return p
+}
+
+type PendingResponse struct {
+	Incoming []PendingTransfer `json:"incoming"`
+	Outgoing []PendingTransfer `json:"outgoing"`
+}
+
+// pending Ожидающие переводы пользователю и от него.
+func (h *Handler) pending(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	incoming, outgoing, err := h.svc.ListPending(ctx, user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	resp := PendingResponse{
+		Incoming: make([]PendingTransfer, len(incoming)),
+		Outgoing: make([]PendingTransfer, len(outgoing)),
+	}
+	for i, t := range incoming {
+		resp.Incoming[i] = newPendingTransfer(t)
+	}
+	for i, t := range outgoing {
+		resp.Outgoing[i] = newPendingTransfer(t)
+	}
+	return c.JSON(resp)
+}
+
+// accept Получатель принимает перевод.
+func (h *Handler) accept(c *fiber.Ctx) error {
+	return h.settle(c, h.svc.Accept)
+}
+
+// decline Получатель отклоняет перевод, монеты возвращаются отправителю.
+func (h *Handler) decline(c *fiber.Ctx) error {
+	return h.settle(c, h.svc.Decline)
+}
+
+func (h *Handler) settle(c *fiber.Ctx, settle func(context.Context, *auth.User, TransactionID) (*Transaction, error)) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": NewErrInvalidTransactionID(int64(id)).Error(),
+		})
+	}
+
+	tx, err := settle(ctx, user, TransactionID(id))
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrTransactionNotFound):
+			status = fiber.StatusNotFound
+		case errors.Is(err, ErrNotRecipient):
+			status = fiber.StatusForbidden
+		case errors.Is(err, ErrNotPending):
+			status = fiber.StatusConflict
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	return c.JSON(newPendingTransfer(tx))
+}
+
+type CreatePoolRequest struct {
+	Recipient string `json:"recipient"`
+	Title     string `json:"title"`
+	// Goal — желаемая сумма, 0 — без цели.
+	Goal     int        `json:"goal,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+type ContributeRequest struct {
+	Amount  int      `json:"amount"`
+	Message string   `json:"message,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// PoolContributionItem — взнос в сбор.
+type PoolContributionItem struct {
+	ID        TransactionID `json:"id"`
+	FromUser  string        `json:"fromUser"`
+	Amount    int           `json:"amount"`
+	Message   string        `json:"message,omitempty"`
+	Tags      []Tag         `json:"tags,omitempty"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+type PoolResponse struct {
+	ID            PoolID                 `json:"id"`
+	Organizer     string                 `json:"organizer"`
+	Recipient     string                 `json:"recipient"`
+	Title         string                 `json:"title"`
+	Goal          int                    `json:"goal,omitempty"`
+	Deadline      *time.Time             `json:"deadline,omitempty"`
+	Status        PoolStatus             `json:"status"`
+	Collected     int                    `json:"collected"`
+	Contributors  int                    `json:"contributors"`
+	CreatedAt     time.Time              `json:"createdAt"`
+	ClosedAt      *time.Time             `json:"closedAt,omitempty"`
+	Contributions []PoolContributionItem `json:"contributions,omitempty"`
+}
+
+func newPoolResponse(p *Pool) PoolResponse {
+	resp := PoolResponse{
+		ID:           p.ID,
+		Organizer:    p.Organizer.Username,
+		Recipient:    p.Recipient.Username,
+		Title:        p.Title,
+		Goal:         p.Goal,
+		Status:       p.Status,
+		Collected:    p.Collected,
+		Contributors: p.Contributors,
+		CreatedAt:    p.CreatedAt,
+	}
+	if !p.Deadline.IsZero() {
+		resp.Deadline = &p.Deadline
+	}
+	if !p.ClosedAt.IsZero() {
+		resp.ClosedAt = &p.ClosedAt
+	}
+	return resp
+}
+
+type PoolsResponse struct {
+	Pools []PoolResponse `json:"pools"`
+}
+
+// poolErrorStatus возвращает HTTP-статус для ошибки операции со сбором.
+func poolErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrPoolNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, ErrNotOrganizer):
+		return fiber.StatusForbidden
+	case errors.Is(err, ErrPoolClosed), errors.Is(err, ErrPoolEmpty):
+		return fiber.StatusConflict
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, ErrInvalidRecipient),
+		errors.Is(err, ErrPoolRecipient), errors.Is(err, ErrInvalidPoolTitle),
+		errors.Is(err, ErrInvalidGoal), errors.Is(err, ErrInvalidDeadline),
+		errors.Is(err, ErrInvalidAmount), errors.Is(err, ErrNotEnoughCoins):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// createPool Открывает сбор в подарок сотруднику.
+func (h *Handler) createPool(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	organizer, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	var req CreatePoolRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	var deadline time.Time
+	if req.Deadline != nil {
+		deadline = *req.Deadline
+	}
+
+	recipient, err := h.svc.GetUserByUsername(ctx, req.Recipient)
+	if err != nil {
+		return c.Status(poolErrorStatus(err)).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	pool, err := h.svc.CreatePool(ctx, organizer, recipient, req.Title, req.Goal, deadline)
+	if err != nil {
+		return c.Status(poolErrorStatus(err)).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(newPoolResponse(pool))
+}
+
+// listPools Сборы, которые пользователь организовал или в которые внёс монеты.
+func (h *Handler) listPools(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	pools, err := h.svc.ListPools(ctx, user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	resp := PoolsResponse{Pools: make([]PoolResponse, len(pools))}
+	for i, p := range pools {
+		resp.Pools[i] = newPoolResponse(p)
+	}
+	return c.JSON(resp)
+}
+
+// getPool Сбор со списком взносов. Виден организатору, получателю, участникам и hr-admin.
+func (h *Handler) getPool(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": NewErrInvalidPoolID(int64(id)).Error(),
+		})
+	}
+	pool, contributions, err := h.svc.GetPool(ctx, user, PoolID(id))
+	if err != nil {
+		return c.Status(poolErrorStatus(err)).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	resp := newPoolResponse(pool)
+	resp.Contributions = make([]PoolContributionItem, len(contributions))
+	for i, t := range contributions {
+		resp.Contributions[i] = PoolContributionItem{
+			ID:        t.ID,
+			FromUser:  t.FromUser.Username,
+			Amount:    t.Amount,
+			Message:   t.Message,
+			Tags:      t.Tags,
+			CreatedAt: t.CreatedAt,
+		}
+	}
+	return c.JSON(resp)
+}
+
+// contribute Взнос в сбор.
+func (h *Handler) contribute(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": NewErrInvalidPoolID(int64(id)).Error(),
+		})
+	}
+	var req ContributeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	note, err := NewNote(req.Message, req.Tags)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+
+	t, err := h.svc.Contribute(ctx, user, PoolID(id), req.Amount, note)
+	if err != nil {
+		var violation PolicyViolation
+		if errors.As(err, &violation) {
+			return c.Status(violationStatus(c, err)).JSON(fiber.Map{
+				"errors": err.Error(),
+				"code":   violation.Code(),
+			})
+		}
+		return c.Status(poolErrorStatus(err)).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(PoolContributionItem{
+		ID:        t.ID,
+		FromUser:  user.Username,
+		Amount:    t.Amount,
+		Message:   t.Message,
+		Tags:      t.Tags,
+		CreatedAt: t.CreatedAt,
+	})
+}
+
+// closePool Организатор закрывает сбор, собранное выплачивается получателю.
+func (h *Handler) closePool(c *fiber.Ctx) error {
+	return h.settlePool(c, h.svc.ClosePool)
+}
+
+// cancelPool Организатор отменяет сбор, взносы возвращаются участникам.
+func (h *Handler) cancelPool(c *fiber.Ctx) error {
+	return h.settlePool(c, h.svc.CancelPool)
+}
+
+func (h *Handler) settlePool(c *fiber.Ctx, settle func(context.Context, *auth.User, PoolID) (*Pool, error)) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": NewErrInvalidPoolID(int64(id)).Error(),
+		})
+	}
+
+	pool, err := settle(ctx, user, PoolID(id))
+	if err != nil {
+		return c.Status(poolErrorStatus(err)).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	return c.JSON(newPoolResponse(pool))
+}
@@ -0,0 +1,33 @@
+package coin
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViolationStatus(t *testing.T) {
+	tests := []struct {
+		err        error
+		status     int
+		retryAfter string
+	}{
+		{NewErrAmountTooLarge(100), fiber.StatusBadRequest, ""},
+		{NewErrCapExceeded(Daily, 100, 0, 90*time.Minute+time.Millisecond), fiber.StatusTooManyRequests, "5401"},
+		{NewErrCooldown(1500 * time.Millisecond), fiber.StatusTooManyRequests, "2"},
+	}
+	for _, tt := range tests {
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendStatus(violationStatus(c, tt.err))
+		})
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+		require.NoError(t, err)
+		assert.Equal(t, tt.status, resp.StatusCode, tt.err.Error())
+		assert.Equal(t, tt.retryAfter, resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+}
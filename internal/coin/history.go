@@ -0,0 +1,117 @@
+package coin
+
+import (
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultHistoryLimit — размер страницы истории по умолчанию.
+	DefaultHistoryLimit = 20
+	// MaxHistoryLimit — максимальный размер страницы истории.
+	MaxHistoryLimit = 100
+)
+
+// Direction — направление движения монет относительно пользователя.
+type Direction string
+
+const (
+	Incoming Direction = "in"
+	Outgoing Direction = "out"
+)
+
+// SortOrder — порядок истории: от новых к старым или наоборот.
+type SortOrder string
+
+const (
+	NewestFirst SortOrder = "desc"
+	OldestFirst SortOrder = "asc"
+)
+
+// Cursor указывает на последнюю проводку предыдущей страницы. Для клиента он непрозрачен.
+type Cursor int64
+
+func (c Cursor) String() string {
+	if c == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(int64(c), 10)))
+}
+
+// ParseCursor разбирает курсор, пустая строка — первая страница.
+func ParseCursor(raw string) (Cursor, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(decoded), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return Cursor(id), nil
+}
+
+// HistoryFilter — условия выборки истории пользователя. Пустые поля не ограничивают выборку.
+type HistoryFilter struct {
+	Direction Direction
+	Types     []Type
+	From      time.Time
+	To        time.Time
+	// Counterparty — имя второго участника перевода.
+	Counterparty string
+	Order        SortOrder
+	After        Cursor
+	Limit        int
+}
+
+// HistoryEntry — транзакция с точки зрения пользователя: проводка по его счёту.
+// Amount транзакции всегда положительный, направление задаёт Direction.
+type HistoryEntry struct {
+	EntryID   int64
+	Direction Direction
+	Transaction
+}
+
+// HistoryPage — страница истории и курсор следующей страницы, пустой на последней.
+type HistoryPage struct {
+	Entries    []*HistoryEntry
+	NextCursor Cursor
+}
+
+// Validate проверяет фильтр и подставляет значения по умолчанию.
+func (f *HistoryFilter) Validate() error {
+	switch f.Direction {
+	case "", Incoming, Outgoing:
+	default:
+		return NewErrInvalidFilter("direction")
+	}
+	for _, t := range f.Types {
+		switch t {
+		case Transfer, Purchase, Grant, Reversal, Expiry, Adjustment,
+			PoolContribution, PoolPayout, PoolRefund:
+		default:
+			return NewErrInvalidFilter("type")
+		}
+	}
+	switch f.Order {
+	case "":
+		f.Order = NewestFirst
+	case NewestFirst, OldestFirst:
+	default:
+		return NewErrInvalidFilter("order")
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && f.To.Before(f.From) {
+		return NewErrInvalidFilter("to")
+	}
+	switch {
+	case f.Limit == 0:
+		f.Limit = DefaultHistoryLimit
+	case f.Limit < 0 || f.Limit > MaxHistoryLimit:
+		return NewErrInvalidFilter("limit")
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package coin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	c := Cursor(12345)
+	parsed, err := ParseCursor(c.String())
+	assert.NoError(t, err)
+	assert.Equal(t, c, parsed)
+
+	parsed, err = ParseCursor("")
+	assert.NoError(t, err)
+	assert.Zero(t, parsed)
+
+	for _, raw := range []string{"!!!", "YWJj", "LTE"} {
+		_, err = ParseCursor(raw)
+		assert.ErrorIs(t, err, ErrInvalidCursor, raw)
+	}
+}
+
+func TestHistoryFilter_Defaults(t *testing.T) {
+	var f HistoryFilter
+	assert.NoError(t, f.Validate())
+	assert.Equal(t, NewestFirst, f.Order)
+	assert.Equal(t, DefaultHistoryLimit, f.Limit)
+}
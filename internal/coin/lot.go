@@ -0,0 +1,39 @@
+package coin
+
+import (
+	"avito-intern/internal/auth"
+	"time"
+)
+
+// Lot — партия монет, полученная пользователем одной транзакцией. Списания расходуют партии
+// в порядке получения (FIFO), остаток партии сгорает через CoinExpiryMonths после получения.
+// Сумма Remaining открытых партий пользователя равна его балансу.
+type Lot struct {
+	ID            int64
+	UserID        auth.UserID
+	TransactionID *TransactionID // nil у начальной партии, перенесённой из баланса
+	Amount        int
+	Remaining     int
+	ReceivedAt    time.Time
+}
+
+// ExpiringCoins — сколько монет сгорает в один день.
+type ExpiringCoins struct {
+	Amount    int
+	ExpiresAt time.Time
+}
+
+// expiringByDay группирует остатки партий по дню сгорания (UTC), раньше сгорающие первыми.
+// Партии должны быть упорядочены по времени получения.
+func expiringByDay(lots []*Lot, months int) []ExpiringCoins {
+	res := make([]ExpiringCoins, 0)
+	for _, lot := range lots {
+		day := lot.ReceivedAt.UTC().AddDate(0, months, 0).Truncate(24 * time.Hour)
+		if n := len(res); n > 0 && res[n-1].ExpiresAt.Equal(day) {
+			res[n-1].Amount += lot.Remaining
+			continue
+		}
+		res = append(res, ExpiringCoins{Amount: lot.Remaining, ExpiresAt: day})
+	}
+	return res
+}
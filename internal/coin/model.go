@@ -2,7 +2,11 @@ package coin
 
 import (
 	"avito-intern/internal/auth"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 type TransactionID int64
@@ -12,6 +16,47 @@ type Type string
 const (
 	Purchase Type = "purchase"
 	Transfer Type = "transfer"
+	Grant    Type = "grant"
+	// Reversal — компенсирующая транзакция: возврат перевода отправителю, PrevTransaction указывает на перевод.
+	Reversal Type = "reversal"
+	// Expiry — списание монет, полученных раньше срока действия, на счёт issuance.
+	Expiry Type = "expiry"
+	// Adjustment — корректировка баланса по итогам сверки: начисление со счёта issuance (ToUser)
+	// или списание на него (FromUser).
+	Adjustment Type = "adjustment"
+	// PoolContribution — взнос в сбор: монеты участника уходят на счёт escrow, ToUser — получатель сбора.
+	PoolContribution Type = "pool_contribution"
+	// PoolPayout — выплата закрытого сбора получателю со счёта escrow.
+	PoolPayout Type = "pool_payout"
+	// PoolRefund — возврат взносов участнику отменённого сбора со счёта escrow.
+	PoolRefund Type = "pool_refund"
+)
+
+// Account — счёт в журнале проводок.
+type Account string
+
+const (
+	// UserAccount — личный счёт сотрудника.
+	UserAccount Account = "user"
+	// IssuanceAccount — системный счёт, с которого начисляются монеты.
+	IssuanceAccount Account = "issuance"
+	// ShopAccount — системный счёт магазина, на который поступает оплата покупок.
+	ShopAccount Account = "shop"
+	// EscrowAccount — системный счёт, на котором монеты ждут, пока получатель примет перевод
+	// или пока закроется сбор.
+	EscrowAccount Account = "escrow"
+)
+
+// Status — состояние транзакции. Обычные транзакции сразу завершены,
+// перевод с подтверждением ждёт получателя в StatusPending.
+type Status string
+
+const (
+	StatusCompleted Status = "completed"
+	StatusPending   Status = "pending"
+	StatusAccepted  Status = "accepted"
+	StatusDeclined  Status = "declined"
+	StatusExpired   Status = "expired"
 )
 
 type Transaction struct {
@@ -21,5 +66,200 @@ type Transaction struct {
 	Amount          int
 	Type            Type
 	PrevTransaction *int64
+	BatchID         BatchID // заполнен у переводов из пакетного перевода
+	PoolID          PoolID  // заполнен у взносов, выплат и возвратов сбора
+	Status          Status  // пустой статус — StatusCompleted
+	ExpiresAt       time.Time
 	CreatedAt       time.Time
+	Note
+}
+
+const (
+	// MaxMessageLength — максимальная длина сообщения к переводу в символах.
+	MaxMessageLength = 280
+	// MaxTags — максимальное число тегов у перевода.
+	MaxTags = 5
+	// maxTagLength — максимальная длина тега в символах.
+	maxTagLength = 24
+)
+
+// Tag — категория перевода (thanks, help, ...) или эмодзи.
+type Tag string
+
+// Note — необязательная благодарность к переводу: сообщение и теги.
+type Note struct {
+	Message string
+	Tags    []Tag
+}
+
+// NewNote проверяет и очищает сообщение и теги перевода.
+// Из сообщения удаляются управляющие символы и переопределения направления текста,
+// пробелы по краям обрезаются. Повторяющиеся теги схлопываются.
+func NewNote(message string, tags []string) (Note, error) {
+	if !utf8.ValidString(message) {
+		return Note{}, ErrInvalidMessage
+	}
+	message = strings.TrimSpace(strings.Map(sanitizeRune, message))
+	if utf8.RuneCountInString(message) > MaxMessageLength {
+		return Note{}, ErrInvalidMessage
+	}
+
+	var note Note
+	note.Message = message
+	for _, raw := range tags {
+		tag, err := NewTag(raw)
+		if err != nil {
+			return Note{}, err
+		}
+		if !slices.Contains(note.Tags, tag) {
+			note.Tags = append(note.Tags, tag)
+		}
+	}
+	if len(note.Tags) > MaxTags {
+		return Note{}, ErrTooManyTags
+	}
+	return note, nil
+}
+
+// sanitizeRune оставляет переводы строк и печатные символы, включая
+// соединитель и селектор вариантов, из которых составляются эмодзи.
+func sanitizeRune(r rune) rune {
+	switch {
+	case r == '\n' || r == zeroWidthJoiner || r == variationSelector:
+		return r
+	case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+		return -1
+	default:
+		return r
+	}
+}
+
+const (
+	zeroWidthJoiner   = '\u200d'
+	variationSelector = '\ufe0f'
+)
+
+// NewTag принимает категорию из строчных латинских букв, цифр и дефиса или одно эмодзи.
+func NewTag(raw string) (Tag, error) {
+	tag := strings.TrimSpace(raw)
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+		return "", NewErrInvalidTag(raw)
+	}
+	if isCategory(tag) || isEmoji(tag) {
+		return Tag(tag), nil
+	}
+	return "", NewErrInvalidTag(raw)
+}
+
+func isCategory(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func isEmoji(s string) bool {
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			return false
+		}
+		if !(unicode.Is(unicode.So, r) || unicode.Is(unicode.Sk, r) ||
+			r == zeroWidthJoiner || r == variationSelector) {
+			return false
+		}
+	}
+	return true
+}
+
+// Entry — проводка в журнале. Положительная сумма — приход на счёт, отрицательная — расход.
+// UserID заполняется только для UserAccount.
+type Entry struct {
+	Account Account
+	UserID  auth.UserID
+	Amount  int
+}
+
+// Entries раскладывает транзакцию на сбалансированные проводки: сумма Amount всех проводок равна 0.
+func (t *Transaction) Entries() ([]Entry, error) {
+	if t.Amount <= 0 {
+		return nil, ErrInvalidTransaction
+	}
+	switch {
+	case t.Type == Transfer && t.Status == StatusPending && t.FromUser != nil && t.ToUser != nil:
+		return []Entry{
+			{Account: UserAccount, UserID: t.FromUser.ID, Amount: -t.Amount},
+			{Account: EscrowAccount, Amount: t.Amount},
+		}, nil
+	case (t.Type == Transfer || t.Type == Reversal) && t.FromUser != nil && t.ToUser != nil:
+		return []Entry{
+			{Account: UserAccount, UserID: t.FromUser.ID, Amount: -t.Amount},
+			{Account: UserAccount, UserID: t.ToUser.ID, Amount: t.Amount},
+		}, nil
+	case t.Type == PoolContribution && t.FromUser != nil && t.PoolID != 0:
+		return []Entry{
+			{Account: UserAccount, UserID: t.FromUser.ID, Amount: -t.Amount},
+			{Account: EscrowAccount, Amount: t.Amount},
+		}, nil
+	case (t.Type == PoolPayout || t.Type == PoolRefund) && t.ToUser != nil && t.FromUser == nil && t.PoolID != 0:
+		return []Entry{
+			{Account: EscrowAccount, Amount: -t.Amount},
+			{Account: UserAccount, UserID: t.ToUser.ID, Amount: t.Amount},
+		}, nil
+	case t.Type == Purchase && t.FromUser != nil:
+		return []Entry{
+			{Account: UserAccount, UserID: t.FromUser.ID, Amount: -t.Amount},
+			{Account: ShopAccount, Amount: t.Amount},
+		}, nil
+	case t.Type == Expiry && t.FromUser != nil:
+		return []Entry{
+			{Account: UserAccount, UserID: t.FromUser.ID, Amount: -t.Amount},
+			{Account: IssuanceAccount, Amount: t.Amount},
+		}, nil
+	case t.Type == Adjustment && t.FromUser != nil && t.ToUser == nil:
+		return []Entry{
+			{Account: UserAccount, UserID: t.FromUser.ID, Amount: -t.Amount},
+			{Account: IssuanceAccount, Amount: t.Amount},
+		}, nil
+	case t.Type == Adjustment && t.ToUser != nil && t.FromUser == nil:
+		return []Entry{
+			{Account: IssuanceAccount, Amount: -t.Amount},
+			{Account: UserAccount, UserID: t.ToUser.ID, Amount: t.Amount},
+		}, nil
+	case t.Type == Grant && t.ToUser != nil:
+		return []Entry{
+			{Account: IssuanceAccount, Amount: -t.Amount},
+			{Account: UserAccount, UserID: t.ToUser.ID, Amount: t.Amount},
+		}, nil
+	default:
+		return nil, ErrInvalidTransaction
+	}
+}
+
+// Settled сообщает, что монеты дошли до получателя.
+func (t *Transaction) Settled() bool {
+	return t.Status == "" || t.Status == StatusCompleted || t.Status == StatusAccepted
+}
+
+// SettlementEntries возвращает проводки, закрывающие ожидающий перевод в статусе status:
+// при принятии монеты уходят из escrow получателю, иначе возвращаются отправителю.
+func (t *Transaction) SettlementEntries(status Status) ([]Entry, error) {
+	if t.Type != Transfer || t.Status != StatusPending || t.FromUser == nil || t.ToUser == nil {
+		return nil, ErrNotPending
+	}
+	switch status {
+	case StatusAccepted:
+		return []Entry{
+			{Account: EscrowAccount, Amount: -t.Amount},
+			{Account: UserAccount, UserID: t.ToUser.ID, Amount: t.Amount},
+		}, nil
+	case StatusDeclined, StatusExpired:
+		return []Entry{
+			{Account: EscrowAccount, Amount: -t.Amount},
+			{Account: UserAccount, UserID: t.FromUser.ID, Amount: t.Amount},
+		}, nil
+	default:
+		return nil, ErrInvalidTransaction
+	}
 }
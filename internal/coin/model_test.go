@@ -0,0 +1,256 @@
+package coin
+
+import (
+	"avito-intern/internal/auth"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntries_Balanced(t *testing.T) {
+	from := &auth.User{ID: 1}
+	to := &auth.User{ID: 2}
+
+	tests := []struct {
+		name     string
+		tx       Transaction
+		expected []Entry
+	}{
+		{
+			name: "transfer",
+			tx:   Transaction{FromUser: from, ToUser: to, Amount: 30, Type: Transfer},
+			expected: []Entry{
+				{Account: UserAccount, UserID: 1, Amount: -30},
+				{Account: UserAccount, UserID: 2, Amount: 30},
+			},
+		},
+		{
+			name: "purchase",
+			tx:   Transaction{FromUser: from, Amount: 80, Type: Purchase},
+			expected: []Entry{
+				{Account: UserAccount, UserID: 1, Amount: -80},
+				{Account: ShopAccount, Amount: 80},
+			},
+		},
+		{
+			name: "pending transfer",
+			tx:   Transaction{FromUser: from, ToUser: to, Amount: 30, Type: Transfer, Status: StatusPending},
+			expected: []Entry{
+				{Account: UserAccount, UserID: 1, Amount: -30},
+				{Account: EscrowAccount, Amount: 30},
+			},
+		},
+		{
+			name: "reversal",
+			tx:   Transaction{FromUser: to, ToUser: from, Amount: 30, Type: Reversal},
+			expected: []Entry{
+				{Account: UserAccount, UserID: 2, Amount: -30},
+				{Account: UserAccount, UserID: 1, Amount: 30},
+			},
+		},
+		{
+			name: "grant",
+			tx:   Transaction{ToUser: to, Amount: 1000, Type: Grant},
+			expected: []Entry{
+				{Account: IssuanceAccount, Amount: -1000},
+				{Account: UserAccount, UserID: 2, Amount: 1000},
+			},
+		},
+		{
+			name: "expiry",
+			tx:   Transaction{FromUser: from, Amount: 15, Type: Expiry},
+			expected: []Entry{
+				{Account: UserAccount, UserID: 1, Amount: -15},
+				{Account: IssuanceAccount, Amount: 15},
+			},
+		},
+		{
+			name: "adjustment credit",
+			tx:   Transaction{ToUser: to, Amount: 7, Type: Adjustment},
+			expected: []Entry{
+				{Account: IssuanceAccount, Amount: -7},
+				{Account: UserAccount, UserID: 2, Amount: 7},
+			},
+		},
+		{
+			name: "adjustment debit",
+			tx:   Transaction{FromUser: from, Amount: 7, Type: Adjustment},
+			expected: []Entry{
+				{Account: UserAccount, UserID: 1, Amount: -7},
+				{Account: IssuanceAccount, Amount: 7},
+			},
+		},
+		{
+			name: "pool contribution",
+			tx:   Transaction{FromUser: from, ToUser: to, Amount: 20, Type: PoolContribution, PoolID: 1},
+			expected: []Entry{
+				{Account: UserAccount, UserID: 1, Amount: -20},
+				{Account: EscrowAccount, Amount: 20},
+			},
+		},
+		{
+			name: "pool payout",
+			tx:   Transaction{ToUser: to, Amount: 60, Type: PoolPayout, PoolID: 1},
+			expected: []Entry{
+				{Account: EscrowAccount, Amount: -60},
+				{Account: UserAccount, UserID: 2, Amount: 60},
+			},
+		},
+		{
+			name: "pool refund",
+			tx:   Transaction{ToUser: from, Amount: 20, Type: PoolRefund, PoolID: 1},
+			expected: []Entry{
+				{Account: EscrowAccount, Amount: -20},
+				{Account: UserAccount, UserID: 1, Amount: 20},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := tc.tx.Entries()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, entries)
+
+			sum := 0
+			for _, e := range entries {
+				sum += e.Amount
+			}
+			assert.Zero(t, sum, "проводки должны быть сбалансированы")
+		})
+	}
+}
+
+func TestEntries_Invalid(t *testing.T) {
+	user := &auth.User{ID: 1}
+
+	tests := []struct {
+		name string
+		tx   Transaction
+	}{
+		{"zero amount", Transaction{FromUser: user, ToUser: &auth.User{ID: 2}, Amount: 0, Type: Transfer}},
+		{"negative amount", Transaction{FromUser: user, ToUser: &auth.User{ID: 2}, Amount: -10, Type: Transfer}},
+		{"transfer without recipient", Transaction{FromUser: user, Amount: 10, Type: Transfer}},
+		{"purchase without buyer", Transaction{Amount: 10, Type: Purchase}},
+		{"grant without recipient", Transaction{Amount: 10, Type: Grant}},
+		{"expiry without owner", Transaction{ToUser: user, Amount: 10, Type: Expiry}},
+		{"adjustment between users", Transaction{FromUser: user, ToUser: &auth.User{ID: 2}, Amount: 10, Type: Adjustment}},
+		{"contribution without pool", Transaction{FromUser: user, Amount: 10, Type: PoolContribution}},
+		{"payout from user", Transaction{FromUser: user, ToUser: &auth.User{ID: 2}, Amount: 10, Type: PoolPayout, PoolID: 1}},
+		{"unknown type", Transaction{FromUser: user, Amount: 10, Type: "unknown"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := tc.tx.Entries()
+			assert.ErrorIs(t, err, ErrInvalidTransaction)
+			assert.Nil(t, entries)
+		})
+	}
+}
+
+func TestSettlementEntries(t *testing.T) {
+	from := &auth.User{ID: 1}
+	to := &auth.User{ID: 2}
+	pending := Transaction{FromUser: from, ToUser: to, Amount: 30, Type: Transfer, Status: StatusPending}
+	hold, err := pending.Entries()
+	require.NoError(t, err)
+
+	// итоговое движение монет по счетам пользователей после удержания и закрытия.
+	tests := []struct {
+		status Status
+		net    map[auth.UserID]int
+	}{
+		{StatusAccepted, map[auth.UserID]int{from.ID: -30, to.ID: 30}},
+		{StatusDeclined, map[auth.UserID]int{from.ID: 0}},
+		{StatusExpired, map[auth.UserID]int{from.ID: 0}},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.status), func(t *testing.T) {
+			entries, err := pending.SettlementEntries(tc.status)
+			require.NoError(t, err)
+
+			escrow := 0
+			net := make(map[auth.UserID]int)
+			for _, e := range append(entries, hold...) {
+				switch e.Account {
+				case EscrowAccount:
+					escrow += e.Amount
+				case UserAccount:
+					net[e.UserID] += e.Amount
+				}
+			}
+			assert.Zero(t, escrow, "escrow должен опустеть")
+			assert.Equal(t, tc.net, net)
+		})
+	}
+
+	completed := pending
+	completed.Status = StatusCompleted
+	_, err = completed.SettlementEntries(StatusAccepted)
+	assert.ErrorIs(t, err, ErrNotPending)
+}
+
+func TestPoolSettlement(t *testing.T) {
+	alice := &auth.User{ID: 1}
+	bob := &auth.User{ID: 2}
+	carol := &auth.User{ID: 3}
+	pool := &Pool{ID: 5, Recipient: carol, Title: "Проводы"}
+	contributions := []*Transaction{
+		{FromUser: bob, ToUser: carol, Amount: 20, Type: PoolContribution, PoolID: 5},
+		{FromUser: alice, ToUser: carol, Amount: 30, Type: PoolContribution, PoolID: 5},
+		{FromUser: bob, ToUser: carol, Amount: 10, Type: PoolContribution, PoolID: 5},
+	}
+
+	payout, err := pool.Settlement(PoolPaidOut, contributions)
+	require.NoError(t, err)
+	assert.Equal(t, []*Transaction{
+		{ToUser: carol, Amount: 60, Type: PoolPayout, PoolID: 5, Note: Note{Message: "Проводы"}},
+	}, payout)
+
+	refunds, err := pool.Settlement(PoolCancelled, contributions)
+	require.NoError(t, err)
+	assert.Equal(t, []*Transaction{
+		{ToUser: bob, Amount: 30, Type: PoolRefund, PoolID: 5, Note: Note{Message: "Проводы"}},
+		{ToUser: alice, Amount: 30, Type: PoolRefund, PoolID: 5, Note: Note{Message: "Проводы"}},
+	}, refunds)
+
+	_, err = pool.Settlement(PoolPaidOut, nil)
+	assert.ErrorIs(t, err, ErrPoolEmpty)
+	refunds, err = pool.Settlement(PoolCancelled, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, refunds)
+	_, err = pool.Settlement(PoolOpen, contributions)
+	assert.ErrorIs(t, err, ErrPoolClosed)
+}
+
+func TestNewNote(t *testing.T) {
+	note, err := NewNote("  Спасибо\u202e за помощь!\x00\n", []string{"thanks", "🙏", "thanks", "👩\u200d💻"})
+	require.NoError(t, err)
+	assert.Equal(t, "Спасибо за помощь!", note.Message)
+	assert.Equal(t, []Tag{"thanks", "🙏", "👩\u200d💻"}, note.Tags)
+
+	note, err = NewNote("", nil)
+	require.NoError(t, err)
+	assert.Equal(t, Note{}, note)
+}
+
+func TestNewNote_Invalid(t *testing.T) {
+	var tagErr ErrInvalidTag
+
+	_, err := NewNote(strings.Repeat("я", MaxMessageLength+1), nil)
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	_, err = NewNote("\xff", nil)
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	_, err = NewNote("", []string{"a", "b", "c", "d", "e", "f"})
+	assert.ErrorIs(t, err, ErrTooManyTags)
+
+	for _, tag := range []string{"", "Thanks", "<script>", "thanks!", "^", strings.Repeat("a", 25)} {
+		_, err = NewNote("", []string{tag})
+		assert.ErrorAs(t, err, &tagErr, "tag %q", tag)
+	}
+}
@@ -0,0 +1,163 @@
+package coin
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"time"
+)
+
+// TransferPolicy — правило, которому должен удовлетворять перевод.
+// Check вызывается в транзакции под блокировкой отправителя, поэтому правила
+// видят все его зафиксированные переводы и не гоняются с параллельными запросами.
+type TransferPolicy interface {
+	Check(ctx context.Context, from *auth.User, legs []TransferLeg) error
+}
+
+// TransferStatsRepo отдаёт статистику исходящих переводов для правил, взносы в сборы считаются переводами.
+type TransferStatsRepo interface {
+	// SumOutgoingTransfers возвращает сумму переводов from начиная с since, при to != nil — только этому получателю.
+	SumOutgoingTransfers(ctx context.Context, from auth.UserID, to *auth.UserID, since time.Time) (int, error)
+	// LastOutgoingTransferAt возвращает время последнего перевода from, нулевое — если переводов не было.
+	LastOutgoingTransferAt(ctx context.Context, from auth.UserID) (time.Time, error)
+}
+
+// PolicyChain проверяет правила по порядку и возвращает первое нарушение.
+type PolicyChain []TransferPolicy
+
+func (c PolicyChain) Check(ctx context.Context, from *auth.User, legs []TransferLeg) error {
+	for _, p := range c {
+		if err := p.Check(ctx, from, legs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewTransferPolicy собирает цепочку правил из cfg, правила с нулевым лимитом не добавляются.
+func NewTransferPolicy(cfg *Config, stats TransferStatsRepo) PolicyChain {
+	now := time.Now
+	chain := PolicyChain{amountPolicy{min: cfg.TransferMinAmount, max: cfg.TransferMaxAmount}}
+	if cfg.TransferCooldown > 0 {
+		chain = append(chain, cooldownPolicy{interval: cfg.TransferCooldown, stats: stats, now: now})
+	}
+	if cfg.TransferDailyCap > 0 {
+		chain = append(chain, capPolicy{period: Daily, cap: cfg.TransferDailyCap, stats: stats, now: now})
+	}
+	if cfg.TransferWeeklyCap > 0 {
+		chain = append(chain, capPolicy{period: Weekly, cap: cfg.TransferWeeklyCap, stats: stats, now: now})
+	}
+	if cfg.TransferRecipientDailyCap > 0 {
+		chain = append(chain, recipientCapPolicy{cap: cfg.TransferRecipientDailyCap, stats: stats, now: now})
+	}
+	return chain
+}
+
+// Period — календарный период лимита в UTC.
+type Period string
+
+const (
+	Daily  Period = "daily"
+	Weekly Period = "weekly"
+)
+
+// Start возвращает начало периода, в который попадает t. Неделя начинается с понедельника.
+func (p Period) Start(t time.Time) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	if p == Weekly {
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return day
+}
+
+// End возвращает начало следующего периода.
+func (p Period) End(t time.Time) time.Time {
+	if p == Weekly {
+		return p.Start(t).AddDate(0, 0, 7)
+	}
+	return p.Start(t).AddDate(0, 0, 1)
+}
+
+// amountPolicy ограничивает сумму каждого перевода, max = 0 — без верхней границы.
+type amountPolicy struct {
+	min, max int
+}
+
+func (p amountPolicy) Check(_ context.Context, _ *auth.User, legs []TransferLeg) error {
+	for _, leg := range legs {
+		if leg.Amount < p.min {
+			return NewErrAmountTooSmall(p.min)
+		}
+		if p.max > 0 && leg.Amount > p.max {
+			return NewErrAmountTooLarge(p.max)
+		}
+	}
+	return nil
+}
+
+// capPolicy ограничивает сумму исходящих переводов пользователя за период.
+type capPolicy struct {
+	period Period
+	cap    int
+	stats  TransferStatsRepo
+	now    func() time.Time
+}
+
+func (p capPolicy) Check(ctx context.Context, from *auth.User, legs []TransferLeg) error {
+	now := p.now()
+	sent, err := p.stats.SumOutgoingTransfers(ctx, from.ID, nil, p.period.Start(now))
+	if err != nil {
+		return err
+	}
+	if sent+legsTotal(legs) > p.cap {
+		return NewErrCapExceeded(p.period, p.cap, max(p.cap-sent, 0), p.period.End(now).Sub(now))
+	}
+	return nil
+}
+
+// recipientCapPolicy ограничивает сумму переводов одному получателю за день.
+type recipientCapPolicy struct {
+	cap   int
+	stats TransferStatsRepo
+	now   func() time.Time
+}
+
+func (p recipientCapPolicy) Check(ctx context.Context, from *auth.User, legs []TransferLeg) error {
+	now := p.now()
+	for _, leg := range legs {
+		sent, err := p.stats.SumOutgoingTransfers(ctx, from.ID, &leg.To.ID, Daily.Start(now))
+		if err != nil {
+			return err
+		}
+		if sent+leg.Amount > p.cap {
+			return NewErrRecipientCapExceeded(leg.To.Username, p.cap, max(p.cap-sent, 0), Daily.End(now).Sub(now))
+		}
+	}
+	return nil
+}
+
+// cooldownPolicy задаёт минимальный интервал между переводами пользователя.
+// Пакетный перевод считается одним переводом.
+type cooldownPolicy struct {
+	interval time.Duration
+	stats    TransferStatsRepo
+	now      func() time.Time
+}
+
+func (p cooldownPolicy) Check(ctx context.Context, from *auth.User, _ []TransferLeg) error {
+	last, err := p.stats.LastOutgoingTransferAt(ctx, from.ID)
+	if err != nil || last.IsZero() {
+		return err
+	}
+	if wait := last.Add(p.interval).Sub(p.now()); wait > 0 {
+		return NewErrCooldown(wait)
+	}
+	return nil
+}
+
+func legsTotal(legs []TransferLeg) int {
+	total := 0
+	for _, leg := range legs {
+		total += leg.Amount
+	}
+	return total
+}
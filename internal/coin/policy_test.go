@@ -0,0 +1,119 @@
+package coin
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStats хранит исходящие переводы в памяти.
+type fakeStats struct {
+	transfers []*Transaction
+}
+
+func (s *fakeStats) SumOutgoingTransfers(_ context.Context, from auth.UserID, to *auth.UserID, since time.Time) (int, error) {
+	sum := 0
+	for _, t := range s.transfers {
+		if t.FromUser.ID == from && !t.CreatedAt.Before(since) && (to == nil || t.ToUser.ID == *to) {
+			sum += t.Amount
+		}
+	}
+	return sum, nil
+}
+
+func (s *fakeStats) LastOutgoingTransferAt(_ context.Context, from auth.UserID) (time.Time, error) {
+	var last time.Time
+	for _, t := range s.transfers {
+		if t.FromUser.ID == from && t.CreatedAt.After(last) {
+			last = t.CreatedAt
+		}
+	}
+	return last, nil
+}
+
+func TestPeriod(t *testing.T) {
+	// среда, 12 марта 2025.
+	now := time.Date(2025, 3, 12, 15, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC), Daily.Start(now))
+	assert.Equal(t, time.Date(2025, 3, 13, 0, 0, 0, 0, time.UTC), Daily.End(now))
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), Weekly.Start(now))
+	assert.Equal(t, time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC), Weekly.End(now))
+
+	sunday := time.Date(2025, 3, 16, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), Weekly.Start(sunday))
+}
+
+func TestTransferPolicy(t *testing.T) {
+	now := time.Date(2025, 3, 12, 12, 0, 0, 0, time.UTC)
+	from := &auth.User{ID: 1}
+	alice := &auth.User{ID: 2, Username: "alice"}
+	bob := &auth.User{ID: 3, Username: "bob"}
+
+	stats := &fakeStats{transfers: []*Transaction{
+		{FromUser: from, ToUser: alice, Amount: 40, CreatedAt: now.Add(-time.Hour)},
+		{FromUser: from, ToUser: bob, Amount: 100, CreatedAt: now.AddDate(0, 0, -1)},
+	}}
+	clock := func() time.Time { return now }
+	leg := func(to *auth.User, amount int) []TransferLeg {
+		return []TransferLeg{{To: to, Amount: amount}}
+	}
+
+	tests := []struct {
+		name   string
+		policy TransferPolicy
+		legs   []TransferLeg
+		code   string
+		retry  time.Duration
+	}{
+		{"min amount", amountPolicy{min: 5}, leg(bob, 4), "amount_too_small", 0},
+		{"max amount", amountPolicy{min: 1, max: 50}, leg(bob, 51), "amount_too_large", 0},
+		{"amount ok", amountPolicy{min: 1, max: 50}, leg(bob, 50), "", 0},
+		{"daily cap", capPolicy{period: Daily, cap: 50, stats: stats, now: clock}, leg(bob, 11), "daily_cap_exceeded", 12 * time.Hour},
+		{"daily cap ok", capPolicy{period: Daily, cap: 50, stats: stats, now: clock}, leg(bob, 10), "", 0},
+		{"weekly cap", capPolicy{period: Weekly, cap: 150, stats: stats, now: clock}, leg(bob, 11), "weekly_cap_exceeded", 4*24*time.Hour + 12*time.Hour},
+		{"batch counts total", capPolicy{period: Daily, cap: 50, stats: stats, now: clock}, append(leg(alice, 5), leg(bob, 6)...), "daily_cap_exceeded", 12 * time.Hour},
+		{"recipient cap", recipientCapPolicy{cap: 45, stats: stats, now: clock}, leg(alice, 6), "recipient_cap_exceeded", 12 * time.Hour},
+		{"recipient cap other", recipientCapPolicy{cap: 45, stats: stats, now: clock}, leg(bob, 45), "", 0},
+		{"cooldown", cooldownPolicy{interval: 2 * time.Hour, stats: stats, now: clock}, leg(bob, 1), "cooldown", time.Hour},
+		{"cooldown passed", cooldownPolicy{interval: time.Hour, stats: stats, now: clock}, leg(bob, 1), "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(context.Background(), from, tt.legs)
+			if tt.code == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var violation PolicyViolation
+			assert.ErrorAs(t, err, &violation)
+			assert.Equal(t, tt.code, violation.Code())
+
+			var limited RateLimited
+			if tt.retry == 0 {
+				assert.False(t, errors.As(err, &limited))
+				return
+			}
+			assert.ErrorAs(t, err, &limited)
+			assert.Equal(t, tt.retry, limited.RetryAfter())
+		})
+	}
+}
+
+func TestNewTransferPolicy(t *testing.T) {
+	chain := NewTransferPolicy(&Config{TransferMinAmount: 1}, &fakeStats{})
+	assert.Len(t, chain, 1)
+
+	chain = NewTransferPolicy(&Config{
+		TransferMinAmount:         1,
+		TransferDailyCap:          100,
+		TransferWeeklyCap:         300,
+		TransferRecipientDailyCap: 50,
+		TransferCooldown:          time.Minute,
+	}, &fakeStats{})
+	assert.Len(t, chain, 5)
+}
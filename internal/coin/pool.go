@@ -0,0 +1,81 @@
+package coin
+
+import (
+	"avito-intern/internal/auth"
+	"time"
+)
+
+// PoolID — идентификатор сбора.
+type PoolID int64
+
+// PoolStatus — состояние сбора.
+type PoolStatus string
+
+const (
+	PoolOpen      PoolStatus = "open"
+	PoolPaidOut   PoolStatus = "paid_out"
+	PoolCancelled PoolStatus = "cancelled"
+)
+
+// Pool — сбор монет в подарок одному получателю (на день рождения, проводы).
+// Взносы лежат на счёте escrow, пока организатор не закроет сбор: тогда получатель
+// получает всю сумму одним начислением, а при отмене каждому участнику возвращаются его взносы.
+type Pool struct {
+	ID        PoolID
+	Organizer *auth.User
+	Recipient *auth.User
+	Title     string
+	// Goal — желаемая сумма, 0 — без цели. Сбор можно закрыть и до достижения цели.
+	Goal int
+	// Deadline — срок сбора, нулевой — без срока. После срока сбор закрывается фоновой проверкой.
+	Deadline     time.Time
+	Status       PoolStatus
+	Collected    int
+	Contributors int
+	CreatedAt    time.Time
+	ClosedAt     time.Time
+}
+
+// Settlement возвращает транзакции, закрывающие сбор в статусе status по его взносам:
+// при выплате — одно начисление получателю на всю сумму, при отмене — возврат каждому
+// участнику суммы его взносов в порядке первого взноса.
+func (p *Pool) Settlement(status PoolStatus, contributions []*Transaction) ([]*Transaction, error) {
+	switch status {
+	case PoolPaidOut:
+		total := 0
+		for _, c := range contributions {
+			total += c.Amount
+		}
+		if total == 0 {
+			return nil, ErrPoolEmpty
+		}
+		return []*Transaction{{
+			ToUser: p.Recipient,
+			Amount: total,
+			Type:   PoolPayout,
+			PoolID: p.ID,
+			Note:   Note{Message: p.Title},
+		}}, nil
+	case PoolCancelled:
+		refunds := make([]*Transaction, 0)
+		byUser := make(map[auth.UserID]*Transaction)
+		for _, c := range contributions {
+			if refund, ok := byUser[c.FromUser.ID]; ok {
+				refund.Amount += c.Amount
+				continue
+			}
+			refund := &Transaction{
+				ToUser: c.FromUser,
+				Amount: c.Amount,
+				Type:   PoolRefund,
+				PoolID: p.ID,
+				Note:   Note{Message: p.Title},
+			}
+			byUser[c.FromUser.ID] = refund
+			refunds = append(refunds, refund)
+		}
+		return refunds, nil
+	default:
+		return nil, ErrPoolClosed
+	}
+}
@@ -3,10 +3,57 @@ package coin
 import (
 	"avito-intern/internal/auth"
 	"context"
+	"time"
 )
 
 type Repository interface {
+	TransferStatsRepo
+	// LockUsers блокирует пользователей до конца транзакции, вызывается внутри RunInTransaction.
+	LockUsers(ctx context.Context, userIDs ...auth.UserID) error
 	SaveTransaction(context.Context, *Transaction) (*Transaction, error)
-	GetIncomingTransfers(context.Context, auth.UserID) ([]*Transaction, error)
-	GetOutgoingTransfers(context.Context, auth.UserID) ([]*Transaction, error)
+	// SaveBatch проводит все переводы пакета в одной транзакции БД: либо все, либо ни одного.
+	SaveBatch(context.Context, *Batch) (*Batch, error)
+	GetBalance(context.Context, auth.UserID) (int, error)
+	// GetIncomingTransfers и GetOutgoingTransfers возвращают последние limit переводов
+	// в порядке возрастания, limit <= 0 — все.
+	GetIncomingTransfers(ctx context.Context, userID auth.UserID, limit int) ([]*Transaction, error)
+	GetOutgoingTransfers(ctx context.Context, userID auth.UserID, limit int) ([]*Transaction, error)
+	// GetTransaction возвращает транзакцию с участниками или ErrTransactionNotFound.
+	GetTransaction(ctx context.Context, id TransactionID) (*Transaction, error)
+	// SumReversed возвращает сумму уже проведённых возвратов транзакции.
+	SumReversed(ctx context.Context, id TransactionID) (int, error)
+	// SaveReversal проводит транзакцию возврата и записывает причину и администратора.
+	SaveReversal(ctx context.Context, r *ReversalRecord) (*ReversalRecord, error)
+	// SettlePending закрывает ожидающий перевод в статусе status проводками SettlementEntries.
+	// Перевод блокируется, если он уже не в StatusPending — ErrNotPending.
+	SettlePending(ctx context.Context, id TransactionID, status Status) (*Transaction, error)
+	// ListPending возвращает ожидающие переводы, где пользователь отправитель или получатель.
+	ListPending(ctx context.Context, userID auth.UserID) ([]*Transaction, error)
+	// ListExpiredPending возвращает до limit ожидающих переводов, истекших к now.
+	ListExpiredPending(ctx context.Context, now time.Time, limit int) ([]TransactionID, error)
+	// ListOpenLots возвращает партии пользователя с остатком, полученные до receivedBefore, в порядке получения.
+	ListOpenLots(ctx context.Context, userID auth.UserID, receivedBefore time.Time) ([]*Lot, error)
+	// ListUsersWithExpiredLots возвращает до limit пользователей с остатком в партиях, полученных до receivedBefore.
+	ListUsersWithExpiredLots(ctx context.Context, receivedBefore time.Time, limit int) ([]auth.UserID, error)
+	// ExpireLots списывает транзакцией Expiry остатки партий пользователя, полученных до receivedBefore.
+	// Если списывать нечего, возвращает nil.
+	ExpireLots(ctx context.Context, userID auth.UserID, receivedBefore time.Time) (*Transaction, error)
+	// CreatePool сохраняет новый открытый сбор.
+	CreatePool(ctx context.Context, p *Pool) (*Pool, error)
+	// GetPool возвращает сбор с собранной суммой или ErrPoolNotFound.
+	GetPool(ctx context.Context, id PoolID) (*Pool, error)
+	// ListPools возвращает сборы, которые пользователь организовал или в которые внёс монеты, новые первыми.
+	ListPools(ctx context.Context, userID auth.UserID) ([]*Pool, error)
+	// ListPoolContributions возвращает взносы в сбор в порядке внесения.
+	ListPoolContributions(ctx context.Context, id PoolID) ([]*Transaction, error)
+	// SavePoolContribution блокирует сбор и проводит взнос, если сбор открыт и его срок не истёк,
+	// иначе ErrPoolClosed.
+	SavePoolContribution(ctx context.Context, t *Transaction) (*Transaction, error)
+	// ClosePool закрывает открытый сбор в статусе status и проводит транзакции Pool.Settlement.
+	// Если сбор уже закрыт — ErrPoolClosed.
+	ClosePool(ctx context.Context, id PoolID, status PoolStatus) (*Pool, error)
+	// ListPoolsPastDeadline возвращает до limit открытых сборов, срок которых истёк к now.
+	ListPoolsPastDeadline(ctx context.Context, now time.Time, limit int) ([]PoolID, error)
+	// ListHistory возвращает до filter.Limit проводок пользователя после курсора filter.After.
+	ListHistory(ctx context.Context, userID auth.UserID, filter HistoryFilter) ([]*HistoryEntry, error)
 }
@@ -0,0 +1,22 @@
+package coin
+
+import "avito-intern/internal/auth"
+
+// ReversalRecord — компенсирующая транзакция вместе с причиной и администратором, который её провёл.
+type ReversalRecord struct {
+	// Transaction — транзакция возврата, Transaction.PrevTransaction указывает на Original.
+	Transaction *Transaction
+	Original    *Transaction
+	Admin       *auth.User
+	Reason      string
+	// Requested — сумма, которую запросил администратор. Amount возврата может быть меньше,
+	// если получатель уже потратил монеты.
+	Requested int
+	// Remaining — сумма перевода, которую ещё можно вернуть после этого возврата.
+	Remaining int
+}
+
+// Partial сообщает, что вернуть удалось меньше запрошенного.
+func (r *ReversalRecord) Partial() bool {
+	return r.Transaction.Amount < r.Requested
+}
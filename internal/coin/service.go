@@ -5,24 +5,52 @@ import (
 	"avito-intern/internal/common"
 	"context"
 	"errors"
+	"log/slog"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"golang.org/x/sync/errgroup"
 )
 
 type service struct {
+	cfg          *Config
 	authService  auth.Service
 	transactions Repository
+	uow          common.UnitOfWork
+	policy       TransferPolicy
+	now          func() time.Time
 }
 
-func NewService(authService auth.Service, transactions Repository) Service {
+func NewService(
+	cfg *Config,
+	authService auth.Service,
+	transactions Repository,
+	uow common.UnitOfWork,
+	policy TransferPolicy,
+) Service {
 	return &service{
+		cfg:          cfg,
 		authService:  authService,
 		transactions: transactions,
+		uow:          uow,
+		policy:       policy,
+		now:          time.Now,
 	}
 }
 
-func (s *service) Transfer(ctx context.Context, from, to *auth.User, amount int) (*Transaction, error) {
+func (s *service) Transfer(ctx context.Context, from, to *auth.User, amount int, note Note) (*Transaction, error) {
+	return s.transfer(ctx, from, to, amount, note, StatusCompleted)
+}
+
+// TransferPending переводит монеты с подтверждением: они списываются в escrow
+// и дойдут до получателя, только если он примет перевод до истечения PendingTTL.
+func (s *service) TransferPending(ctx context.Context, from, to *auth.User, amount int, note Note) (*Transaction, error) {
+	return s.transfer(ctx, from, to, amount, note, StatusPending)
+}
+
+func (s *service) transfer(ctx context.Context, from, to *auth.User, amount int, note Note, status Status) (*Transaction, error) {
 	if from == nil || to == nil {
 		return nil, errors.New("missing required data")
 	}
@@ -30,23 +58,480 @@ func (s *service) Transfer(ctx context.Context, from, to *auth.User, amount int)
 	if from.ID == to.ID {
 		return nil, ErrInvalidRecipient
 	}
-	if from.CoinBalance < amount {
-		return nil, ErrNotEnoughCoins
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
 	}
-	return s.transactions.SaveTransaction(ctx, &Transaction{
+
+	now := s.now()
+	t := &Transaction{
 		ID:        0,
 		FromUser:  from,
 		ToUser:    to,
 		Amount:    amount,
 		Type:      Transfer,
-		CreatedAt: time.Now(),
+		Status:    status,
+		CreatedAt: now,
+		Note:      note,
+	}
+	if status == StatusPending {
+		t.ExpiresAt = now.Add(s.cfg.PendingTTL)
+	}
+	var saved *Transaction
+	err := s.checkPolicy(ctx, from, []TransferLeg{{To: to, Amount: amount}}, func(ctx context.Context) (err error) {
+		// баланс проверяется в хранилище под той же блокировкой отправителя.
+		saved, err = s.transactions.SaveTransaction(ctx, t)
+		return err
 	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
 }
 
-func (s *service) Purchase(ctx context.Context, buyer *auth.User, amount int) (*Transaction, error) {
-	if buyer.CoinBalance < amount {
-		return nil, errors.New("not enough coins")
+// Accept зачисляет ожидающий перевод получателю.
+func (s *service) Accept(ctx context.Context, user *auth.User, id TransactionID) (*Transaction, error) {
+	return s.settle(ctx, user, id, StatusAccepted)
+}
+
+// Decline возвращает ожидающий перевод отправителю.
+func (s *service) Decline(ctx context.Context, user *auth.User, id TransactionID) (*Transaction, error) {
+	return s.settle(ctx, user, id, StatusDeclined)
+}
+
+func (s *service) settle(ctx context.Context, user *auth.User, id TransactionID, status Status) (*Transaction, error) {
+	var settled *Transaction
+	err := s.uow.RunInTransaction(ctx, func(ctx context.Context) error {
+		t, err := s.transactions.GetTransaction(ctx, id)
+		if err != nil {
+			return err
+		}
+		if t.ToUser == nil || t.ToUser.ID != user.ID {
+			return ErrNotRecipient
+		}
+		// истекший перевод вернёт отправителю фоновая проверка.
+		if t.Status != StatusPending || !s.now().Before(t.ExpiresAt) {
+			return ErrNotPending
+		}
+		settled, err = s.transactions.SettlePending(ctx, id, status)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return settled, nil
+}
+
+// ListPending возвращает ожидающие переводы пользователю и от него.
+func (s *service) ListPending(ctx context.Context, user *auth.User) (incoming, outgoing []*Transaction, err error) {
+	pending, err := s.transactions.ListPending(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	incoming, outgoing = make([]*Transaction, 0), make([]*Transaction, 0)
+	for _, t := range pending {
+		if t.ToUser != nil && t.ToUser.ID == user.ID {
+			incoming = append(incoming, t)
+		} else {
+			outgoing = append(outgoing, t)
+		}
+	}
+	return incoming, outgoing, nil
+}
+
+// ExpirePending возвращает отправителям до PendingExpiryBatch истекших переводов.
+func (s *service) ExpirePending(ctx context.Context) (int, error) {
+	ids, err := s.transactions.ListExpiredPending(ctx, s.now(), s.cfg.PendingExpiryBatch)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, id := range ids {
+		_, err := s.transactions.SettlePending(ctx, id, StatusExpired)
+		switch {
+		case errors.Is(err, ErrNotPending):
+			// перевод успели принять или его вернула другая реплика.
+		case err != nil:
+			return expired, err
+		default:
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// RunExpiry периодически возвращает истекшие переводы и закрывает сборы с истекшим сроком,
+// пока не отменён ctx.
+func (s *service) RunExpiry(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PendingExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if expired, err := s.ExpirePending(ctx); err != nil {
+				slog.Error("failed to expire pending transfers", "error", err)
+			} else {
+				slog.Debug("expired pending transfers", "count", expired)
+			}
+			if closed, err := s.ClosePoolsPastDeadline(ctx); err != nil {
+				slog.Error("failed to close pools past deadline", "error", err)
+			} else {
+				slog.Debug("closed pools past deadline", "count", closed)
+			}
+		}
+	}
+}
+
+// ExpireCoins списывает сгоревшие монеты у CoinExpiryBatch пользователей и возвращает,
+// у скольких пользователей монеты списаны.
+func (s *service) ExpireCoins(ctx context.Context) (int, error) {
+	if s.cfg.CoinExpiryMonths <= 0 {
+		return 0, nil
+	}
+	receivedBefore := s.now().AddDate(0, -s.cfg.CoinExpiryMonths, 0)
+	userIDs, err := s.transactions.ListUsersWithExpiredLots(ctx, receivedBefore, s.cfg.CoinExpiryBatch)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, userID := range userIDs {
+		t, err := s.transactions.ExpireLots(ctx, userID, receivedBefore)
+		if err != nil {
+			return expired, err
+		}
+		if t != nil {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// RunCoinExpiry периодически списывает сгоревшие монеты, пока не отменён ctx.
+func (s *service) RunCoinExpiry(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.CoinExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.ExpireCoins(ctx)
+			if err != nil {
+				slog.Error("failed to expire coins", "error", err)
+				continue
+			}
+			slog.Debug("expired coins", "users", expired)
+		}
+	}
+}
+
+// ExpiringCoins возвращает монеты пользователя, которые сгорят в ближайшие CoinExpiryNotice, по дням.
+func (s *service) ExpiringCoins(ctx context.Context, user *auth.User) ([]ExpiringCoins, error) {
+	if s.cfg.CoinExpiryMonths <= 0 {
+		return make([]ExpiringCoins, 0), nil
+	}
+	receivedBefore := s.now().Add(s.cfg.CoinExpiryNotice).AddDate(0, -s.cfg.CoinExpiryMonths, 0)
+	lots, err := s.transactions.ListOpenLots(ctx, user.ID, receivedBefore)
+	if err != nil {
+		return nil, err
+	}
+	return expiringByDay(lots, s.cfg.CoinExpiryMonths), nil
+}
+
+// checkPolicy блокирует отправителя, проверяет правила переводов и в той же транзакции вызывает save.
+func (s *service) checkPolicy(ctx context.Context, from *auth.User, legs []TransferLeg, save func(ctx context.Context) error) error {
+	if s.policy == nil {
+		return save(ctx)
+	}
+	return s.uow.RunInTransaction(ctx, func(ctx context.Context) error {
+		if err := s.transactions.LockUsers(ctx, from.ID); err != nil {
+			return err
+		}
+		if err := s.policy.Check(ctx, from, legs); err != nil {
+			return err
+		}
+		return save(ctx)
+	})
+}
+
+// TransferBatch переводит монеты нескольким получателям атомарно.
+// Получатели проверяются все сразу, ошибки возвращаются по индексам в ErrInvalidBatch.
+func (s *service) TransferBatch(ctx context.Context, from *auth.User, legs []TransferLeg, note Note) (*Batch, error) {
+	if from == nil {
+		return nil, errors.New("missing required data")
+	}
+	if len(legs) == 0 || len(legs) > MaxBatchSize {
+		return nil, ErrEmptyBatch
+	}
+
+	now := s.now()
+	batch := &Batch{From: from, CreatedAt: now}
+	invalid := make(map[int]error)
+	seen := make(map[auth.UserID]struct{}, len(legs))
+	for i, leg := range legs {
+		switch {
+		case leg.To == nil:
+			invalid[i] = auth.ErrUserNotFound
+			continue
+		case leg.To.ID == from.ID:
+			invalid[i] = ErrInvalidRecipient
+			continue
+		case leg.Amount <= 0:
+			invalid[i] = ErrInvalidAmount
+			continue
+		}
+		if _, ok := seen[leg.To.ID]; ok {
+			invalid[i] = ErrDuplicateRecipient
+			continue
+		}
+		seen[leg.To.ID] = struct{}{}
+		batch.Transfers = append(batch.Transfers, &Transaction{
+			FromUser:  from,
+			ToUser:    leg.To,
+			Amount:    leg.Amount,
+			Type:      Transfer,
+			CreatedAt: now,
+			Note:      note,
+		})
+	}
+	if len(invalid) > 0 {
+		return nil, NewErrInvalidBatch(invalid)
+	}
+	var saved *Batch
+	err := s.checkPolicy(ctx, from, legs, func(ctx context.Context) (err error) {
+		// сумма пакета сверяется с балансом в хранилище под блокировкой отправителя.
+		saved, err = s.transactions.SaveBatch(ctx, batch)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// Reverse возвращает отправителю перевод id компенсирующей транзакцией.
+// amount = 0 — вернуть всё, что ещё не возвращено. Если получатель уже потратил часть монет,
+// возвращается столько, сколько есть на его балансе.
+func (s *service) Reverse(ctx context.Context, admin *auth.User, id TransactionID, amount int, reason string) (*ReversalRecord, error) {
+	if admin == nil {
+		return nil, errors.New("missing required data")
 	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" || utf8.RuneCountInString(reason) > MaxMessageLength {
+		return nil, ErrInvalidReason
+	}
+	if amount < 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	var saved *ReversalRecord
+	err := s.uow.RunInTransaction(ctx, func(ctx context.Context) error {
+		original, err := s.transactions.GetTransaction(ctx, id)
+		if err != nil {
+			return err
+		}
+		if original.Type != Transfer || !original.Settled() || original.FromUser == nil || original.ToUser == nil {
+			return ErrNotReversible
+		}
+		// все возвраты перевода списывают с получателя, его блокировка не даёт вернуть больше перевода.
+		if err := s.transactions.LockUsers(ctx, original.ToUser.ID); err != nil {
+			return err
+		}
+		reversed, err := s.transactions.SumReversed(ctx, id)
+		if err != nil {
+			return err
+		}
+		remaining := original.Amount - reversed
+		if remaining <= 0 {
+			return ErrAlreadyReversed
+		}
+		requested := amount
+		if requested == 0 {
+			requested = remaining
+		}
+		if requested > remaining {
+			return ErrReversalTooLarge
+		}
+		balance, err := s.transactions.GetBalance(ctx, original.ToUser.ID)
+		if err != nil {
+			return err
+		}
+		reverse := min(requested, balance)
+		if reverse <= 0 {
+			return ErrNothingToReverse
+		}
+
+		prev := int64(original.ID)
+		saved, err = s.transactions.SaveReversal(ctx, &ReversalRecord{
+			Transaction: &Transaction{
+				FromUser:        original.ToUser,
+				ToUser:          original.FromUser,
+				Amount:          reverse,
+				Type:            Reversal,
+				PrevTransaction: &prev,
+				CreatedAt:       s.now(),
+			},
+			Original:  original,
+			Admin:     admin,
+			Reason:    reason,
+			Requested: requested,
+			Remaining: remaining - reverse,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// CreatePool открывает сбор в подарок recipient. goal = 0 — без цели, нулевой deadline — без срока.
+func (s *service) CreatePool(ctx context.Context, organizer, recipient *auth.User, title string, goal int, deadline time.Time) (*Pool, error) {
+	if organizer == nil || recipient == nil {
+		return nil, errors.New("missing required data")
+	}
+	if organizer.ID == recipient.ID {
+		return nil, ErrInvalidRecipient
+	}
+	// название очищается так же, как сообщение к переводу.
+	note, err := NewNote(title, nil)
+	if err != nil || note.Message == "" {
+		return nil, ErrInvalidPoolTitle
+	}
+	if goal < 0 {
+		return nil, ErrInvalidGoal
+	}
+	now := s.now()
+	if !deadline.IsZero() && !deadline.After(now) {
+		return nil, ErrInvalidDeadline
+	}
+	return s.transactions.CreatePool(ctx, &Pool{
+		Organizer: organizer,
+		Recipient: recipient,
+		Title:     note.Message,
+		Goal:      goal,
+		Deadline:  deadline,
+		Status:    PoolOpen,
+		CreatedAt: now,
+	})
+}
+
+// GetPool возвращает сбор и его взносы организатору, получателю, участникам и hr-admin.
+// Для остальных сбор не отличается от несуществующего.
+func (s *service) GetPool(ctx context.Context, user *auth.User, id PoolID) (*Pool, []*Transaction, error) {
+	pool, err := s.transactions.GetPool(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	contributions, err := s.transactions.ListPoolContributions(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !canViewPool(user, pool, contributions) {
+		return nil, nil, ErrPoolNotFound
+	}
+	return pool, contributions, nil
+}
+
+func canViewPool(user *auth.User, pool *Pool, contributions []*Transaction) bool {
+	if user.HasRole(auth.RoleHRAdmin) || pool.Organizer.ID == user.ID || pool.Recipient.ID == user.ID {
+		return true
+	}
+	return slices.ContainsFunc(contributions, func(t *Transaction) bool {
+		return t.FromUser != nil && t.FromUser.ID == user.ID
+	})
+}
+
+// ListPools возвращает сборы, которые пользователь организовал или в которые внёс монеты.
+func (s *service) ListPools(ctx context.Context, user *auth.User) ([]*Pool, error) {
+	return s.transactions.ListPools(ctx, user.ID)
+}
+
+// Contribute вносит монеты пользователя в сбор, они лежат в escrow до закрытия сбора.
+func (s *service) Contribute(ctx context.Context, user *auth.User, id PoolID, amount int, note Note) (*Transaction, error) {
+	if user == nil {
+		return nil, errors.New("missing required data")
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	pool, err := s.transactions.GetPool(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Recipient.ID == user.ID {
+		return nil, ErrPoolRecipient
+	}
+	t := &Transaction{
+		FromUser:  user,
+		ToUser:    pool.Recipient,
+		Amount:    amount,
+		Type:      PoolContribution,
+		PoolID:    id,
+		CreatedAt: s.now(),
+		Note:      note,
+	}
+	// взнос — перевод получателю сбора, поэтому проходит те же правила, что и обычный перевод.
+	var saved *Transaction
+	err = s.checkPolicy(ctx, user, []TransferLeg{{To: pool.Recipient, Amount: amount}}, func(ctx context.Context) (err error) {
+		// статус и срок сбора проверяются в хранилище под блокировкой сбора.
+		saved, err = s.transactions.SavePoolContribution(ctx, t)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// ClosePool выплачивает собранное получателю одним начислением.
+func (s *service) ClosePool(ctx context.Context, user *auth.User, id PoolID) (*Pool, error) {
+	return s.closePool(ctx, user, id, PoolPaidOut)
+}
+
+// CancelPool отменяет сбор и возвращает участникам их взносы.
+func (s *service) CancelPool(ctx context.Context, user *auth.User, id PoolID) (*Pool, error) {
+	return s.closePool(ctx, user, id, PoolCancelled)
+}
+
+func (s *service) closePool(ctx context.Context, user *auth.User, id PoolID, status PoolStatus) (*Pool, error) {
+	pool, err := s.transactions.GetPool(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Organizer.ID != user.ID {
+		return nil, ErrNotOrganizer
+	}
+	return s.transactions.ClosePool(ctx, id, status)
+}
+
+// ClosePoolsPastDeadline закрывает до PendingExpiryBatch сборов с истекшим сроком:
+// собранное выплачивается получателю, сбор без взносов отменяется.
+func (s *service) ClosePoolsPastDeadline(ctx context.Context) (int, error) {
+	ids, err := s.transactions.ListPoolsPastDeadline(ctx, s.now(), s.cfg.PendingExpiryBatch)
+	if err != nil {
+		return 0, err
+	}
+	closed := 0
+	for _, id := range ids {
+		_, err := s.transactions.ClosePool(ctx, id, PoolPaidOut)
+		if errors.Is(err, ErrPoolEmpty) {
+			_, err = s.transactions.ClosePool(ctx, id, PoolCancelled)
+		}
+		switch {
+		case errors.Is(err, ErrPoolClosed):
+			// сбор успел закрыть организатор или другая реплика.
+		case err != nil:
+			return closed, err
+		default:
+			closed++
+		}
+	}
+	return closed, nil
+}
+
+func (s *service) Purchase(ctx context.Context, buyer *auth.User, amount int) (*Transaction, error) {
 	t := Transaction{
 		ID:        0,
 		FromUser:  buyer,
@@ -58,6 +543,11 @@ func (s *service) Purchase(ctx context.Context, buyer *auth.User, amount int) (*
 	return s.transactions.SaveTransaction(ctx, &t)
 }
 
+// GetBalance возвращает текущий баланс пользователя по журналу проводок.
+func (s *service) GetBalance(ctx context.Context, user *auth.User) (int, error) {
+	return s.transactions.GetBalance(ctx, user.ID)
+}
+
 func (s *service) GetUserByUsername(ctx context.Context, username string) (*auth.User, error) {
 	return s.authService.GetUserByUsername(ctx, username)
 }
@@ -69,7 +559,7 @@ func (s *service) ListTransfers(ctx context.Context, user *auth.User) (incoming,
 	)
 
 	g.Go(func() error {
-		incoming, inErr = s.transactions.GetIncomingTransfers(ctx, user.ID)
+		incoming, inErr = s.transactions.GetIncomingTransfers(ctx, user.ID, s.cfg.InfoHistoryLimit)
 		if inErr != nil {
 			if errors.Is(inErr, common.ErrNotFound) {
 				incoming = make([]*Transaction, 0)
@@ -80,7 +570,7 @@ func (s *service) ListTransfers(ctx context.Context, user *auth.User) (incoming,
 		return nil
 	})
 	g.Go(func() error {
-		outgoing, outErr = s.transactions.GetOutgoingTransfers(ctx, user.ID)
+		outgoing, outErr = s.transactions.GetOutgoingTransfers(ctx, user.ID, s.cfg.InfoHistoryLimit)
 		if outErr != nil {
 			if errors.Is(outErr, common.ErrNotFound) {
 				outgoing = make([]*Transaction, 0)
@@ -96,3 +586,23 @@ func (s *service) ListTransfers(ctx context.Context, user *auth.User) (incoming,
 	}
 	return nil, nil, err
 }
+
+// History возвращает страницу истории пользователя по фильтру.
+func (s *service) History(ctx context.Context, user *auth.User, filter HistoryFilter) (*HistoryPage, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	limit := filter.Limit
+	// лишняя запись показывает, есть ли следующая страница.
+	filter.Limit++
+	entries, err := s.transactions.ListHistory(ctx, user.ID, filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &HistoryPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextCursor = Cursor(page.Entries[limit-1].EntryID)
+	}
+	return page, nil
+}
@@ -5,6 +5,7 @@ import (
 	"avito-intern/internal/common"
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,8 +16,16 @@ type mockAuthService struct {
 	getUserByUsernameFunc func(ctx context.Context, username string) (*auth.User, error)
 }
 
-func (m *mockAuthService) AuthUser(_ context.Context, _, _ string) (auth.Token, error) {
-	return "", nil
+func (m *mockAuthService) AuthUser(_ context.Context, _, _ string) (*auth.TokenPair, error) {
+	return nil, nil
+}
+
+func (m *mockAuthService) Refresh(_ context.Context, _ auth.RefreshToken) (*auth.TokenPair, error) {
+	return nil, nil
+}
+
+func (m *mockAuthService) Logout(_ context.Context, _ auth.Token) error {
+	return nil
 }
 
 func (m *mockAuthService) GetUserFromToken(_ context.Context, _ auth.Token) (*auth.User, error) {
@@ -27,22 +36,152 @@ func (m *mockAuthService) GetUserByUsername(ctx context.Context, username string
 	return m.getUserByUsernameFunc(ctx, username)
 }
 
+func (m *mockAuthService) Register(_ context.Context, _, _ string) (*auth.TokenPair, error) {
+	return nil, nil
+}
+
+func (m *mockAuthService) SetRoles(_ context.Context, _ string, _ []auth.Role) (*auth.User, error) {
+	return nil, nil
+}
+
+func (m *mockAuthService) BootstrapAdmin(_ context.Context) error {
+	return nil
+}
+
+func (m *mockAuthService) RunCleanup(_ context.Context) {}
+
 type mockRepository struct {
 	saveTransactionFunc  func(ctx context.Context, tx *Transaction) (*Transaction, error)
-	getIncomingTransfers func(ctx context.Context, userID auth.UserID) ([]*Transaction, error)
-	getOutgoingTransfers func(ctx context.Context, userID auth.UserID) ([]*Transaction, error)
+	saveBatchFunc        func(ctx context.Context, b *Batch) (*Batch, error)
+	getIncomingTransfers func(ctx context.Context, userID auth.UserID, limit int) ([]*Transaction, error)
+	getOutgoingTransfers func(ctx context.Context, userID auth.UserID, limit int) ([]*Transaction, error)
+	getBalance           func(ctx context.Context, userID auth.UserID) (int, error)
+	listHistory          func(ctx context.Context, userID auth.UserID, filter HistoryFilter) ([]*HistoryEntry, error)
+	sumOutgoing          func(ctx context.Context, from auth.UserID, to *auth.UserID, since time.Time) (int, error)
+	getTransaction       func(ctx context.Context, id TransactionID) (*Transaction, error)
+	sumReversed          func(ctx context.Context, id TransactionID) (int, error)
+	saveReversal         func(ctx context.Context, r *ReversalRecord) (*ReversalRecord, error)
+	settlePending        func(ctx context.Context, id TransactionID, status Status) (*Transaction, error)
+	listPending          func(ctx context.Context, userID auth.UserID) ([]*Transaction, error)
+	listExpiredPending   func(ctx context.Context, now time.Time, limit int) ([]TransactionID, error)
+	listOpenLots         func(ctx context.Context, userID auth.UserID, receivedBefore time.Time) ([]*Lot, error)
+	listExpiredLotUsers  func(ctx context.Context, receivedBefore time.Time, limit int) ([]auth.UserID, error)
+	expireLots           func(ctx context.Context, userID auth.UserID, receivedBefore time.Time) (*Transaction, error)
+	createPool           func(ctx context.Context, p *Pool) (*Pool, error)
+	getPool              func(ctx context.Context, id PoolID) (*Pool, error)
+	listPools            func(ctx context.Context, userID auth.UserID) ([]*Pool, error)
+	listContributions    func(ctx context.Context, id PoolID) ([]*Transaction, error)
+	saveContribution     func(ctx context.Context, t *Transaction) (*Transaction, error)
+	closePool            func(ctx context.Context, id PoolID, status PoolStatus) (*Pool, error)
+	listPastDeadline     func(ctx context.Context, now time.Time, limit int) ([]PoolID, error)
+	locked               []auth.UserID
+}
+
+func (m *mockRepository) CreatePool(ctx context.Context, p *Pool) (*Pool, error) {
+	return m.createPool(ctx, p)
+}
+
+func (m *mockRepository) GetPool(ctx context.Context, id PoolID) (*Pool, error) {
+	return m.getPool(ctx, id)
+}
+
+func (m *mockRepository) ListPools(ctx context.Context, userID auth.UserID) ([]*Pool, error) {
+	return m.listPools(ctx, userID)
+}
+
+func (m *mockRepository) ListPoolContributions(ctx context.Context, id PoolID) ([]*Transaction, error) {
+	return m.listContributions(ctx, id)
+}
+
+func (m *mockRepository) SavePoolContribution(ctx context.Context, t *Transaction) (*Transaction, error) {
+	return m.saveContribution(ctx, t)
+}
+
+func (m *mockRepository) ClosePool(ctx context.Context, id PoolID, status PoolStatus) (*Pool, error) {
+	return m.closePool(ctx, id, status)
+}
+
+func (m *mockRepository) ListPoolsPastDeadline(ctx context.Context, now time.Time, limit int) ([]PoolID, error) {
+	return m.listPastDeadline(ctx, now, limit)
+}
+
+func (m *mockRepository) SettlePending(ctx context.Context, id TransactionID, status Status) (*Transaction, error) {
+	return m.settlePending(ctx, id, status)
+}
+
+func (m *mockRepository) ListPending(ctx context.Context, userID auth.UserID) ([]*Transaction, error) {
+	return m.listPending(ctx, userID)
+}
+
+func (m *mockRepository) ListExpiredPending(ctx context.Context, now time.Time, limit int) ([]TransactionID, error) {
+	return m.listExpiredPending(ctx, now, limit)
+}
+
+func (m *mockRepository) ListOpenLots(ctx context.Context, userID auth.UserID, receivedBefore time.Time) ([]*Lot, error) {
+	return m.listOpenLots(ctx, userID, receivedBefore)
+}
+
+func (m *mockRepository) ListUsersWithExpiredLots(ctx context.Context, receivedBefore time.Time, limit int) ([]auth.UserID, error) {
+	return m.listExpiredLotUsers(ctx, receivedBefore, limit)
+}
+
+func (m *mockRepository) ExpireLots(ctx context.Context, userID auth.UserID, receivedBefore time.Time) (*Transaction, error) {
+	return m.expireLots(ctx, userID, receivedBefore)
+}
+
+func (m *mockRepository) GetTransaction(ctx context.Context, id TransactionID) (*Transaction, error) {
+	return m.getTransaction(ctx, id)
+}
+
+func (m *mockRepository) SumReversed(ctx context.Context, id TransactionID) (int, error) {
+	return m.sumReversed(ctx, id)
+}
+
+func (m *mockRepository) SaveReversal(ctx context.Context, r *ReversalRecord) (*ReversalRecord, error) {
+	return m.saveReversal(ctx, r)
+}
+
+func (m *mockRepository) LockUsers(_ context.Context, userIDs ...auth.UserID) error {
+	m.locked = append(m.locked, userIDs...)
+	return nil
+}
+
+func (m *mockRepository) SumOutgoingTransfers(ctx context.Context, from auth.UserID, to *auth.UserID, since time.Time) (int, error) {
+	return m.sumOutgoing(ctx, from, to, since)
+}
+
+func (m *mockRepository) LastOutgoingTransferAt(_ context.Context, _ auth.UserID) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+type fakeUnitOfWork struct{}
+
+func (fakeUnitOfWork) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
 }
 
 func (m *mockRepository) SaveTransaction(ctx context.Context, tx *Transaction) (*Transaction, error) {
 	return m.saveTransactionFunc(ctx, tx)
 }
 
-func (m *mockRepository) GetIncomingTransfers(ctx context.Context, userID auth.UserID) ([]*Transaction, error) {
-	return m.getIncomingTransfers(ctx, userID)
+func (m *mockRepository) SaveBatch(ctx context.Context, b *Batch) (*Batch, error) {
+	return m.saveBatchFunc(ctx, b)
 }
 
-func (m *mockRepository) GetOutgoingTransfers(ctx context.Context, userID auth.UserID) ([]*Transaction, error) {
-	return m.getOutgoingTransfers(ctx, userID)
+func (m *mockRepository) GetIncomingTransfers(ctx context.Context, userID auth.UserID, limit int) ([]*Transaction, error) {
+	return m.getIncomingTransfers(ctx, userID, limit)
+}
+
+func (m *mockRepository) GetOutgoingTransfers(ctx context.Context, userID auth.UserID, limit int) ([]*Transaction, error) {
+	return m.getOutgoingTransfers(ctx, userID, limit)
+}
+
+func (m *mockRepository) ListHistory(ctx context.Context, userID auth.UserID, filter HistoryFilter) ([]*HistoryEntry, error) {
+	return m.listHistory(ctx, userID, filter)
+}
+
+func (m *mockRepository) GetBalance(ctx context.Context, userID auth.UserID) (int, error) {
+	return m.getBalance(ctx, userID)
 }
 
 func TestTransfer_Success(t *testing.T) {
@@ -64,9 +203,10 @@ func TestTransfer_Success(t *testing.T) {
 		},
 	}
 
-	svc := NewService(&mockAuthService{}, repo)
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
 
-	tx, err := svc.Transfer(context.Background(), fromUser, toUser, 50)
+	note := Note{Message: "спасибо за ревью", Tags: []Tag{"thanks", "🎉"}}
+	tx, err := svc.Transfer(context.Background(), fromUser, toUser, 50, note)
 	assert.NoError(t, err)
 	assert.NotNil(t, tx)
 	assert.Equal(t, TransactionID(1), tx.ID)
@@ -74,6 +214,7 @@ func TestTransfer_Success(t *testing.T) {
 	assert.Equal(t, toUser, tx.ToUser)
 	assert.Equal(t, 50, tx.Amount)
 	assert.Equal(t, Transfer, tx.Type)
+	assert.Equal(t, note, tx.Note)
 }
 
 func TestTransfer_InsufficientFunds(t *testing.T) {
@@ -88,23 +229,142 @@ func TestTransfer_InsufficientFunds(t *testing.T) {
 		CoinBalance: 50,
 	}
 
-	svc := NewService(&mockAuthService{}, &mockRepository{})
+	// баланс в пользователе может устареть, решение принимает хранилище.
+	repo := &mockRepository{
+		saveTransactionFunc: func(_ context.Context, _ *Transaction) (*Transaction, error) {
+			return nil, ErrNotEnoughCoins
+		},
+	}
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
 
-	tx, err := svc.Transfer(context.Background(), fromUser, toUser, 50)
+	tx, err := svc.Transfer(context.Background(), fromUser, toUser, 50, Note{})
 	assert.Error(t, err)
 	assert.Nil(t, tx)
-	assert.Contains(t, err.Error(), "not enough coins")
+	assert.ErrorIs(t, err, ErrNotEnoughCoins)
 }
 
 func TestTransfer_MissingUsers(t *testing.T) {
-	svc := NewService(&mockAuthService{}, &mockRepository{})
+	svc := NewService(&Config{}, &mockAuthService{}, &mockRepository{}, fakeUnitOfWork{}, nil)
 
-	tx, err := svc.Transfer(context.Background(), nil, nil, 50)
+	tx, err := svc.Transfer(context.Background(), nil, nil, 50, Note{})
 	assert.Error(t, err)
 	assert.Nil(t, tx)
 	assert.Contains(t, err.Error(), "missing required data")
 }
 
+func TestTransfer_InvalidAmount(t *testing.T) {
+	svc := NewService(&Config{}, &mockAuthService{}, &mockRepository{}, fakeUnitOfWork{}, nil)
+
+	for _, amount := range []int{0, -10} {
+		tx, err := svc.Transfer(context.Background(), &auth.User{ID: 1}, &auth.User{ID: 2}, amount, Note{})
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+		assert.Nil(t, tx)
+	}
+}
+
+func TestTransfer_PolicyCheckedUnderLock(t *testing.T) {
+	from := &auth.User{ID: 1}
+	saved := false
+	repo := &mockRepository{
+		sumOutgoing: func(_ context.Context, _ auth.UserID, _ *auth.UserID, _ time.Time) (int, error) {
+			return 90, nil
+		},
+		saveTransactionFunc: func(_ context.Context, tx *Transaction) (*Transaction, error) {
+			saved = true
+			return tx, nil
+		},
+	}
+	cfg := &Config{TransferMinAmount: 1, TransferDailyCap: 100}
+	svc := NewService(cfg, &mockAuthService{}, repo, fakeUnitOfWork{}, NewTransferPolicy(cfg, repo))
+
+	_, err := svc.Transfer(context.Background(), from, &auth.User{ID: 2}, 20, Note{})
+	var capErr ErrCapExceeded
+	assert.ErrorAs(t, err, &capErr)
+	assert.Equal(t, "daily_cap_exceeded", capErr.Code())
+	assert.False(t, saved)
+	assert.Equal(t, []auth.UserID{from.ID}, repo.locked)
+
+	_, err = svc.Transfer(context.Background(), from, &auth.User{ID: 2}, 10, Note{})
+	assert.NoError(t, err)
+	assert.True(t, saved)
+}
+
+func TestTransferBatch_Success(t *testing.T) {
+	from := &auth.User{ID: 1, Username: "lead"}
+	team := []*auth.User{{ID: 2, Username: "a"}, {ID: 3, Username: "b"}}
+
+	repo := &mockRepository{
+		saveBatchFunc: func(_ context.Context, b *Batch) (*Batch, error) {
+			saved := *b
+			saved.ID = 7
+			for i, tx := range saved.Transfers {
+				tx.ID = TransactionID(i + 1)
+				tx.BatchID = saved.ID
+			}
+			return &saved, nil
+		},
+	}
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+
+	note := Note{Message: "спасибо за релиз", Tags: []Tag{"thanks"}}
+	batch, err := svc.TransferBatch(context.Background(), from, []TransferLeg{
+		{To: team[0], Amount: 10},
+		{To: team[1], Amount: 20},
+	}, note)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchID(7), batch.ID)
+	assert.Len(t, batch.Transfers, 2)
+	for i, tx := range batch.Transfers {
+		assert.Equal(t, from, tx.FromUser)
+		assert.Equal(t, team[i], tx.ToUser)
+		assert.Equal(t, Transfer, tx.Type)
+		assert.Equal(t, note, tx.Note)
+		assert.Equal(t, BatchID(7), tx.BatchID)
+	}
+}
+
+func TestTransferBatch_InvalidLegs(t *testing.T) {
+	from := &auth.User{ID: 1}
+	to := &auth.User{ID: 2}
+
+	// ни один перевод не сохраняется, если хотя бы один получатель некорректен.
+	svc := NewService(&Config{}, &mockAuthService{}, &mockRepository{}, fakeUnitOfWork{}, nil)
+
+	_, err := svc.TransferBatch(context.Background(), from, []TransferLeg{
+		{To: to, Amount: 10},
+		{To: nil, Amount: 10},
+		{To: from, Amount: 10},
+		{To: &auth.User{ID: 3}, Amount: 0},
+		{To: to, Amount: 5},
+	}, Note{})
+	var batchErr ErrInvalidBatch
+	assert.ErrorAs(t, err, &batchErr)
+	assert.Len(t, batchErr.Legs, 4)
+	assert.ErrorIs(t, batchErr.Legs[1], auth.ErrUserNotFound)
+	assert.ErrorIs(t, batchErr.Legs[2], ErrInvalidRecipient)
+	assert.ErrorIs(t, batchErr.Legs[3], ErrInvalidAmount)
+	assert.ErrorIs(t, batchErr.Legs[4], ErrDuplicateRecipient)
+
+	_, err = svc.TransferBatch(context.Background(), from, nil, Note{})
+	assert.ErrorIs(t, err, ErrEmptyBatch)
+}
+
+func TestTransferBatch_InsufficientFunds(t *testing.T) {
+	repo := &mockRepository{
+		saveBatchFunc: func(_ context.Context, _ *Batch) (*Batch, error) {
+			return nil, ErrNotEnoughCoins
+		},
+	}
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+
+	batch, err := svc.TransferBatch(context.Background(), &auth.User{ID: 1}, []TransferLeg{
+		{To: &auth.User{ID: 2}, Amount: 600},
+		{To: &auth.User{ID: 3}, Amount: 600},
+	}, Note{})
+	assert.ErrorIs(t, err, ErrNotEnoughCoins)
+	assert.Nil(t, batch)
+}
+
 func TestPurchase_Success(t *testing.T) {
 	buyer := &auth.User{
 		ID:          1,
@@ -119,7 +379,7 @@ func TestPurchase_Success(t *testing.T) {
 		},
 	}
 
-	svc := NewService(&mockAuthService{}, repo)
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
 
 	tx, err := svc.Purchase(context.Background(), buyer, 50)
 	assert.NoError(t, err)
@@ -138,12 +398,18 @@ func TestPurchase_InsufficientFunds(t *testing.T) {
 		CoinBalance: 40,
 	}
 
-	svc := NewService(&mockAuthService{}, &mockRepository{})
+	// баланс в пользователе может устареть, решение принимает хранилище.
+	repo := &mockRepository{
+		saveTransactionFunc: func(_ context.Context, _ *Transaction) (*Transaction, error) {
+			return nil, ErrNotEnoughCoins
+		},
+	}
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
 
 	tx, err := svc.Purchase(context.Background(), buyer, 50)
 	assert.Error(t, err)
 	assert.Nil(t, tx)
-	assert.Contains(t, err.Error(), "not enough coins")
+	assert.ErrorIs(t, err, ErrNotEnoughCoins)
 }
 
 func TestListTransfers(t *testing.T) {
@@ -176,15 +442,15 @@ func TestListTransfers(t *testing.T) {
 	}
 
 	repo := &mockRepository{
-		getIncomingTransfers: func(_ context.Context, _ auth.UserID) ([]*Transaction, error) {
+		getIncomingTransfers: func(_ context.Context, _ auth.UserID, _ int) ([]*Transaction, error) {
 			return incomingTx, nil
 		},
-		getOutgoingTransfers: func(_ context.Context, _ auth.UserID) ([]*Transaction, error) {
+		getOutgoingTransfers: func(_ context.Context, _ auth.UserID, _ int) ([]*Transaction, error) {
 			return outgoingTx, nil
 		},
 	}
 
-	svc := NewService(&mockAuthService{}, repo)
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
 
 	incoming, outgoing, err := svc.ListTransfers(context.Background(), user)
 	assert.NoError(t, err)
@@ -199,15 +465,15 @@ func TestListTransfers_NotFound(t *testing.T) {
 	}
 
 	repo := &mockRepository{
-		getIncomingTransfers: func(_ context.Context, _ auth.UserID) ([]*Transaction, error) {
+		getIncomingTransfers: func(_ context.Context, _ auth.UserID, _ int) ([]*Transaction, error) {
 			return nil, common.ErrNotFound
 		},
-		getOutgoingTransfers: func(_ context.Context, _ auth.UserID) ([]*Transaction, error) {
+		getOutgoingTransfers: func(_ context.Context, _ auth.UserID, _ int) ([]*Transaction, error) {
 			return nil, common.ErrNotFound
 		},
 	}
 
-	svc := NewService(&mockAuthService{}, repo)
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
 
 	incoming, outgoing, err := svc.ListTransfers(context.Background(), user)
 	assert.NoError(t, err)
@@ -223,15 +489,15 @@ func TestListTransfers_Error(t *testing.T) {
 
 	expectedErr := errors.New("database error")
 	repo := &mockRepository{
-		getIncomingTransfers: func(_ context.Context, _ auth.UserID) ([]*Transaction, error) {
+		getIncomingTransfers: func(_ context.Context, _ auth.UserID, _ int) ([]*Transaction, error) {
 			return nil, expectedErr
 		},
-		getOutgoingTransfers: func(_ context.Context, _ auth.UserID) ([]*Transaction, error) {
+		getOutgoingTransfers: func(_ context.Context, _ auth.UserID, _ int) ([]*Transaction, error) {
 			return nil, expectedErr
 		},
 	}
 
-	svc := NewService(&mockAuthService{}, repo)
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
 
 	incoming, outgoing, err := svc.ListTransfers(context.Background(), user)
 	assert.Error(t, err)
@@ -254,7 +520,7 @@ func TestGetUserByUsername(t *testing.T) {
 		},
 	}
 
-	svc := NewService(authSvc, &mockRepository{})
+	svc := NewService(&Config{}, authSvc, &mockRepository{}, fakeUnitOfWork{}, nil)
 
 	user, err := svc.GetUserByUsername(context.Background(), "testuser")
 	assert.NoError(t, err)
@@ -265,3 +531,574 @@ func TestGetUserByUsername(t *testing.T) {
 	assert.Nil(t, user)
 	assert.ErrorIs(t, err, auth.ErrUserNotFound)
 }
+
+func TestGetBalance(t *testing.T) {
+	user := &auth.User{ID: 1, CoinBalance: 1000}
+
+	repo := &mockRepository{
+		getBalance: func(_ context.Context, userID auth.UserID) (int, error) {
+			assert.Equal(t, user.ID, userID)
+			return 420, nil
+		},
+	}
+
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+
+	balance, err := svc.GetBalance(context.Background(), user)
+	assert.NoError(t, err)
+	assert.Equal(t, 420, balance)
+}
+
+func TestListTransfers_InfoHistoryLimit(t *testing.T) {
+	var limits []int
+	repo := &mockRepository{
+		getIncomingTransfers: func(_ context.Context, _ auth.UserID, limit int) ([]*Transaction, error) {
+			limits = append(limits, limit)
+			return nil, nil
+		},
+		getOutgoingTransfers: func(_ context.Context, _ auth.UserID, _ int) ([]*Transaction, error) {
+			return nil, nil
+		},
+	}
+
+	svc := NewService(&Config{InfoHistoryLimit: 10}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+
+	_, _, err := svc.ListTransfers(context.Background(), &auth.User{ID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{10}, limits)
+}
+
+func TestHistory_Pagination(t *testing.T) {
+	user := &auth.User{ID: 1}
+	entries := make([]*HistoryEntry, 0, 5)
+	for id := int64(5); id > 0; id-- {
+		entries = append(entries, &HistoryEntry{EntryID: id, Direction: Incoming})
+	}
+
+	repo := &mockRepository{
+		listHistory: func(_ context.Context, userID auth.UserID, filter HistoryFilter) ([]*HistoryEntry, error) {
+			assert.Equal(t, user.ID, userID)
+			assert.Equal(t, NewestFirst, filter.Order)
+			var res []*HistoryEntry
+			for _, e := range entries {
+				if filter.After == 0 || e.EntryID < int64(filter.After) {
+					res = append(res, e)
+				}
+			}
+			if len(res) > filter.Limit {
+				res = res[:filter.Limit]
+			}
+			return res, nil
+		},
+	}
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+
+	page, err := svc.History(context.Background(), user, HistoryFilter{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, page.Entries, 2)
+	assert.Equal(t, Cursor(4), page.NextCursor)
+
+	page, err = svc.History(context.Background(), user, HistoryFilter{Limit: 2, After: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), page.Entries[0].EntryID)
+	assert.Equal(t, Cursor(2), page.NextCursor)
+
+	page, err = svc.History(context.Background(), user, HistoryFilter{Limit: 2, After: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Len(t, page.Entries, 1)
+	assert.Zero(t, page.NextCursor)
+}
+
+func TestHistory_InvalidFilter(t *testing.T) {
+	svc := NewService(&Config{}, &mockAuthService{}, &mockRepository{}, fakeUnitOfWork{}, nil)
+
+	for _, filter := range []HistoryFilter{
+		{Direction: "sideways"},
+		{Types: []Type{"refund"}},
+		{Order: "random"},
+		{Limit: MaxHistoryLimit + 1},
+		{From: time.Now(), To: time.Now().Add(-time.Hour)},
+	} {
+		_, err := svc.History(context.Background(), &auth.User{ID: 1}, filter)
+		var errFilter ErrInvalidFilter
+		assert.ErrorAs(t, err, &errFilter)
+	}
+}
+
+// reversalRepo — перевод 100 монет от 1 к 2, из которых reversed уже возвращено, а у получателя balance.
+func reversalRepo(original *Transaction, reversed, balance int) *mockRepository {
+	return &mockRepository{
+		getTransaction: func(_ context.Context, id TransactionID) (*Transaction, error) {
+			if id != original.ID {
+				return nil, ErrTransactionNotFound
+			}
+			return original, nil
+		},
+		sumReversed: func(_ context.Context, _ TransactionID) (int, error) {
+			return reversed, nil
+		},
+		getBalance: func(_ context.Context, _ auth.UserID) (int, error) {
+			return balance, nil
+		},
+		saveReversal: func(_ context.Context, r *ReversalRecord) (*ReversalRecord, error) {
+			saved := *r
+			tx := *r.Transaction
+			tx.ID = 99
+			saved.Transaction = &tx
+			return &saved, nil
+		},
+	}
+}
+
+func TestReverse(t *testing.T) {
+	admin := &auth.User{ID: 10, Username: "admin"}
+	sender := &auth.User{ID: 1, Username: "sender"}
+	recipient := &auth.User{ID: 2, Username: "typo"}
+	original := &Transaction{ID: 5, FromUser: sender, ToUser: recipient, Amount: 100, Type: Transfer}
+
+	tests := []struct {
+		name      string
+		reversed  int
+		balance   int
+		amount    int
+		want      int
+		requested int
+		remaining int
+		err       error
+	}{
+		{name: "full", balance: 500, want: 100, requested: 100},
+		{name: "partial amount", balance: 500, amount: 30, want: 30, requested: 30, remaining: 70},
+		{name: "recipient spent coins", balance: 40, want: 40, requested: 100, remaining: 60},
+		{name: "rest after partial", reversed: 40, balance: 500, want: 60, requested: 60},
+		{name: "already reversed", reversed: 100, balance: 500, err: ErrAlreadyReversed},
+		{name: "too large", reversed: 40, balance: 500, amount: 61, err: ErrReversalTooLarge},
+		{name: "nothing left", balance: 0, err: ErrNothingToReverse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := reversalRepo(original, tt.reversed, tt.balance)
+			svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+
+			rev, err := svc.Reverse(context.Background(), admin, original.ID, tt.amount, " wrong recipient ")
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, TransactionID(99), rev.Transaction.ID)
+			assert.Equal(t, Reversal, rev.Transaction.Type)
+			assert.Equal(t, recipient, rev.Transaction.FromUser)
+			assert.Equal(t, sender, rev.Transaction.ToUser)
+			assert.Equal(t, int64(original.ID), *rev.Transaction.PrevTransaction)
+			assert.Equal(t, tt.want, rev.Transaction.Amount)
+			assert.Equal(t, tt.requested, rev.Requested)
+			assert.Equal(t, tt.remaining, rev.Remaining)
+			assert.Equal(t, tt.want < tt.requested, rev.Partial())
+			assert.Equal(t, admin, rev.Admin)
+			assert.Equal(t, "wrong recipient", rev.Reason)
+			assert.Equal(t, []auth.UserID{recipient.ID}, repo.locked)
+		})
+	}
+}
+
+func TestReverse_Invalid(t *testing.T) {
+	admin := &auth.User{ID: 10}
+	purchase := &Transaction{ID: 5, FromUser: &auth.User{ID: 1}, Amount: 100, Type: Purchase}
+	svc := NewService(&Config{}, &mockAuthService{}, reversalRepo(purchase, 0, 100), fakeUnitOfWork{}, nil)
+
+	_, err := svc.Reverse(context.Background(), admin, purchase.ID, 0, "refund")
+	assert.ErrorIs(t, err, ErrNotReversible)
+
+	_, err = svc.Reverse(context.Background(), admin, 6, 0, "refund")
+	assert.ErrorIs(t, err, ErrTransactionNotFound)
+
+	_, err = svc.Reverse(context.Background(), admin, purchase.ID, 0, "   ")
+	assert.ErrorIs(t, err, ErrInvalidReason)
+
+	_, err = svc.Reverse(context.Background(), admin, purchase.ID, -1, "refund")
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+}
+
+func TestTransferPending(t *testing.T) {
+	now := time.Date(2025, 3, 13, 12, 0, 0, 0, time.UTC)
+	repo := &mockRepository{
+		saveTransactionFunc: func(_ context.Context, tx *Transaction) (*Transaction, error) {
+			tx.ID = 1
+			return tx, nil
+		},
+	}
+	svc := NewService(&Config{PendingTTL: time.Hour}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil).(*service)
+	svc.now = func() time.Time { return now }
+
+	tx, err := svc.TransferPending(context.Background(), &auth.User{ID: 1}, &auth.User{ID: 2}, 50, Note{})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusPending, tx.Status)
+	assert.Equal(t, now.Add(time.Hour), tx.ExpiresAt)
+}
+
+func TestSettlePending(t *testing.T) {
+	now := time.Date(2025, 3, 13, 12, 0, 0, 0, time.UTC)
+	sender := &auth.User{ID: 1}
+	recipient := &auth.User{ID: 2}
+	pending := &Transaction{
+		ID: 7, FromUser: sender, ToUser: recipient, Amount: 50, Type: Transfer,
+		Status: StatusPending, ExpiresAt: now.Add(time.Minute),
+	}
+
+	newSvc := func(tx *Transaction) (*service, *[]Status) {
+		var settled []Status
+		repo := &mockRepository{
+			getTransaction: func(_ context.Context, _ TransactionID) (*Transaction, error) {
+				return tx, nil
+			},
+			settlePending: func(_ context.Context, _ TransactionID, status Status) (*Transaction, error) {
+				settled = append(settled, status)
+				res := *tx
+				res.Status = status
+				return &res, nil
+			},
+		}
+		svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil).(*service)
+		svc.now = func() time.Time { return now }
+		return svc, &settled
+	}
+
+	svc, settled := newSvc(pending)
+	tx, err := svc.Accept(context.Background(), recipient, pending.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusAccepted, tx.Status)
+	tx, err = svc.Decline(context.Background(), recipient, pending.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusDeclined, tx.Status)
+	assert.Equal(t, []Status{StatusAccepted, StatusDeclined}, *settled)
+
+	_, err = svc.Accept(context.Background(), sender, pending.ID)
+	assert.ErrorIs(t, err, ErrNotRecipient)
+
+	expired := *pending
+	expired.ExpiresAt = now
+	svc, settled = newSvc(&expired)
+	_, err = svc.Accept(context.Background(), recipient, pending.ID)
+	assert.ErrorIs(t, err, ErrNotPending)
+
+	completed := *pending
+	completed.Status = StatusCompleted
+	svc, _ = newSvc(&completed)
+	_, err = svc.Decline(context.Background(), recipient, pending.ID)
+	assert.ErrorIs(t, err, ErrNotPending)
+	assert.Empty(t, *settled)
+}
+
+func TestExpirePending(t *testing.T) {
+	var expired []TransactionID
+	repo := &mockRepository{
+		listExpiredPending: func(_ context.Context, _ time.Time, limit int) ([]TransactionID, error) {
+			assert.Equal(t, 10, limit)
+			return []TransactionID{1, 2, 3}, nil
+		},
+		settlePending: func(_ context.Context, id TransactionID, status Status) (*Transaction, error) {
+			assert.Equal(t, StatusExpired, status)
+			if id == 2 {
+				// перевод приняли между выборкой и возвратом.
+				return nil, ErrNotPending
+			}
+			expired = append(expired, id)
+			return &Transaction{ID: id, Status: status}, nil
+		},
+	}
+	svc := NewService(&Config{PendingExpiryBatch: 10}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+
+	count, err := svc.(*service).ExpirePending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []TransactionID{1, 3}, expired)
+}
+
+func TestExpireCoins(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	cutoff := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	var expired []auth.UserID
+	repo := &mockRepository{
+		listExpiredLotUsers: func(_ context.Context, receivedBefore time.Time, limit int) ([]auth.UserID, error) {
+			assert.Equal(t, cutoff, receivedBefore)
+			assert.Equal(t, 10, limit)
+			return []auth.UserID{1, 2}, nil
+		},
+		expireLots: func(_ context.Context, userID auth.UserID, receivedBefore time.Time) (*Transaction, error) {
+			assert.Equal(t, cutoff, receivedBefore)
+			if userID == 2 {
+				// монеты успели потратить между выборкой и списанием.
+				return nil, nil
+			}
+			expired = append(expired, userID)
+			return &Transaction{FromUser: &auth.User{ID: userID}, Type: Expiry, Amount: 5}, nil
+		},
+	}
+	svc := NewService(&Config{CoinExpiryMonths: 12, CoinExpiryBatch: 10}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+	svc.(*service).now = func() time.Time { return now }
+
+	count, err := svc.(*service).ExpireCoins(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []auth.UserID{1}, expired)
+
+	// без срока действия монеты не сгорают.
+	svc = NewService(&Config{}, &mockAuthService{}, &mockRepository{}, fakeUnitOfWork{}, nil)
+	count, err = svc.(*service).ExpireCoins(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestExpiringCoins(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	user := &auth.User{ID: 1}
+	repo := &mockRepository{
+		listOpenLots: func(_ context.Context, userID auth.UserID, receivedBefore time.Time) ([]*Lot, error) {
+			assert.Equal(t, user.ID, userID)
+			assert.Equal(t, time.Date(2025, 4, 14, 12, 0, 0, 0, time.UTC), receivedBefore)
+			return []*Lot{
+				{Remaining: 10, ReceivedAt: time.Date(2025, 3, 20, 9, 0, 0, 0, time.UTC)},
+				{Remaining: 5, ReceivedAt: time.Date(2025, 3, 20, 18, 0, 0, 0, time.UTC)},
+				{Remaining: 7, ReceivedAt: time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC)},
+			}, nil
+		},
+	}
+	svc := NewService(&Config{CoinExpiryMonths: 12, CoinExpiryNotice: 30 * 24 * time.Hour}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+	svc.(*service).now = func() time.Time { return now }
+
+	expiring, err := svc.ExpiringCoins(context.Background(), user)
+	assert.NoError(t, err)
+	assert.Equal(t, []ExpiringCoins{
+		{Amount: 15, ExpiresAt: time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{Amount: 7, ExpiresAt: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}, expiring)
+}
+
+func TestListPending(t *testing.T) {
+	user := &auth.User{ID: 1}
+	in := &Transaction{ID: 1, FromUser: &auth.User{ID: 2}, ToUser: user, Status: StatusPending}
+	out := &Transaction{ID: 2, FromUser: user, ToUser: &auth.User{ID: 3}, Status: StatusPending}
+	repo := &mockRepository{
+		listPending: func(_ context.Context, _ auth.UserID) ([]*Transaction, error) {
+			return []*Transaction{in, out}, nil
+		},
+	}
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+
+	incoming, outgoing, err := svc.ListPending(context.Background(), user)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Transaction{in}, incoming)
+	assert.Equal(t, []*Transaction{out}, outgoing)
+}
+
+func TestCreatePool(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	organizer := &auth.User{ID: 1, Username: "alice"}
+	recipient := &auth.User{ID: 2, Username: "bob"}
+	tests := []struct {
+		name      string
+		recipient *auth.User
+		title     string
+		goal      int
+		deadline  time.Time
+		wantErr   error
+	}{
+		{name: "ok", recipient: recipient, title: " С днём рождения! ", goal: 500, deadline: now.Add(time.Hour)},
+		{name: "no deadline", recipient: recipient, title: "Проводы"},
+		{name: "organizer is recipient", recipient: organizer, title: "Проводы", wantErr: ErrInvalidRecipient},
+		{name: "empty title", recipient: recipient, title: " \u200e ", wantErr: ErrInvalidPoolTitle},
+		{name: "negative goal", recipient: recipient, title: "Проводы", goal: -1, wantErr: ErrInvalidGoal},
+		{name: "deadline passed", recipient: recipient, title: "Проводы", deadline: now, wantErr: ErrInvalidDeadline},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *Pool
+			repo := &mockRepository{
+				createPool: func(_ context.Context, p *Pool) (*Pool, error) {
+					created = p
+					saved := *p
+					saved.ID = 1
+					return &saved, nil
+				},
+			}
+			svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+			svc.(*service).now = func() time.Time { return now }
+
+			pool, err := svc.CreatePool(context.Background(), organizer, tt.recipient, tt.title, tt.goal, tt.deadline)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, created)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, PoolID(1), pool.ID)
+			assert.Equal(t, PoolOpen, created.Status)
+			assert.Equal(t, strings.TrimSpace(tt.title), created.Title)
+			assert.Equal(t, tt.deadline, created.Deadline)
+		})
+	}
+}
+
+func TestContribute(t *testing.T) {
+	organizer := &auth.User{ID: 1, Username: "alice"}
+	recipient := &auth.User{ID: 2, Username: "bob"}
+	contributor := &auth.User{ID: 3, Username: "carol"}
+	var saved *Transaction
+	repo := &mockRepository{
+		getPool: func(_ context.Context, id PoolID) (*Pool, error) {
+			if id != 7 {
+				return nil, ErrPoolNotFound
+			}
+			return &Pool{ID: 7, Organizer: organizer, Recipient: recipient, Status: PoolOpen}, nil
+		},
+		saveContribution: func(_ context.Context, tx *Transaction) (*Transaction, error) {
+			saved = tx
+			return tx, nil
+		},
+	}
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+	ctx := context.Background()
+
+	_, err := svc.Contribute(ctx, contributor, 7, 50, Note{Message: "С днём рождения!"})
+	assert.NoError(t, err)
+	assert.Equal(t, PoolContribution, saved.Type)
+	assert.Equal(t, PoolID(7), saved.PoolID)
+	assert.Equal(t, contributor, saved.FromUser)
+	assert.Equal(t, recipient, saved.ToUser)
+	assert.Equal(t, 50, saved.Amount)
+
+	// организатор тоже может внести монеты, а получатель — нет.
+	_, err = svc.Contribute(ctx, organizer, 7, 10, Note{})
+	assert.NoError(t, err)
+	_, err = svc.Contribute(ctx, recipient, 7, 10, Note{})
+	assert.ErrorIs(t, err, ErrPoolRecipient)
+	_, err = svc.Contribute(ctx, contributor, 7, 0, Note{})
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = svc.Contribute(ctx, contributor, 8, 10, Note{})
+	assert.ErrorIs(t, err, ErrPoolNotFound)
+}
+
+func TestContribute_PolicyCheckedUnderLock(t *testing.T) {
+	contributor := &auth.User{ID: 3, Username: "carol"}
+	recipient := &auth.User{ID: 2, Username: "bob"}
+	var saved bool
+	var sumTo *auth.UserID
+	repo := &mockRepository{
+		getPool: func(_ context.Context, _ PoolID) (*Pool, error) {
+			return &Pool{ID: 7, Organizer: &auth.User{ID: 1}, Recipient: recipient, Status: PoolOpen}, nil
+		},
+		sumOutgoing: func(_ context.Context, _ auth.UserID, to *auth.UserID, _ time.Time) (int, error) {
+			sumTo = to
+			return 90, nil
+		},
+		saveContribution: func(_ context.Context, tx *Transaction) (*Transaction, error) {
+			saved = true
+			return tx, nil
+		},
+	}
+	cfg := &Config{TransferMinAmount: 1, TransferMaxAmount: 50, TransferRecipientDailyCap: 100}
+	svc := NewService(cfg, &mockAuthService{}, repo, fakeUnitOfWork{}, NewTransferPolicy(cfg, repo))
+	ctx := context.Background()
+
+	_, err := svc.Contribute(ctx, contributor, 7, 60, Note{})
+	var tooLarge ErrAmountTooLarge
+	assert.ErrorAs(t, err, &tooLarge)
+
+	// лимит на получателя считается по получателю сбора.
+	_, err = svc.Contribute(ctx, contributor, 7, 20, Note{})
+	var capErr ErrRecipientCapExceeded
+	assert.ErrorAs(t, err, &capErr)
+	assert.Equal(t, &recipient.ID, sumTo)
+	assert.False(t, saved)
+	assert.Equal(t, []auth.UserID{contributor.ID, contributor.ID}, repo.locked)
+
+	_, err = svc.Contribute(ctx, contributor, 7, 10, Note{})
+	assert.NoError(t, err)
+	assert.True(t, saved)
+}
+
+func TestClosePool(t *testing.T) {
+	organizer := &auth.User{ID: 1, Username: "alice"}
+	var closed []PoolStatus
+	repo := &mockRepository{
+		getPool: func(_ context.Context, id PoolID) (*Pool, error) {
+			return &Pool{ID: id, Organizer: organizer, Recipient: &auth.User{ID: 2}, Status: PoolOpen}, nil
+		},
+		closePool: func(_ context.Context, id PoolID, status PoolStatus) (*Pool, error) {
+			closed = append(closed, status)
+			return &Pool{ID: id, Status: status}, nil
+		},
+	}
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+	ctx := context.Background()
+
+	_, err := svc.ClosePool(ctx, &auth.User{ID: 3}, 1)
+	assert.ErrorIs(t, err, ErrNotOrganizer)
+	_, err = svc.CancelPool(ctx, &auth.User{ID: 3}, 1)
+	assert.ErrorIs(t, err, ErrNotOrganizer)
+	assert.Empty(t, closed)
+
+	pool, err := svc.ClosePool(ctx, organizer, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, PoolPaidOut, pool.Status)
+	pool, err = svc.CancelPool(ctx, organizer, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, PoolCancelled, pool.Status)
+	assert.Equal(t, []PoolStatus{PoolPaidOut, PoolCancelled}, closed)
+}
+
+func TestGetPool_Visibility(t *testing.T) {
+	organizer := &auth.User{ID: 1, Username: "alice"}
+	recipient := &auth.User{ID: 2, Username: "bob"}
+	contributor := &auth.User{ID: 3, Username: "carol"}
+	repo := &mockRepository{
+		getPool: func(_ context.Context, id PoolID) (*Pool, error) {
+			return &Pool{ID: id, Organizer: organizer, Recipient: recipient, Status: PoolOpen}, nil
+		},
+		listContributions: func(_ context.Context, _ PoolID) ([]*Transaction, error) {
+			return []*Transaction{{FromUser: contributor, ToUser: recipient, Amount: 10, Type: PoolContribution}}, nil
+		},
+	}
+	svc := NewService(&Config{}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+	ctx := context.Background()
+
+	admin := &auth.User{ID: 4, Roles: []auth.Role{auth.RoleEmployee, auth.RoleHRAdmin}}
+	for _, user := range []*auth.User{organizer, recipient, contributor, admin} {
+		_, contributions, err := svc.GetPool(ctx, user, 7)
+		assert.NoError(t, err, user.ID)
+		assert.Len(t, contributions, 1)
+	}
+	// посторонний не отличает чужой сбор от несуществующего.
+	_, _, err := svc.GetPool(ctx, &auth.User{ID: 5, Roles: []auth.Role{auth.RoleEmployee}}, 7)
+	assert.ErrorIs(t, err, ErrPoolNotFound)
+}
+
+func TestClosePoolsPastDeadline(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	closed := make(map[PoolID]PoolStatus)
+	repo := &mockRepository{
+		listPastDeadline: func(_ context.Context, at time.Time, limit int) ([]PoolID, error) {
+			assert.Equal(t, now, at)
+			assert.Equal(t, 10, limit)
+			return []PoolID{1, 2, 3}, nil
+		},
+		closePool: func(_ context.Context, id PoolID, status PoolStatus) (*Pool, error) {
+			switch {
+			case id == 2 && status == PoolPaidOut:
+				return nil, ErrPoolEmpty
+			case id == 3:
+				// организатор успел закрыть сбор сам.
+				return nil, ErrPoolClosed
+			}
+			closed[id] = status
+			return &Pool{ID: id, Status: status}, nil
+		},
+	}
+	svc := NewService(&Config{PendingExpiryBatch: 10}, &mockAuthService{}, repo, fakeUnitOfWork{}, nil)
+	svc.(*service).now = func() time.Time { return now }
+
+	count, err := svc.(*service).ClosePoolsPastDeadline(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, map[PoolID]PoolStatus{1: PoolPaidOut, 2: PoolCancelled}, closed)
+}
@@ -0,0 +1,10 @@
+package common
+
+import "context"
+
+// UnitOfWork объединяет операции нескольких репозиториев в одну транзакцию БД.
+// Репозитории, вызванные с контекстом из fn, присоединяются к этой транзакции;
+// ошибка из fn откатывает все изменения.
+type UnitOfWork interface {
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -2,6 +2,13 @@ package config
 
 import (
 	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"avito-intern/internal/idempotency"
+	"avito-intern/internal/leaderboard"
+	"avito-intern/internal/merch"
+	"avito-intern/internal/outbox"
+	"avito-intern/internal/scheduler"
+	"avito-intern/internal/webhook"
 	"avito-intern/pkg/db"
 	"avito-intern/server"
 
@@ -9,9 +16,16 @@ import (
 )
 
 type Config struct {
-	PG   db.Config
-	HTTP server.Config
-	Auth auth.Config
+	PG          db.Config
+	HTTP        server.Config
+	Auth        auth.Config
+	Coin        coin.Config
+	Merch       merch.Config
+	Idempotency idempotency.Config
+	Scheduler   scheduler.Config
+	Leaderboard leaderboard.Config
+	Outbox      outbox.Config
+	Webhook     webhook.Config
 }
 
 func NewConfig() Config {
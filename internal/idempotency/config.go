@@ -0,0 +1,15 @@
+package idempotency
+
+import "time"
+
+type Config struct {
+	// TTL — сколько хранится результат запроса для повторов.
+	TTL time.Duration `env:"IDEMPOTENCY_TTL" env-default:"24h"`
+	// LockTimeout — сколько ключ считается занятым выполняющимся запросом.
+	// По истечении ключ можно занять снова, например, если сервис упал посреди запроса:
+	// результат фиксируется вместе с изменениями запроса, поэтому незавершённый ключ
+	// означает, что запрос не выполнен.
+	LockTimeout time.Duration `env:"IDEMPOTENCY_LOCK_TIMEOUT" env-default:"1m"`
+	// CleanupInterval — период удаления истекших ключей.
+	CleanupInterval time.Duration `env:"IDEMPOTENCY_CLEANUP_INTERVAL" env-default:"1h"`
+}
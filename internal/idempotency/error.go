@@ -0,0 +1,14 @@
+package idempotency
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	Err            = errors.New("idempotency")
+	ErrInvalidKey  = fmt.Errorf("%v: недопустимый Idempotency-Key", Err)
+	ErrInProgress  = fmt.Errorf("%v: запрос с этим ключом уже выполняется", Err)
+	ErrKeyReused   = fmt.Errorf("%v: ключ уже использован для другого запроса", Err)
+	ErrKeyNotFound = fmt.Errorf("%v: ключ не найден", Err)
+)
@@ -0,0 +1,161 @@
+package idempotency
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/common"
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// HeaderKey — заголовок, в котором клиент передаёт ключ идемпотентности.
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed выставляется в ответах, восстановленных из сохранённого результата.
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+type Service interface {
+	Begin(ctx context.Context, userID auth.UserID, key Key, fingerprint string) (*Record, error)
+	Complete(ctx context.Context, rec *Record) error
+	Release(ctx context.Context, userID auth.UserID, key Key) error
+	RunCleanup(ctx context.Context)
+}
+
+type Handler struct {
+	svc Service
+	uow common.UnitOfWork
+}
+
+func NewIdempotencyHandler(svc Service, uow common.UnitOfWork) *Handler {
+	return &Handler{
+		svc: svc,
+		uow: uow,
+	}
+}
+
+var (
+	// errNotApplied откатывает транзакцию запроса, который завершился ответом 4xx или 5xx.
+	errNotApplied = errors.New("request not applied")
+	// errNotRetried останавливает повтор транзакции: обработчик нельзя вызвать второй раз.
+	errNotRetried = errors.New("request transaction can't be retried")
+)
+
+// Handle middleware, ставится после auth Verify.
+// Первый результат запроса с заголовком Idempotency-Key сохраняется,
+// повторы с тем же ключом получают его без повторного выполнения запроса.
+// Запрос выполняется в одной транзакции с сохранением успешного результата: либо фиксируются
+// оба, либо ничего, поэтому незавершённый ключ всегда означает невыполненный запрос и его
+// безопасно занять снова после LockTimeout. Ответ 4xx откатывает изменения запроса и сохраняется
+// отдельно. Ответы 5xx не сохраняются, чтобы клиент мог повторить запрос.
+func (h *Handler) Handle(c *fiber.Ctx) error {
+	rawKey := c.Get(HeaderKey)
+	if rawKey == "" {
+		return c.Next()
+	}
+	key, err := NewKey(rawKey)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+
+	fingerprint := Fingerprint(c.Method(), c.Path(), c.Body())
+	rec, err := h.svc.Begin(ctx, user.ID, key, fingerprint)
+	switch {
+	case errors.Is(err, ErrInProgress):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	case errors.Is(err, ErrKeyReused):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	case err != nil:
+		slog.Error("failed to reserve idempotency key", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	case rec != nil:
+		c.Set(HeaderReplayed, "true")
+		if rec.ContentType != "" {
+			c.Set(fiber.HeaderContentType, rec.ContentType)
+		}
+		return c.Status(rec.StatusCode).Send(rec.Body)
+	}
+
+	record := func(status int) *Record {
+		return &Record{
+			UserID:      user.ID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			StatusCode:  status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		}
+	}
+	// транзакция не повторяется при deadlock: ошибка уходит клиенту как 5xx,
+	// а ключ освобождается для повтора.
+	var (
+		status    int
+		attempted bool
+	)
+	err = h.uow.RunInTransaction(ctx, func(ctx context.Context) error {
+		if attempted {
+			return errNotRetried
+		}
+		attempted = true
+		c.SetUserContext(ctx)
+		if err := c.Next(); err != nil {
+			return err
+		}
+		status = c.Response().StatusCode()
+		if status >= fiber.StatusBadRequest {
+			return errNotApplied
+		}
+		return h.svc.Complete(ctx, record(status))
+	})
+	c.SetUserContext(ctx)
+	switch {
+	case errors.Is(err, errNotApplied) && status < fiber.StatusInternalServerError:
+		if err := h.svc.Complete(ctx, record(status)); err != nil {
+			// изменений нет, поэтому ключ можно занять снова после LockTimeout.
+			slog.Error("failed to save idempotent response", "error", err)
+		}
+		return nil
+	case errors.Is(err, errNotApplied):
+		h.release(ctx, user.ID, key)
+		return nil
+	case errors.Is(err, ErrKeyNotFound):
+		// ключ занял другой запрос, пока этот выполнялся дольше LockTimeout; изменения откачены.
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"errors": ErrInProgress.Error(),
+		})
+	case err != nil && status != 0:
+		// обработчик ответил успехом, но изменения откачены вместе с результатом.
+		slog.Error("failed to save idempotent response", "error", err)
+		h.release(ctx, user.ID, key)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	case err != nil:
+		h.release(ctx, user.ID, key)
+		return err
+	}
+	return nil
+}
+
+func (h *Handler) release(ctx context.Context, userID auth.UserID, key Key) {
+	if err := h.svc.Release(ctx, userID, key); err != nil {
+		slog.Error("failed to release idempotency key", "error", err)
+	}
+}
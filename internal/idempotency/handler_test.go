@@ -0,0 +1,265 @@
+package idempotency
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type txKey struct{}
+
+// fakeUnitOfWork помечает контекст открытой транзакцией и считает её исходы.
+type fakeUnitOfWork struct {
+	committed  atomic.Int32
+	rolledBack atomic.Int32
+}
+
+func (u *fakeUnitOfWork) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(context.WithValue(ctx, txKey{}, u)); err != nil {
+		u.rolledBack.Add(1)
+		return err
+	}
+	u.committed.Add(1)
+	return nil
+}
+
+func inTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*fakeUnitOfWork)
+	return ok
+}
+
+// txRepo проверяет, что результат сохраняется в транзакции запроса, и может вернуть ошибку.
+type txRepo struct {
+	*memoryRepo
+	completedInTx []bool
+	completeErr   error
+}
+
+func (r *txRepo) CompleteIdempotencyKey(ctx context.Context, rec *Record) error {
+	r.completedInTx = append(r.completedInTx, inTx(ctx))
+	if r.completeErr != nil {
+		return r.completeErr
+	}
+	return r.memoryRepo.CompleteIdempotencyKey(ctx, rec)
+}
+
+// setupTestHandler собирает приложение: фиктивная аутентификация, Handle и обработчик,
+// который считает свои вызовы и отвечает next.
+func setupTestHandler(svc Service, next fiber.Handler) *fiber.App {
+	return setupTestHandlerWithUoW(svc, &fakeUnitOfWork{}, next)
+}
+
+func setupTestHandlerWithUoW(svc Service, uow *fakeUnitOfWork, next fiber.Handler) *fiber.App {
+	app := fiber.New()
+	handlers := NewIdempotencyHandler(svc, uow)
+	setUser := func(c *fiber.Ctx) error {
+		c.SetUserContext(auth.SetUser(c.UserContext(), &auth.User{ID: 1, Username: "user"}))
+		return c.Next()
+	}
+	app.Post("/sendCoin", setUser, handlers.Handle, next)
+	return app
+}
+
+func newSendCoinRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/sendCoin", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+	return req
+}
+
+func doRequest(t *testing.T, app *fiber.App, req *http.Request) (*http.Response, string) {
+	t.Helper()
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestHandle_ReplaysFirstResult(t *testing.T) {
+	now := time.Now()
+	var calls atomic.Int32
+	app := setupTestHandler(newTestService(newMemoryRepo(), &now), func(c *fiber.Ctx) error {
+		calls.Add(1)
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"call": calls.Load()})
+	})
+
+	first, firstBody := doRequest(t, app, newSendCoinRequest("key-1", `{"toUser":"bob","amount":10}`))
+	second, secondBody := doRequest(t, app, newSendCoinRequest("key-1", `{"toUser":"bob","amount":10}`))
+
+	assert.Equal(t, int32(1), calls.Load(), "повтор не должен выполнять запрос")
+	assert.Equal(t, http.StatusCreated, first.StatusCode)
+	assert.Equal(t, http.StatusCreated, second.StatusCode)
+	assert.Equal(t, firstBody, secondBody)
+	assert.Equal(t, "application/json", second.Header.Get(fiber.HeaderContentType))
+	assert.Empty(t, first.Header.Get(HeaderReplayed))
+	assert.Equal(t, "true", second.Header.Get(HeaderReplayed))
+}
+
+func TestHandle_WithoutKey(t *testing.T) {
+	now := time.Now()
+	var calls atomic.Int32
+	app := setupTestHandler(newTestService(newMemoryRepo(), &now), func(c *fiber.Ctx) error {
+		calls.Add(1)
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	doRequest(t, app, newSendCoinRequest("", `{}`))
+	doRequest(t, app, newSendCoinRequest("", `{}`))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHandle_InvalidKey(t *testing.T) {
+	now := time.Now()
+	app := setupTestHandler(newTestService(newMemoryRepo(), &now), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, _ := doRequest(t, app, newSendCoinRequest(strings.Repeat("k", MaxKeyLength+1), `{}`))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHandle_KeyReusedWithDifferentBody(t *testing.T) {
+	now := time.Now()
+	app := setupTestHandler(newTestService(newMemoryRepo(), &now), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	doRequest(t, app, newSendCoinRequest("key-1", `{"toUser":"bob","amount":10}`))
+	resp, _ := doRequest(t, app, newSendCoinRequest("key-1", `{"toUser":"bob","amount":20}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestHandle_ConcurrentDuplicateRejected(t *testing.T) {
+	now := time.Now()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var calls atomic.Int32
+	app := setupTestHandler(newTestService(newMemoryRepo(), &now), func(c *fiber.Ctx) error {
+		if calls.Add(1) == 1 {
+			close(entered)
+			<-release
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	done := make(chan int)
+	go func() {
+		resp, err := app.Test(newSendCoinRequest("key-1", `{}`), -1)
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+
+	<-entered
+	resp, _ := doRequest(t, app, newSendCoinRequest("key-1", `{}`))
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestHandle_ServerErrorIsNotStored(t *testing.T) {
+	now := time.Now()
+	var calls atomic.Int32
+	app := setupTestHandler(newTestService(newMemoryRepo(), &now), func(c *fiber.Ctx) error {
+		if calls.Add(1) == 1 {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	first, _ := doRequest(t, app, newSendCoinRequest("key-1", `{}`))
+	second, _ := doRequest(t, app, newSendCoinRequest("key-1", `{}`))
+
+	assert.Equal(t, http.StatusInternalServerError, first.StatusCode)
+	assert.Equal(t, http.StatusOK, second.StatusCode)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHandle_ClientErrorIsStored(t *testing.T) {
+	now := time.Now()
+	var calls atomic.Int32
+	app := setupTestHandler(newTestService(newMemoryRepo(), &now), func(c *fiber.Ctx) error {
+		calls.Add(1)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": "not enough coins"})
+	})
+
+	doRequest(t, app, newSendCoinRequest("key-1", `{}`))
+	resp, body := doRequest(t, app, newSendCoinRequest("key-1", `{}`))
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.JSONEq(t, `{"errors":"not enough coins"}`, body)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestHandle_ResultSavedInRequestTransaction(t *testing.T) {
+	now := time.Now()
+	repo := &txRepo{memoryRepo: newMemoryRepo()}
+	uow := &fakeUnitOfWork{}
+	app := setupTestHandlerWithUoW(newTestService(repo, &now), uow, func(c *fiber.Ctx) error {
+		assert.True(t, inTx(c.UserContext()), "запрос выполняется в транзакции")
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, _ := doRequest(t, app, newSendCoinRequest("key-1", `{}`))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []bool{true}, repo.completedInTx)
+	assert.Equal(t, int32(1), uow.committed.Load())
+}
+
+func TestHandle_CompleteFailedRollsBackRequest(t *testing.T) {
+	now := time.Now()
+	repo := &txRepo{memoryRepo: newMemoryRepo(), completeErr: errors.New("connection reset")}
+	uow := &fakeUnitOfWork{}
+	var calls atomic.Int32
+	app := setupTestHandlerWithUoW(newTestService(repo, &now), uow, func(c *fiber.Ctx) error {
+		calls.Add(1)
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"call": calls.Load()})
+	})
+
+	// результат не сохранён — изменения запроса откачены вместе с ним, клиент получает 5xx.
+	resp, _ := doRequest(t, app, newSendCoinRequest("key-1", `{}`))
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, int32(1), uow.rolledBack.Load())
+	assert.Zero(t, uow.committed.Load())
+
+	// ключ освобождён, повтор выполняет запрос заново.
+	repo.completeErr = nil
+	resp, _ = doRequest(t, app, newSendCoinRequest("key-1", `{}`))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHandle_ClientErrorRollsBackRequest(t *testing.T) {
+	now := time.Now()
+	repo := &txRepo{memoryRepo: newMemoryRepo()}
+	uow := &fakeUnitOfWork{}
+	app := setupTestHandlerWithUoW(newTestService(repo, &now), uow, func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"errors": "pool closed"})
+	})
+
+	resp, _ := doRequest(t, app, newSendCoinRequest("key-1", `{}`))
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, int32(1), uow.rolledBack.Load())
+	// результат 4xx сохраняется вне отменённой транзакции.
+	assert.Equal(t, []bool{false}, repo.completedInTx)
+}
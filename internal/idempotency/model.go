@@ -0,0 +1,48 @@
+package idempotency
+
+import (
+	"avito-intern/internal/auth"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// MaxKeyLength — максимальная длина Idempotency-Key.
+const MaxKeyLength = 255
+
+// Key — клиентский ключ идемпотентности, уникальный в рамках пользователя.
+type Key string
+
+func NewKey(raw string) (Key, error) {
+	if raw == "" || len(raw) > MaxKeyLength {
+		return "", ErrInvalidKey
+	}
+	return Key(raw), nil
+}
+
+// Record — сохранённый результат запроса. Пока запрос выполняется, StatusCode равен 0.
+type Record struct {
+	UserID      auth.UserID
+	Key         Key
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// Completed сообщает, сохранён ли уже результат запроса.
+func (r *Record) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// Fingerprint вычисляет отпечаток запроса, чтобы отличать повтор от другого запроса с тем же ключом.
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{' '})
+	h.Write([]byte(path))
+	h.Write([]byte{'\n'})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
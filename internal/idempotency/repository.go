@@ -0,0 +1,16 @@
+package idempotency
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// ReserveIdempotencyKey сохраняет незавершённую запись, если ключ свободен или истёк.
+	ReserveIdempotencyKey(ctx context.Context, rec *Record, now time.Time) (bool, error)
+	GetIdempotencyKey(ctx context.Context, userID auth.UserID, key Key) (*Record, error)
+	CompleteIdempotencyKey(ctx context.Context, rec *Record) error
+	ReleaseIdempotencyKey(ctx context.Context, userID auth.UserID, key Key) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+}
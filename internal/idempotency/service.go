@@ -0,0 +1,88 @@
+package idempotency
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+type service struct {
+	repo Repository
+	cfg  *Config
+	now  func() time.Time
+}
+
+func NewService(cfg *Config, repo Repository) Service {
+	return &service{
+		repo: repo,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+// Begin занимает ключ под выполнение запроса.
+// Возвращает nil, если запрос нужно выполнить, или сохранённый результат, если это повтор.
+func (s *service) Begin(ctx context.Context, userID auth.UserID, key Key, fingerprint string) (*Record, error) {
+	now := s.now()
+	reserved, err := s.repo.ReserveIdempotencyKey(ctx, &Record{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(s.cfg.LockTimeout),
+	}, now)
+	if err != nil {
+		return nil, err
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	rec, err := s.repo.GetIdempotencyKey(ctx, userID, key)
+	if err != nil {
+		// ключ освободили между попытками резервирования и чтения —
+		// клиенту безопаснее повторить запрос.
+		if errors.Is(err, ErrKeyNotFound) {
+			return nil, ErrInProgress
+		}
+		return nil, err
+	}
+	if rec.Fingerprint != fingerprint {
+		return nil, ErrKeyReused
+	}
+	if !rec.Completed() {
+		return nil, ErrInProgress
+	}
+	return rec, nil
+}
+
+// Complete сохраняет результат запроса на время TTL.
+func (s *service) Complete(ctx context.Context, rec *Record) error {
+	rec.ExpiresAt = s.now().Add(s.cfg.TTL)
+	return s.repo.CompleteIdempotencyKey(ctx, rec)
+}
+
+// Release освобождает ключ, если результат запроса не нужно сохранять.
+func (s *service) Release(ctx context.Context, userID auth.UserID, key Key) error {
+	return s.repo.ReleaseIdempotencyKey(ctx, userID, key)
+}
+
+// RunCleanup периодически удаляет истекшие ключи, пока не отменён ctx.
+func (s *service) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.repo.DeleteExpiredIdempotencyKeys(ctx, s.now())
+			if err != nil {
+				slog.Error("failed to delete expired idempotency keys", "error", err)
+				continue
+			}
+			slog.Debug("deleted expired idempotency keys", "count", deleted)
+		}
+	}
+}
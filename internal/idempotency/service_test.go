@@ -0,0 +1,202 @@
+package idempotency
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordID struct {
+	userID auth.UserID
+	key    Key
+}
+
+// memoryRepo — потокобезопасная реализация Repository в памяти.
+type memoryRepo struct {
+	mu      sync.Mutex
+	records map[recordID]Record
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{records: make(map[recordID]Record)}
+}
+
+func (m *memoryRepo) ReserveIdempotencyKey(_ context.Context, rec *Record, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := recordID{rec.UserID, rec.Key}
+	if existing, ok := m.records[id]; ok && !existing.ExpiresAt.Before(now) {
+		return false, nil
+	}
+	m.records[id] = *rec
+	return true, nil
+}
+
+func (m *memoryRepo) GetIdempotencyKey(_ context.Context, userID auth.UserID, key Key) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[recordID{userID, key}]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return &rec, nil
+}
+
+func (m *memoryRepo) CompleteIdempotencyKey(_ context.Context, rec *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := recordID{rec.UserID, rec.Key}
+	existing, ok := m.records[id]
+	if !ok || existing.Completed() || existing.Fingerprint != rec.Fingerprint {
+		return ErrKeyNotFound
+	}
+	m.records[id] = *rec
+	return nil
+}
+
+func (m *memoryRepo) ReleaseIdempotencyKey(_ context.Context, userID auth.UserID, key Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := recordID{userID, key}
+	if rec, ok := m.records[id]; ok && !rec.Completed() {
+		delete(m.records, id)
+	}
+	return nil
+}
+
+func (m *memoryRepo) DeleteExpiredIdempotencyKeys(_ context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted int64
+	for id, rec := range m.records {
+		if rec.ExpiresAt.Before(now) {
+			delete(m.records, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func newTestService(repo Repository, now *time.Time) *service {
+	return &service{
+		repo: repo,
+		cfg: &Config{
+			TTL:             time.Hour,
+			LockTimeout:     time.Minute,
+			CleanupInterval: time.Hour,
+		},
+		now: func() time.Time { return *now },
+	}
+}
+
+func TestBegin_ReservesNewKey(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(newMemoryRepo(), &now)
+
+	rec, err := svc.Begin(context.Background(), 1, "key", "fp")
+	require.NoError(t, err)
+	assert.Nil(t, rec, "новый ключ должен выполнять запрос")
+}
+
+func TestBegin_InProgress(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(newMemoryRepo(), &now)
+
+	_, err := svc.Begin(context.Background(), 1, "key", "fp")
+	require.NoError(t, err)
+
+	_, err = svc.Begin(context.Background(), 1, "key", "fp")
+	assert.ErrorIs(t, err, ErrInProgress)
+}
+
+func TestBegin_ReplaysCompleted(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(newMemoryRepo(), &now)
+	ctx := context.Background()
+
+	_, err := svc.Begin(ctx, 1, "key", "fp")
+	require.NoError(t, err)
+	require.NoError(t, svc.Complete(ctx, &Record{
+		UserID:      1,
+		Key:         "key",
+		Fingerprint: "fp",
+		StatusCode:  200,
+		Body:        []byte("ok"),
+	}))
+
+	rec, err := svc.Begin(ctx, 1, "key", "fp")
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	assert.Equal(t, 200, rec.StatusCode)
+	assert.Equal(t, []byte("ok"), rec.Body)
+}
+
+func TestBegin_KeyReusedForAnotherRequest(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(newMemoryRepo(), &now)
+
+	_, err := svc.Begin(context.Background(), 1, "key", "fp")
+	require.NoError(t, err)
+
+	_, err = svc.Begin(context.Background(), 1, "key", "other")
+	assert.ErrorIs(t, err, ErrKeyReused)
+}
+
+func TestBegin_KeysAreScopedPerUser(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(newMemoryRepo(), &now)
+
+	_, err := svc.Begin(context.Background(), 1, "key", "fp")
+	require.NoError(t, err)
+
+	rec, err := svc.Begin(context.Background(), 2, "key", "fp")
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+}
+
+func TestBegin_ExpiredKeyCanBeReused(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(newMemoryRepo(), &now)
+	ctx := context.Background()
+
+	_, err := svc.Begin(ctx, 1, "key", "fp")
+	require.NoError(t, err)
+	require.NoError(t, svc.Complete(ctx, &Record{UserID: 1, Key: "key", Fingerprint: "fp", StatusCode: 200}))
+
+	now = now.Add(svc.cfg.TTL + time.Second)
+	rec, err := svc.Begin(ctx, 1, "key", "other")
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+}
+
+func TestBegin_StaleLockIsTakenOver(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(newMemoryRepo(), &now)
+
+	_, err := svc.Begin(context.Background(), 1, "key", "fp")
+	require.NoError(t, err)
+
+	now = now.Add(svc.cfg.LockTimeout + time.Second)
+	rec, err := svc.Begin(context.Background(), 1, "key", "fp")
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+}
+
+func TestRelease(t *testing.T) {
+	now := time.Now()
+	svc := newTestService(newMemoryRepo(), &now)
+	ctx := context.Background()
+
+	_, err := svc.Begin(ctx, 1, "key", "fp")
+	require.NoError(t, err)
+	require.NoError(t, svc.Release(ctx, 1, "key"))
+
+	rec, err := svc.Begin(ctx, 1, "key", "fp")
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+}
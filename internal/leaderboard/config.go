@@ -0,0 +1,6 @@
+package leaderboard
+
+type Config struct {
+	// Limit — сколько мест рейтинга отдаётся по умолчанию, не больше MaxLimit.
+	Limit int `env:"LEADERBOARD_LIMIT" env-default:"10"`
+}
@@ -0,0 +1,13 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	Err              = errors.New("leaderboard")
+	ErrInvalidWindow = fmt.Errorf("%v: window must be week, month or all", Err)
+	ErrInvalidSort   = fmt.Errorf("%v: sort must be amount or count", Err)
+	ErrInvalidLimit  = fmt.Errorf("%v: limit must be from 1 to %d", Err, MaxLimit)
+)
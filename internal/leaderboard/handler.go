@@ -0,0 +1,120 @@
+package leaderboard
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Service interface {
+	Board(ctx context.Context, q Query) (*Board, error)
+	SetOptOut(ctx context.Context, user *auth.User, optOut bool) error
+}
+
+type AuthHandler interface {
+	Verify(c *fiber.Ctx) error
+}
+
+type Handler struct {
+	svc          Service
+	authHandlers AuthHandler
+}
+
+func NewLeaderboardHandler(svc Service, authHandler AuthHandler) *Handler {
+	return &Handler{
+		svc:          svc,
+		authHandlers: authHandler,
+	}
+}
+
+func (h *Handler) Init(router fiber.Router) {
+	router.Get("/leaderboard", h.authHandlers.Verify, h.board)
+	router.Put("/leaderboard/opt-out", h.authHandlers.Verify, h.setOptOut)
+}
+
+type EntryResponse struct {
+	Rank     int    `json:"rank"`
+	Username string `json:"username"`
+	Count    int    `json:"count"`
+	Total    int    `json:"total"`
+}
+
+type BoardResponse struct {
+	Window    Window          `json:"window"`
+	Since     *time.Time      `json:"since,omitempty"`
+	Sort      Sort            `json:"sort"`
+	Givers    []EntryResponse `json:"givers"`
+	Receivers []EntryResponse `json:"receivers"`
+}
+
+func newEntries(entries []*Entry) []EntryResponse {
+	resp := make([]EntryResponse, len(entries))
+	for i, e := range entries {
+		resp[i] = EntryResponse{
+			Rank:     e.Rank,
+			Username: e.Username,
+			Count:    e.Count,
+			Total:    e.Total,
+		}
+	}
+	return resp
+}
+
+func (h *Handler) board(c *fiber.Ctx) error {
+	board, err := h.svc.Board(c.UserContext(), Query{
+		Window: Window(c.Query("window")),
+		Sort:   Sort(c.Query("sort")),
+		Limit:  c.QueryInt("limit"),
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidWindow) || errors.Is(err, ErrInvalidSort) || errors.Is(err, ErrInvalidLimit) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		}
+		slog.Error("failed to build leaderboard", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	resp := BoardResponse{
+		Window:    board.Window,
+		Sort:      board.Sort,
+		Givers:    newEntries(board.Givers),
+		Receivers: newEntries(board.Receivers),
+	}
+	if !board.Since.IsZero() {
+		resp.Since = &board.Since
+	}
+	return c.JSON(resp)
+}
+
+type OptOutRequest struct {
+	OptOut bool `json:"optOut"`
+}
+
+func (h *Handler) setOptOut(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	var req OptOutRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	if err := h.svc.SetOptOut(ctx, user, req.OptOut); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	return c.JSON(req)
+}
@@ -0,0 +1,72 @@
+package leaderboard
+
+import (
+	"avito-intern/internal/auth"
+	"time"
+)
+
+// Window — период рейтинга. Неделя начинается в понедельник, месяц — первого числа, по UTC.
+type Window string
+
+const (
+	Week    Window = "week"
+	Month   Window = "month"
+	AllTime Window = "all"
+)
+
+// Since возвращает начало периода, в который попадает t, нулевое время — для AllTime.
+func (w Window) Since(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch w {
+	case Week:
+		// time.Sunday == 0, неделя начинается с понедельника.
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case Month:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return time.Time{}
+	}
+}
+
+// Side — сторона рейтинга: кто отправляет благодарности или кто их получает.
+type Side string
+
+const (
+	Givers    Side = "givers"
+	Receivers Side = "receivers"
+)
+
+// Sort — по чему упорядочен рейтинг.
+type Sort string
+
+const (
+	ByAmount Sort = "amount"
+	ByCount  Sort = "count"
+)
+
+// Query — параметры рейтинга.
+type Query struct {
+	Window Window
+	Sort   Sort
+	Limit  int
+}
+
+// Entry — место в рейтинге.
+type Entry struct {
+	Rank     int
+	UserID   auth.UserID
+	Username string
+	// Count — число переводов, Total — сумма переводов за вычетом возвратов.
+	Count int
+	Total int
+}
+
+// Board — рейтинг отправителей и получателей за период.
+type Board struct {
+	Window    Window
+	Since     time.Time
+	Sort      Sort
+	Givers    []*Entry
+	Receivers []*Entry
+}
@@ -0,0 +1,14 @@
+package leaderboard
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// ListTop возвращает до limit пользователей стороны side с наибольшими суммами или числом
+	// переводов начиная с since (нулевое — за всё время). Отказавшиеся от рейтинга пропускаются.
+	ListTop(ctx context.Context, side Side, since time.Time, sort Sort, limit int) ([]*Entry, error)
+	SetLeaderboardOptOut(ctx context.Context, userID auth.UserID, optOut bool) error
+}
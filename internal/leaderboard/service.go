@@ -0,0 +1,83 @@
+package leaderboard
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// MaxLimit — максимальное число мест в рейтинге.
+const MaxLimit = 100
+
+type service struct {
+	cfg  *Config
+	repo Repository
+	now  func() time.Time
+}
+
+func NewService(cfg *Config, repo Repository) Service {
+	return &service{
+		cfg:  cfg,
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// Board строит рейтинги отправителей и получателей за период.
+func (s *service) Board(ctx context.Context, q Query) (*Board, error) {
+	switch q.Window {
+	case "":
+		q.Window = Week
+	case Week, Month, AllTime:
+	default:
+		return nil, ErrInvalidWindow
+	}
+	switch q.Sort {
+	case "":
+		q.Sort = ByAmount
+	case ByAmount, ByCount:
+	default:
+		return nil, ErrInvalidSort
+	}
+	switch {
+	case q.Limit == 0:
+		q.Limit = min(s.cfg.Limit, MaxLimit)
+	case q.Limit < 0 || q.Limit > MaxLimit:
+		return nil, ErrInvalidLimit
+	}
+
+	board := &Board{Window: q.Window, Since: q.Window.Since(s.now()), Sort: q.Sort}
+	var g errgroup.Group
+	g.Go(func() error {
+		var err error
+		board.Givers, err = s.top(ctx, Givers, board.Since, q)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		board.Receivers, err = s.top(ctx, Receivers, board.Since, q)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+func (s *service) top(ctx context.Context, side Side, since time.Time, q Query) ([]*Entry, error) {
+	entries, err := s.repo.ListTop(ctx, side, since, q.Sort, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		e.Rank = i + 1
+	}
+	return entries, nil
+}
+
+// SetOptOut скрывает пользователя из рейтинга или возвращает в него.
+func (s *service) SetOptOut(ctx context.Context, user *auth.User, optOut bool) error {
+	return s.repo.SetLeaderboardOptOut(ctx, user.ID, optOut)
+}
@@ -0,0 +1,101 @@
+package leaderboard
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type topCall struct {
+	side  Side
+	since time.Time
+	sort  Sort
+	limit int
+}
+
+// fakeRepo запоминает запросы рейтинга и отдаёт одинаковые места для обеих сторон.
+type fakeRepo struct {
+	mu     sync.Mutex
+	calls  []topCall
+	optOut map[auth.UserID]bool
+}
+
+func (r *fakeRepo) ListTop(_ context.Context, side Side, since time.Time, sort Sort, limit int) ([]*Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, topCall{side, since, sort, limit})
+	return []*Entry{
+		{UserID: 1, Username: "alice", Count: 3, Total: 120},
+		{UserID: 2, Username: "bob", Count: 5, Total: 50},
+	}, nil
+}
+
+func (r *fakeRepo) SetLeaderboardOptOut(_ context.Context, userID auth.UserID, optOut bool) error {
+	r.optOut[userID] = optOut
+	return nil
+}
+
+func TestWindowSince(t *testing.T) {
+	// среда, 12 марта 2025.
+	now := time.Date(2025, 3, 12, 15, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), Week.Since(now))
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Month.Since(now))
+	assert.True(t, AllTime.Since(now).IsZero())
+
+	sunday := time.Date(2025, 3, 16, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), Week.Since(sunday))
+}
+
+func TestBoard(t *testing.T) {
+	now := time.Date(2025, 3, 12, 15, 30, 0, 0, time.UTC)
+	repo := &fakeRepo{}
+	svc := NewService(&Config{Limit: 10}, repo)
+	svc.(*service).now = func() time.Time { return now }
+
+	board, err := svc.Board(context.Background(), Query{})
+	require.NoError(t, err)
+	assert.Equal(t, Week, board.Window)
+	assert.Equal(t, ByAmount, board.Sort)
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), board.Since)
+	require.Len(t, board.Givers, 2)
+	assert.Equal(t, 1, board.Givers[0].Rank)
+	assert.Equal(t, 2, board.Receivers[1].Rank)
+	assert.ElementsMatch(t, []topCall{
+		{Givers, board.Since, ByAmount, 10},
+		{Receivers, board.Since, ByAmount, 10},
+	}, repo.calls)
+
+	repo.calls = nil
+	_, err = svc.Board(context.Background(), Query{Window: AllTime, Sort: ByCount, Limit: 3})
+	require.NoError(t, err)
+	assert.Contains(t, repo.calls, topCall{Givers, time.Time{}, ByCount, 3})
+}
+
+func TestBoard_Invalid(t *testing.T) {
+	svc := NewService(&Config{Limit: 10}, &fakeRepo{})
+	tests := []struct {
+		q   Query
+		err error
+	}{
+		{Query{Window: "year"}, ErrInvalidWindow},
+		{Query{Sort: "name"}, ErrInvalidSort},
+		{Query{Limit: -1}, ErrInvalidLimit},
+		{Query{Limit: MaxLimit + 1}, ErrInvalidLimit},
+	}
+	for _, tt := range tests {
+		_, err := svc.Board(context.Background(), tt.q)
+		assert.ErrorIs(t, err, tt.err)
+	}
+}
+
+func TestSetOptOut(t *testing.T) {
+	repo := &fakeRepo{optOut: make(map[auth.UserID]bool)}
+	svc := NewService(&Config{}, repo)
+	require.NoError(t, svc.SetOptOut(context.Background(), &auth.User{ID: 7}, true))
+	assert.True(t, repo.optOut[7])
+}
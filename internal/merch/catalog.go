@@ -0,0 +1,102 @@
+package merch
+
+import (
+	"avito-intern/pkg/cache"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Catalog — снимок каталога и его ETag. ETag зависит только от содержимого, поэтому
+// у всех реплик он одинаков для одного и того же каталога.
+type Catalog struct {
+	Items []*Merch
+	ETag  string
+	// Version — версия каталога в БД, прочитанная до товаров: снимок не старше неё.
+	Version int64
+}
+
+func newCatalog(items []*Merch, version int64) (*Catalog, error) {
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("%v: failed to marshal catalog: %w", Err, err)
+	}
+	sum := sha256.Sum256(raw)
+	return &Catalog{Items: items, ETag: `"` + hex.EncodeToString(sum[:16]) + `"`, Version: version}, nil
+}
+
+// CatalogCache хранит каталог в памяти, чтобы клиенты могли часто его опрашивать, не нагружая БД.
+// Снимок годен, пока его версия совпадает с версией каталога в БД, которую любое изменение товаров
+// увеличивает на всех репликах сразу. Версия сверяется не чаще раза в versionTTL, в промежутке
+// снимок отдаётся без запросов к БД.
+type CatalogCache struct {
+	catalog    *cache.Cache[struct{}, *Catalog]
+	versionTTL time.Duration
+	now        func() time.Time
+
+	// mu защищает checkedAt и делает проверку версии и запись в Set атомарными.
+	mu sync.Mutex
+	// checkedAt — когда версия снимка в кэше последний раз совпала с версией в БД.
+	checkedAt time.Time
+}
+
+// NewCatalogCache создает кэш каталога. При ttl <= 0 кэш выключен, при versionTTL <= 0
+// версия сверяется на каждый запрос.
+func NewCatalogCache(ttl, versionTTL time.Duration) *CatalogCache {
+	size := 1
+	if ttl <= 0 {
+		size = 0
+	}
+	return &CatalogCache{
+		catalog:    cache.New[struct{}, *Catalog](size, ttl),
+		versionTTL: versionTTL,
+		now:        time.Now,
+	}
+}
+
+// Fresh возвращает снимок, версию которого сверяли с БД меньше versionTTL назад.
+func (c *CatalogCache) Fresh() (*Catalog, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	catalog, ok := c.catalog.Get(struct{}{})
+	if !ok || c.now().Sub(c.checkedAt) >= c.versionTTL {
+		return nil, false
+	}
+	return catalog, true
+}
+
+// Get возвращает снимок каталога версии version и запоминает время сверки.
+func (c *CatalogCache) Get(version int64) (*Catalog, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	catalog, ok := c.catalog.Get(struct{}{})
+	if !ok || catalog.Version != version {
+		return nil, false
+	}
+	c.checkedAt = c.now()
+	return catalog, true
+}
+
+// Set сохраняет снимок, если в кэше нет снимка новее: каталог, прочитанный до изменения,
+// не вытесняет прочитанный после него.
+func (c *CatalogCache) Set(catalog *Catalog) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.catalog.Get(struct{}{}); ok && cached.Version > catalog.Version {
+		return
+	}
+	c.catalog.Set(struct{}{}, catalog)
+	c.checkedAt = c.now()
+}
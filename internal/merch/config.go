@@ -0,0 +1,11 @@
+package merch
+
+import "time"
+
+type Config struct {
+	// CatalogTTL — сколько каталог живёт в кэше, 0 — без кэша.
+	CatalogTTL time.Duration `env:"MERCH_CATALOG_TTL" env-default:"1m"`
+	// CatalogVersionTTL — как часто кэш сверяется с версией каталога в БД. Изменение каталога
+	// видно всем репликам не позже чем через этот интервал.
+	CatalogVersionTTL time.Duration `env:"MERCH_CATALOG_VERSION_TTL" env-default:"1s"`
+}
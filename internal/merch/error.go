@@ -9,4 +9,6 @@ var (
 	Err                  = errors.New("merch")
 	ErrPurchasesNotFound = fmt.Errorf("%v: purchases not found", Err)
 	ErrMerchNotFound     = fmt.Errorf("%v: merch not found", Err)
+	ErrMerchUnavailable  = fmt.Errorf("%v: merch is not available", Err)
+	ErrInvalidPrice      = fmt.Errorf("%v: price must be positive", Err)
 )
@@ -6,6 +6,8 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -14,37 +16,54 @@ type Service interface {
 	Purchase(ctx context.Context, user *auth.User, merchName string) error
 	ListPurchases(ctx context.Context, user *auth.User) ([]*Purchase, error)
 	ListTransfers(ctx context.Context, user *auth.User) (incoming, outgoing []*coin.Transaction, err error)
+	GetBalance(ctx context.Context, user *auth.User) (int, error)
+	ExpiringCoins(ctx context.Context, user *auth.User) ([]coin.ExpiringCoins, error)
+	Catalog(ctx context.Context) (*Catalog, error)
+	UpdateMerch(ctx context.Context, id int64, upd MerchUpdate) (*Merch, error)
 }
 
 type Handler struct {
 	svc          Service
 	authHandlers AuthHandler
+	idempotency  IdempotencyHandler
 }
 
 type AuthHandler interface {
 	Verify(c *fiber.Ctx) error
+	RequireRole(roles ...auth.Role) fiber.Handler
 }
 
-func NewMerchHandler(svc Service, authHandler AuthHandler) *Handler {
+type IdempotencyHandler interface {
+	Handle(c *fiber.Ctx) error
+}
+
+func NewMerchHandler(svc Service, authHandler AuthHandler, idempotencyHandler IdempotencyHandler) *Handler {
 	return &Handler{
 		svc:          svc,
 		authHandlers: authHandler,
+		idempotency:  idempotencyHandler,
 	}
 }
 
 func (h *Handler) Init(router fiber.Router) {
 	router.Get("/info", h.authHandlers.Verify, h.info)
-	router.Get("/buy/:item", h.authHandlers.Verify, h.buyItem)
+	router.Get("/buy/:item", h.authHandlers.Verify, h.idempotency.Handle, h.buyItem)
+	router.Get("/merch", h.authHandlers.Verify, h.catalog)
+	router.Patch("/admin/merch/:id", h.authHandlers.Verify, h.authHandlers.RequireRole(auth.RoleHRAdmin), h.updateMerch)
 }
 
 type ReceivedTx struct {
-	FromUser string `json:"fromUser"`
-	Amount   int    `json:"amount"`
+	FromUser string     `json:"fromUser"`
+	Amount   int        `json:"amount"`
+	Message  string     `json:"message,omitempty"`
+	Tags     []coin.Tag `json:"tags,omitempty"`
 }
 
 type SentTx struct {
-	FromUser string `json:"fromUser"`
-	Amount   int    `json:"amount"`
+	FromUser string     `json:"fromUser"`
+	Amount   int        `json:"amount"`
+	Message  string     `json:"message,omitempty"`
+	Tags     []coin.Tag `json:"tags,omitempty"`
 }
 type History struct {
 	Received []ReceivedTx `json:"received"`
@@ -56,10 +75,17 @@ type InventoryItem struct {
 	Quantity int    `json:"quantity"`
 }
 
+// ExpiringCoins — монеты, которые сгорят в этот день.
+type ExpiringCoins struct {
+	Amount    int       `json:"amount"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
 type InfoResponse struct {
-	Coins       int             `json:"coins"`
-	Inventory   []InventoryItem `json:"inventory"`
-	CoinHistory History         `json:"coinHistory"`
+	Coins         int             `json:"coins"`
+	Inventory     []InventoryItem `json:"inventory"`
+	CoinHistory   History         `json:"coinHistory"`
+	ExpiringCoins []ExpiringCoins `json:"expiringCoins"`
 }
 
 func (h *Handler) info(c *fiber.Ctx) error {
@@ -75,6 +101,13 @@ func (h *Handler) info(c *fiber.Ctx) error {
 			"errors": err.Error(),
 		})
 	}
+	balance, err := h.svc.GetBalance(ctx, user)
+	if err != nil {
+		slog.Error("failed to get balance", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
 	purchases, err := h.svc.ListPurchases(ctx, user)
 	if err != nil {
 		slog.Error("failed to get purchases", "error", err)
@@ -82,6 +115,13 @@ func (h *Handler) info(c *fiber.Ctx) error {
 			"errors": err.Error(),
 		})
 	}
+	expiring, err := h.svc.ExpiringCoins(ctx, user)
+	if err != nil {
+		slog.Error("failed to get expiring coins", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
 
 	var (
 		received = make([]ReceivedTx, len(in))
@@ -92,12 +132,16 @@ func (h *Handler) info(c *fiber.Ctx) error {
 		received[idx] = ReceivedTx{
 			FromUser: row.FromUser.Username,
 			Amount:   row.Amount,
+			Message:  row.Message,
+			Tags:     row.Tags,
 		}
 	}
 	for idx, row := range out {
 		sent[idx] = SentTx{
 			FromUser: row.ToUser.Username,
 			Amount:   row.Amount,
+			Message:  row.Message,
+			Tags:     row.Tags,
 		}
 	}
 
@@ -114,13 +158,19 @@ func (h *Handler) info(c *fiber.Ctx) error {
 		})
 	}
 
+	expiringCoins := make([]ExpiringCoins, len(expiring))
+	for idx, e := range expiring {
+		expiringCoins[idx] = ExpiringCoins{Amount: e.Amount, ExpiresAt: e.ExpiresAt}
+	}
+
 	return c.JSON(InfoResponse{
-		Coins:     user.CoinBalance,
+		Coins:     balance,
 		Inventory: inventory,
 		CoinHistory: History{
 			Received: received,
 			Sent:     sent,
 		},
+		ExpiringCoins: expiringCoins,
 	})
 }
 
@@ -140,6 +190,11 @@ func (h *Handler) buyItem(c *fiber.Ctx) error {
 	}
 
 	err := h.svc.Purchase(ctx, user, item)
+	if errors.Is(err, ErrMerchUnavailable) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"errors": err.Error(),
@@ -148,3 +203,93 @@ func (h *Handler) buyItem(c *fiber.Ctx) error {
 
 	return c.SendStatus(fiber.StatusOK)
 }
+
+type MerchResponse struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Price       int    `json:"price"`
+	Available   bool   `json:"available"`
+	Description string `json:"description"`
+}
+
+func newMerchResponse(m *Merch) MerchResponse {
+	return MerchResponse{
+		ID:          m.ID,
+		Name:        m.Name,
+		Price:       m.Price,
+		Available:   m.Available,
+		Description: m.Description,
+	}
+}
+
+type UpdateMerchRequest struct {
+	Price       *int    `json:"price"`
+	Description *string `json:"description"`
+	Available   *bool   `json:"available"`
+}
+
+// catalog Каталог мерча. Клиент передаёт полученный ETag в If-None-Match и получает 304, пока каталог не изменился.
+func (h *Handler) catalog(c *fiber.Ctx) error {
+	catalog, err := h.svc.Catalog(c.UserContext())
+	if err != nil {
+		slog.Error("failed to get merch catalog", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	c.Set(fiber.HeaderETag, catalog.ETag)
+	// кэшировать можно, но перед использованием — сверить ETag.
+	c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), catalog.ETag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	resp := make([]MerchResponse, len(catalog.Items))
+	for i, m := range catalog.Items {
+		resp[i] = newMerchResponse(m)
+	}
+	return c.JSON(resp)
+}
+
+// etagMatches проверяет If-None-Match: список ETag через запятую или *, слабые W/ сравниваются как сильные.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) updateMerch(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid merch id",
+		})
+	}
+	var req UpdateMerchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	m, err := h.svc.UpdateMerch(c.UserContext(), int64(id), MerchUpdate{
+		Price:       req.Price,
+		Description: req.Description,
+		Available:   req.Available,
+	})
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrMerchNotFound):
+			status = fiber.StatusNotFound
+		case errors.Is(err, ErrInvalidPrice):
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	return c.JSON(newMerchResponse(m))
+}
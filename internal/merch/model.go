@@ -2,20 +2,32 @@ package merch
 
 import (
 	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
 	"time"
 )
 
 type Merch struct {
-	ID    int64
-	Name  string
-	Price int
+	ID          int64
+	Name        string
+	Price       int
+	Description string
+	// Available — товар можно купить. Снятый с продажи товар остаётся в каталоге и в инвентаре купивших.
+	Available bool
+}
+
+// MerchUpdate — изменение товара каталога, nil-поля не меняются.
+type MerchUpdate struct {
+	Price       *int
+	Description *string
+	Available   *bool
 }
 
 type Purchase struct {
-	ID          int
-	UserID      auth.UserID
-	MerchID     int64
-	MerchName   string
-	Quantity    int
-	PurchasedAt time.Time
+	ID            int
+	UserID        auth.UserID
+	TransactionID coin.TransactionID
+	MerchID       int64
+	MerchName     string
+	Quantity      int
+	PurchasedAt   time.Time
 }
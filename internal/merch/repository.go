@@ -9,4 +9,12 @@ type Repository interface {
 	GetMerchByID(ctx context.Context, merchName string) (*Merch, error)
 	SavePurchase(ctx context.Context, purchase *Purchase) error
 	ListPurchasesByUserID(ctx context.Context, userID auth.UserID) ([]*Purchase, error)
+	// ListMerch возвращает весь каталог, упорядоченный по цене и имени.
+	ListMerch(ctx context.Context) ([]*Merch, error)
+	// GetMerch возвращает товар по ID или ErrMerchNotFound.
+	GetMerch(ctx context.Context, id int64) (*Merch, error)
+	// UpdateMerch одним запросом меняет заданные в upd поля товара и возвращает его или ErrMerchNotFound.
+	UpdateMerch(ctx context.Context, id int64, upd MerchUpdate) (*Merch, error)
+	// CatalogVersion возвращает версию каталога, она растёт с каждым изменением товаров.
+	CatalogVersion(ctx context.Context) (int64, error)
 }
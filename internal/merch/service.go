@@ -3,8 +3,9 @@ package merch
 import (
 	"avito-intern/internal/auth"
 	"avito-intern/internal/coin"
+	"avito-intern/internal/common"
 	"context"
-	"errors"
+	"strings"
 	"time"
 )
 
@@ -12,13 +13,18 @@ type service struct {
 	authService auth.Service
 	coinService coin.Service
 	repo        Repository
+	uow         common.UnitOfWork
+	catalog     *CatalogCache
 }
 
-func NewService(authService auth.Service, coinService coin.Service, repo Repository) Service {
+// NewService создает сервис магазина. catalog может быть nil, тогда каталог каждый раз читается из БД.
+func NewService(authService auth.Service, coinService coin.Service, repo Repository, uow common.UnitOfWork, catalog *CatalogCache) Service {
 	return &service{
 		authService: authService,
 		coinService: coinService,
 		repo:        repo,
+		uow:         uow,
+		catalog:     catalog,
 	}
 }
 
@@ -27,28 +33,28 @@ func (s *service) Purchase(ctx context.Context, user *auth.User, merchName strin
 	if err != nil {
 		return err
 	}
+	if !merch.Available {
+		return ErrMerchUnavailable
+	}
 
 	totalCost := merch.Price * 1
-	if user.CoinBalance < totalCost {
-		return errors.New("not enough coins")
-	}
 
-	_, err = s.coinService.Purchase(ctx, user, totalCost)
-	if err != nil {
-		return err
-	}
+	// Списание монет и запись покупки фиксируются одной транзакцией:
+	// если покупку не удалось сохранить, монеты не списываются.
+	return s.uow.RunInTransaction(ctx, func(ctx context.Context) error {
+		tx, err := s.coinService.Purchase(ctx, user, totalCost)
+		if err != nil {
+			return err
+		}
 
-	err = s.repo.SavePurchase(ctx, &Purchase{
-		UserID:      user.ID,
-		MerchID:     merch.ID,
-		Quantity:    1,
-		PurchasedAt: time.Now(),
+		return s.repo.SavePurchase(ctx, &Purchase{
+			UserID:        user.ID,
+			TransactionID: tx.ID,
+			MerchID:       merch.ID,
+			Quantity:      1,
+			PurchasedAt:   time.Now(),
+		})
 	})
-	if err != nil {
-		return err
-	}
-
-	return nil
 }
 
 func (s *service) ListPurchases(ctx context.Context, user *auth.User) ([]*Purchase, error) {
@@ -58,3 +64,49 @@ func (s *service) ListPurchases(ctx context.Context, user *auth.User) ([]*Purcha
 func (s *service) ListTransfers(ctx context.Context, user *auth.User) (incoming, outgoing []*coin.Transaction, err error) {
 	return s.coinService.ListTransfers(ctx, user)
 }
+
+func (s *service) GetBalance(ctx context.Context, user *auth.User) (int, error) {
+	return s.coinService.GetBalance(ctx, user)
+}
+
+func (s *service) ExpiringCoins(ctx context.Context, user *auth.User) ([]coin.ExpiringCoins, error) {
+	return s.coinService.ExpiringCoins(ctx, user)
+}
+
+// Catalog возвращает каталог из кэша, если его версия недавно сверялась или совпадает с версией в БД,
+// иначе читает его из БД.
+func (s *service) Catalog(ctx context.Context) (*Catalog, error) {
+	if catalog, ok := s.catalog.Fresh(); ok {
+		return catalog, nil
+	}
+	version, err := s.repo.CatalogVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if catalog, ok := s.catalog.Get(version); ok {
+		return catalog, nil
+	}
+	items, err := s.repo.ListMerch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := newCatalog(items, version)
+	if err != nil {
+		return nil, err
+	}
+	s.catalog.Set(catalog)
+	return catalog, nil
+}
+
+// UpdateMerch меняет цену, описание или доступность товара. Кэш каталога на всех репликах
+// устаревает вместе с версией каталога в БД.
+func (s *service) UpdateMerch(ctx context.Context, id int64, upd MerchUpdate) (*Merch, error) {
+	if upd.Price != nil && *upd.Price <= 0 {
+		return nil, ErrInvalidPrice
+	}
+	if upd.Description != nil {
+		description := strings.TrimSpace(*upd.Description)
+		upd.Description = &description
+	}
+	return s.repo.UpdateMerch(ctx, id, upd)
+}
@@ -4,11 +4,13 @@ import (
 	"avito-intern/internal/auth"
 	"avito-intern/internal/coin"
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockAuthService is a mock implementation of the auth.Service interface.
@@ -16,9 +18,19 @@ type MockAuthService struct {
 	mock.Mock
 }
 
-func (m *MockAuthService) AuthUser(ctx context.Context, username, password string) (auth.Token, error) {
+func (m *MockAuthService) AuthUser(ctx context.Context, username, password string) (*auth.TokenPair, error) {
 	args := m.Called(ctx, username, password)
-	return args.Get(0).(auth.Token), args.Error(1)
+	return args.Get(0).(*auth.TokenPair), args.Error(1)
+}
+
+func (m *MockAuthService) Refresh(ctx context.Context, refreshToken auth.RefreshToken) (*auth.TokenPair, error) {
+	args := m.Called(ctx, refreshToken)
+	return args.Get(0).(*auth.TokenPair), args.Error(1)
+}
+
+func (m *MockAuthService) Logout(ctx context.Context, rawToken auth.Token) error {
+	args := m.Called(ctx, rawToken)
+	return args.Error(0)
 }
 
 func (m *MockAuthService) GetUserFromToken(ctx context.Context, rawToken auth.Token) (*auth.User, error) {
@@ -31,6 +43,25 @@ func (m *MockAuthService) GetUserByUsername(ctx context.Context, username string
 	return args.Get(0).(*auth.User), args.Error(1)
 }
 
+func (m *MockAuthService) Register(ctx context.Context, username, password string) (*auth.TokenPair, error) {
+	args := m.Called(ctx, username, password)
+	return args.Get(0).(*auth.TokenPair), args.Error(1)
+}
+
+func (m *MockAuthService) SetRoles(ctx context.Context, username string, roles []auth.Role) (*auth.User, error) {
+	args := m.Called(ctx, username, roles)
+	return args.Get(0).(*auth.User), args.Error(1)
+}
+
+func (m *MockAuthService) BootstrapAdmin(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) RunCleanup(ctx context.Context) {
+	m.Called(ctx)
+}
+
 // MockCoinService is a mock implementation of the coin.Service interface.
 type MockCoinService struct {
 	mock.Mock
@@ -41,11 +72,49 @@ func (m *MockCoinService) GetUserByUsername(ctx context.Context, username string
 	return args.Get(0).(*auth.User), args.Error(1)
 }
 
-func (m *MockCoinService) Transfer(ctx context.Context, from, to *auth.User, amount int) (*coin.Transaction, error) {
-	args := m.Called(ctx, from, to, amount)
+func (m *MockCoinService) Transfer(ctx context.Context, from, to *auth.User, amount int, note coin.Note) (*coin.Transaction, error) {
+	args := m.Called(ctx, from, to, amount, note)
+	return args.Get(0).(*coin.Transaction), args.Error(1)
+}
+
+func (m *MockCoinService) TransferBatch(ctx context.Context, from *auth.User, legs []coin.TransferLeg, note coin.Note) (*coin.Batch, error) {
+	args := m.Called(ctx, from, legs, note)
+	return args.Get(0).(*coin.Batch), args.Error(1)
+}
+
+func (m *MockCoinService) TransferPending(ctx context.Context, from, to *auth.User, amount int, note coin.Note) (*coin.Transaction, error) {
+	args := m.Called(ctx, from, to, amount, note)
+	return args.Get(0).(*coin.Transaction), args.Error(1)
+}
+
+func (m *MockCoinService) Accept(ctx context.Context, user *auth.User, id coin.TransactionID) (*coin.Transaction, error) {
+	args := m.Called(ctx, user, id)
+	return args.Get(0).(*coin.Transaction), args.Error(1)
+}
+
+func (m *MockCoinService) Decline(ctx context.Context, user *auth.User, id coin.TransactionID) (*coin.Transaction, error) {
+	args := m.Called(ctx, user, id)
 	return args.Get(0).(*coin.Transaction), args.Error(1)
 }
 
+func (m *MockCoinService) ListPending(ctx context.Context, user *auth.User) (incoming, outgoing []*coin.Transaction, err error) {
+	args := m.Called(ctx, user)
+	return args.Get(0).([]*coin.Transaction), args.Get(1).([]*coin.Transaction), args.Error(2)
+}
+
+func (m *MockCoinService) RunExpiry(ctx context.Context) {
+	m.Called(ctx)
+}
+
+func (m *MockCoinService) RunCoinExpiry(ctx context.Context) {
+	m.Called(ctx)
+}
+
+func (m *MockCoinService) ExpiringCoins(ctx context.Context, user *auth.User) ([]coin.ExpiringCoins, error) {
+	args := m.Called(ctx, user)
+	return args.Get(0).([]coin.ExpiringCoins), args.Error(1)
+}
+
 func (m *MockCoinService) Purchase(ctx context.Context, user *auth.User, amount int) (*coin.Transaction, error) {
 	args := m.Called(ctx, user, amount)
 	return args.Get(0).(*coin.Transaction), args.Error(1)
@@ -56,6 +125,51 @@ func (m *MockCoinService) ListTransfers(ctx context.Context, user *auth.User) (i
 	return args.Get(0).([]*coin.Transaction), args.Get(1).([]*coin.Transaction), args.Error(2)
 }
 
+func (m *MockCoinService) History(ctx context.Context, user *auth.User, filter coin.HistoryFilter) (*coin.HistoryPage, error) {
+	args := m.Called(ctx, user, filter)
+	return args.Get(0).(*coin.HistoryPage), args.Error(1)
+}
+
+func (m *MockCoinService) Reverse(ctx context.Context, admin *auth.User, id coin.TransactionID, amount int, reason string) (*coin.ReversalRecord, error) {
+	args := m.Called(ctx, admin, id, amount, reason)
+	return args.Get(0).(*coin.ReversalRecord), args.Error(1)
+}
+
+func (m *MockCoinService) CreatePool(ctx context.Context, organizer, recipient *auth.User, title string, goal int, deadline time.Time) (*coin.Pool, error) {
+	args := m.Called(ctx, organizer, recipient, title, goal, deadline)
+	return args.Get(0).(*coin.Pool), args.Error(1)
+}
+
+func (m *MockCoinService) GetPool(ctx context.Context, user *auth.User, id coin.PoolID) (*coin.Pool, []*coin.Transaction, error) {
+	args := m.Called(ctx, user, id)
+	return args.Get(0).(*coin.Pool), args.Get(1).([]*coin.Transaction), args.Error(2)
+}
+
+func (m *MockCoinService) ListPools(ctx context.Context, user *auth.User) ([]*coin.Pool, error) {
+	args := m.Called(ctx, user)
+	return args.Get(0).([]*coin.Pool), args.Error(1)
+}
+
+func (m *MockCoinService) Contribute(ctx context.Context, user *auth.User, id coin.PoolID, amount int, note coin.Note) (*coin.Transaction, error) {
+	args := m.Called(ctx, user, id, amount, note)
+	return args.Get(0).(*coin.Transaction), args.Error(1)
+}
+
+func (m *MockCoinService) ClosePool(ctx context.Context, user *auth.User, id coin.PoolID) (*coin.Pool, error) {
+	args := m.Called(ctx, user, id)
+	return args.Get(0).(*coin.Pool), args.Error(1)
+}
+
+func (m *MockCoinService) CancelPool(ctx context.Context, user *auth.User, id coin.PoolID) (*coin.Pool, error) {
+	args := m.Called(ctx, user, id)
+	return args.Get(0).(*coin.Pool), args.Error(1)
+}
+
+func (m *MockCoinService) GetBalance(ctx context.Context, user *auth.User) (int, error) {
+	args := m.Called(ctx, user)
+	return args.Int(0), args.Error(1)
+}
+
 // MockRepository is a mock implementation of the merch.Repository interface.
 type MockRepository struct {
 	mock.Mock
@@ -76,15 +190,57 @@ func (m *MockRepository) ListPurchasesByUserID(ctx context.Context, userID auth.
 	return args.Get(0).([]*Purchase), args.Error(1)
 }
 
+func (m *MockRepository) ListMerch(ctx context.Context) ([]*Merch, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*Merch), args.Error(1)
+}
+
+func (m *MockRepository) GetMerch(ctx context.Context, id int64) (*Merch, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*Merch), args.Error(1)
+}
+
+func (m *MockRepository) UpdateMerch(ctx context.Context, id int64, upd MerchUpdate) (*Merch, error) {
+	args := m.Called(ctx, id, upd)
+	return args.Get(0).(*Merch), args.Error(1)
+}
+
+func (m *MockRepository) CatalogVersion(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type txKey struct{}
+
+// fakeUnitOfWork помечает контекст открытой транзакцией и запоминает её исход.
+type fakeUnitOfWork struct {
+	committed  int
+	rolledBack int
+}
+
+func (u *fakeUnitOfWork) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(context.WithValue(ctx, txKey{}, u)); err != nil {
+		u.rolledBack++
+		return err
+	}
+	u.committed++
+	return nil
+}
+
+func inTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*fakeUnitOfWork)
+	return ok
+}
+
 func TestService_Purchase(t *testing.T) {
 	mockAuthService := new(MockAuthService)
 	mockCoinService := new(MockCoinService)
 	mockRepo := new(MockRepository)
 
-	service := NewService(mockAuthService, mockCoinService, mockRepo)
+	service := NewService(mockAuthService, mockCoinService, mockRepo, &fakeUnitOfWork{}, nil)
 
 	user := &auth.User{ID: 1, CoinBalance: 100}
-	merchItem := &Merch{ID: 1, Name: "T-Shirt", Price: 50}
+	merchItem := &Merch{ID: 1, Name: "T-Shirt", Price: 50, Available: true}
 
 	mockRepo.On("GetMerchByID", mock.Anything, merchItem.Name).Return(merchItem, nil)
 	mockCoinService.On("Purchase", mock.Anything, user, merchItem.Price).Return(&coin.Transaction{}, nil)
@@ -96,12 +252,164 @@ func TestService_Purchase(t *testing.T) {
 	mockCoinService.AssertExpectations(t)
 }
 
+func TestService_Purchase_SingleUnitOfWork(t *testing.T) {
+	mockAuthService := new(MockAuthService)
+	mockCoinService := new(MockCoinService)
+	mockRepo := new(MockRepository)
+	uow := &fakeUnitOfWork{}
+
+	service := NewService(mockAuthService, mockCoinService, mockRepo, uow, nil)
+
+	user := &auth.User{ID: 1, CoinBalance: 100}
+	merchItem := &Merch{ID: 1, Name: "cup", Price: 20, Available: true}
+
+	mockRepo.On("GetMerchByID", mock.Anything, merchItem.Name).Return(merchItem, nil)
+	mockCoinService.On("Purchase", mock.MatchedBy(inTx), user, merchItem.Price).
+		Return(&coin.Transaction{ID: 7}, nil)
+	mockRepo.On("SavePurchase", mock.MatchedBy(inTx), mock.MatchedBy(func(p *Purchase) bool {
+		return p.TransactionID == 7 && p.MerchID == merchItem.ID
+	})).Return(nil)
+
+	err := service.Purchase(context.Background(), user, merchItem.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, uow.committed)
+	assert.Equal(t, 0, uow.rolledBack)
+	mockRepo.AssertExpectations(t)
+	mockCoinService.AssertExpectations(t)
+}
+
+func TestService_Purchase_RollbackOnSavePurchaseError(t *testing.T) {
+	mockAuthService := new(MockAuthService)
+	mockCoinService := new(MockCoinService)
+	mockRepo := new(MockRepository)
+	uow := &fakeUnitOfWork{}
+
+	service := NewService(mockAuthService, mockCoinService, mockRepo, uow, nil)
+
+	user := &auth.User{ID: 1, CoinBalance: 100}
+	merchItem := &Merch{ID: 1, Name: "cup", Price: 20, Available: true}
+	saveErr := errors.New("insert failed")
+
+	mockRepo.On("GetMerchByID", mock.Anything, merchItem.Name).Return(merchItem, nil)
+	mockCoinService.On("Purchase", mock.MatchedBy(inTx), user, merchItem.Price).
+		Return(&coin.Transaction{ID: 7}, nil)
+	mockRepo.On("SavePurchase", mock.MatchedBy(inTx), mock.AnythingOfType("*merch.Purchase")).Return(saveErr)
+
+	err := service.Purchase(context.Background(), user, merchItem.Name)
+	assert.ErrorIs(t, err, saveErr)
+	// Списание монет и запись покупки выполнены в одной транзакции, и она откачена.
+	assert.Equal(t, 0, uow.committed)
+	assert.Equal(t, 1, uow.rolledBack)
+	mockRepo.AssertExpectations(t)
+	mockCoinService.AssertExpectations(t)
+}
+
+func TestService_Purchase_Unavailable(t *testing.T) {
+	mockCoinService := new(MockCoinService)
+	mockRepo := new(MockRepository)
+	service := NewService(new(MockAuthService), mockCoinService, mockRepo, &fakeUnitOfWork{}, nil)
+
+	merchItem := &Merch{ID: 1, Name: "pink-hoody", Price: 500}
+	mockRepo.On("GetMerchByID", mock.Anything, merchItem.Name).Return(merchItem, nil)
+
+	err := service.Purchase(context.Background(), &auth.User{ID: 1}, merchItem.Name)
+	assert.ErrorIs(t, err, ErrMerchUnavailable)
+	mockCoinService.AssertNotCalled(t, "Purchase", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestService_Catalog(t *testing.T) {
+	mockRepo := new(MockRepository)
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	catalogCache := NewCatalogCache(time.Minute, time.Second)
+	catalogCache.now = func() time.Time { return now }
+	service := NewService(new(MockAuthService), new(MockCoinService), mockRepo, &fakeUnitOfWork{}, catalogCache)
+	ctx := context.Background()
+
+	cup := &Merch{ID: 2, Name: "cup", Price: 20, Description: "Кружка", Available: true}
+	mockRepo.On("CatalogVersion", mock.Anything).Return(int64(1), nil).Twice()
+	mockRepo.On("ListMerch", mock.Anything).Return([]*Merch{cup}, nil).Once()
+
+	first, err := service.Catalog(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*Merch{cup}, first.Items)
+	assert.NotEmpty(t, first.ETag)
+	// в пределах versionTTL каталог отдаётся из кэша без запросов к БД.
+	for i := 0; i < 3; i++ {
+		cached, err := service.Catalog(ctx)
+		require.NoError(t, err)
+		assert.Same(t, first, cached)
+	}
+	mockRepo.AssertNumberOfCalls(t, "CatalogVersion", 1)
+	mockRepo.AssertNumberOfCalls(t, "ListMerch", 1)
+
+	// по истечении versionTTL версия сверяется, и неизменный каталог остаётся в кэше.
+	now = now.Add(time.Second)
+	cached, err := service.Catalog(ctx)
+	require.NoError(t, err)
+	assert.Same(t, first, cached)
+	mockRepo.AssertNumberOfCalls(t, "CatalogVersion", 2)
+
+	// каталог изменили на другой реплике: версия выросла, и кэш читается заново.
+	now = now.Add(time.Second)
+	updated := &Merch{ID: 2, Name: "cup", Price: 25, Description: "Кружка", Available: true}
+	mockRepo.On("CatalogVersion", mock.Anything).Return(int64(2), nil)
+	mockRepo.On("ListMerch", mock.Anything).Return([]*Merch{updated}, nil).Once()
+	second, err := service.Catalog(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ETag, second.ETag)
+	assert.Equal(t, int64(2), second.Version)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_UpdateMerch(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(new(MockAuthService), new(MockCoinService), mockRepo, &fakeUnitOfWork{}, nil)
+	ctx := context.Background()
+
+	// в репозиторий уходят только заданные поля, остальные он не трогает.
+	description, trimmed := "  Кружка  ", "Кружка"
+	updated := &Merch{ID: 2, Name: "cup", Price: 20, Description: trimmed, Available: true}
+	mockRepo.On("UpdateMerch", mock.Anything, int64(2), MerchUpdate{Description: &trimmed}).Return(updated, nil)
+	got, err := service.UpdateMerch(ctx, 2, MerchUpdate{Description: &description})
+	require.NoError(t, err)
+	assert.Equal(t, updated, got)
+
+	invalid := 0
+	_, err = service.UpdateMerch(ctx, 2, MerchUpdate{Price: &invalid})
+	assert.ErrorIs(t, err, ErrInvalidPrice)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCatalogCache_StaleSet(t *testing.T) {
+	c := NewCatalogCache(time.Minute, 0)
+	_, ok := c.Get(1)
+	require.False(t, ok)
+
+	c.Set(&Catalog{ETag: `"fresh"`, Version: 2})
+	// каталог прочитан до изменения, а сохраняется после — более новый снимок он не вытесняет.
+	c.Set(&Catalog{ETag: `"stale"`, Version: 1})
+	catalog, ok := c.Get(2)
+	require.True(t, ok)
+	assert.Equal(t, `"fresh"`, catalog.ETag)
+	_, ok = c.Get(3)
+	assert.False(t, ok)
+}
+
+func TestETagMatches(t *testing.T) {
+	etag := `"abc"`
+	assert.True(t, etagMatches(`"abc"`, etag))
+	assert.True(t, etagMatches(`"x", W/"abc"`, etag))
+	assert.True(t, etagMatches(`*`, etag))
+	assert.False(t, etagMatches(``, etag))
+	assert.False(t, etagMatches(`"abcd"`, etag))
+}
+
 func TestService_ListPurchases(t *testing.T) {
 	mockAuthService := new(MockAuthService)
 	mockCoinService := new(MockCoinService)
 	mockRepo := new(MockRepository)
 
-	service := NewService(mockAuthService, mockCoinService, mockRepo)
+	service := NewService(mockAuthService, mockCoinService, mockRepo, &fakeUnitOfWork{}, nil)
 
 	user := &auth.User{ID: 1}
 	purchases := []*Purchase{
@@ -122,7 +430,7 @@ func TestService_ListTransfers(t *testing.T) {
 	mockCoinService := new(MockCoinService)
 	mockRepo := new(MockRepository)
 
-	service := NewService(mockAuthService, mockCoinService, mockRepo)
+	service := NewService(mockAuthService, mockCoinService, mockRepo, &fakeUnitOfWork{}, nil)
 
 	user := &auth.User{ID: 1}
 	incoming := []*coin.Transaction{
@@ -140,3 +448,19 @@ func TestService_ListTransfers(t *testing.T) {
 	assert.Equal(t, outgoing, out)
 	mockCoinService.AssertExpectations(t)
 }
+
+func TestService_GetBalance(t *testing.T) {
+	mockAuthService := new(MockAuthService)
+	mockCoinService := new(MockCoinService)
+	mockRepo := new(MockRepository)
+
+	service := NewService(mockAuthService, mockCoinService, mockRepo, &fakeUnitOfWork{}, nil)
+
+	user := &auth.User{ID: 1, CoinBalance: 1000}
+	mockCoinService.On("GetBalance", mock.Anything, user).Return(750, nil)
+
+	balance, err := service.GetBalance(context.Background(), user)
+	assert.NoError(t, err)
+	assert.Equal(t, 750, balance)
+	mockCoinService.AssertExpectations(t)
+}
@@ -0,0 +1,73 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- Журнал проводок: каждая транзакция раскладывается на сбалансированные
+-- записи (сумма amount по транзакции равна 0). Положительная сумма — приход
+-- на счёт, отрицательная — расход. Для счетов сотрудников заполнен fk_user,
+-- системные счета (issuance, shop) его не имеют.
+CREATE TABLE ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    fk_transaction INTEGER NOT NULL REFERENCES transactions(id),
+    account TEXT NOT NULL,
+    fk_user INTEGER REFERENCES users(id),
+    amount INTEGER NOT NULL CHECK (amount <> 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ((account = 'user') = (fk_user IS NOT NULL))
+);
+
+CREATE INDEX ledger_entries_user_idx ON ledger_entries (fk_user, account) INCLUDE (amount);
+CREATE INDEX ledger_entries_transaction_idx ON ledger_entries (fk_transaction);
+
+CREATE FUNCTION ledger_entries_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_append_only
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_entries_append_only();
+
+CREATE FUNCTION ledger_entries_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM ledger_entries WHERE fk_transaction = NEW.fk_transaction) <> 0 THEN
+        RAISE EXCEPTION 'transaction % is not balanced', NEW.fk_transaction;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced
+    AFTER INSERT ON ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION ledger_entries_balanced();
+
+-- Переносим текущие балансы в журнал как начальные начисления.
+WITH opening AS (
+    INSERT INTO transactions (fk_to_user, amount, type)
+    SELECT id, coin_balance, 'grant' FROM users WHERE coin_balance > 0
+    RETURNING id, fk_to_user, amount
+)
+INSERT INTO ledger_entries (fk_transaction, account, fk_user, amount)
+SELECT id, 'issuance', NULL, -amount FROM opening
+UNION ALL
+SELECT id, 'user', fk_to_user, amount FROM opening;
+
+ALTER TABLE users DROP COLUMN coin_balance;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE users ADD COLUMN coin_balance INT;
+UPDATE users u SET coin_balance = COALESCE((
+    SELECT SUM(e.amount) FROM ledger_entries e
+    WHERE e.account = 'user' AND e.fk_user = u.id
+), 0);
+
+DROP TABLE ledger_entries;
+DROP FUNCTION ledger_entries_balanced();
+DROP FUNCTION ledger_entries_append_only();
+-- +goose StatementEnd
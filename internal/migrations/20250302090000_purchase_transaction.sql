@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE purchases ADD COLUMN fk_transaction INTEGER REFERENCES transactions(id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE purchases DROP COLUMN fk_transaction;
+-- +goose StatementEnd
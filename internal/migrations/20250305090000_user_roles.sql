@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Роли пользователя: employee есть у всех, административные выдаются явно.
+ALTER TABLE users ADD COLUMN roles TEXT[] NOT NULL DEFAULT '{employee}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE users DROP COLUMN roles;
+-- +goose StatementEnd
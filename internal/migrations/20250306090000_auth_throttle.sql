@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Счётчики неудачных входов и регистраций по имени пользователя и IP.
+CREATE TABLE auth_throttle (
+    key TEXT PRIMARY KEY,
+    attempts INTEGER NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE auth_throttle;
+-- +goose StatementEnd
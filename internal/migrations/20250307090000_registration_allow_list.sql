@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Сотрудники, которым разрешена регистрация в режиме REGISTRATION_MODE=allow-list.
+CREATE TABLE registration_allow_list (
+    username TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE registration_allow_list;
+-- +goose StatementEnd
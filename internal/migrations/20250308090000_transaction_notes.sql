@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Необязательная благодарность к переводу: сообщение и теги (категории или эмодзи).
+ALTER TABLE transactions
+    ADD COLUMN message TEXT NOT NULL DEFAULT '',
+    ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE transactions
+    DROP COLUMN tags,
+    DROP COLUMN message;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- История пользователя листается по id проводки (keyset), фильтр по дате — по created_at.
+CREATE INDEX ledger_entries_user_history_idx ON ledger_entries (fk_user, id)
+    INCLUDE (amount, created_at)
+    WHERE account = 'user';
+-- Фильтр по второму участнику перевода.
+CREATE INDEX transactions_from_user_idx ON transactions (fk_from_user);
+CREATE INDEX transactions_to_user_idx ON transactions (fk_to_user);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX transactions_to_user_idx;
+DROP INDEX transactions_from_user_idx;
+DROP INDEX ledger_entries_user_history_idx;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Пакетный перевод: переводы нескольким получателям, проведённые в одной транзакции.
+CREATE TABLE transfer_batches (
+    id BIGSERIAL PRIMARY KEY,
+    fk_from_user INTEGER NOT NULL REFERENCES users(id),
+    transfers INTEGER NOT NULL CHECK (transfers > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE transactions ADD COLUMN fk_batch BIGINT REFERENCES transfer_batches(id);
+CREATE INDEX transactions_batch_idx ON transactions (fk_batch) WHERE fk_batch IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE transactions DROP COLUMN fk_batch;
+DROP TABLE transfer_batches;
+-- +goose StatementEnd
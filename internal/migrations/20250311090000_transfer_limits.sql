@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Лимиты переводов считают сумму и время последнего перевода отправителя за период.
+CREATE INDEX transactions_transfer_limits_idx ON transactions (fk_from_user, created_at)
+    INCLUDE (fk_to_user, amount)
+    WHERE type = 'transfer';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX transactions_transfer_limits_idx;
+-- +goose StatementEnd
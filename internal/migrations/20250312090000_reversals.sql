@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Компенсирующая транзакция ссылается на исходную, возвратов одного перевода может быть несколько.
+ALTER TABLE transactions ADD COLUMN fk_prev_transaction INTEGER REFERENCES transactions(id);
+CREATE INDEX transactions_prev_transaction_idx ON transactions (fk_prev_transaction)
+    WHERE fk_prev_transaction IS NOT NULL;
+
+-- Кто и почему провёл возврат.
+CREATE TABLE reversals (
+    fk_transaction INTEGER PRIMARY KEY REFERENCES transactions(id),
+    fk_admin INTEGER NOT NULL REFERENCES users(id),
+    reason TEXT NOT NULL,
+    requested INTEGER NOT NULL CHECK (requested > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE reversals;
+ALTER TABLE transactions DROP COLUMN fk_prev_transaction;
+-- +goose StatementEnd
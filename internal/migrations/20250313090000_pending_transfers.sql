@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Перевод с подтверждением: монеты лежат на счёте escrow, пока получатель не примет
+-- или не отклонит перевод, или пока он не истечёт. Закрывающие проводки добавляются
+-- к той же транзакции, поэтому она остаётся сбалансированной.
+ALTER TABLE transactions
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'completed'
+        CHECK (status IN ('completed', 'pending', 'accepted', 'declined', 'expired')),
+    ADD COLUMN expires_at TIMESTAMPTZ,
+    ADD COLUMN settled_at TIMESTAMPTZ,
+    ADD CHECK (status <> 'pending' OR expires_at IS NOT NULL);
+
+CREATE INDEX transactions_pending_expiry_idx ON transactions (expires_at) WHERE status = 'pending';
+CREATE INDEX transactions_pending_to_user_idx ON transactions (fk_to_user) WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE transactions
+    DROP COLUMN settled_at,
+    DROP COLUMN expires_at,
+    DROP COLUMN status;
+-- +goose StatementEnd
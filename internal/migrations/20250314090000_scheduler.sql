@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Периодические задачи. next_run_at — время следующего запуска и период, за который он выполняется,
+-- retry_at откладывает повтор неудачного периода.
+CREATE TABLE jobs (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL,
+    schedule TEXT NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    retry_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX jobs_due_idx ON jobs (next_run_at) WHERE enabled;
+
+-- Не больше одного запуска задачи за период, неудачный запуск повторяется в той же записи.
+CREATE TABLE job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    fk_job BIGINT NOT NULL REFERENCES jobs(id),
+    period TIMESTAMPTZ NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed')),
+    result TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    UNIQUE (fk_job, period)
+);
+
+-- Начисления по расписанию: пользователь получает монеты по задаче не больше раза за период.
+CREATE TABLE scheduled_grants (
+    fk_job BIGINT NOT NULL REFERENCES jobs(id),
+    period TIMESTAMPTZ NOT NULL,
+    fk_user INTEGER NOT NULL REFERENCES users(id),
+    fk_transaction INTEGER NOT NULL REFERENCES transactions(id),
+    PRIMARY KEY (fk_job, period, fk_user)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE scheduled_grants;
+DROP TABLE job_runs;
+DROP TABLE jobs;
+-- +goose StatementEnd
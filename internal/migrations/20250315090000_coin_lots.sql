@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Партии монет: каждое поступление на счёт сотрудника открывает партию, списания расходуют
+-- партии в порядке получения (FIFO). Сумма remaining открытых партий равна балансу.
+-- Срок действия считается от received_at, поэтому его можно менять настройкой.
+CREATE TABLE coin_lots (
+    id BIGSERIAL PRIMARY KEY,
+    fk_user INTEGER NOT NULL REFERENCES users(id),
+    fk_transaction INTEGER REFERENCES transactions(id),
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    remaining INTEGER NOT NULL CHECK (remaining >= 0 AND remaining <= amount),
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX coin_lots_open_idx ON coin_lots (fk_user, received_at, id) WHERE remaining > 0;
+CREATE INDEX coin_lots_expiry_idx ON coin_lots (received_at) WHERE remaining > 0;
+
+-- Текущие балансы переносятся одной партией без транзакции, срок действия считается от миграции.
+INSERT INTO coin_lots (fk_user, amount, remaining)
+SELECT fk_user, SUM(amount), SUM(amount)
+FROM ledger_entries
+WHERE account = 'user'
+GROUP BY fk_user
+HAVING SUM(amount) > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE coin_lots;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Корректировки балансов по итогам сверки: кто одобрил, почему и какие балансы были до неё.
+-- fk_transaction пуст, если исправлялись только партии монет.
+CREATE TABLE balance_corrections (
+    id BIGSERIAL PRIMARY KEY,
+    fk_user INTEGER NOT NULL REFERENCES users(id),
+    fk_admin INTEGER NOT NULL REFERENCES users(id),
+    fk_transaction INTEGER REFERENCES transactions(id),
+    reason TEXT NOT NULL,
+    ledger_before INTEGER NOT NULL,
+    history INTEGER NOT NULL,
+    lots_before INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE balance_corrections;
+-- +goose StatementEnd
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE users ADD COLUMN leaderboard_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Агрегаты переводов для рейтинга, обновляются вместе с проводкой перевода: по дням (UTC)
+-- для недели и месяца и итоговые для всего времени. Возврат уменьшает суммы в дне перевода.
+CREATE TABLE kudos_daily (
+    day DATE NOT NULL,
+    fk_user INTEGER NOT NULL REFERENCES users(id),
+    given_count INTEGER NOT NULL DEFAULT 0,
+    given_amount INTEGER NOT NULL DEFAULT 0,
+    received_count INTEGER NOT NULL DEFAULT 0,
+    received_amount INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, fk_user)
+);
+
+CREATE TABLE kudos_totals (
+    fk_user INTEGER PRIMARY KEY REFERENCES users(id),
+    given_count INTEGER NOT NULL DEFAULT 0,
+    given_amount INTEGER NOT NULL DEFAULT 0,
+    received_count INTEGER NOT NULL DEFAULT 0,
+    received_amount INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX kudos_totals_given_amount_idx ON kudos_totals (given_amount DESC);
+CREATE INDEX kudos_totals_given_count_idx ON kudos_totals (given_count DESC);
+CREATE INDEX kudos_totals_received_amount_idx ON kudos_totals (received_amount DESC);
+CREATE INDEX kudos_totals_received_count_idx ON kudos_totals (received_count DESC);
+
+-- Заполняем агрегаты по уже проведённым переводам и возвратам.
+INSERT INTO kudos_daily (day, fk_user, given_count, given_amount, received_count, received_amount)
+SELECT day, fk_user, SUM(given_count), SUM(given_amount), SUM(received_count), SUM(received_amount)
+FROM (
+    SELECT (created_at::timestamptz AT TIME ZONE 'UTC')::date AS day, fk_from_user AS fk_user,
+        1 AS given_count, amount AS given_amount, 0 AS received_count, 0 AS received_amount
+    FROM transactions
+    WHERE type = 'transfer' AND status IN ('completed', 'accepted')
+    UNION ALL
+    SELECT (created_at::timestamptz AT TIME ZONE 'UTC')::date, fk_to_user, 0, 0, 1, amount
+    FROM transactions
+    WHERE type = 'transfer' AND status IN ('completed', 'accepted')
+    UNION ALL
+    SELECT (o.created_at::timestamptz AT TIME ZONE 'UTC')::date, o.fk_from_user, 0, -r.amount, 0, 0
+    FROM transactions r JOIN transactions o ON o.id = r.fk_prev_transaction
+    WHERE r.type = 'reversal'
+    UNION ALL
+    SELECT (o.created_at::timestamptz AT TIME ZONE 'UTC')::date, o.fk_to_user, 0, 0, 0, -r.amount
+    FROM transactions r JOIN transactions o ON o.id = r.fk_prev_transaction
+    WHERE r.type = 'reversal'
+) kudos
+GROUP BY day, fk_user;
+
+INSERT INTO kudos_totals (fk_user, given_count, given_amount, received_count, received_amount)
+SELECT fk_user, SUM(given_count), SUM(given_amount), SUM(received_count), SUM(received_amount)
+FROM kudos_daily
+GROUP BY fk_user;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE kudos_totals;
+DROP TABLE kudos_daily;
+ALTER TABLE users DROP COLUMN leaderboard_opt_out;
+-- +goose StatementEnd
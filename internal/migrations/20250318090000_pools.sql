@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Сбор монет в подарок: взносы лежат на счёте escrow, при закрытии получатель получает
+-- их одним начислением, при отмене участникам возвращаются их взносы.
+CREATE TABLE pools (
+    id BIGSERIAL PRIMARY KEY,
+    fk_organizer INTEGER NOT NULL REFERENCES users(id),
+    fk_recipient INTEGER NOT NULL REFERENCES users(id),
+    title TEXT NOT NULL,
+    goal INTEGER NOT NULL DEFAULT 0 CHECK (goal >= 0),
+    deadline TIMESTAMPTZ,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'paid_out', 'cancelled')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ,
+    CHECK (fk_organizer <> fk_recipient),
+    CHECK ((status = 'open') = (closed_at IS NULL))
+);
+
+CREATE INDEX pools_organizer_idx ON pools (fk_organizer);
+CREATE INDEX pools_deadline_idx ON pools (deadline) WHERE status = 'open';
+
+ALTER TABLE transactions ADD COLUMN fk_pool BIGINT REFERENCES pools(id);
+
+CREATE INDEX transactions_pool_idx ON transactions (fk_pool, fk_from_user) WHERE fk_pool IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE transactions DROP COLUMN fk_pool;
+DROP TABLE pools;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Transactional outbox: доменные события пишутся в одной транзакции с изменением,
+-- фоновая доставка отправляет их во внешние системы и отмечает delivered_at.
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_attempt_at TIMESTAMPTZ,
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_events_undelivered_idx ON outbox_events (id) WHERE delivered_at IS NULL;
+CREATE INDEX outbox_events_delivered_at_idx ON outbox_events (delivered_at) WHERE delivered_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE outbox_events;
+-- +goose StatementEnd
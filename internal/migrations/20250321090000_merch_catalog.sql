@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Каталог мерча: описание для витрины и признак, что товар можно купить.
+ALTER TABLE merch
+    ADD COLUMN description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN available BOOLEAN NOT NULL DEFAULT TRUE;
+
+UPDATE merch SET description = d.description
+FROM (VALUES
+    ('t-shirt', 'Футболка с логотипом'),
+    ('cup', 'Керамическая кружка'),
+    ('book', 'Блокнот в твёрдой обложке'),
+    ('pen', 'Шариковая ручка'),
+    ('powerbank', 'Внешний аккумулятор'),
+    ('hoody', 'Худи с логотипом'),
+    ('umbrella', 'Складной зонт'),
+    ('socks', 'Носки с принтом'),
+    ('wallet', 'Кожаный кошелёк'),
+    ('pink-hoody', 'Розовое худи ограниченной серии')
+) AS d(name, description)
+WHERE merch.name = d.name;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE merch DROP COLUMN available, DROP COLUMN description;
+-- +goose StatementEnd
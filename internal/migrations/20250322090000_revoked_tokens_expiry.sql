@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Отозванные токены удаляются после истечения срока, индекс нужен для периодической очистки.
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX revoked_tokens_expires_at_idx;
+-- +goose StatementEnd
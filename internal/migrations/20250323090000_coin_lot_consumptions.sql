@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Какие партии израсходовало каждое списание. Возврат (отклонённый или истекший перевод,
+-- reversal, отмена сбора) восстанавливает эти партии с исходным received_at, а не открывает
+-- новую партию, иначе возвратом можно было бы продлить срок действия монет.
+-- amount уменьшается при восстановлении, поэтому частичные возвраты не вернут больше списанного.
+CREATE TABLE coin_lot_consumptions (
+    fk_transaction INTEGER NOT NULL REFERENCES transactions(id),
+    fk_lot BIGINT NOT NULL REFERENCES coin_lots(id),
+    amount INTEGER NOT NULL CHECK (amount >= 0),
+    PRIMARY KEY (fk_transaction, fk_lot)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE coin_lot_consumptions;
+-- +goose StatementEnd
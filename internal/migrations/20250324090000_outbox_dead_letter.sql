@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Событие, которое приёмник не принял OUTBOX_MAX_ATTEMPTS раз, откладывается (dead_at)
+-- и больше не доставляется, чтобы не держать очередь.
+ALTER TABLE outbox_events ADD COLUMN dead_at TIMESTAMPTZ;
+
+DROP INDEX outbox_events_undelivered_idx;
+CREATE INDEX outbox_events_undelivered_idx ON outbox_events (id) WHERE delivered_at IS NULL AND dead_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX outbox_events_undelivered_idx;
+CREATE INDEX outbox_events_undelivered_idx ON outbox_events (id) WHERE delivered_at IS NULL;
+ALTER TABLE outbox_events DROP COLUMN dead_at;
+-- +goose StatementEnd
@@ -0,0 +1,77 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Получатели outbox (подписки на вебхуки, приёмник OUTBOX_SINK) доставляются независимо:
+-- у каждого своя строка доставки на событие, и сбой одного не задерживает остальных.
+-- Событие получает строки для всех получателей, зарегистрированных на момент записи.
+CREATE TABLE outbox_consumers (
+    name TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE outbox_deliveries (
+    consumer TEXT NOT NULL REFERENCES outbox_consumers(name) ON DELETE CASCADE,
+    fk_event BIGINT NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_attempt_at TIMESTAMPTZ,
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ,
+    dead_at TIMESTAMPTZ,
+    PRIMARY KEY (consumer, fk_event)
+);
+
+CREATE INDEX outbox_deliveries_pending_idx ON outbox_deliveries (consumer, fk_event)
+    WHERE delivered_at IS NULL AND dead_at IS NULL;
+CREATE INDEX outbox_deliveries_event_idx ON outbox_deliveries (fk_event);
+
+-- до разделения оба получателя доставлялись одной пачкой, поэтому прогресс у них общий.
+INSERT INTO outbox_consumers (name) VALUES ('webhooks'), ('sink');
+INSERT INTO outbox_deliveries (consumer, fk_event, attempts, last_attempt_at, last_error, delivered_at, dead_at)
+SELECT c.name, e.id, e.attempts, e.last_attempt_at, e.last_error, e.delivered_at, e.dead_at
+FROM outbox_events e
+CROSS JOIN outbox_consumers c;
+
+DROP INDEX outbox_events_undelivered_idx;
+DROP INDEX outbox_events_delivered_at_idx;
+ALTER TABLE outbox_events
+    DROP COLUMN attempts,
+    DROP COLUMN last_attempt_at,
+    DROP COLUMN last_error,
+    DROP COLUMN delivered_at,
+    DROP COLUMN dead_at;
+CREATE INDEX outbox_events_created_at_idx ON outbox_events (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX outbox_events_created_at_idx;
+ALTER TABLE outbox_events
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN last_attempt_at TIMESTAMPTZ,
+    ADD COLUMN last_error TEXT,
+    ADD COLUMN delivered_at TIMESTAMPTZ,
+    ADD COLUMN dead_at TIMESTAMPTZ;
+-- событие считается доставленным, только если его получили все получатели.
+UPDATE outbox_events e SET
+    attempts = d.attempts,
+    last_attempt_at = d.last_attempt_at,
+    last_error = d.last_error,
+    delivered_at = d.delivered_at,
+    dead_at = d.dead_at
+FROM (
+    SELECT fk_event,
+        MAX(attempts) AS attempts,
+        MAX(last_attempt_at) AS last_attempt_at,
+        MAX(last_error) AS last_error,
+        CASE WHEN bool_and(delivered_at IS NOT NULL) THEN MAX(delivered_at) END AS delivered_at,
+        CASE WHEN bool_or(dead_at IS NOT NULL) THEN MAX(dead_at) END AS dead_at
+    FROM outbox_deliveries
+    GROUP BY fk_event
+) d
+WHERE d.fk_event = e.id;
+CREATE INDEX outbox_events_undelivered_idx ON outbox_events (id) WHERE delivered_at IS NULL AND dead_at IS NULL;
+CREATE INDEX outbox_events_delivered_at_idx ON outbox_events (delivered_at) WHERE delivered_at IS NOT NULL;
+DROP TABLE outbox_deliveries;
+DROP TABLE outbox_consumers;
+-- +goose StatementEnd
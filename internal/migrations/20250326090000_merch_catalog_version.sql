@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Версия каталога растёт с каждым изменением merch. Реплики сверяют с ней кэш каталога,
+-- поэтому изменение на одной реплике сразу видно на всех.
+CREATE TABLE merch_catalog (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    version BIGINT NOT NULL DEFAULT 0
+);
+INSERT INTO merch_catalog DEFAULT VALUES;
+
+CREATE FUNCTION merch_catalog_bump() RETURNS trigger AS $$
+BEGIN
+    UPDATE merch_catalog SET version = version + 1;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER merch_catalog_bump
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON merch
+    FOR EACH STATEMENT EXECUTE FUNCTION merch_catalog_bump();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TRIGGER merch_catalog_bump ON merch;
+DROP FUNCTION merch_catalog_bump();
+DROP TABLE merch_catalog;
+-- +goose StatementEnd
@@ -12,7 +12,7 @@ import (
 var embedMigrations embed.FS
 
 func Migrate(db *db.Database) func() bool {
-	readyCh := make(chan bool)
+	readyCh := make(chan struct{})
 	go func() {
 		goose.SetBaseFS(embedMigrations)
 		if err := goose.SetDialect("postgres"); err != nil {
@@ -23,7 +23,8 @@ func Migrate(db *db.Database) func() bool {
 		if err := goose.Up(conn, "."); err != nil {
 			slog.Error("migration err", "error", err)
 		}
-		readyCh <- true
+		// закрытый канал остаётся готовым для всех последующих проверок.
+		close(readyCh)
 	}()
 
 	return func() bool {
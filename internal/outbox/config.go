@@ -0,0 +1,31 @@
+package outbox
+
+import "time"
+
+type Config struct {
+	// Sink — куда ещё, кроме подписок на вебхуки, доставляются события: file, http или пусто.
+	Sink string `env:"OUTBOX_SINK"`
+	// FilePath — файл, в который приёмник file дописывает события по одному JSON в строке.
+	FilePath string `env:"OUTBOX_FILE" env-default:"events.jsonl"`
+	// WebhookURL — адрес, на который приёмник http отправляет пачки событий.
+	WebhookURL string `env:"OUTBOX_WEBHOOK_URL"`
+	// HTTPTimeout ограничивает отправку одной пачки приёмником http.
+	HTTPTimeout time.Duration `env:"OUTBOX_HTTP_TIMEOUT" env-default:"10s"`
+
+	// PollInterval — как часто проверяются новые события.
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" env-default:"1s"`
+	// MaxBackoff — наибольшая пауза между повторами, если приёмник недоступен. Пауза удваивается с PollInterval.
+	MaxBackoff time.Duration `env:"OUTBOX_MAX_BACKOFF" env-default:"5m"`
+	// BatchSize — сколько событий доставляется за раз.
+	BatchSize int `env:"OUTBOX_BATCH" env-default:"100"`
+	// MaxAttempts — после стольких неудачных попыток событие откладывается в dead letter
+	// и больше не доставляется, 0 — повторять без ограничения.
+	MaxAttempts int `env:"OUTBOX_MAX_ATTEMPTS" env-default:"10"`
+	// LockKey — ключ advisory-блокировки, под которой одна реплика доставляет события приёмнику.
+	LockKey int64 `env:"OUTBOX_LOCK_KEY" env-default:"73010002"`
+
+	// Retention — сколько хранятся доставленные события.
+	Retention time.Duration `env:"OUTBOX_RETENTION" env-default:"168h"`
+	// CleanupInterval — период удаления доставленных событий старше Retention.
+	CleanupInterval time.Duration `env:"OUTBOX_CLEANUP_INTERVAL" env-default:"1h"`
+}
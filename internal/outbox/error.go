@@ -0,0 +1,37 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	Err                  = errors.New("outbox")
+	ErrMissingWebhookURL = fmt.Errorf("%v: OUTBOX_WEBHOOK_URL is required for the http sink", Err)
+)
+
+// ErrUnknownSink — в OUTBOX_SINK указан неизвестный приёмник.
+type ErrUnknownSink struct {
+	sink string
+}
+
+func (e ErrUnknownSink) Error() string {
+	return fmt.Sprintf("%v: unknown sink %q", Err, e.sink)
+}
+
+func NewErrUnknownSink(sink string) error {
+	return ErrUnknownSink{sink: sink}
+}
+
+// ErrUnexpectedStatus — HTTP-приёмник ответил не 2xx, доставка будет повторена.
+type ErrUnexpectedStatus struct {
+	Status int
+}
+
+func (e ErrUnexpectedStatus) Error() string {
+	return fmt.Sprintf("%v: webhook responded with status %d", Err, e.Status)
+}
+
+func NewErrUnexpectedStatus(status int) error {
+	return ErrUnexpectedStatus{Status: status}
+}
@@ -0,0 +1,48 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FilePublisher дописывает события в файл по одному JSON в строке (JSONL).
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("%v: failed to open %s: %w", Err, path, err)
+	}
+	return &FilePublisher{file: f}, nil
+}
+
+// Publish записывает пачку одним вызовом write и сбрасывает её на диск.
+func (p *FilePublisher) Publish(_ context.Context, events []*Event) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("%v: failed to encode event %d: %w", Err, e.ID, err)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("%v: failed to write events: %w", Err, err)
+	}
+	if err := p.file.Sync(); err != nil {
+		return fmt.Errorf("%v: failed to sync events: %w", Err, err)
+	}
+	return nil
+}
+
+func (p *FilePublisher) Close() error {
+	return p.file.Close()
+}
@@ -0,0 +1,48 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Batch — тело запроса HTTP-приёмника.
+type Batch struct {
+	Events []*Event `json:"events"`
+}
+
+// HTTPPublisher отправляет пачку событий POST-запросом с Batch, доставленной считается пачка с ответом 2xx.
+type HTTPPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPPublisher(url string, client *http.Client) *HTTPPublisher {
+	return &HTTPPublisher{url: url, client: client}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, events []*Event) error {
+	body, err := json.Marshal(Batch{Events: events})
+	if err != nil {
+		return fmt.Errorf("%v: failed to encode events: %w", Err, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%v: failed to build request: %w", Err, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%v: failed to deliver events: %w", Err, err)
+	}
+	defer resp.Body.Close()
+	// тело дочитывается, чтобы соединение вернулось в пул.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return NewErrUnexpectedStatus(resp.StatusCode)
+	}
+	return nil
+}
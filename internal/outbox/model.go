@@ -0,0 +1,71 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EventID — идентификатор события, растёт в порядке записи. По нему получатель отбрасывает повторы.
+type EventID int64
+
+// EventType — тип доменного события.
+type EventType string
+
+const (
+	// TransferCompleted — монеты дошли до получателя: перевод проведён или принят.
+	TransferCompleted EventType = "TransferCompleted"
+	// PurchaseCompleted — покупка в магазине оплачена.
+	PurchaseCompleted EventType = "PurchaseCompleted"
+	// UserRegistered — зарегистрирован сотрудник.
+	UserRegistered EventType = "UserRegistered"
+)
+
+// Event — доменное событие, записанное в outbox в одной транзакции с изменением, которое оно описывает.
+type Event struct {
+	ID         EventID         `json:"id"`
+	Type       EventType       `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Payload    json.RawMessage `json:"payload"`
+	// Attempts — сколько раз доставка получателю уже не удалась.
+	Attempts int `json:"-"`
+}
+
+// NewEvent сериализует payload события.
+func NewEvent(t EventType, payload any) (*Event, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%v: failed to marshal %s payload: %w", Err, t, err)
+	}
+	return &Event{Type: t, Payload: raw}, nil
+}
+
+// Transfer — данные события TransferCompleted.
+type Transfer struct {
+	TransactionID int64    `json:"transactionId"`
+	FromUserID    int64    `json:"fromUserId"`
+	FromUser      string   `json:"fromUser"`
+	ToUserID      int64    `json:"toUserId"`
+	ToUser        string   `json:"toUser"`
+	Amount        int      `json:"amount"`
+	BatchID       int64    `json:"batchId,omitempty"`
+	Message       string   `json:"message,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// Purchase — данные события PurchaseCompleted.
+type Purchase struct {
+	PurchaseID    int64  `json:"purchaseId"`
+	TransactionID int64  `json:"transactionId"`
+	UserID        int64  `json:"userId"`
+	Username      string `json:"username"`
+	Item          string `json:"item"`
+	Quantity      int    `json:"quantity"`
+	Amount        int    `json:"amount"`
+}
+
+// User — данные события UserRegistered.
+type User struct {
+	UserID   int64  `json:"userId"`
+	Username string `json:"username"`
+}
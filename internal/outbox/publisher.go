@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	SinkFile = "file"
+	SinkHTTP = "http"
+)
+
+// SinkConsumer — имя, под которым приёмник OUTBOX_SINK ведёт свою очередь событий.
+const SinkConsumer = "sink"
+
+// Publisher доставляет пачку событий во внешнюю систему. Ошибка означает, что пачку доставят
+// заново целиком, поэтому получатель должен отбрасывать повторы по Event.ID.
+type Publisher interface {
+	Publish(ctx context.Context, events []*Event) error
+}
+
+// NewPublisher создаёт приёмник из конфигурации, nil — приёмник не задан.
+func NewPublisher(cfg *Config) (Publisher, error) {
+	switch cfg.Sink {
+	case "":
+		return nil, nil
+	case SinkFile:
+		p, err := NewFilePublisher(cfg.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case SinkHTTP:
+		if cfg.WebhookURL == "" {
+			return nil, ErrMissingWebhookURL
+		}
+		return NewHTTPPublisher(cfg.WebhookURL, &http.Client{Timeout: cfg.HTTPTimeout}), nil
+	default:
+		return nil, NewErrUnknownSink(cfg.Sink)
+	}
+}
@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvents() []*Event {
+	at := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	return []*Event{
+		{ID: 1, Type: UserRegistered, OccurredAt: at, Payload: json.RawMessage(`{"userId":1,"username":"alice"}`)},
+		{ID: 2, Type: TransferCompleted, OccurredAt: at, Payload: json.RawMessage(`{"transactionId":7,"amount":10}`)},
+	}
+}
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	p, err := NewFilePublisher(path)
+	require.NoError(t, err)
+	events := testEvents()
+	require.NoError(t, p.Publish(context.Background(), events[:1]))
+	require.NoError(t, p.Publish(context.Background(), events[1:]))
+	require.NoError(t, p.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var got []*Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		got = append(got, &e)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, got, 2)
+	for i := range events {
+		assert.Equal(t, events[i].ID, got[i].ID)
+		assert.Equal(t, events[i].Type, got[i].Type)
+		assert.JSONEq(t, string(events[i].Payload), string(got[i].Payload))
+	}
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	var received Batch
+	status := http.StatusAccepted
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer stub.Close()
+
+	p := NewHTTPPublisher(stub.URL, stub.Client())
+	require.NoError(t, p.Publish(context.Background(), testEvents()))
+	require.Len(t, received.Events, 2)
+	assert.Equal(t, TransferCompleted, received.Events[1].Type)
+	assert.JSONEq(t, `{"transactionId":7,"amount":10}`, string(received.Events[1].Payload))
+
+	status = http.StatusServiceUnavailable
+	err := p.Publish(context.Background(), testEvents())
+	assert.ErrorIs(t, err, NewErrUnexpectedStatus(http.StatusServiceUnavailable))
+}
+
+func TestHTTPPublisher_Unreachable(t *testing.T) {
+	stub := httptest.NewServer(http.NotFoundHandler())
+	url := stub.URL
+	stub.Close()
+
+	err := NewHTTPPublisher(url, &http.Client{Timeout: time.Second}).Publish(context.Background(), testEvents())
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestNewPublisher(t *testing.T) {
+	p, err := NewPublisher(&Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	p, err = NewPublisher(&Config{Sink: SinkHTTP, WebhookURL: "http://localhost:9000/events"})
+	assert.NoError(t, err)
+	assert.IsType(t, &HTTPPublisher{}, p)
+
+	_, err = NewPublisher(&Config{Sink: SinkHTTP})
+	assert.ErrorIs(t, err, ErrMissingWebhookURL)
+	_, err = NewPublisher(&Config{Sink: "kafka"})
+	assert.ErrorIs(t, err, NewErrUnknownSink("kafka"))
+}
@@ -0,0 +1,211 @@
+package outbox
+
+import (
+	"avito-intern/internal/common"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Consumer — получатель событий outbox. У каждого получателя своя очередь и своя блокировка,
+// поэтому недоступный приёмник не задерживает доставку остальным.
+type Consumer struct {
+	Name string
+	// LockKey — ключ advisory-блокировки, под которой одна реплика доставляет события получателю.
+	LockKey   int64
+	Publisher Publisher
+}
+
+// Relay доставляет события из outbox каждому получателю хотя бы один раз в порядке Event.ID.
+// Событие транзакции, зафиксированной позже, может уйти после событий с большим ID.
+type Relay interface {
+	// Run регистрирует получателей и доставляет им события, пока не отменён ctx. Пока приёмник
+	// недоступен, повторы идут с удваивающейся паузой до MaxBackoff.
+	Run(ctx context.Context)
+	// RunCleanup периодически удаляет доставленные события старше Retention, пока не отменён ctx.
+	RunCleanup(ctx context.Context)
+}
+
+type relay struct {
+	cfg       *Config
+	repo      Repository
+	uow       common.UnitOfWork
+	consumers []Consumer
+	now       func() time.Time
+}
+
+func NewRelay(cfg *Config, repo Repository, uow common.UnitOfWork, consumers ...Consumer) Relay {
+	return &relay{
+		cfg:       cfg,
+		repo:      repo,
+		uow:       uow,
+		consumers: consumers,
+		now:       time.Now,
+	}
+}
+
+func (r *relay) Run(ctx context.Context) {
+	names := make([]string, len(r.consumers))
+	for i, c := range r.consumers {
+		names[i] = c.Name
+	}
+	if err := r.register(ctx, names); err != nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range r.consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+}
+
+// register повторяет регистрацию получателей с удваивающейся паузой, пока не пройдут миграции.
+// Ошибка — только отмена ctx.
+func (r *relay) register(ctx context.Context, names []string) error {
+	delay := r.cfg.PollInterval
+	for {
+		err := r.repo.RegisterConsumers(ctx, names)
+		if err == nil {
+			r.warnUnconfigured(ctx, names)
+			return nil
+		}
+		slog.Error("failed to register outbox consumers", "error", err, "retry_in", delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, r.cfg.MaxBackoff)
+	}
+}
+
+// warnUnconfigured предупреждает о получателях, которых нет в конфигурации этой реплики. Их очередь
+// не удаляется автоматически: реплика без приёмника или старой версии во время выкладки иначе
+// потеряла бы недоставленные события. Получателя, который больше не нужен, удаляют вручную.
+func (r *relay) warnUnconfigured(ctx context.Context, names []string) {
+	registered, err := r.repo.ListConsumers(ctx)
+	if err != nil {
+		slog.Error("failed to list outbox consumers", "error", err)
+		return
+	}
+	for _, name := range registered {
+		if !slices.Contains(names, name) {
+			slog.Warn("outbox consumer is registered but not configured, its events are kept until it is removed",
+				"consumer", name)
+		}
+	}
+}
+
+func (r *relay) run(ctx context.Context, c Consumer) {
+	delay := r.cfg.PollInterval
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		delivered, err := r.Deliver(ctx, c)
+		switch {
+		case err != nil:
+			delay = min(max(delay, r.cfg.PollInterval)*2, r.cfg.MaxBackoff)
+			slog.Error("failed to deliver outbox events", "consumer", c.Name, "error", err, "retry_in", delay)
+		case delivered == r.cfg.BatchSize:
+			// в очереди остались события, следующая пачка — сразу.
+			delay = 0
+		default:
+			delay = r.cfg.PollInterval
+		}
+		timer.Reset(delay)
+	}
+}
+
+// Deliver доставляет получателю пачку из BatchSize самых старых событий его очереди и возвращает,
+// сколько доставлено. Пачка читается под блокировкой получателя, поэтому ему доставляет одна
+// реплика. Если приёмник вернул ошибку, у событий пачки записывается попытка. После неудачи
+// события доставляются по одному, пока первое не пройдёт, так что попытки копятся только
+// у события, которое приёмник не принимает; после MaxAttempts оно откладывается в dead letter
+// получателя и не держит очередь.
+func (r *relay) Deliver(ctx context.Context, c Consumer) (int, error) {
+	var (
+		delivered  int
+		publishErr error
+	)
+	// доставка — побочный эффект вне БД: если транзакцию повторят после deadlock или
+	// serialization failure, пачка уйдёт ещё раз, что допускает доставка хотя бы один раз.
+	err := r.uow.RunInTransaction(ctx, func(ctx context.Context) error {
+		locked, err := r.repo.TryLockRelay(ctx, c.LockKey)
+		if err != nil || !locked {
+			return err
+		}
+		events, err := r.repo.ListUndelivered(ctx, c.Name, r.cfg.BatchSize)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		if events[0].Attempts > 0 {
+			events = events[:1]
+		}
+		ids := make([]EventID, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+		if publishErr = c.Publisher.Publish(ctx, events); publishErr != nil {
+			if err := r.repo.MarkFailed(ctx, c.Name, ids, publishErr.Error()); err != nil {
+				return err
+			}
+			return r.deadLetter(ctx, c, events, publishErr)
+		}
+		delivered = len(events)
+		return r.repo.MarkDelivered(ctx, c.Name, ids, r.now())
+	})
+	if err != nil {
+		return 0, err
+	}
+	return delivered, publishErr
+}
+
+// deadLetter откладывает события, исчерпавшие MaxAttempts попыток.
+func (r *relay) deadLetter(ctx context.Context, c Consumer, events []*Event, publishErr error) error {
+	if r.cfg.MaxAttempts <= 0 {
+		return nil
+	}
+	var dead []EventID
+	for _, e := range events {
+		if e.Attempts+1 >= r.cfg.MaxAttempts {
+			dead = append(dead, e.ID)
+			slog.Error("outbox event moved to dead letter", "consumer", c.Name,
+				"event_id", e.ID, "type", e.Type, "attempts", e.Attempts+1, "error", publishErr)
+		}
+	}
+	if len(dead) == 0 {
+		return nil
+	}
+	return r.repo.MarkDead(ctx, c.Name, dead, r.now())
+}
+
+func (r *relay) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := r.repo.DeleteDelivered(ctx, r.now().Add(-r.cfg.Retention))
+			if err != nil {
+				slog.Error("failed to delete delivered outbox events", "error", err)
+				continue
+			}
+			slog.Debug("deleted delivered outbox events", "count", deleted)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQueue — состояние доставки событий одному получателю.
+type fakeQueue struct {
+	attempts  map[EventID]int
+	delivered map[EventID]time.Time
+	failed    map[EventID]string
+	dead      map[EventID]time.Time
+}
+
+type fakeRepo struct {
+	events    []*Event
+	locked    bool
+	consumers []string
+	queues    map[string]*fakeQueue
+}
+
+func newFakeRepo(n int) *fakeRepo {
+	repo := &fakeRepo{locked: true, queues: make(map[string]*fakeQueue)}
+	for i := 1; i <= n; i++ {
+		repo.events = append(repo.events, &Event{ID: EventID(i), Type: TransferCompleted, Payload: []byte(`{}`)})
+	}
+	return repo
+}
+
+func (r *fakeRepo) queue(consumer string) *fakeQueue {
+	q, ok := r.queues[consumer]
+	if !ok {
+		q = &fakeQueue{
+			attempts:  make(map[EventID]int),
+			delivered: make(map[EventID]time.Time),
+			failed:    make(map[EventID]string),
+			dead:      make(map[EventID]time.Time),
+		}
+		r.queues[consumer] = q
+	}
+	return q
+}
+
+func (r *fakeRepo) RegisterConsumers(_ context.Context, names []string) error {
+	for _, name := range names {
+		if !slices.Contains(r.consumers, name) {
+			r.consumers = append(r.consumers, name)
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) ListConsumers(_ context.Context) ([]string, error) {
+	return r.consumers, nil
+}
+
+func (r *fakeRepo) TryLockRelay(_ context.Context, _ int64) (bool, error) {
+	return r.locked, nil
+}
+
+func (r *fakeRepo) ListUndelivered(_ context.Context, consumer string, limit int) ([]*Event, error) {
+	q := r.queue(consumer)
+	var res []*Event
+	for _, e := range r.events {
+		_, delivered := q.delivered[e.ID]
+		_, dead := q.dead[e.ID]
+		if !delivered && !dead && len(res) < limit {
+			// копия, как при чтении из БД: MarkFailed не меняет уже прочитанные события.
+			copied := *e
+			copied.Attempts = q.attempts[e.ID]
+			res = append(res, &copied)
+		}
+	}
+	return res, nil
+}
+
+func (r *fakeRepo) MarkDelivered(_ context.Context, consumer string, ids []EventID, at time.Time) error {
+	for _, id := range ids {
+		r.queue(consumer).delivered[id] = at
+	}
+	return nil
+}
+
+func (r *fakeRepo) MarkFailed(_ context.Context, consumer string, ids []EventID, reason string) error {
+	q := r.queue(consumer)
+	for _, id := range ids {
+		q.failed[id] = reason
+		q.attempts[id]++
+	}
+	return nil
+}
+
+func (r *fakeRepo) MarkDead(_ context.Context, consumer string, ids []EventID, at time.Time) error {
+	for _, id := range ids {
+		r.queue(consumer).dead[id] = at
+	}
+	return nil
+}
+
+func (r *fakeRepo) DeleteDelivered(_ context.Context, _ time.Time) (int64, error) {
+	return 0, nil
+}
+
+type fakeUnitOfWork struct{}
+
+func (fakeUnitOfWork) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type fakePublisher struct {
+	batches [][]EventID
+	err     error
+}
+
+func (p *fakePublisher) Publish(_ context.Context, events []*Event) error {
+	ids := make([]EventID, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	p.batches = append(p.batches, ids)
+	return p.err
+}
+
+func TestDeliver(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	repo := newFakeRepo(3)
+	publisher := &fakePublisher{}
+	r := NewRelay(&Config{BatchSize: 2}, repo, fakeUnitOfWork{}, Consumer{Name: "test", Publisher: publisher}).(*relay)
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	delivered, err := r.Deliver(ctx, r.consumers[0])
+	require.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+	delivered, err = r.Deliver(ctx, r.consumers[0])
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	delivered, err = r.Deliver(ctx, r.consumers[0])
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+
+	assert.Equal(t, [][]EventID{{1, 2}, {3}}, publisher.batches)
+	assert.Equal(t, map[EventID]time.Time{1: now, 2: now, 3: now}, repo.queue("test").delivered)
+}
+
+func TestDeliver_PublishFailed(t *testing.T) {
+	repo := newFakeRepo(2)
+	publisher := &fakePublisher{err: errors.New("connection refused")}
+	r := NewRelay(&Config{BatchSize: 10}, repo, fakeUnitOfWork{}, Consumer{Name: "test", Publisher: publisher}).(*relay)
+	ctx := context.Background()
+
+	delivered, err := r.Deliver(ctx, r.consumers[0])
+	assert.ErrorIs(t, err, publisher.err)
+	assert.Zero(t, delivered)
+	assert.Empty(t, repo.queue("test").delivered)
+	assert.Equal(t, map[EventID]string{1: "connection refused", 2: "connection refused"}, repo.queue("test").failed)
+
+	// после неудачи события повторяются по одному, пока не дойдут до новых.
+	publisher.err = nil
+	for _, want := range []int{1, 1, 0} {
+		delivered, err = r.Deliver(ctx, r.consumers[0])
+		require.NoError(t, err)
+		assert.Equal(t, want, delivered)
+	}
+	assert.Equal(t, [][]EventID{{1, 2}, {1}, {2}}, publisher.batches)
+}
+
+// rejectingPublisher не принимает события с заданным ID.
+type rejectingPublisher struct {
+	fakePublisher
+	reject EventID
+}
+
+func (p *rejectingPublisher) Publish(ctx context.Context, events []*Event) error {
+	_ = p.fakePublisher.Publish(ctx, events)
+	for _, e := range events {
+		if e.ID == p.reject {
+			return errors.New("bad request")
+		}
+	}
+	return nil
+}
+
+func TestDeliver_DeadLetter(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	repo := newFakeRepo(3)
+	publisher := &rejectingPublisher{reject: 1}
+	r := NewRelay(&Config{BatchSize: 10, MaxAttempts: 3}, repo, fakeUnitOfWork{}, Consumer{Name: "test", Publisher: publisher}).(*relay)
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := r.Deliver(ctx, r.consumers[0])
+		assert.Error(t, err)
+	}
+	// попытки копятся только у события, которое приёмник не принимает.
+	assert.Equal(t, 3, repo.queue("test").attempts[1])
+	assert.Equal(t, 1, repo.queue("test").attempts[2])
+	assert.Equal(t, map[EventID]time.Time{1: now}, repo.queue("test").dead)
+
+	// отложенное событие больше не держит очередь.
+	delivered, err := r.Deliver(ctx, r.consumers[0])
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	delivered, err = r.Deliver(ctx, r.consumers[0])
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, [][]EventID{{1, 2, 3}, {1}, {1}, {2}, {3}}, publisher.batches)
+	assert.NotContains(t, repo.queue("test").delivered, EventID(1))
+}
+
+func TestDeliver_NotLocked(t *testing.T) {
+	repo := newFakeRepo(2)
+	repo.locked = false
+	publisher := &fakePublisher{}
+	r := NewRelay(&Config{BatchSize: 10}, repo, fakeUnitOfWork{}, Consumer{Name: "test", Publisher: publisher}).(*relay)
+
+	delivered, err := r.Deliver(context.Background(), r.consumers[0])
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Empty(t, publisher.batches)
+}
+
+func TestDeliver_ConsumersIndependent(t *testing.T) {
+	repo := newFakeRepo(2)
+	webhooks := &fakePublisher{}
+	sink := &fakePublisher{err: errors.New("connection refused")}
+	r := NewRelay(&Config{BatchSize: 10}, repo, fakeUnitOfWork{},
+		Consumer{Name: "webhooks", LockKey: 1, Publisher: webhooks},
+		Consumer{Name: SinkConsumer, LockKey: 2, Publisher: sink},
+	).(*relay)
+	ctx := context.Background()
+
+	_, err := r.Deliver(ctx, r.consumers[1])
+	assert.ErrorIs(t, err, sink.err)
+	// недоступный приёмник не задерживает доставку подпискам на вебхуки.
+	delivered, err := r.Deliver(ctx, r.consumers[0])
+	require.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+	assert.Len(t, repo.queue("webhooks").delivered, 2)
+	assert.Empty(t, repo.queue("webhooks").failed)
+
+	sink.err = nil
+	for _, want := range []int{1, 1} {
+		delivered, err = r.Deliver(ctx, r.consumers[1])
+		require.NoError(t, err)
+		assert.Equal(t, want, delivered)
+	}
+	assert.Equal(t, [][]EventID{{1, 2}}, webhooks.batches)
+	assert.Equal(t, [][]EventID{{1, 2}, {1}, {2}}, sink.batches)
+}
+
+func TestRun_RegistersConsumers(t *testing.T) {
+	repo := newFakeRepo(0)
+	repo.consumers = []string{"webhooks"}
+	r := NewRelay(&Config{BatchSize: 10, PollInterval: time.Hour}, repo, fakeUnitOfWork{},
+		Consumer{Name: "webhooks", Publisher: &fakePublisher{}},
+		Consumer{Name: SinkConsumer, Publisher: &fakePublisher{}},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Run(ctx)
+	assert.Equal(t, []string{"webhooks", SinkConsumer}, repo.consumers)
+}
@@ -0,0 +1,27 @@
+package outbox
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// RegisterConsumers регистрирует получателей, новые события ставятся в очередь каждому из них.
+	// Зарегистрированные раньше получатели не удаляются.
+	RegisterConsumers(ctx context.Context, names []string) error
+	// ListConsumers возвращает имена всех зарегистрированных получателей.
+	ListConsumers(ctx context.Context) ([]string, error)
+	// TryLockRelay берёт блокировку доставки до конца транзакции, вызывается внутри RunInTransaction.
+	// false — события доставляет другая реплика.
+	TryLockRelay(ctx context.Context, key int64) (bool, error)
+	// ListUndelivered возвращает до limit недоставленных получателю и не отложенных событий в порядке записи.
+	ListUndelivered(ctx context.Context, consumer string, limit int) ([]*Event, error)
+	// MarkDelivered отмечает события доставленными получателю.
+	MarkDelivered(ctx context.Context, consumer string, ids []EventID, at time.Time) error
+	// MarkFailed увеличивает число неудачных попыток доставки получателю и запоминает ошибку.
+	MarkFailed(ctx context.Context, consumer string, ids []EventID, reason string) error
+	// MarkDead откладывает события в dead letter получателя: они остаются в таблице, но ему больше не доставляются.
+	MarkDead(ctx context.Context, consumer string, ids []EventID, at time.Time) error
+	// DeleteDelivered удаляет события, доставленные всем получателям до before, и возвращает их число.
+	DeleteDelivered(ctx context.Context, before time.Time) (int64, error)
+}
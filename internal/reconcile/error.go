@@ -0,0 +1,16 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	Err                 = errors.New("reconcile")
+	ErrNotAdmin         = fmt.Errorf("%v: corrections must be approved by hr-admin", Err)
+	ErrInvalidReason    = fmt.Errorf("%v: reason is required", Err)
+	ErrStaleBalance     = fmt.Errorf("%v: balance changed since the report, run the check again", Err)
+	ErrNegativeBalance  = fmt.Errorf("%v: balance by history is negative, it can't be corrected automatically", Err)
+	ErrUnknownFormat    = fmt.Errorf("%v: unknown report format", Err)
+	ErrNothingToCorrect = fmt.Errorf("%v: report has no discrepancies", Err)
+)
@@ -0,0 +1,72 @@
+package reconcile
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"time"
+)
+
+// Balance — баланс пользователя, посчитанный тремя независимыми способами.
+type Balance struct {
+	UserID   auth.UserID `json:"userId"`
+	Username string      `json:"username"`
+	// Ledger — по журналу проводок, этот баланс видит пользователь.
+	Ledger int `json:"ledger"`
+	// History — по таблице транзакций: поступления минус списания с учётом статусов переводов.
+	// Корректировки в неё не входят, они приводят журнал к истории.
+	History int `json:"history"`
+	// Lots — по остаткам партий монет.
+	Lots int `json:"lots"`
+}
+
+// Consistent сообщает, что все три баланса совпадают.
+func (b *Balance) Consistent() bool {
+	return b.Ledger == b.History && b.Lots == b.Ledger
+}
+
+// Diff — сколько нужно начислить (отрицательное — списать), чтобы журнал совпал с историей.
+func (b *Balance) Diff() int {
+	return b.History - b.Ledger
+}
+
+// Conservation — глобальный баланс монет по истории транзакций и журналу.
+type Conservation struct {
+	// Issued — начислено монет за вычетом сгоревших, с учётом корректировок.
+	Issued int `json:"issued"`
+	// Spent — потрачено в магазине.
+	Spent int `json:"spent"`
+	// Escrow — ждёт получателей переводов с подтверждением и закрытия сборов.
+	Escrow int `json:"escrow"`
+	// Balances — сумма балансов пользователей по журналу.
+	Balances int `json:"balances"`
+}
+
+// Holds сообщает, что монеты не появились и не пропали: выпущенное минус потраченное
+// и удерживаемое равно сумме балансов.
+func (c *Conservation) Holds() bool {
+	return c.Issued-c.Spent-c.Escrow == c.Balances
+}
+
+// Report — результат сверки.
+type Report struct {
+	CheckedAt     time.Time    `json:"checkedAt"`
+	Users         int          `json:"users"`
+	Conservation  Conservation `json:"conservation"`
+	Discrepancies []*Balance   `json:"discrepancies"`
+}
+
+// OK сообщает, что расхождений не найдено.
+func (r *Report) OK() bool {
+	return len(r.Discrepancies) == 0 && r.Conservation.Holds()
+}
+
+// Correction — одобренная администратором корректировка баланса пользователя.
+type Correction struct {
+	// Balance — расхождение из одобренного отчёта. Корректировка проводится,
+	// только если текущие балансы с ним совпадают.
+	Balance Balance
+	Admin   *auth.User
+	Reason  string
+	// Transaction — проведённая корректировка журнала, nil, если исправлялись только партии.
+	Transaction *coin.Transaction
+}
@@ -0,0 +1,52 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// Format — формат отчёта сверки.
+type Format string
+
+const (
+	JSON Format = "json"
+	CSV  Format = "csv"
+)
+
+// WriteReport записывает отчёт. CSV содержит только расхождения, по строке на пользователя.
+func WriteReport(w io.Writer, report *Report, format Format) error {
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case CSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"user_id", "username", "ledger", "history", "lots", "diff"})
+		for _, b := range report.Discrepancies {
+			_ = cw.Write([]string{
+				strconv.FormatInt(int64(b.UserID), 10),
+				b.Username,
+				strconv.Itoa(b.Ledger),
+				strconv.Itoa(b.History),
+				strconv.Itoa(b.Lots),
+				strconv.Itoa(b.Diff()),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// ReadReport читает отчёт в формате JSON, например одобренный администратором.
+func ReadReport(r io.Reader) (*Report, error) {
+	var report Report
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,33 @@
+package reconcile
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteReport(t *testing.T) {
+	report := &Report{
+		CheckedAt:    time.Date(2025, 3, 16, 9, 0, 0, 0, time.UTC),
+		Users:        2,
+		Conservation: Conservation{Issued: 200, Balances: 170},
+		Discrepancies: []*Balance{
+			{UserID: 2, Username: "bob", Ledger: 70, History: 100, Lots: 70},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteReport(&buf, report, CSV))
+	assert.Equal(t, "user_id,username,ledger,history,lots,diff\n2,bob,70,100,70,30\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteReport(&buf, report, JSON))
+	read, err := ReadReport(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, report, read)
+
+	assert.ErrorIs(t, WriteReport(&buf, report, "xml"), ErrUnknownFormat)
+}
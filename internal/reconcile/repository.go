@@ -0,0 +1,16 @@
+package reconcile
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+)
+
+type Repository interface {
+	// ListBalances считает балансы всех пользователей по журналу, истории транзакций и партиям монет.
+	ListBalances(ctx context.Context) ([]*Balance, error)
+	GetConservation(ctx context.Context) (*Conservation, error)
+	// SaveCorrection под блокировкой пользователя пересчитывает его балансы и, если они совпадают
+	// с c.Balance, проводит корректировку журнала и партий. Иначе ErrStaleBalance.
+	SaveCorrection(ctx context.Context, c *Correction) (*Correction, error)
+	GetUserByUsername(ctx context.Context, username string) (*auth.User, error)
+}
@@ -0,0 +1,43 @@
+package reconcile
+
+import (
+	"avito-intern/internal/scheduler"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Job — задача планировщика, выполняющая сверку. Корректировки она не проводит.
+const Job scheduler.Kind = "reconcile"
+
+type runner struct {
+	svc Service
+}
+
+// NewRunner создаёт исполнитель задач Job.
+func NewRunner(svc Service) scheduler.Runner {
+	return &runner{svc: svc}
+}
+
+func (r *runner) Validate(raw json.RawMessage) error {
+	var params struct{}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return scheduler.NewErrInvalidParams(err.Error())
+	}
+	return nil
+}
+
+func (r *runner) Run(ctx context.Context, _ *scheduler.Job, _ time.Time) (string, error) {
+	report, err := r.svc.Check(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !report.OK() {
+		slog.Warn("balance reconciliation found discrepancies",
+			"discrepancies", len(report.Discrepancies), "conservation", report.Conservation)
+	}
+	return fmt.Sprintf("checked %d users, %d discrepancies, conservation holds: %t",
+		report.Users, len(report.Discrepancies), report.Conservation.Holds()), nil
+}
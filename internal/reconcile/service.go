@@ -0,0 +1,91 @@
+package reconcile
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Service interface {
+	// Check пересчитывает балансы всех пользователей и проверяет сохранение монет.
+	Check(ctx context.Context) (*Report, error)
+	// Correct проводит корректировки по расхождениям из одобренного отчёта. Каждое расхождение
+	// исправляется отдельно: возвращаются проведённые корректировки и ошибки остальных.
+	Correct(ctx context.Context, admin, reason string, approved []*Balance) ([]*Correction, error)
+}
+
+type service struct {
+	repo Repository
+	now  func() time.Time
+}
+
+func NewService(repo Repository) Service {
+	return &service{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+func (s *service) Check(ctx context.Context) (*Report, error) {
+	balances, err := s.repo.ListBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conservation, err := s.repo.GetConservation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		CheckedAt:     s.now(),
+		Users:         len(balances),
+		Conservation:  *conservation,
+		Discrepancies: make([]*Balance, 0),
+	}
+	for _, b := range balances {
+		if !b.Consistent() {
+			report.Discrepancies = append(report.Discrepancies, b)
+		}
+	}
+	return report, nil
+}
+
+func (s *service) Correct(ctx context.Context, admin, reason string, approved []*Balance) ([]*Correction, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrInvalidReason
+	}
+	if len(approved) == 0 {
+		return nil, ErrNothingToCorrect
+	}
+	approver, err := s.repo.GetUserByUsername(ctx, admin)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, ErrNotAdmin
+		}
+		return nil, err
+	}
+	if !approver.HasRole(auth.RoleHRAdmin) {
+		return nil, ErrNotAdmin
+	}
+
+	var (
+		corrections []*Correction
+		errs        []error
+	)
+	for _, b := range approved {
+		if b.History < 0 {
+			errs = append(errs, fmt.Errorf("user %s: %w", b.Username, ErrNegativeBalance))
+			continue
+		}
+		c, err := s.repo.SaveCorrection(ctx, &Correction{Balance: *b, Admin: approver, Reason: reason})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %s: %w", b.Username, err))
+			continue
+		}
+		corrections = append(corrections, c)
+	}
+	return corrections, errors.Join(errs...)
+}
@@ -0,0 +1,114 @@
+package reconcile
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepo хранит балансы в памяти, корректировка приводит журнал и партии к истории.
+type fakeRepo struct {
+	balances     map[auth.UserID]*Balance
+	conservation Conservation
+	users        map[string]*auth.User
+}
+
+func (r *fakeRepo) ListBalances(_ context.Context) ([]*Balance, error) {
+	var res []*Balance
+	for id := auth.UserID(1); int(id) <= len(r.balances); id++ {
+		b := *r.balances[id]
+		res = append(res, &b)
+	}
+	return res, nil
+}
+
+func (r *fakeRepo) GetConservation(_ context.Context) (*Conservation, error) {
+	c := r.conservation
+	return &c, nil
+}
+
+func (r *fakeRepo) SaveCorrection(_ context.Context, c *Correction) (*Correction, error) {
+	current := r.balances[c.Balance.UserID]
+	if *current != c.Balance {
+		return nil, ErrStaleBalance
+	}
+	current.Ledger, current.Lots = current.History, current.History
+	return c, nil
+}
+
+func (r *fakeRepo) GetUserByUsername(_ context.Context, username string) (*auth.User, error) {
+	u, ok := r.users[username]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	return u, nil
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		balances: map[auth.UserID]*Balance{
+			1: {UserID: 1, Username: "ok", Ledger: 100, History: 100, Lots: 100},
+			2: {UserID: 2, Username: "lost-transfer", Ledger: 70, History: 100, Lots: 70},
+			3: {UserID: 3, Username: "lots", Ledger: 50, History: 50, Lots: 40},
+		},
+		conservation: Conservation{Issued: 300, Spent: 50, Escrow: 30, Balances: 220},
+		users: map[string]*auth.User{
+			"hr":    {ID: 10, Username: "hr", Roles: []auth.Role{auth.RoleEmployee, auth.RoleHRAdmin}},
+			"staff": {ID: 11, Username: "staff", Roles: []auth.Role{auth.RoleEmployee}},
+		},
+	}
+}
+
+func TestConservation(t *testing.T) {
+	assert.True(t, (&Conservation{Issued: 300, Spent: 50, Escrow: 30, Balances: 220}).Holds())
+	assert.False(t, (&Conservation{Issued: 300, Spent: 50, Escrow: 0, Balances: 220}).Holds())
+}
+
+func TestCheck(t *testing.T) {
+	repo := newFakeRepo()
+	svc := NewService(repo)
+	now := time.Date(2025, 3, 16, 9, 0, 0, 0, time.UTC)
+	svc.(*service).now = func() time.Time { return now }
+
+	report, err := svc.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, now, report.CheckedAt)
+	assert.Equal(t, 3, report.Users)
+	require.Len(t, report.Discrepancies, 2)
+	assert.Equal(t, "lost-transfer", report.Discrepancies[0].Username)
+	assert.Equal(t, 30, report.Discrepancies[0].Diff())
+	assert.Equal(t, "lots", report.Discrepancies[1].Username)
+	assert.Zero(t, report.Discrepancies[1].Diff())
+	assert.True(t, report.Conservation.Holds())
+	assert.False(t, report.OK())
+}
+
+func TestCorrect(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepo()
+	svc := NewService(repo)
+	report, err := svc.Check(ctx)
+	require.NoError(t, err)
+
+	_, err = svc.Correct(ctx, "staff", "сверка", report.Discrepancies)
+	assert.ErrorIs(t, err, ErrNotAdmin)
+	_, err = svc.Correct(ctx, "nobody", "сверка", report.Discrepancies)
+	assert.ErrorIs(t, err, ErrNotAdmin)
+	_, err = svc.Correct(ctx, "hr", " ", report.Discrepancies)
+	assert.ErrorIs(t, err, ErrInvalidReason)
+	_, err = svc.Correct(ctx, "hr", "сверка", nil)
+	assert.ErrorIs(t, err, ErrNothingToCorrect)
+
+	// баланс изменился после отчёта: эта корректировка не проводится, остальные проводятся.
+	repo.balances[3].Ledger, repo.balances[3].History = 45, 45
+	corrections, err := svc.Correct(ctx, "hr", "сверка", report.Discrepancies)
+	assert.ErrorIs(t, err, ErrStaleBalance)
+	require.Len(t, corrections, 1)
+	assert.Equal(t, auth.UserID(2), corrections[0].Balance.UserID)
+	assert.Equal(t, "hr", corrections[0].Admin.Username)
+	assert.True(t, repo.balances[2].Consistent())
+}
@@ -0,0 +1,15 @@
+package scheduler
+
+import "time"
+
+type Config struct {
+	// TickInterval — как часто лидер проверяет задачи, которым пора выполняться.
+	TickInterval time.Duration `env:"SCHEDULER_TICK" env-default:"30s"`
+	// LockKey — ключ advisory-блокировки, которую держит лидер. Реплики одного сервиса должны использовать один ключ.
+	LockKey int64 `env:"SCHEDULER_LOCK_KEY" env-default:"73010001"`
+	// RetryInterval — через сколько повторяется неудачный запуск.
+	RetryInterval time.Duration `env:"SCHEDULER_RETRY_INTERVAL" env-default:"5m"`
+	// RunTimeout ограничивает запуск. Запуск, который дольше висит в running, считается
+	// брошенным упавшим лидером и может быть перезапущен.
+	RunTimeout time.Duration `env:"SCHEDULER_RUN_TIMEOUT" env-default:"30m"`
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	Err            = errors.New("scheduler")
+	ErrJobNotFound = fmt.Errorf("%v: job not found", Err)
+	ErrJobExists   = fmt.Errorf("%v: job with this name already exists", Err)
+	ErrInvalidName = fmt.Errorf("%v: job name is required", Err)
+)
+
+// ErrUnknownKind — для типа задачи не зарегистрирован Runner.
+type ErrUnknownKind struct {
+	kind Kind
+}
+
+func (e ErrUnknownKind) Error() string {
+	return fmt.Sprintf("%v: unknown job kind %q", Err, e.kind)
+}
+
+func NewErrUnknownKind(kind Kind) error {
+	return ErrUnknownKind{kind: kind}
+}
+
+// ErrInvalidSchedule — расписание не разбирается или никогда не срабатывает.
+type ErrInvalidSchedule struct {
+	schedule string
+}
+
+func (e ErrInvalidSchedule) Error() string {
+	return fmt.Sprintf("%v: invalid schedule %q", Err, e.schedule)
+}
+
+func NewErrInvalidSchedule(schedule string) error {
+	return ErrInvalidSchedule{schedule: schedule}
+}
+
+// ErrInvalidParams — параметры не подходят для задачи этого типа.
+type ErrInvalidParams struct {
+	reason string
+}
+
+func (e ErrInvalidParams) Error() string {
+	return fmt.Sprintf("%v: invalid job params: %s", Err, e.reason)
+}
+
+func NewErrInvalidParams(reason string) error {
+	return ErrInvalidParams{reason: reason}
+}
@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Service interface {
+	CreateJob(ctx context.Context, job *Job) (*Job, error)
+	UpdateJob(ctx context.Context, id JobID, upd JobUpdate) (*Job, error)
+	ListJobs(ctx context.Context) ([]*Job, error)
+	ListRuns(ctx context.Context, id JobID, limit int) ([]*Run, error)
+	Run(ctx context.Context)
+}
+
+type AuthHandler interface {
+	Verify(c *fiber.Ctx) error
+	RequireRole(roles ...auth.Role) fiber.Handler
+}
+
+type Handler struct {
+	svc          Service
+	authHandlers AuthHandler
+}
+
+func NewSchedulerHandler(svc Service, authHandler AuthHandler) *Handler {
+	return &Handler{
+		svc:          svc,
+		authHandlers: authHandler,
+	}
+}
+
+func (h *Handler) Init(router fiber.Router) {
+	read := h.authHandlers.RequireRole(auth.RoleHRAdmin, auth.RoleAuditor)
+	write := h.authHandlers.RequireRole(auth.RoleHRAdmin)
+	router.Get("/admin/jobs", h.authHandlers.Verify, read, h.listJobs)
+	router.Post("/admin/jobs", h.authHandlers.Verify, write, h.createJob)
+	router.Patch("/admin/jobs/:id", h.authHandlers.Verify, write, h.updateJob)
+	router.Get("/admin/jobs/:id/runs", h.authHandlers.Verify, read, h.listRuns)
+}
+
+type JobResponse struct {
+	ID        JobID           `json:"id"`
+	Name      string          `json:"name"`
+	Kind      Kind            `json:"kind"`
+	Schedule  string          `json:"schedule"`
+	Params    json.RawMessage `json:"params"`
+	Enabled   bool            `json:"enabled"`
+	NextRunAt time.Time       `json:"nextRunAt"`
+	RetryAt   *time.Time      `json:"retryAt,omitempty"`
+}
+
+func newJobResponse(job *Job) JobResponse {
+	resp := JobResponse{
+		ID:        job.ID,
+		Name:      job.Name,
+		Kind:      job.Kind,
+		Schedule:  job.Schedule,
+		Params:    job.Params,
+		Enabled:   job.Enabled,
+		NextRunAt: job.NextRunAt,
+	}
+	if !job.RetryAt.IsZero() {
+		resp.RetryAt = &job.RetryAt
+	}
+	return resp
+}
+
+type CreateJobRequest struct {
+	Name     string          `json:"name"`
+	Kind     Kind            `json:"kind"`
+	Schedule string          `json:"schedule"`
+	Params   json.RawMessage `json:"params"`
+	Enabled  *bool           `json:"enabled"`
+}
+
+type UpdateJobRequest struct {
+	Schedule *string         `json:"schedule"`
+	Params   json.RawMessage `json:"params"`
+	Enabled  *bool           `json:"enabled"`
+}
+
+type RunResponse struct {
+	ID         int64      `json:"id"`
+	Period     time.Time  `json:"period"`
+	Status     RunStatus  `json:"status"`
+	Result     string     `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+func (h *Handler) listJobs(c *fiber.Ctx) error {
+	jobs, err := h.svc.ListJobs(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	resp := make([]JobResponse, len(jobs))
+	for i, job := range jobs {
+		resp[i] = newJobResponse(job)
+	}
+	return c.JSON(resp)
+}
+
+func (h *Handler) createJob(c *fiber.Ctx) error {
+	var req CreateJobRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	job := &Job{
+		Name:     req.Name,
+		Kind:     req.Kind,
+		Schedule: req.Schedule,
+		Params:   req.Params,
+		Enabled:  req.Enabled == nil || *req.Enabled,
+	}
+	job, err := h.svc.CreateJob(c.UserContext(), job)
+	if err != nil {
+		return jobError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(newJobResponse(job))
+}
+
+func (h *Handler) updateJob(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid job id",
+		})
+	}
+	var req UpdateJobRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	job, err := h.svc.UpdateJob(c.UserContext(), JobID(id), JobUpdate{
+		Schedule: req.Schedule,
+		Params:   req.Params,
+		Enabled:  req.Enabled,
+	})
+	if err != nil {
+		return jobError(c, err)
+	}
+	return c.JSON(newJobResponse(job))
+}
+
+func (h *Handler) listRuns(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid job id",
+		})
+	}
+	runs, err := h.svc.ListRuns(c.UserContext(), JobID(id), c.QueryInt("limit", DefaultRunsLimit))
+	if err != nil {
+		return jobError(c, err)
+	}
+	resp := make([]RunResponse, len(runs))
+	for i, run := range runs {
+		resp[i] = RunResponse{
+			ID:        run.ID,
+			Period:    run.Period,
+			Status:    run.Status,
+			Result:    run.Result,
+			Error:     run.Error,
+			StartedAt: run.StartedAt,
+		}
+		if !run.FinishedAt.IsZero() {
+			resp[i].FinishedAt = &run.FinishedAt
+		}
+	}
+	return c.JSON(resp)
+}
+
+// jobError отвечает на ошибку работы с задачей.
+func jobError(c *fiber.Ctx, err error) error {
+	var (
+		kindErr     ErrUnknownKind
+		scheduleErr ErrInvalidSchedule
+		paramsErr   ErrInvalidParams
+	)
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, ErrJobExists):
+		status = fiber.StatusConflict
+	case errors.Is(err, ErrInvalidName), errors.As(err, &kindErr),
+		errors.As(err, &scheduleErr), errors.As(err, &paramsErr):
+		status = fiber.StatusBadRequest
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"errors": err.Error(),
+	})
+}
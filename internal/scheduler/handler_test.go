@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{ErrJobNotFound, fiber.StatusNotFound},
+		{ErrJobExists, fiber.StatusConflict},
+		{ErrInvalidName, fiber.StatusBadRequest},
+		{NewErrUnknownKind("nope"), fiber.StatusBadRequest},
+		{NewErrInvalidSchedule("* *"), fiber.StatusBadRequest},
+		{NewErrInvalidParams("amount must be positive"), fiber.StatusBadRequest},
+		{errors.New("db is down"), fiber.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			return jobError(c, tt.err)
+		})
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+		require.NoError(t, err)
+		assert.Equal(t, tt.status, resp.StatusCode, tt.err.Error())
+	}
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"avito-intern/pkg/cron"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type JobID int64
+
+// Kind — тип задачи, определяет Runner, который её выполняет.
+type Kind string
+
+// Job — периодическая задача с расписанием cron.
+type Job struct {
+	ID       JobID
+	Name     string
+	Kind     Kind
+	Schedule string
+	// Params — параметры задачи, их формат задаёт Runner.
+	Params  json.RawMessage
+	Enabled bool
+	// NextRunAt — время следующего запуска, оно же период, за который запуск выполняется.
+	NextRunAt time.Time
+	// RetryAt — после неудачного запуска период повторяется не раньше этого времени.
+	RetryAt   time.Time
+	CreatedAt time.Time
+}
+
+// JobUpdate — изменение задачи, nil-поля не меняются.
+type JobUpdate struct {
+	Schedule *string
+	Params   json.RawMessage
+	Enabled  *bool
+}
+
+type RunStatus string
+
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+)
+
+// Run — запуск задачи за период. На каждый период задачи есть не больше одного запуска,
+// неудачный запуск повторяется в той же записи.
+type Run struct {
+	ID         int64
+	JobID      JobID
+	Period     time.Time
+	Status     RunStatus
+	Result     string
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Runner выполняет задачи одного типа. Run должен быть идемпотентным в пределах периода:
+// после сбоя запуск за тот же период повторяется.
+type Runner interface {
+	// Validate проверяет параметры задачи при создании и изменении.
+	Validate(params json.RawMessage) error
+	// Run выполняет задачу за период и возвращает краткий итог для истории запусков.
+	Run(ctx context.Context, job *Job, period time.Time) (string, error)
+}
+
+// nextRun возвращает первый запуск по расписанию после t.
+func nextRun(schedule string, t time.Time) (time.Time, error) {
+	s, err := cron.Parse(schedule)
+	if err != nil {
+		return time.Time{}, NewErrInvalidSchedule(schedule)
+	}
+	next := s.Next(t)
+	if next.IsZero() {
+		return time.Time{}, NewErrInvalidSchedule(schedule)
+	}
+	return next, nil
+}
@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// CreateJob сохраняет задачу, ErrJobExists — если имя занято.
+	CreateJob(ctx context.Context, job *Job) (*Job, error)
+	UpdateJob(ctx context.Context, job *Job) (*Job, error)
+	GetJob(ctx context.Context, id JobID) (*Job, error)
+	ListJobs(ctx context.Context) ([]*Job, error)
+	// ListDueJobs возвращает включённые задачи, которым пора выполняться к now.
+	ListDueJobs(ctx context.Context, now time.Time) ([]*Job, error)
+	// SetNextRun переносит следующий запуск задачи, нулевой retryAt сбрасывает повтор.
+	SetNextRun(ctx context.Context, id JobID, nextRunAt, retryAt time.Time) error
+
+	// StartRun занимает запуск задачи за период. Новый, неудачный или брошенный (running с начала
+	// раньше staleBefore) запуск переводится в running и возвращается со started = true,
+	// иначе возвращается существующий запуск.
+	StartRun(ctx context.Context, id JobID, period, staleBefore time.Time) (run *Run, started bool, err error)
+	FinishRun(ctx context.Context, run *Run) error
+	// ListRuns возвращает последние limit запусков задачи, новые первыми.
+	ListRuns(ctx context.Context, id JobID, limit int) ([]*Run, error)
+}
+
+// Locker выбирает лидера: задачи выполняет только реплика, взявшая блокировку.
+type Locker interface {
+	// TryLock берёт блокировку без ожидания, nil — если её держит другая реплика.
+	TryLock(ctx context.Context, key int64) (Lock, error)
+}
+
+type Lock interface {
+	// Alive проверяет, что блокировка всё ещё принадлежит этой реплике.
+	Alive(ctx context.Context) error
+	Release(ctx context.Context)
+}
@@ -0,0 +1,214 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRunsLimit — сколько последних запусков отдаётся по умолчанию.
+	DefaultRunsLimit = 20
+	// MaxRunsLimit — максимальное число запусков в ответе.
+	MaxRunsLimit = 100
+)
+
+type service struct {
+	cfg     *Config
+	repo    Repository
+	locker  Locker
+	runners map[Kind]Runner
+	now     func() time.Time
+}
+
+func NewService(cfg *Config, repo Repository, locker Locker, runners map[Kind]Runner) Service {
+	return &service{
+		cfg:     cfg,
+		repo:    repo,
+		locker:  locker,
+		runners: runners,
+		now:     time.Now,
+	}
+}
+
+// CreateJob проверяет расписание и параметры и сохраняет задачу. Первый запуск — по расписанию после создания.
+func (s *service) CreateJob(ctx context.Context, job *Job) (*Job, error) {
+	job.Name = strings.TrimSpace(job.Name)
+	if job.Name == "" {
+		return nil, ErrInvalidName
+	}
+	if err := s.validate(job); err != nil {
+		return nil, err
+	}
+	next, err := nextRun(job.Schedule, s.now())
+	if err != nil {
+		return nil, err
+	}
+	job.NextRunAt = next
+	return s.repo.CreateJob(ctx, job)
+}
+
+// UpdateJob меняет расписание, параметры или включает и выключает задачу.
+// При смене расписания следующий запуск пересчитывается от текущего времени.
+func (s *service) UpdateJob(ctx context.Context, id JobID, upd JobUpdate) (*Job, error) {
+	job, err := s.repo.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if upd.Schedule != nil && *upd.Schedule != job.Schedule {
+		job.Schedule = *upd.Schedule
+		if job.NextRunAt, err = nextRun(job.Schedule, s.now()); err != nil {
+			return nil, err
+		}
+		job.RetryAt = time.Time{}
+	}
+	if upd.Params != nil {
+		job.Params = upd.Params
+	}
+	if upd.Enabled != nil {
+		job.Enabled = *upd.Enabled
+	}
+	if err := s.validate(job); err != nil {
+		return nil, err
+	}
+	return s.repo.UpdateJob(ctx, job)
+}
+
+func (s *service) validate(job *Job) error {
+	runner, ok := s.runners[job.Kind]
+	if !ok {
+		return NewErrUnknownKind(job.Kind)
+	}
+	if len(job.Params) == 0 {
+		job.Params = json.RawMessage("{}")
+	}
+	return runner.Validate(job.Params)
+}
+
+func (s *service) ListJobs(ctx context.Context) ([]*Job, error) {
+	return s.repo.ListJobs(ctx)
+}
+
+// ListRuns возвращает историю запусков задачи, новые первыми.
+func (s *service) ListRuns(ctx context.Context, id JobID, limit int) ([]*Run, error) {
+	if _, err := s.repo.GetJob(ctx, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxRunsLimit {
+		limit = DefaultRunsLimit
+	}
+	return s.repo.ListRuns(ctx, id, limit)
+}
+
+// Run выполняет задачи по расписанию, пока не отменён ctx. Задачи выполняет только
+// реплика-лидер: она держит advisory-блокировку, остальные на каждом тике пробуют её взять.
+func (s *service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.TickInterval)
+	defer ticker.Stop()
+
+	var lock Lock
+	defer func() {
+		if lock != nil {
+			lock.Release(context.WithoutCancel(ctx))
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if lock != nil {
+			if err := lock.Alive(ctx); err != nil {
+				slog.Warn("lost scheduler leadership", "error", err)
+				lock.Release(ctx)
+				lock = nil
+			}
+		}
+		if lock == nil {
+			var err error
+			if lock, err = s.locker.TryLock(ctx, s.cfg.LockKey); err != nil {
+				slog.Error("failed to take scheduler lock", "error", err)
+				continue
+			}
+			if lock == nil {
+				continue
+			}
+			slog.Info("became scheduler leader")
+		}
+		if err := s.RunDue(ctx); err != nil {
+			slog.Error("failed to run scheduled jobs", "error", err)
+		}
+	}
+}
+
+// RunDue выполняет задачи, которым пора выполняться. Вызывается лидером.
+func (s *service) RunDue(ctx context.Context) error {
+	jobs, err := s.repo.ListDueJobs(ctx, s.now())
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if err := s.runJob(ctx, job); err != nil {
+			slog.Error("failed to run job", "job", job.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// runJob выполняет задачу за период job.NextRunAt. Пропущенные периоды не догоняются:
+// после запуска следующий период считается от текущего времени.
+func (s *service) runJob(ctx context.Context, job *Job) error {
+	period := job.NextRunAt
+	run, started, err := s.repo.StartRun(ctx, job.ID, period, s.now().Add(-s.cfg.RunTimeout))
+	if err != nil {
+		return err
+	}
+	if !started {
+		if run.Status == RunSucceeded {
+			// период уже выполнен, например, до смены лидера.
+			return s.scheduleNext(ctx, job)
+		}
+		// период выполняет другая реплика, проверим позже.
+		return s.repo.SetNextRun(ctx, job.ID, period, s.now().Add(s.cfg.RetryInterval))
+	}
+
+	result, runErr := s.execute(ctx, job, period)
+	run.Result = result
+	run.FinishedAt = s.now()
+	run.Status = RunSucceeded
+	if runErr != nil {
+		run.Status = RunFailed
+		run.Error = runErr.Error()
+	}
+	if err := s.repo.FinishRun(ctx, run); err != nil {
+		return err
+	}
+	if runErr != nil {
+		slog.Error("job failed", "job", job.Name, "period", period, "error", runErr)
+		return s.repo.SetNextRun(ctx, job.ID, period, s.now().Add(s.cfg.RetryInterval))
+	}
+	slog.Info("job succeeded", "job", job.Name, "period", period, "result", result)
+	return s.scheduleNext(ctx, job)
+}
+
+func (s *service) execute(ctx context.Context, job *Job, period time.Time) (string, error) {
+	runner, ok := s.runners[job.Kind]
+	if !ok {
+		return "", NewErrUnknownKind(job.Kind)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.RunTimeout)
+	defer cancel()
+	return runner.Run(ctx, job, period)
+}
+
+func (s *service) scheduleNext(ctx context.Context, job *Job) error {
+	next, err := nextRun(job.Schedule, s.now())
+	if err != nil {
+		return err
+	}
+	return s.repo.SetNextRun(ctx, job.ID, next, time.Time{})
+}
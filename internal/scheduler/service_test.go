@@ -0,0 +1,336 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type runKey struct {
+	job    JobID
+	period time.Time
+}
+
+// memoryRepo — потокобезопасная реализация Repository в памяти.
+type memoryRepo struct {
+	mu   sync.Mutex
+	jobs map[JobID]Job
+	runs map[runKey]*Run
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{jobs: make(map[JobID]Job), runs: make(map[runKey]*Run)}
+}
+
+func (m *memoryRepo) CreateJob(_ context.Context, job *Job) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.Name == job.Name {
+			return nil, ErrJobExists
+		}
+	}
+	saved := *job
+	saved.ID = JobID(len(m.jobs) + 1)
+	m.jobs[saved.ID] = saved
+	return &saved, nil
+}
+
+func (m *memoryRepo) UpdateJob(_ context.Context, job *Job) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jobs[job.ID]; !ok {
+		return nil, ErrJobNotFound
+	}
+	m.jobs[job.ID] = *job
+	return job, nil
+}
+
+func (m *memoryRepo) GetJob(_ context.Context, id JobID) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return &job, nil
+}
+
+func (m *memoryRepo) ListJobs(_ context.Context) ([]*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var jobs []*Job
+	for _, job := range m.jobs {
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+func (m *memoryRepo) ListDueJobs(_ context.Context, now time.Time) ([]*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var jobs []*Job
+	for _, job := range m.jobs {
+		if job.Enabled && !job.NextRunAt.After(now) && !job.RetryAt.After(now) {
+			jobs = append(jobs, &job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *memoryRepo) SetNextRun(_ context.Context, id JobID, nextRunAt, retryAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id]
+	job.NextRunAt, job.RetryAt = nextRunAt, retryAt
+	m.jobs[id] = job
+	return nil
+}
+
+func (m *memoryRepo) StartRun(_ context.Context, id JobID, period, staleBefore time.Time) (*Run, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := runKey{id, period}
+	run, ok := m.runs[key]
+	if ok && run.Status != RunFailed && (run.Status != RunRunning || !run.StartedAt.Before(staleBefore)) {
+		return run, false, nil
+	}
+	if !ok {
+		run = &Run{ID: int64(len(m.runs) + 1), JobID: id, Period: period}
+		m.runs[key] = run
+	}
+	run.Status, run.Error, run.StartedAt = RunRunning, "", time.Now()
+	started := *run
+	return &started, true, nil
+}
+
+func (m *memoryRepo) FinishRun(_ context.Context, run *Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *run
+	m.runs[runKey{run.JobID, run.Period}] = &saved
+	return nil
+}
+
+func (m *memoryRepo) ListRuns(_ context.Context, id JobID, limit int) ([]*Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []*Run
+	for key, run := range m.runs {
+		if key.job == id && len(runs) < limit {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+// fakeRunner возвращает ошибки из errs по очереди, затем выполняется успешно.
+type fakeRunner struct {
+	mu      sync.Mutex
+	periods []time.Time
+	errs    []error
+}
+
+func (r *fakeRunner) Validate(params json.RawMessage) error {
+	var p struct {
+		Valid *bool `json:"valid"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || (p.Valid != nil && !*p.Valid) {
+		return NewErrInvalidParams("not valid")
+	}
+	return nil
+}
+
+func (r *fakeRunner) Run(_ context.Context, _ *Job, period time.Time) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.periods = append(r.periods, period)
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		return "", err
+	}
+	return "done", nil
+}
+
+func (r *fakeRunner) calls() []time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]time.Time(nil), r.periods...)
+}
+
+type fakeLock struct{}
+
+func (fakeLock) Alive(context.Context) error { return nil }
+func (fakeLock) Release(context.Context)     {}
+
+// fakeLocker отдаёт блокировку, только если held = false.
+type fakeLocker struct {
+	held bool
+}
+
+func (l *fakeLocker) TryLock(context.Context, int64) (Lock, error) {
+	if l.held {
+		return nil, nil
+	}
+	return fakeLock{}, nil
+}
+
+const testKind Kind = "test"
+
+func newTestService(repo *memoryRepo, runner *fakeRunner, locker Locker, now *time.Time) *service {
+	svc := NewService(&Config{
+		TickInterval:  10 * time.Millisecond,
+		RetryInterval: 5 * time.Minute,
+		RunTimeout:    time.Hour,
+	}, repo, locker, map[Kind]Runner{testKind: runner}).(*service)
+	svc.now = func() time.Time { return *now }
+	return svc
+}
+
+func TestCreateJob(t *testing.T) {
+	now := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	svc := newTestService(newMemoryRepo(), &fakeRunner{}, &fakeLocker{}, &now)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		job  Job
+		err  error
+	}{
+		{"empty name", Job{Name: " ", Kind: testKind, Schedule: "@daily"}, ErrInvalidName},
+		{"unknown kind", Job{Name: "a", Kind: "nope", Schedule: "@daily"}, NewErrUnknownKind("nope")},
+		{"bad schedule", Job{Name: "a", Kind: testKind, Schedule: "every day"}, NewErrInvalidSchedule("every day")},
+		{"never fires", Job{Name: "a", Kind: testKind, Schedule: "0 0 30 2 *"}, NewErrInvalidSchedule("0 0 30 2 *")},
+		{"bad params", Job{Name: "a", Kind: testKind, Schedule: "@daily", Params: json.RawMessage(`{"valid":false}`)}, NewErrInvalidParams("not valid")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := tt.job
+			_, err := svc.CreateJob(ctx, &job)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	job, err := svc.CreateJob(ctx, &Job{Name: " monthly ", Kind: testKind, Schedule: "@monthly", Enabled: true})
+	require.NoError(t, err)
+	assert.Equal(t, "monthly", job.Name)
+	assert.JSONEq(t, `{}`, string(job.Params))
+	assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), job.NextRunAt)
+
+	_, err = svc.CreateJob(ctx, &Job{Name: "monthly", Kind: testKind, Schedule: "@daily"})
+	assert.ErrorIs(t, err, ErrJobExists)
+}
+
+func TestUpdateJob(t *testing.T) {
+	now := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	svc := newTestService(newMemoryRepo(), &fakeRunner{}, &fakeLocker{}, &now)
+	ctx := context.Background()
+
+	job, err := svc.CreateJob(ctx, &Job{Name: "daily", Kind: testKind, Schedule: "@daily", Enabled: true})
+	require.NoError(t, err)
+
+	disabled := false
+	weekly := "@weekly"
+	job, err = svc.UpdateJob(ctx, job.ID, JobUpdate{Schedule: &weekly, Enabled: &disabled})
+	require.NoError(t, err)
+	assert.False(t, job.Enabled)
+	// 16 марта 2025 — воскресенье.
+	assert.Equal(t, time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC), job.NextRunAt)
+
+	bad := "* *"
+	_, err = svc.UpdateJob(ctx, job.ID, JobUpdate{Schedule: &bad})
+	assert.ErrorIs(t, err, NewErrInvalidSchedule(bad))
+
+	_, err = svc.UpdateJob(ctx, 42, JobUpdate{Enabled: &disabled})
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestRunDue(t *testing.T) {
+	now := time.Date(2025, 3, 31, 23, 0, 0, 0, time.UTC)
+	repo := newMemoryRepo()
+	runner := &fakeRunner{errs: []error{errors.New("db is down")}}
+	svc := newTestService(repo, runner, &fakeLocker{}, &now)
+	ctx := context.Background()
+
+	job, err := svc.CreateJob(ctx, &Job{Name: "monthly", Kind: testKind, Schedule: "@monthly", Enabled: true})
+	require.NoError(t, err)
+	period := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	// ещё не время.
+	require.NoError(t, svc.RunDue(ctx))
+	assert.Empty(t, runner.calls())
+
+	// первый запуск падает, повтор откладывается на RetryInterval.
+	now = period.Add(time.Minute)
+	require.NoError(t, svc.RunDue(ctx))
+	assert.Equal(t, []time.Time{period}, runner.calls())
+	run := repo.runs[runKey{job.ID, period}]
+	assert.Equal(t, RunFailed, run.Status)
+	assert.Equal(t, "db is down", run.Error)
+	job, _ = repo.GetJob(ctx, job.ID)
+	assert.Equal(t, period, job.NextRunAt)
+	assert.Equal(t, now.Add(5*time.Minute), job.RetryAt)
+
+	require.NoError(t, svc.RunDue(ctx))
+	assert.Len(t, runner.calls(), 1)
+
+	// повтор выполняется за тот же период и переносит задачу на следующий.
+	now = now.Add(10 * time.Minute)
+	require.NoError(t, svc.RunDue(ctx))
+	assert.Equal(t, []time.Time{period, period}, runner.calls())
+	run = repo.runs[runKey{job.ID, period}]
+	assert.Equal(t, RunSucceeded, run.Status)
+	assert.Equal(t, "done", run.Result)
+	job, _ = repo.GetJob(ctx, job.ID)
+	assert.Equal(t, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), job.NextRunAt)
+	assert.True(t, job.RetryAt.IsZero())
+}
+
+func TestRunDue_AlreadySucceeded(t *testing.T) {
+	now := time.Date(2025, 4, 1, 0, 1, 0, 0, time.UTC)
+	repo := newMemoryRepo()
+	runner := &fakeRunner{}
+	svc := newTestService(repo, runner, &fakeLocker{}, &now)
+	ctx := context.Background()
+
+	period := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	job, err := repo.CreateJob(ctx, &Job{Name: "monthly", Kind: testKind, Schedule: "@monthly", Enabled: true, NextRunAt: period})
+	require.NoError(t, err)
+	// прежний лидер выполнил период, но не успел перенести задачу.
+	repo.runs[runKey{job.ID, period}] = &Run{JobID: job.ID, Period: period, Status: RunSucceeded}
+
+	require.NoError(t, svc.RunDue(ctx))
+	assert.Empty(t, runner.calls())
+	job, _ = repo.GetJob(ctx, job.ID)
+	assert.Equal(t, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), job.NextRunAt)
+}
+
+func TestRun_OnlyLeader(t *testing.T) {
+	for _, held := range []bool{true, false} {
+		now := time.Date(2025, 4, 1, 0, 1, 0, 0, time.UTC)
+		repo := newMemoryRepo()
+		runner := &fakeRunner{}
+		svc := newTestService(repo, runner, &fakeLocker{held: held}, &now)
+		_, err := repo.CreateJob(context.Background(), &Job{
+			Name: "monthly", Kind: testKind, Schedule: "@monthly", Enabled: true,
+			NextRunAt: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		svc.Run(ctx)
+		cancel()
+		if held {
+			assert.Empty(t, runner.calls(), "replica without the lock must not run jobs")
+		} else {
+			assert.Len(t, runner.calls(), 1)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package statement
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	Err              = errors.New("statement")
+	ErrInvalidPeriod = fmt.Errorf("%v: period must be a month in the form yyyy-mm", Err)
+	ErrFuturePeriod  = fmt.Errorf("%v: period hasn't started yet", Err)
+	ErrNotAcceptable = fmt.Errorf("%v: statement is available as %s or %s", Err, JSON, CSV)
+)
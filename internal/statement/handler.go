@@ -0,0 +1,136 @@
+package statement
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Service interface {
+	Statement(ctx context.Context, user *auth.User, period Period) (*Statement, error)
+}
+
+type AuthHandler interface {
+	Verify(c *fiber.Ctx) error
+}
+
+type Handler struct {
+	svc          Service
+	authHandlers AuthHandler
+}
+
+func NewStatementHandler(svc Service, authHandler AuthHandler) *Handler {
+	return &Handler{
+		svc:          svc,
+		authHandlers: authHandler,
+	}
+}
+
+func (h *Handler) Init(router fiber.Router) {
+	router.Get("/statements/:period", h.authHandlers.Verify, h.statement)
+}
+
+type LineResponse struct {
+	TransactionID coin.TransactionID `json:"transactionId"`
+	Type          coin.Type          `json:"type"`
+	Direction     coin.Direction     `json:"direction"`
+	Amount        int                `json:"amount"`
+	Counterparty  string             `json:"counterparty,omitempty"`
+	Item          string             `json:"item,omitempty"`
+	Quantity      int                `json:"quantity,omitempty"`
+	Message       string             `json:"message,omitempty"`
+	Balance       int                `json:"balance"`
+	CreatedAt     time.Time          `json:"createdAt"`
+}
+
+type StatementResponse struct {
+	Period         string         `json:"period"`
+	From           time.Time      `json:"from"`
+	To             time.Time      `json:"to"`
+	OpeningBalance int            `json:"openingBalance"`
+	Credits        int            `json:"credits"`
+	Debits         int            `json:"debits"`
+	ClosingBalance int            `json:"closingBalance"`
+	Lines          []LineResponse `json:"lines"`
+}
+
+// statement Выписка по счёту за месяц в JSON или CSV по заголовку Accept.
+//
+//	GET /api/statements/2025-03
+func (h *Handler) statement(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	format := Format(c.Accepts(string(JSON), string(CSV)))
+	if format == "" {
+		return c.Status(fiber.StatusNotAcceptable).JSON(fiber.Map{
+			"errors": ErrNotAcceptable.Error(),
+		})
+	}
+	period, err := ParsePeriod(c.Params("period"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+
+	st, err := h.svc.Statement(ctx, user, period)
+	if err != nil {
+		if errors.Is(err, ErrFuturePeriod) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+
+	if format == CSV {
+		var buf bytes.Buffer
+		if err := WriteCSV(&buf, st); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"errors": err.Error(),
+			})
+		}
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="statement-%s.csv"`, period))
+		return c.Send(buf.Bytes())
+	}
+
+	lines := make([]LineResponse, len(st.Lines))
+	for i, l := range st.Lines {
+		lines[i] = LineResponse{
+			TransactionID: l.TransactionID,
+			Type:          l.Type,
+			Direction:     l.Direction,
+			Amount:        l.Amount,
+			Counterparty:  l.Counterparty,
+			Item:          l.Item,
+			Quantity:      l.Quantity,
+			Message:       l.Message,
+			Balance:       l.Balance,
+			CreatedAt:     l.CreatedAt,
+		}
+	}
+	return c.JSON(StatementResponse{
+		Period:         st.Period.String(),
+		From:           st.Period.From,
+		To:             st.Period.To,
+		OpeningBalance: st.Opening,
+		Credits:        st.Credits,
+		Debits:         st.Debits,
+		ClosingBalance: st.Closing,
+		Lines:          lines,
+	})
+}
@@ -0,0 +1,57 @@
+package statement
+
+import (
+	"avito-intern/internal/coin"
+	"time"
+)
+
+// periodLayout — формат месяца выписки в запросе.
+const periodLayout = "2006-01"
+
+// Period — календарный месяц выписки по UTC.
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+// ParsePeriod разбирает месяц вида 2025-03.
+func ParsePeriod(raw string) (Period, error) {
+	from, err := time.ParseInLocation(periodLayout, raw, time.UTC)
+	if err != nil {
+		return Period{}, ErrInvalidPeriod
+	}
+	return Period{From: from, To: from.AddDate(0, 1, 0)}, nil
+}
+
+func (p Period) String() string {
+	return p.From.Format(periodLayout)
+}
+
+// Line — движение монет по счёту пользователя за период.
+type Line struct {
+	TransactionID coin.TransactionID
+	Type          coin.Type
+	Direction     coin.Direction
+	Amount        int
+	// Counterparty — второй участник перевода или сбора, пусто для начислений и списаний системы.
+	Counterparty string
+	// Item и Quantity — купленный мерч для покупок и их возвратов.
+	Item     string
+	Quantity int
+	Message  string
+	// Balance — баланс после этого движения.
+	Balance   int
+	CreatedAt time.Time
+}
+
+// Statement — выписка по счёту пользователя за месяц: входящий баланс, все движения
+// в порядке проведения и исходящий баланс.
+type Statement struct {
+	Username string
+	Period   Period
+	Opening  int
+	Credits  int
+	Debits   int
+	Closing  int
+	Lines    []*Line
+}
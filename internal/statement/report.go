@@ -0,0 +1,73 @@
+package statement
+
+import (
+	"avito-intern/internal/coin"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format — формат выписки.
+type Format string
+
+const (
+	JSON Format = "application/json"
+	CSV  Format = "text/csv"
+)
+
+const (
+	openingType = "opening_balance"
+	closingType = "closing_balance"
+)
+
+// WriteCSV записывает выписку по строке на движение, между строками входящего и исходящего баланса.
+// Сумма движения стоит в колонке credit или debit, balance — баланс после него.
+func WriteCSV(w io.Writer, st *Statement) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{
+		"date", "transaction_id", "type", "counterparty", "item", "quantity", "message", "credit", "debit", "balance",
+	})
+	_ = cw.Write([]string{
+		st.Period.From.Format(time.RFC3339), "", openingType, "", "", "", "", "", "", strconv.Itoa(st.Opening),
+	})
+	for _, l := range st.Lines {
+		credit, debit := "", ""
+		if l.Direction == coin.Incoming {
+			credit = strconv.Itoa(l.Amount)
+		} else {
+			debit = strconv.Itoa(l.Amount)
+		}
+		quantity := ""
+		if l.Quantity > 0 {
+			quantity = strconv.Itoa(l.Quantity)
+		}
+		_ = cw.Write([]string{
+			l.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.FormatInt(int64(l.TransactionID), 10),
+			string(l.Type),
+			cell(l.Counterparty),
+			cell(l.Item),
+			quantity,
+			cell(l.Message),
+			credit,
+			debit,
+			strconv.Itoa(l.Balance),
+		})
+	}
+	_ = cw.Write([]string{
+		st.Period.To.Format(time.RFC3339), "", closingType, "", "", "", "",
+		strconv.Itoa(st.Credits), strconv.Itoa(st.Debits), strconv.Itoa(st.Closing),
+	})
+	cw.Flush()
+	return cw.Error()
+}
+
+// cell экранирует текст, который табличный редактор принял бы за формулу: сообщения пишут сотрудники.
+func cell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,45 @@
+package statement
+
+import (
+	"avito-intern/internal/coin"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCSV(t *testing.T) {
+	period, err := ParsePeriod("2025-03")
+	require.NoError(t, err)
+	st := &Statement{
+		Username: "bob",
+		Period:   period,
+		Opening:  100,
+		Credits:  50,
+		Debits:   20,
+		Closing:  130,
+		Lines: []*Line{
+			{
+				TransactionID: 3, Type: coin.Transfer, Direction: coin.Incoming, Amount: 50,
+				Counterparty: "alice", Message: "=HYPERLINK(\"x\"), спасибо", Balance: 150,
+				CreatedAt: time.Date(2025, 3, 5, 10, 0, 0, 0, time.UTC),
+			},
+			{
+				TransactionID: 4, Type: coin.Purchase, Direction: coin.Outgoing, Amount: 20,
+				Item: "cup", Quantity: 1, Balance: 130,
+				CreatedAt: time.Date(2025, 3, 6, 10, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, st))
+	assert.Equal(t, `date,transaction_id,type,counterparty,item,quantity,message,credit,debit,balance
+2025-03-01T00:00:00Z,,opening_balance,,,,,,,100
+2025-03-05T10:00:00Z,3,transfer,alice,,,"'=HYPERLINK(""x""), спасибо",50,,150
+2025-03-06T10:00:00Z,4,purchase,,cup,1,,,20,130
+2025-04-01T00:00:00Z,,closing_balance,,,,,50,20,130
+`, buf.String())
+}
@@ -0,0 +1,19 @@
+package statement
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"avito-intern/internal/merch"
+	"context"
+	"time"
+)
+
+// Repository — история монет и покупки мерча, из которых собирается выписка.
+type Repository interface {
+	// ListHistory — история пользователя, как в coin.Repository.
+	ListHistory(ctx context.Context, userID auth.UserID, filter coin.HistoryFilter) ([]*coin.HistoryEntry, error)
+	// SumHistory возвращает сумму проводок по счёту пользователя до before, то есть его баланс на этот момент.
+	SumHistory(ctx context.Context, userID auth.UserID, before time.Time) (int, error)
+	// ListPurchasesByTransactions возвращает покупки, оплаченные транзакциями ids.
+	ListPurchasesByTransactions(ctx context.Context, ids []coin.TransactionID) ([]*merch.Purchase, error)
+}
@@ -0,0 +1,143 @@
+package statement
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"avito-intern/internal/merch"
+	"context"
+	"time"
+)
+
+type service struct {
+	repo Repository
+	now  func() time.Time
+}
+
+func NewService(repo Repository) Service {
+	return &service{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// Statement собирает выписку пользователя за период. Входящий баланс считается по истории
+// до начала периода, а не по текущему балансу, поэтому выписка за прошлый месяц не меняется
+// от движений после него. Выписка за текущий месяц — на момент запроса.
+func (s *service) Statement(ctx context.Context, user *auth.User, period Period) (*Statement, error) {
+	if period.From.After(s.now()) {
+		return nil, ErrFuturePeriod
+	}
+	opening, err := s.repo.SumHistory(ctx, user.ID, period.From)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.listHistory(ctx, user, period)
+	if err != nil {
+		return nil, err
+	}
+	purchases, err := s.listPurchases(ctx, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Statement{
+		Username: user.Username,
+		Period:   period,
+		Opening:  opening,
+		Closing:  opening,
+		Lines:    make([]*Line, len(entries)),
+	}
+	for i, e := range entries {
+		line := &Line{
+			TransactionID: e.ID,
+			Type:          e.Type,
+			Direction:     e.Direction,
+			Amount:        e.Amount,
+			Counterparty:  counterparty(e, user.Username),
+			Message:       e.Message,
+			CreatedAt:     e.CreatedAt,
+		}
+		if p, ok := purchases[purchaseTransaction(e)]; ok {
+			line.Item = p.MerchName
+			line.Quantity = p.Quantity
+		}
+		if e.Direction == coin.Incoming {
+			st.Credits += e.Amount
+			st.Closing += e.Amount
+		} else {
+			st.Debits += e.Amount
+			st.Closing -= e.Amount
+		}
+		line.Balance = st.Closing
+		st.Lines[i] = line
+	}
+	return st, nil
+}
+
+// listHistory читает все проводки пользователя за период от старых к новым.
+func (s *service) listHistory(ctx context.Context, user *auth.User, period Period) ([]*coin.HistoryEntry, error) {
+	filter := coin.HistoryFilter{
+		From:  period.From,
+		To:    period.To,
+		Order: coin.OldestFirst,
+		Limit: coin.MaxHistoryLimit,
+	}
+	var entries []*coin.HistoryEntry
+	for {
+		page, err := s.repo.ListHistory(ctx, user.ID, filter)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, page...)
+		if len(page) < filter.Limit {
+			return entries, nil
+		}
+		filter.After = coin.Cursor(page[len(page)-1].EntryID)
+	}
+}
+
+// listPurchases возвращает покупки, к которым относятся проводки, по ID оплатившей транзакции.
+func (s *service) listPurchases(ctx context.Context, entries []*coin.HistoryEntry) (map[coin.TransactionID]*merch.Purchase, error) {
+	var ids []coin.TransactionID
+	for _, e := range entries {
+		if id := purchaseTransaction(e); id != 0 {
+			ids = append(ids, id)
+		}
+	}
+	res := make(map[coin.TransactionID]*merch.Purchase)
+	if len(ids) == 0 {
+		return res, nil
+	}
+	purchases, err := s.repo.ListPurchasesByTransactions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range purchases {
+		res[p.TransactionID] = p
+	}
+	return res, nil
+}
+
+// purchaseTransaction возвращает транзакцию, которой мог быть оплачен мерч: саму покупку
+// или транзакцию, которую отменяет возврат. 0 — проводка к мерчу не относится.
+func purchaseTransaction(e *coin.HistoryEntry) coin.TransactionID {
+	switch {
+	case e.Type == coin.Purchase:
+		return e.ID
+	case e.Type == coin.Reversal && e.PrevTransaction != nil:
+		return coin.TransactionID(*e.PrevTransaction)
+	default:
+		return 0
+	}
+}
+
+// counterparty возвращает второго участника транзакции. Возврат отклонённого перевода приходит
+// отправителю от него самого, поэтому второй участник — получатель.
+func counterparty(e *coin.HistoryEntry, username string) string {
+	for _, u := range []*auth.User{e.FromUser, e.ToUser} {
+		if u != nil && u.Username != username {
+			return u.Username
+		}
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+package statement
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"avito-intern/internal/merch"
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepo хранит историю одного пользователя от старых проводок к новым.
+type fakeRepo struct {
+	entries   []*coin.HistoryEntry
+	purchases []*merch.Purchase
+	// pages — сколько раз запрошена история.
+	pages int
+}
+
+func (f *fakeRepo) ListHistory(_ context.Context, _ auth.UserID, filter coin.HistoryFilter) ([]*coin.HistoryEntry, error) {
+	f.pages++
+	var res []*coin.HistoryEntry
+	for _, e := range f.entries {
+		if e.EntryID <= int64(filter.After) || e.CreatedAt.Before(filter.From) || !e.CreatedAt.Before(filter.To) {
+			continue
+		}
+		if len(res) == filter.Limit {
+			break
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (f *fakeRepo) SumHistory(_ context.Context, _ auth.UserID, before time.Time) (int, error) {
+	sum := 0
+	for _, e := range f.entries {
+		if !e.CreatedAt.Before(before) {
+			continue
+		}
+		if e.Direction == coin.Incoming {
+			sum += e.Amount
+		} else {
+			sum -= e.Amount
+		}
+	}
+	return sum, nil
+}
+
+func (f *fakeRepo) ListPurchasesByTransactions(_ context.Context, ids []coin.TransactionID) ([]*merch.Purchase, error) {
+	var res []*merch.Purchase
+	for _, p := range f.purchases {
+		if slices.Contains(ids, p.TransactionID) {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+func entry(id int64, t coin.Type, dir coin.Direction, amount int, at time.Time, from, to string) *coin.HistoryEntry {
+	e := &coin.HistoryEntry{
+		EntryID:   id,
+		Direction: dir,
+		Transaction: coin.Transaction{
+			ID:        coin.TransactionID(id),
+			Type:      t,
+			Amount:    amount,
+			CreatedAt: at,
+		},
+	}
+	if from != "" {
+		e.FromUser = &auth.User{Username: from}
+	}
+	if to != "" {
+		e.ToUser = &auth.User{Username: to}
+	}
+	return e
+}
+
+func TestParsePeriod(t *testing.T) {
+	p, err := ParsePeriod("2025-12")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC), p.From)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), p.To)
+	assert.Equal(t, "2025-12", p.String())
+
+	for _, raw := range []string{"", "2025-13", "2025-3", "2025-03-01", "march"} {
+		_, err := ParsePeriod(raw)
+		assert.ErrorIs(t, err, ErrInvalidPeriod, raw)
+	}
+}
+
+func TestStatement(t *testing.T) {
+	feb := time.Date(2025, 2, 20, 10, 0, 0, 0, time.UTC)
+	mar := time.Date(2025, 3, 5, 10, 0, 0, 0, time.UTC)
+	apr := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	prev := int64(4)
+	reversal := entry(6, coin.Reversal, coin.Incoming, 10, mar.Add(3*time.Hour), "", "bob")
+	reversal.PrevTransaction = &prev
+	repo := &fakeRepo{
+		entries: []*coin.HistoryEntry{
+			entry(1, coin.Grant, coin.Incoming, 1000, feb, "", "bob"),
+			entry(2, coin.Transfer, coin.Outgoing, 100, feb.Add(time.Hour), "bob", "alice"),
+			entry(3, coin.Transfer, coin.Incoming, 50, mar, "alice", "bob"),
+			entry(4, coin.Purchase, coin.Outgoing, 20, mar.Add(time.Hour), "bob", ""),
+			// отклонённый перевод: списание и возврат отправителю.
+			entry(5, coin.Transfer, coin.Outgoing, 30, mar.Add(2*time.Hour), "bob", "carol"),
+			entry(5, coin.Transfer, coin.Incoming, 30, mar.Add(150*time.Minute), "bob", "carol"),
+			reversal,
+			entry(7, coin.Transfer, coin.Outgoing, 5, apr, "bob", "alice"),
+		},
+		purchases: []*merch.Purchase{{TransactionID: 4, MerchName: "cup", Quantity: 1}},
+	}
+	// у проводок возврата и списания одной транзакции разные EntryID.
+	repo.entries[5].EntryID = 51
+	repo.entries[6].EntryID = 61
+	repo.entries[7].EntryID = 71
+
+	svc := NewService(repo).(*service)
+	svc.now = func() time.Time { return apr.Add(time.Hour) }
+	period, err := ParsePeriod("2025-03")
+	require.NoError(t, err)
+
+	st, err := svc.Statement(context.Background(), &auth.User{ID: 2, Username: "bob"}, period)
+	require.NoError(t, err)
+	assert.Equal(t, 900, st.Opening)
+	assert.Equal(t, 90, st.Credits)
+	assert.Equal(t, 50, st.Debits)
+	assert.Equal(t, 940, st.Closing)
+
+	type row struct {
+		id           coin.TransactionID
+		counterparty string
+		item         string
+		balance      int
+	}
+	var rows []row
+	for _, l := range st.Lines {
+		rows = append(rows, row{l.TransactionID, l.Counterparty, l.Item, l.Balance})
+	}
+	assert.Equal(t, []row{
+		{3, "alice", "", 950},
+		{4, "", "cup", 930},
+		{5, "carol", "", 900},
+		{5, "carol", "", 930},
+		{6, "", "cup", 940},
+	}, rows)
+}
+
+func TestStatement_Pages(t *testing.T) {
+	mar := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	repo := &fakeRepo{}
+	for i := range coin.MaxHistoryLimit + 1 {
+		repo.entries = append(repo.entries,
+			entry(int64(i+1), coin.Transfer, coin.Incoming, 1, mar.Add(time.Duration(i)*time.Minute), "alice", "bob"))
+	}
+	svc := NewService(repo).(*service)
+	svc.now = func() time.Time { return mar.AddDate(0, 1, 0) }
+	period, err := ParsePeriod("2025-03")
+	require.NoError(t, err)
+
+	st, err := svc.Statement(context.Background(), &auth.User{ID: 2, Username: "bob"}, period)
+	require.NoError(t, err)
+	assert.Len(t, st.Lines, coin.MaxHistoryLimit+1)
+	assert.Equal(t, coin.MaxHistoryLimit+1, st.Closing)
+	assert.Equal(t, 2, repo.pages)
+
+	svc.now = func() time.Time { return mar.Add(-time.Second) }
+	_, err = svc.Statement(context.Background(), &auth.User{ID: 2, Username: "bob"}, period)
+	assert.ErrorIs(t, err, ErrFuturePeriod)
+}
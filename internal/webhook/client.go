@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// sharedAddressSpace — 100.64.0.0/10 (RFC 6598), адреса провайдерского NAT не считаются публичными.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// NewClient возвращает HTTP клиент для доставок: он соединяется только с публичными адресами
+// и не следует редиректам. Адрес проверяется при соединении, уже после разрешения имени,
+// поэтому подписка не дотянется до внутренних сервисов ни через DNS, ни через редирект.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialPublicOnly}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// прокси из окружения соединялся бы сам, минуя проверку адреса.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		// ответ 3xx считается неудачной попыткой с этим кодом.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// dialPublicOnly отклоняет соединение с непубличным адресом.
+func dialPublicOnly(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !isPublic(addrPort.Addr()) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// isPublic сообщает, что адрес маршрутизируется в интернете: не loopback, не частная сеть,
+// не link-local (в том числе метаданные облака 169.254.169.254) и не служебный диапазон.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
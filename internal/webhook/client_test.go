@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPublic(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::1":   true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"224.0.0.1":            false,
+		"::1":                  false,
+		"fe80::1":              false,
+		"fd00:ec2::254":        false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+	} {
+		assert.Equal(t, public, isPublic(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestClient_RejectsPrivateAddress(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer stub.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, stub.URL, nil)
+	require.NoError(t, err)
+	_, err = NewClient(time.Second).Do(req)
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+	assert.Equal(t, ErrForbiddenAddress, sendError(err))
+}
+
+func TestClient_DoesNotFollowRedirects(t *testing.T) {
+	client := NewClient(time.Second)
+	// транспорт заглушки разрешает loopback, проверяется только политика редиректов.
+	client.Transport = http.DefaultTransport
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	}))
+	defer stub.Close()
+
+	resp, err := client.Get(stub.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+}
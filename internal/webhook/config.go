@@ -0,0 +1,32 @@
+package webhook
+
+import "time"
+
+type Config struct {
+	// DeliveryInterval — как часто проверяются доставки, которым пора выполняться.
+	DeliveryInterval time.Duration `env:"WEBHOOK_DELIVERY_INTERVAL" env-default:"5s"`
+	// Batch — сколько доставок берётся в работу за одну проверку.
+	Batch int `env:"WEBHOOK_BATCH" env-default:"50"`
+	// Workers — сколько доставок выполняется одновременно.
+	Workers int `env:"WEBHOOK_WORKERS" env-default:"8"`
+	// Timeout ограничивает один запрос на адрес подписки.
+	Timeout time.Duration `env:"WEBHOOK_TIMEOUT" env-default:"10s"`
+	// MaxAttempts — после стольких неудачных попыток доставка больше не повторяется.
+	MaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS" env-default:"10"`
+	// RetryBackoff — пауза после первой неудачной попытки, дальше она удваивается до MaxBackoff.
+	RetryBackoff time.Duration `env:"WEBHOOK_RETRY_BACKOFF" env-default:"30s"`
+	MaxBackoff   time.Duration `env:"WEBHOOK_MAX_BACKOFF" env-default:"6h"`
+	// DisableAfter — после стольких неудачных попыток подряд подписка отключается.
+	DisableAfter int `env:"WEBHOOK_DISABLE_AFTER" env-default:"20"`
+	// RelayLockKey — ключ advisory-блокировки, под которой одна реплика раскладывает события outbox по подпискам.
+	RelayLockKey int64 `env:"WEBHOOK_RELAY_LOCK_KEY" env-default:"73010003"`
+}
+
+// backoff возвращает паузу перед следующей попыткой после attempts неудачных.
+func (c *Config) backoff(attempts int) time.Duration {
+	delay := c.RetryBackoff
+	for i := 1; i < attempts && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, c.MaxBackoff)
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/outbox"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// ConsumerName — имя получателя outbox, под которым Dispatcher ведёт свою очередь событий.
+const ConsumerName = "webhooks"
+
+// Dispatcher — приёмник outbox, который раскладывает события по подпискам: создаёт доставки
+// в той же транзакции, в которой relay отмечает события доставленными. Сами запросы
+// отправляет Service.RunDelivery.
+type Dispatcher struct {
+	repo Repository
+}
+
+func NewDispatcher(repo Repository) *Dispatcher {
+	return &Dispatcher{repo: repo}
+}
+
+// target — пользователь, подписки которого получают событие как event.
+type target struct {
+	userID auth.UserID
+	event  Event
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, events []*outbox.Event) error {
+	for _, e := range events {
+		targets, err := targets(e)
+		if err != nil {
+			// повтор не исправит payload, а ошибка остановила бы доставку остальных событий.
+			slog.Error("failed to dispatch outbox event to webhooks", "event_id", e.ID, "error", err)
+			continue
+		}
+		for _, t := range targets {
+			_, err := d.repo.EnqueueDeliveries(ctx, t.userID, &Delivery{
+				EventID:    e.ID,
+				Event:      t.event,
+				Type:       e.Type,
+				Payload:    e.Payload,
+				OccurredAt: e.OccurredAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// targets возвращает, чьим подпискам и как доставляется событие outbox.
+func targets(e *outbox.Event) ([]target, error) {
+	switch e.Type {
+	case outbox.TransferCompleted:
+		var p outbox.Transfer
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return nil, fmt.Errorf("%v: invalid %s payload: %w", Err, e.Type, err)
+		}
+		return []target{
+			{userID: auth.UserID(p.ToUserID), event: CoinsReceived},
+			{userID: auth.UserID(p.FromUserID), event: CoinsSent},
+		}, nil
+	case outbox.PurchaseCompleted:
+		var p outbox.Purchase
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return nil, fmt.Errorf("%v: invalid %s payload: %w", Err, e.Type, err)
+		}
+		return []target{{userID: auth.UserID(p.UserID), event: MerchPurchased}}, nil
+	default:
+		return nil, nil
+	}
+}
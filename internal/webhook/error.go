@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	Err                     = errors.New("webhook")
+	ErrSubscriptionNotFound = fmt.Errorf("%v: subscription not found", Err)
+	ErrInvalidURL           = fmt.Errorf("%v: url must be an absolute http or https URL", Err)
+	ErrInvalidEvents        = fmt.Errorf("%v: at least one event is required", Err)
+	ErrInvalidSecret        = fmt.Errorf("%v: secret must be at least %d characters", Err, MinSecretLength)
+	ErrForbiddenAddress     = fmt.Errorf("%v: endpoint address is not public", Err)
+	ErrDeliveryTimeout      = fmt.Errorf("%v: endpoint did not respond in time", Err)
+	ErrDeliveryFailed       = fmt.Errorf("%v: failed to connect to endpoint", Err)
+)
+
+// ErrUnknownEvent — подписка на неизвестное событие.
+type ErrUnknownEvent struct {
+	event Event
+}
+
+func (e ErrUnknownEvent) Error() string {
+	return fmt.Sprintf("%v: unknown event %q", Err, e.event)
+}
+
+func NewErrUnknownEvent(event Event) error {
+	return ErrUnknownEvent{event: event}
+}
+
+// ErrUnexpectedStatus — адрес подписки ответил не 2xx.
+type ErrUnexpectedStatus struct {
+	Status int
+}
+
+func (e ErrUnexpectedStatus) Error() string {
+	return fmt.Sprintf("%v: endpoint responded with status %d", Err, e.Status)
+}
+
+func NewErrUnexpectedStatus(status int) error {
+	return ErrUnexpectedStatus{Status: status}
+}
@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Service interface {
+	CreateSubscription(ctx context.Context, sub *Subscription) (*Subscription, error)
+	ListSubscriptions(ctx context.Context, user *auth.User) ([]*Subscription, error)
+	UpdateSubscription(ctx context.Context, user *auth.User, id SubscriptionID, upd SubscriptionUpdate) (*Subscription, error)
+	DeleteSubscription(ctx context.Context, user *auth.User, id SubscriptionID) error
+	ListDeliveries(ctx context.Context, user *auth.User, id SubscriptionID, limit int) ([]*Delivery, error)
+	RunDelivery(ctx context.Context)
+}
+
+type AuthHandler interface {
+	Verify(c *fiber.Ctx) error
+}
+
+type Handler struct {
+	svc          Service
+	authHandlers AuthHandler
+}
+
+func NewWebhookHandler(svc Service, authHandler AuthHandler) *Handler {
+	return &Handler{
+		svc:          svc,
+		authHandlers: authHandler,
+	}
+}
+
+func (h *Handler) Init(router fiber.Router) {
+	router.Post("/webhooks", h.authHandlers.Verify, h.createSubscription)
+	router.Get("/webhooks", h.authHandlers.Verify, h.listSubscriptions)
+	router.Patch("/webhooks/:id", h.authHandlers.Verify, h.updateSubscription)
+	router.Delete("/webhooks/:id", h.authHandlers.Verify, h.deleteSubscription)
+	router.Get("/webhooks/:id/deliveries", h.authHandlers.Verify, h.listDeliveries)
+}
+
+type SubscriptionResponse struct {
+	ID     SubscriptionID `json:"id"`
+	URL    string         `json:"url"`
+	Events []Event        `json:"events"`
+	// Secret отдаётся только при создании подписки.
+	Secret              string     `json:"secret,omitempty"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	DisabledAt          *time.Time `json:"disabledAt,omitempty"`
+	CreatedAt           time.Time  `json:"createdAt"`
+}
+
+func newSubscriptionResponse(sub *Subscription) SubscriptionResponse {
+	resp := SubscriptionResponse{
+		ID:                  sub.ID,
+		URL:                 sub.URL,
+		Events:              sub.Events,
+		Active:              sub.Active,
+		ConsecutiveFailures: sub.ConsecutiveFailures,
+		CreatedAt:           sub.CreatedAt,
+	}
+	if !sub.DisabledAt.IsZero() {
+		resp.DisabledAt = &sub.DisabledAt
+	}
+	return resp
+}
+
+type CreateSubscriptionRequest struct {
+	URL    string  `json:"url"`
+	Events []Event `json:"events"`
+	Secret string  `json:"secret"`
+}
+
+type UpdateSubscriptionRequest struct {
+	URL    *string `json:"url"`
+	Events []Event `json:"events"`
+	Active *bool   `json:"active"`
+}
+
+type DeliveryResponse struct {
+	ID             DeliveryID     `json:"id"`
+	EventID        int64          `json:"eventId"`
+	Event          Event          `json:"event"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	ResponseStatus int            `json:"responseStatus,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	NextAttemptAt  *time.Time     `json:"nextAttemptAt,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	DeliveredAt    *time.Time     `json:"deliveredAt,omitempty"`
+}
+
+func (h *Handler) createSubscription(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	var req CreateSubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	sub, err := h.svc.CreateSubscription(ctx, &Subscription{
+		Owner:  user.ID,
+		URL:    req.URL,
+		Events: req.Events,
+		Secret: req.Secret,
+	})
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	resp := newSubscriptionResponse(sub)
+	resp.Secret = sub.Secret
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+func (h *Handler) listSubscriptions(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	subs, err := h.svc.ListSubscriptions(ctx, user)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	resp := make([]SubscriptionResponse, len(subs))
+	for i, sub := range subs {
+		resp[i] = newSubscriptionResponse(sub)
+	}
+	return c.JSON(resp)
+}
+
+func (h *Handler) updateSubscription(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid subscription id",
+		})
+	}
+	var req UpdateSubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": err.Error(),
+		})
+	}
+	sub, err := h.svc.UpdateSubscription(ctx, user, SubscriptionID(id), SubscriptionUpdate{
+		URL:    req.URL,
+		Events: req.Events,
+		Active: req.Active,
+	})
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	return c.JSON(newSubscriptionResponse(sub))
+}
+
+func (h *Handler) deleteSubscription(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid subscription id",
+		})
+	}
+	if err := h.svc.DeleteSubscription(ctx, user, SubscriptionID(id)); err != nil {
+		return subscriptionError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) listDeliveries(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	user, ok := auth.GetUser(ctx)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid user data",
+		})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"errors": "invalid subscription id",
+		})
+	}
+	deliveries, err := h.svc.ListDeliveries(ctx, user, SubscriptionID(id), c.QueryInt("limit", DefaultDeliveriesLimit))
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	resp := make([]DeliveryResponse, len(deliveries))
+	for i, d := range deliveries {
+		resp[i] = DeliveryResponse{
+			ID:             d.ID,
+			EventID:        int64(d.EventID),
+			Event:          d.Event,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			ResponseStatus: d.ResponseStatus,
+			Error:          d.Error,
+			CreatedAt:      d.CreatedAt,
+		}
+		if d.Status == DeliveryPending {
+			resp[i].NextAttemptAt = &d.NextAttemptAt
+		}
+		if !d.DeliveredAt.IsZero() {
+			resp[i].DeliveredAt = &d.DeliveredAt
+		}
+	}
+	return c.JSON(resp)
+}
+
+// subscriptionError отвечает на ошибку работы с подпиской.
+func subscriptionError(c *fiber.Ctx, err error) error {
+	var eventErr ErrUnknownEvent
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrSubscriptionNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, ErrInvalidURL), errors.Is(err, ErrForbiddenAddress), errors.Is(err, ErrInvalidEvents),
+		errors.Is(err, ErrInvalidSecret), errors.As(err, &eventErr):
+		status = fiber.StatusBadRequest
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"errors": err.Error(),
+	})
+}
@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/outbox"
+	"encoding/json"
+	"time"
+)
+
+type SubscriptionID int64
+
+// Event — событие, на которое подписывается пользователь. События относятся к самому владельцу подписки.
+type Event string
+
+const (
+	// CoinsReceived — владельцу пришли монеты.
+	CoinsReceived Event = "coins.received"
+	// CoinsSent — владелец перевёл монеты.
+	CoinsSent Event = "coins.sent"
+	// MerchPurchased — владелец купил мерч.
+	MerchPurchased Event = "merch.purchased"
+)
+
+func (e Event) Valid() bool {
+	switch e {
+	case CoinsReceived, CoinsSent, MerchPurchased:
+		return true
+	default:
+		return false
+	}
+}
+
+const (
+	// MinSecretLength — минимальная длина секрета подписи, заданного пользователем.
+	MinSecretLength = 16
+	// DefaultDeliveriesLimit и MaxDeliveriesLimit ограничивают журнал доставок в одном ответе.
+	DefaultDeliveriesLimit = 50
+	MaxDeliveriesLimit     = 200
+)
+
+// Subscription — HTTP-адрес пользователя, на который доставляются выбранные события.
+type Subscription struct {
+	ID     SubscriptionID
+	Owner  auth.UserID
+	URL    string
+	Events []Event
+	// Secret — ключ HMAC-подписи запросов, показывается только при создании.
+	Secret string
+	// Active — подписка получает события. Подписка отключается сама после DisableAfter
+	// неудачных попыток подряд и включается снова через SubscriptionUpdate.
+	Active              bool
+	ConsecutiveFailures int
+	// DisabledAt — когда подписка отключилась сама, нулевое — не отключалась.
+	DisabledAt time.Time
+	CreatedAt  time.Time
+}
+
+// SubscriptionUpdate — изменение подписки, nil-поля не меняются.
+type SubscriptionUpdate struct {
+	URL    *string
+	Events []Event
+	Active *bool
+}
+
+type DeliveryID int64
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed — попытки исчерпаны, доставка больше не повторяется.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Delivery — доставка одного события одной подписке вместе с итогом последней попытки.
+type Delivery struct {
+	ID DeliveryID
+	// Subscription у доставки, взятой в работу, содержит адрес и секрет, в журнале — только ID.
+	Subscription   *Subscription
+	EventID        outbox.EventID
+	Event          Event
+	Type           outbox.EventType
+	Payload        json.RawMessage
+	OccurredAt     time.Time
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	ResponseStatus int
+	Error          string
+	CreatedAt      time.Time
+	DeliveredAt    time.Time
+}
+
+// Message — тело запроса на адрес подписки.
+type Message struct {
+	// ID — идентификатор доставки, по нему получатель отбрасывает повторы.
+	ID         DeliveryID       `json:"id"`
+	Event      Event            `json:"event"`
+	Type       outbox.EventType `json:"type"`
+	OccurredAt time.Time        `json:"occurredAt"`
+	Data       json.RawMessage  `json:"data"`
+}
@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"time"
+)
+
+type Repository interface {
+	CreateSubscription(ctx context.Context, s *Subscription) (*Subscription, error)
+	// GetSubscription возвращает подписку или ErrSubscriptionNotFound.
+	GetSubscription(ctx context.Context, id SubscriptionID) (*Subscription, error)
+	ListSubscriptions(ctx context.Context, userID auth.UserID) ([]*Subscription, error)
+	// UpdateSubscription сохраняет адрес, события и активность подписки. Включение подписки
+	// сбрасывает счётчик неудач.
+	UpdateSubscription(ctx context.Context, s *Subscription) (*Subscription, error)
+	// DeleteSubscription удаляет подписку вместе с журналом доставок.
+	DeleteSubscription(ctx context.Context, id SubscriptionID) error
+	// ListDeliveries возвращает последние limit доставок подписки, новые первыми.
+	ListDeliveries(ctx context.Context, id SubscriptionID, limit int) ([]*Delivery, error)
+	// EnqueueDeliveries создаёт доставку d каждой активной подписке пользователя на d.Event
+	// и возвращает их число. Повторная постановка того же события пропускается.
+	EnqueueDeliveries(ctx context.Context, userID auth.UserID, d *Delivery) (int, error)
+	// ClaimDeliveries берёт в работу до limit доставок активных подписок, которым пора выполняться к now,
+	// откладывая их следующую попытку до leaseUntil: если попытка не завершится, доставку повторят.
+	ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*Delivery, error)
+	// SaveAttempt сохраняет итог попытки доставки и счётчик неудач подписки подряд.
+	// Подписка отключается, когда счётчик достигает disableAfter; возвращает true, если она отключена этой попыткой.
+	SaveAttempt(ctx context.Context, d *Delivery, disableAfter int) (bool, error)
+}
@@ -0,0 +1,287 @@
+package webhook
+
+import (
+	"avito-intern/internal/auth"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// secretBytes — длина случайного секрета, если пользователь не задал свой.
+const secretBytes = 32
+
+type service struct {
+	cfg    *Config
+	repo   Repository
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewService(cfg *Config, repo Repository, client *http.Client) Service {
+	return &service{
+		cfg:    cfg,
+		repo:   repo,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// CreateSubscription проверяет адрес и события и сохраняет подписку. Если секрет не задан, он генерируется.
+func (s *service) CreateSubscription(ctx context.Context, sub *Subscription) (*Subscription, error) {
+	if err := validateURL(sub.URL); err != nil {
+		return nil, err
+	}
+	if err := validateEvents(sub.Events); err != nil {
+		return nil, err
+	}
+	sub.Events = normalizeEvents(sub.Events)
+	if sub.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, err
+		}
+		sub.Secret = secret
+	} else if len(sub.Secret) < MinSecretLength {
+		return nil, ErrInvalidSecret
+	}
+	sub.Active = true
+	return s.repo.CreateSubscription(ctx, sub)
+}
+
+func (s *service) ListSubscriptions(ctx context.Context, user *auth.User) ([]*Subscription, error) {
+	return s.repo.ListSubscriptions(ctx, user.ID)
+}
+
+// UpdateSubscription меняет адрес, события или включает и выключает подписку.
+func (s *service) UpdateSubscription(ctx context.Context, user *auth.User, id SubscriptionID, upd SubscriptionUpdate) (*Subscription, error) {
+	sub, err := s.getOwned(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+	if upd.URL != nil {
+		if err := validateURL(*upd.URL); err != nil {
+			return nil, err
+		}
+		sub.URL = *upd.URL
+	}
+	if upd.Events != nil {
+		if err := validateEvents(upd.Events); err != nil {
+			return nil, err
+		}
+		sub.Events = normalizeEvents(upd.Events)
+	}
+	if upd.Active != nil {
+		sub.Active = *upd.Active
+	}
+	return s.repo.UpdateSubscription(ctx, sub)
+}
+
+func (s *service) DeleteSubscription(ctx context.Context, user *auth.User, id SubscriptionID) error {
+	if _, err := s.getOwned(ctx, user, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteSubscription(ctx, id)
+}
+
+// ListDeliveries возвращает журнал доставок подписки, новые первыми.
+func (s *service) ListDeliveries(ctx context.Context, user *auth.User, id SubscriptionID, limit int) ([]*Delivery, error) {
+	if _, err := s.getOwned(ctx, user, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultDeliveriesLimit
+	}
+	return s.repo.ListDeliveries(ctx, id, min(limit, MaxDeliveriesLimit))
+}
+
+// getOwned возвращает подписку пользователя. Чужая подписка не отличается от несуществующей.
+func (s *service) getOwned(ctx context.Context, user *auth.User, id SubscriptionID) (*Subscription, error) {
+	sub, err := s.repo.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Owner != user.ID {
+		return nil, ErrSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+func (s *service) RunDelivery(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.DeliveryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// пока берутся полные пачки, очередь не пуста и ждать следующего тика незачем.
+			for {
+				claimed, err := s.DeliverDue(ctx)
+				if err != nil {
+					slog.Error("failed to deliver webhooks", "error", err)
+				}
+				if err != nil || claimed < s.cfg.Batch {
+					break
+				}
+			}
+		}
+	}
+}
+
+// DeliverDue берёт в работу доставки, которым пора выполняться, отправляет их по Workers одновременно
+// и возвращает, сколько доставок взято. Доставка берётся на время двух таймаутов запроса:
+// если реплика упадёт посреди попытки, доставку повторит другая.
+func (s *service) DeliverDue(ctx context.Context) (int, error) {
+	now := s.now()
+	deliveries, err := s.repo.ClaimDeliveries(ctx, now, now.Add(2*s.cfg.Timeout), s.cfg.Batch)
+	if err != nil {
+		return 0, err
+	}
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(max(s.cfg.Workers, 1))
+	for _, d := range deliveries {
+		g.Go(func() error {
+			return s.deliver(gCtx, d)
+		})
+	}
+	return len(deliveries), g.Wait()
+}
+
+// deliver выполняет одну попытку доставки и сохраняет её итог.
+func (s *service) deliver(ctx context.Context, d *Delivery) error {
+	status, sendErr := s.send(ctx, d)
+	now := s.now()
+	d.Attempts++
+	d.ResponseStatus = status
+	switch {
+	case sendErr == nil:
+		d.Status = DeliveryDelivered
+		d.Error = ""
+		d.DeliveredAt = now
+	case d.Attempts >= s.cfg.MaxAttempts:
+		d.Status = DeliveryFailed
+		d.Error = sendErr.Error()
+	default:
+		d.Status = DeliveryPending
+		d.Error = sendErr.Error()
+		d.NextAttemptAt = now.Add(s.cfg.backoff(d.Attempts))
+	}
+	disabled, err := s.repo.SaveAttempt(ctx, d, s.cfg.DisableAfter)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery %d attempt: %w", d.ID, err)
+	}
+	if disabled {
+		slog.Warn("webhook subscription disabled after consecutive failures",
+			"subscription_id", d.Subscription.ID, "url", d.Subscription.URL)
+	}
+	return nil
+}
+
+// send отправляет подписанный запрос и возвращает код ответа, 0 — ответа не было.
+func (s *service) send(ctx context.Context, d *Delivery) (int, error) {
+	body, err := json.Marshal(Message{
+		ID:         d.ID,
+		Event:      d.Event,
+		Type:       d.Type,
+		OccurredAt: d.OccurredAt,
+		Data:       d.Payload,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%v: failed to marshal delivery: %w", Err, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("%v: failed to create request: %w", Err, err)
+	}
+	timestamp := s.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, strconv.FormatInt(int64(d.ID), 10))
+	req.Header.Set(HeaderEvent, string(d.Event))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(d.Subscription.Secret, timestamp, body))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// журнал доставок видит владелец подписки, поэтому в него попадает только класс ошибки:
+		// текст ошибки соединения выдал бы, что находится по адресу во внутренней сети.
+		slog.Warn("webhook delivery failed", "delivery_id", d.ID, "subscription_id", d.Subscription.ID, "error", err)
+		return 0, sendError(err)
+	}
+	defer resp.Body.Close()
+	// тело не нужно, но дочитанный ответ позволяет переиспользовать соединение.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, NewErrUnexpectedStatus(resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	// адрес-IP отклоняется сразу, имя проверяет клиент доставки при соединении.
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); (err == nil && !isPublic(addr)) || strings.EqualFold(host, "localhost") {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// sendError сводит ошибку запроса к одной из ошибок, которые можно показать владельцу подписки.
+func sendError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrForbiddenAddress):
+		return ErrForbiddenAddress
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrDeliveryTimeout
+	default:
+		return ErrDeliveryFailed
+	}
+}
+
+func validateEvents(events []Event) error {
+	if len(events) == 0 {
+		return ErrInvalidEvents
+	}
+	for _, e := range events {
+		if !e.Valid() {
+			return NewErrUnknownEvent(e)
+		}
+	}
+	return nil
+}
+
+// normalizeEvents убирает повторы и упорядочивает события подписки.
+func normalizeEvents(events []Event) []Event {
+	res := slices.Clone(events)
+	slices.Sort(res)
+	return slices.Compact(res)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("%v: failed to generate secret: %w", Err, err)
+	}
+	return hex.EncodeToString(b), nil
+}
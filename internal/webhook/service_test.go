@@ -0,0 +1,429 @@
+package webhook
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/outbox"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRepo — потокобезопасная реализация Repository в памяти.
+type memoryRepo struct {
+	mu         sync.Mutex
+	subs       map[SubscriptionID]*Subscription
+	deliveries []*Delivery
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{subs: make(map[SubscriptionID]*Subscription)}
+}
+
+func (m *memoryRepo) CreateSubscription(_ context.Context, s *Subscription) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *s
+	saved.ID = SubscriptionID(len(m.subs) + 1)
+	m.subs[saved.ID] = &saved
+	return &saved, nil
+}
+
+func (m *memoryRepo) GetSubscription(_ context.Context, id SubscriptionID) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.subs[id]
+	if !ok {
+		return nil, ErrSubscriptionNotFound
+	}
+	saved := *s
+	return &saved, nil
+}
+
+func (m *memoryRepo) ListSubscriptions(_ context.Context, userID auth.UserID) ([]*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []*Subscription
+	for _, s := range m.subs {
+		if s.Owner == userID {
+			saved := *s
+			res = append(res, &saved)
+		}
+	}
+	return res, nil
+}
+
+func (m *memoryRepo) UpdateSubscription(_ context.Context, s *Subscription) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.subs[s.ID]
+	if !ok {
+		return nil, ErrSubscriptionNotFound
+	}
+	saved := *s
+	if saved.Active {
+		if !old.Active {
+			saved.ConsecutiveFailures = 0
+		}
+		saved.DisabledAt = time.Time{}
+	}
+	m.subs[s.ID] = &saved
+	return &saved, nil
+}
+
+func (m *memoryRepo) DeleteSubscription(_ context.Context, id SubscriptionID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.subs, id)
+	return nil
+}
+
+func (m *memoryRepo) ListDeliveries(_ context.Context, id SubscriptionID, limit int) ([]*Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []*Delivery
+	for _, d := range slices.Backward(m.deliveries) {
+		if d.Subscription.ID == id && len(res) < limit {
+			saved := *d
+			res = append(res, &saved)
+		}
+	}
+	return res, nil
+}
+
+func (m *memoryRepo) EnqueueDeliveries(_ context.Context, userID auth.UserID, d *Delivery) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id := SubscriptionID(1); id <= SubscriptionID(len(m.subs)); id++ {
+		s, ok := m.subs[id]
+		if !ok || s.Owner != userID || !s.Active || !slices.Contains(s.Events, d.Event) {
+			continue
+		}
+		exists := slices.ContainsFunc(m.deliveries, func(e *Delivery) bool {
+			return e.Subscription.ID == id && e.EventID == d.EventID && e.Event == d.Event
+		})
+		if exists {
+			continue
+		}
+		saved := *d
+		saved.ID = DeliveryID(len(m.deliveries) + 1)
+		saved.Subscription = s
+		saved.Status = DeliveryPending
+		m.deliveries = append(m.deliveries, &saved)
+		n++
+	}
+	return n, nil
+}
+
+func (m *memoryRepo) ClaimDeliveries(_ context.Context, now, leaseUntil time.Time, limit int) ([]*Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []*Delivery
+	for _, d := range m.deliveries {
+		if len(res) == limit {
+			break
+		}
+		if d.Status != DeliveryPending || d.NextAttemptAt.After(now) || !m.subs[d.Subscription.ID].Active {
+			continue
+		}
+		d.NextAttemptAt = leaseUntil
+		claimed := *d
+		res = append(res, &claimed)
+	}
+	return res, nil
+}
+
+func (m *memoryRepo) SaveAttempt(_ context.Context, d *Delivery, disableAfter int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *d
+	m.deliveries[d.ID-1] = &saved
+	s := m.subs[d.Subscription.ID]
+	if d.Status == DeliveryDelivered {
+		s.ConsecutiveFailures = 0
+		return false, nil
+	}
+	s.ConsecutiveFailures++
+	if s.Active && s.ConsecutiveFailures >= disableAfter {
+		s.Active = false
+		s.DisabledAt = time.Now()
+		return true, nil
+	}
+	return false, nil
+}
+
+func testConfig() *Config {
+	return &Config{
+		Batch:        10,
+		Workers:      2,
+		Timeout:      time.Second,
+		MaxAttempts:  3,
+		RetryBackoff: time.Minute,
+		MaxBackoff:   3 * time.Minute,
+		DisableAfter: 5,
+	}
+}
+
+func TestCreateSubscription(t *testing.T) {
+	svc := NewService(testConfig(), newMemoryRepo(), http.DefaultClient)
+	ctx := context.Background()
+	bob := &auth.User{ID: 2, Username: "bob"}
+
+	sub, err := svc.CreateSubscription(ctx, &Subscription{
+		Owner:  bob.ID,
+		URL:    "https://example.com/hook",
+		Events: []Event{MerchPurchased, CoinsReceived, MerchPurchased},
+	})
+	require.NoError(t, err)
+	assert.True(t, sub.Active)
+	assert.Equal(t, []Event{CoinsReceived, MerchPurchased}, sub.Events)
+	assert.Len(t, sub.Secret, 2*secretBytes)
+
+	for _, tc := range []struct {
+		name string
+		sub  *Subscription
+		err  error
+	}{
+		{"no scheme", &Subscription{URL: "example.com/hook", Events: []Event{CoinsSent}}, ErrInvalidURL},
+		{"ftp", &Subscription{URL: "ftp://example.com", Events: []Event{CoinsSent}}, ErrInvalidURL},
+		{"loopback", &Subscription{URL: "http://127.0.0.1:5432", Events: []Event{CoinsSent}}, ErrForbiddenAddress},
+		{"localhost", &Subscription{URL: "http://localhost:8080/hook", Events: []Event{CoinsSent}}, ErrForbiddenAddress},
+		{"metadata", &Subscription{URL: "http://169.254.169.254/latest", Events: []Event{CoinsSent}}, ErrForbiddenAddress},
+		{"private", &Subscription{URL: "https://10.0.0.5/hook", Events: []Event{CoinsSent}}, ErrForbiddenAddress},
+		{"ipv6 loopback", &Subscription{URL: "http://[::1]/hook", Events: []Event{CoinsSent}}, ErrForbiddenAddress},
+		{"no events", &Subscription{URL: "https://example.com"}, ErrInvalidEvents},
+		{"unknown event", &Subscription{URL: "https://example.com", Events: []Event{"coins.burned"}}, NewErrUnknownEvent("coins.burned")},
+		{"short secret", &Subscription{URL: "https://example.com", Events: []Event{CoinsSent}, Secret: "short"}, ErrInvalidSecret},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.CreateSubscription(ctx, tc.sub)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestSubscriptionOwnership(t *testing.T) {
+	svc := NewService(testConfig(), newMemoryRepo(), http.DefaultClient)
+	ctx := context.Background()
+	alice, bob := &auth.User{ID: 1, Username: "alice"}, &auth.User{ID: 2, Username: "bob"}
+
+	sub, err := svc.CreateSubscription(ctx, &Subscription{Owner: bob.ID, URL: "https://example.com", Events: []Event{CoinsSent}})
+	require.NoError(t, err)
+
+	active := false
+	_, err = svc.UpdateSubscription(ctx, alice, sub.ID, SubscriptionUpdate{Active: &active})
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+	_, err = svc.ListDeliveries(ctx, alice, sub.ID, 0)
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+	assert.ErrorIs(t, svc.DeleteSubscription(ctx, alice, sub.ID), ErrSubscriptionNotFound)
+
+	updated, err := svc.UpdateSubscription(ctx, bob, sub.ID, SubscriptionUpdate{Active: &active})
+	require.NoError(t, err)
+	assert.False(t, updated.Active)
+	assert.NoError(t, svc.DeleteSubscription(ctx, bob, sub.ID))
+}
+
+func TestDispatcher(t *testing.T) {
+	repo := newMemoryRepo()
+	ctx := context.Background()
+	for _, sub := range []*Subscription{
+		{Owner: 1, URL: "https://alice.example.com", Events: []Event{CoinsSent, MerchPurchased}, Active: true},
+		{Owner: 2, URL: "https://bob.example.com", Events: []Event{CoinsReceived}, Active: true},
+		{Owner: 2, URL: "https://bob.example.com/old", Events: []Event{CoinsReceived}},
+	} {
+		_, err := repo.CreateSubscription(ctx, sub)
+		require.NoError(t, err)
+	}
+	transfer, err := outbox.NewEvent(outbox.TransferCompleted, outbox.Transfer{FromUserID: 1, ToUserID: 2, Amount: 10})
+	require.NoError(t, err)
+	transfer.ID = 1
+	purchase, err := outbox.NewEvent(outbox.PurchaseCompleted, outbox.Purchase{UserID: 1, Item: "cup"})
+	require.NoError(t, err)
+	purchase.ID = 2
+	registered, err := outbox.NewEvent(outbox.UserRegistered, outbox.User{UserID: 2})
+	require.NoError(t, err)
+	registered.ID = 3
+	broken := &outbox.Event{ID: 4, Type: outbox.PurchaseCompleted, Payload: []byte(`[`)}
+
+	d := NewDispatcher(repo)
+	events := []*outbox.Event{transfer, purchase, registered, broken}
+	require.NoError(t, d.Publish(ctx, events))
+	// повторная публикация пачки не дублирует доставки.
+	require.NoError(t, d.Publish(ctx, events))
+
+	type key struct {
+		sub   SubscriptionID
+		event Event
+		id    outbox.EventID
+	}
+	var got []key
+	for _, d := range repo.deliveries {
+		got = append(got, key{d.Subscription.ID, d.Event, d.EventID})
+	}
+	assert.Equal(t, []key{
+		{2, CoinsReceived, 1},
+		{1, CoinsSent, 1},
+		{1, MerchPurchased, 2},
+	}, got)
+}
+
+func TestDeliverDue(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		status   = http.StatusOK
+		received []*http.Request
+		bodies   [][]byte
+	)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer stub.Close()
+
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	repo := newMemoryRepo()
+	svc := NewService(testConfig(), repo, stub.Client()).(*service)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+	bob := &auth.User{ID: 2, Username: "bob"}
+
+	// адрес заглушки — loopback, поэтому подписка сохраняется в обход проверки адреса.
+	sub, err := repo.CreateSubscription(ctx, &Subscription{
+		Owner: bob.ID, URL: stub.URL, Events: []Event{CoinsReceived}, Secret: "0123456789abcdef", Active: true,
+	})
+	require.NoError(t, err)
+	_, err = repo.EnqueueDeliveries(ctx, bob.ID, &Delivery{
+		EventID: 7, Event: CoinsReceived, Type: outbox.TransferCompleted,
+		Payload: []byte(`{"amount":10}`), OccurredAt: now,
+	})
+	require.NoError(t, err)
+
+	claimed, err := svc.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+	require.Len(t, received, 1)
+	r := received[0]
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, now.Unix(), timestamp)
+	assert.True(t, Verify(sub.Secret, timestamp, bodies[0], r.Header.Get(HeaderSignature)))
+	assert.False(t, Verify("another-secret-value", timestamp, bodies[0], r.Header.Get(HeaderSignature)))
+	assert.Equal(t, string(CoinsReceived), r.Header.Get(HeaderEvent))
+	assert.Equal(t, "1", r.Header.Get(HeaderID))
+	var msg Message
+	require.NoError(t, json.Unmarshal(bodies[0], &msg))
+	assert.Equal(t, CoinsReceived, msg.Event)
+	assert.JSONEq(t, `{"amount":10}`, string(msg.Data))
+
+	deliveries, err := svc.ListDeliveries(ctx, bob, sub.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, DeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, http.StatusOK, deliveries[0].ResponseStatus)
+
+	// доставленное событие больше не отправляется.
+	claimed, err = svc.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+}
+
+func TestDeliverDue_Retry(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer stub.Close()
+
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	repo := newMemoryRepo()
+	cfg := testConfig()
+	cfg.DisableAfter = 3
+	svc := NewService(cfg, repo, stub.Client()).(*service)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+	bob := &auth.User{ID: 2, Username: "bob"}
+
+	sub, err := repo.CreateSubscription(ctx, &Subscription{Owner: bob.ID, URL: stub.URL, Events: []Event{CoinsReceived}, Active: true})
+	require.NoError(t, err)
+	_, err = repo.EnqueueDeliveries(ctx, bob.ID, &Delivery{EventID: 7, Event: CoinsReceived, Payload: []byte(`{}`)})
+	require.NoError(t, err)
+
+	// попытки идут через 1, 2 минуты, третья неудачная — последняя.
+	for _, delay := range []time.Duration{time.Minute, 2 * time.Minute, 0} {
+		claimed, err := svc.DeliverDue(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, claimed)
+		d := repo.deliveries[0]
+		assert.Equal(t, http.StatusServiceUnavailable, d.ResponseStatus)
+		assert.Equal(t, NewErrUnexpectedStatus(http.StatusServiceUnavailable).Error(), d.Error)
+		if delay == 0 {
+			assert.Equal(t, DeliveryFailed, d.Status)
+			break
+		}
+		assert.Equal(t, DeliveryPending, d.Status)
+		assert.Equal(t, now.Add(delay), d.NextAttemptAt)
+
+		// до следующей попытки доставка не берётся.
+		claimed, err = svc.DeliverDue(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, claimed)
+		now = d.NextAttemptAt
+	}
+
+	// три неудачи подряд отключили подписку.
+	subs, err := svc.ListSubscriptions(ctx, bob)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.False(t, subs[0].Active)
+	assert.Equal(t, 3, subs[0].ConsecutiveFailures)
+
+	active := true
+	enabled, err := svc.UpdateSubscription(ctx, bob, sub.ID, SubscriptionUpdate{Active: &active})
+	require.NoError(t, err)
+	assert.True(t, enabled.Active)
+	assert.Zero(t, enabled.ConsecutiveFailures)
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := &Config{RetryBackoff: 30 * time.Second, MaxBackoff: 6 * time.Hour}
+	assert.Equal(t, 30*time.Second, cfg.backoff(1))
+	assert.Equal(t, time.Minute, cfg.backoff(2))
+	assert.Equal(t, 4*time.Minute, cfg.backoff(4))
+	assert.Equal(t, 6*time.Hour, cfg.backoff(20))
+	assert.Equal(t, 6*time.Hour, cfg.backoff(1000))
+}
+
+func TestDeliverDue_HidesConnectionError(t *testing.T) {
+	stub := httptest.NewServer(http.NotFoundHandler())
+	addr := stub.URL
+	stub.Close()
+
+	repo := newMemoryRepo()
+	svc := NewService(testConfig(), repo, http.DefaultClient).(*service)
+	ctx := context.Background()
+	bob := &auth.User{ID: 2, Username: "bob"}
+	_, err := repo.CreateSubscription(ctx, &Subscription{Owner: bob.ID, URL: addr, Events: []Event{CoinsReceived}, Active: true})
+	require.NoError(t, err)
+	_, err = repo.EnqueueDeliveries(ctx, bob.ID, &Delivery{EventID: 7, Event: CoinsReceived, Payload: []byte(`{}`)})
+	require.NoError(t, err)
+
+	_, err = svc.DeliverDue(ctx)
+	require.NoError(t, err)
+	// в журнал владельца не попадает текст ошибки соединения с адресом и портом.
+	d := repo.deliveries[0]
+	assert.Equal(t, ErrDeliveryFailed.Error(), d.Error)
+	assert.Zero(t, d.ResponseStatus)
+}
@@ -0,0 +1,33 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	// HeaderSignature — подпись запроса: "sha256=" и HMAC-SHA256 в hex от "<timestamp>.<тело>".
+	HeaderSignature = "X-Webhook-Signature"
+	// HeaderTimestamp — время отправки в секундах Unix, входит в подпись, чтобы старый запрос нельзя было повторить.
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-Id"
+
+	signaturePrefix = "sha256="
+)
+
+// Sign возвращает значение HeaderSignature для тела запроса, отправленного в timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись запроса за постоянное время, получатели могут использовать её как образец.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_GetSet(t *testing.T) {
+	c := New[int, string](2, time.Minute)
+
+	c.Set(1, "one")
+	v, ok := c.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, "one", v)
+
+	c.Set(1, "uno")
+	v, _ = c.Get(1)
+	assert.Equal(t, "uno", v)
+
+	c.Delete(1)
+	_, ok = c.Get(1)
+	assert.False(t, ok)
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[int, string](2, time.Minute)
+
+	c.Set(1, "one")
+	c.Set(2, "two")
+	c.Get(1)
+	c.Set(3, "three")
+
+	_, ok := c.Get(2)
+	assert.False(t, ok, "2 использовался давнее всех")
+	_, ok = c.Get(1)
+	assert.True(t, ok)
+	_, ok = c.Get(3)
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCache_Expires(t *testing.T) {
+	now := time.Now()
+	c := New[int, string](2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set(1, "one")
+	now = now.Add(time.Minute)
+	_, ok := c.Get(1)
+	assert.False(t, ok)
+	assert.Zero(t, c.Len())
+}
+
+func TestCache_Disabled(t *testing.T) {
+	c := New[int, string](0, time.Minute)
+	c.Set(1, "one")
+	_, ok := c.Get(1)
+	assert.False(t, ok)
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	c := New[int, int](16, time.Minute)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				c.Set(i%32, i)
+				c.Get((i + w) % 32)
+				if i%10 == 0 {
+					c.Delete(i % 32)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, c.Len(), 16)
+}
+
+func BenchmarkCache_Get(b *testing.B) {
+	c := New[string, int](1024, time.Minute)
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		c.Set(keys[i], i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(keys[i%len(keys)])
+			i++
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule — выражение не является расписанием cron.
+var ErrInvalidSchedule = errors.New("cron: invalid schedule")
+
+// Schedule — расписание из пяти полей cron: минута, час, день месяца, месяц, день недели.
+// Время считается в UTC.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// если ограничены и день месяца, и день недели, достаточно совпадения одного из них, как в cron.
+	domStar, dowStar bool
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type bounds struct {
+	min, max int
+}
+
+var (
+	minutes  = bounds{0, 59}
+	hours    = bounds{0, 23}
+	days     = bounds{1, 31}
+	months   = bounds{1, 12}
+	weekdays = bounds{0, 7}
+)
+
+// Parse разбирает выражение вида "0 0 1 * *" или макрос @monthly, @weekly, @daily, @hourly.
+// Поле — это *, число, диапазон a-b или их список через запятую, с шагом /n.
+// Воскресенье — 0 или 7.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("%w %q: expected 5 fields", ErrInvalidSchedule, expr)
+	}
+
+	var s Schedule
+	var err error
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return Schedule{}, err
+	}
+	if s.hour, err = parseField(fields[1], hours); err != nil {
+		return Schedule{}, err
+	}
+	if s.dom, err = parseField(fields[2], days); err != nil {
+		return Schedule{}, err
+	}
+	if s.month, err = parseField(fields[3], months); err != nil {
+		return Schedule{}, err
+	}
+	if s.dow, err = parseField(fields[4], weekdays); err != nil {
+		return Schedule{}, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepRaw, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepRaw)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%w: bad step in %q", ErrInvalidSchedule, field)
+			}
+			step = n
+		}
+
+		lo, hi := b.min, b.max
+		if rng != "*" {
+			loRaw, hiRaw, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loRaw); err != nil {
+				return 0, fmt.Errorf("%w: bad value in %q", ErrInvalidSchedule, field)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiRaw); err != nil {
+					return 0, fmt.Errorf("%w: bad value in %q", ErrInvalidSchedule, field)
+				}
+			} else if hasStep {
+				hi = b.max
+			}
+		}
+		if lo < b.min || hi > b.max || lo > hi {
+			return 0, fmt.Errorf("%w: %q out of range %d-%d", ErrInvalidSchedule, field, b.min, b.max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// searchYears — на сколько лет вперёд ищется следующий запуск. Расписание вроде "0 0 30 2 *"
+// не срабатывает никогда, и Next для него вернёт нулевое время.
+const searchYears = 5
+
+// Next возвращает первое время запуска строго после t, нулевое — если запусков нет.
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + searchYears
+
+	for t.Year() <= limit {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<v) != 0
+}
@@ -0,0 +1,54 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+		return v
+	}
+
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"@monthly", "2025-03-13T10:00:00Z", "2025-04-01T00:00:00Z"},
+		{"@monthly", "2025-12-31T23:59:00Z", "2026-01-01T00:00:00Z"},
+		{"0 9 1 * *", "2025-03-01T09:00:00Z", "2025-04-01T09:00:00Z"},
+		{"*/15 * * * *", "2025-03-13T10:07:30Z", "2025-03-13T10:15:00Z"},
+		{"30 18 * * 1-5", "2025-03-14T19:00:00Z", "2025-03-17T18:30:00Z"},
+		{"0 0 * * 7", "2025-03-13T00:00:00Z", "2025-03-16T00:00:00Z"},
+		{"0 0 29 2 *", "2025-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		// день месяца или день недели, если ограничены оба.
+		{"0 0 1 * 1", "2025-03-01T00:00:00Z", "2025-03-03T00:00:00Z"},
+		{"0 12 1,15 * *", "2025-03-01T12:00:00Z", "2025-03-15T12:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr+" after "+tt.from, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, at(tt.want), s.Next(at(tt.from)))
+		})
+	}
+}
+
+func TestNext_Never(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	require.NoError(t, err)
+	assert.True(t, s.Next(time.Now()).IsZero())
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@never"} {
+		_, err := Parse(expr)
+		assert.ErrorIs(t, err, ErrInvalidSchedule, expr)
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"fmt"
+	"time"
 )
 
 // Config содержит конфигурацию подключения к базе данных.
@@ -11,6 +12,15 @@ type Config struct {
 	Host     string `yaml:"host" env:"POSTGRES_HOST"`
 	Port     int    `yaml:"port" env:"POSTGRES_PORT"`
 	DB       string `yaml:"db" env:"POSTGRES_DB"`
+
+	// TxIsolation — уровень изоляции транзакций по умолчанию.
+	TxIsolation string `yaml:"tx_isolation" env:"POSTGRES_TX_ISOLATION" env-default:"read committed"`
+	// TxMaxRetries — сколько раз повторять транзакцию после deadlock или serialization failure.
+	TxMaxRetries int `yaml:"tx_max_retries" env:"POSTGRES_TX_MAX_RETRIES" env-default:"5"`
+	// TxRetryBackoff — начальная задержка перед повтором, удваивается с каждой попыткой.
+	TxRetryBackoff time.Duration `yaml:"tx_retry_backoff" env:"POSTGRES_TX_RETRY_BACKOFF" env-default:"5ms"`
+	// TxMaxRetryBackoff — верхняя граница задержки между повторами.
+	TxMaxRetryBackoff time.Duration `yaml:"tx_max_retry_backoff" env:"POSTGRES_TX_MAX_RETRY_BACKOFF" env-default:"200ms"`
 }
 
 func createDsn(cfg Config) string {
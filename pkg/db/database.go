@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
@@ -14,10 +15,13 @@ import (
 
 type txKey struct{}
 
+type afterCommitKey struct{}
+
 // Database обертка для работы с pgxpool.Pool.
 type Database struct {
 	cfg     Config
 	cluster *pgxpool.Pool
+	txOpts  TxOptions
 }
 
 func (db *Database) GetSQLConn() *sql.DB {
@@ -47,7 +51,7 @@ func (db *Database) putTx(ctx context.Context, tx pgx.Tx) context.Context {
 }
 
 func newDatabase(cluster *pgxpool.Pool, cfg Config) *Database {
-	return &Database{cluster: cluster, cfg: cfg}
+	return &Database{cluster: cluster, cfg: cfg, txOpts: txOptionsFromConfig(cfg)}
 }
 
 // GetPool возвращает пул соединений к базе данных.
@@ -75,18 +79,55 @@ func (db *Database) ExecQueryRow(ctx context.Context, query string, args ...inte
 	return db.getConn(ctx).QueryRow(ctx, query, args...)
 }
 
+// RunInTransaction выполняет fn в транзакции, переданной через контекст.
+// Уровень изоляции и политика повторов берутся из Config.
 func (db *Database) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
-	tx, err := db.cluster.BeginTx(ctx, pgx.TxOptions{})
+	return db.RunInTransactionWithOptions(ctx, db.txOpts, fn)
+}
+
+// RunInTransactionWithOptions выполняет fn в транзакции с заданным уровнем изоляции.
+// Если в контексте уже есть открытая транзакция, fn выполняется в ней,
+// а фиксацию, откат и повторы выполняет внешний вызов.
+// При deadlock и serialization failure транзакция целиком повторяется,
+// поэтому fn не должна иметь побочных эффектов вне БД.
+func (db *Database) RunInTransactionWithOptions(ctx context.Context, opts TxOptions, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+	return retry(ctx, opts, func() error {
+		return db.runInTransaction(ctx, opts.IsoLevel, fn)
+	})
+}
+
+func (db *Database) runInTransaction(ctx context.Context, isoLevel pgx.TxIsoLevel, fn func(ctx context.Context) error) error {
+	tx, err := db.cluster.BeginTx(ctx, pgx.TxOptions{IsoLevel: isoLevel})
 	if err != nil {
 		return err
 	}
-	ctx = db.putTx(ctx, tx)
-	if err := fn(ctx); err != nil {
+	var hooks []func()
+	txCtx := context.WithValue(db.putTx(ctx, tx), afterCommitKey{}, &hooks)
+	if err := fn(txCtx); err != nil {
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
-			return rbErr
+			return errors.Join(err, rbErr)
 		}
 		return err
 	}
 
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		hook()
+	}
+	return nil
+}
+
+// AfterCommit выполняет fn после фиксации транзакции из контекста,
+// а вне транзакции — сразу. При откате или повторе транзакции fn не вызывается.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*[]func()); ok {
+		*hooks = append(*hooks, fn)
+		return
+	}
+	fn()
 }
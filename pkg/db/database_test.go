@@ -0,0 +1,20 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAfterCommit(t *testing.T) {
+	called := 0
+	AfterCommit(context.Background(), func() { called++ })
+	assert.Equal(t, 1, called, "вне транзакции fn выполняется сразу")
+
+	var hooks []func()
+	ctx := context.WithValue(context.Background(), afterCommitKey{}, &hooks)
+	AfterCommit(ctx, func() { called++ })
+	assert.Equal(t, 1, called, "в транзакции fn откладывается до фиксации")
+	assert.Len(t, hooks, 1)
+}
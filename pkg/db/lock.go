@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdvisoryLock — сессионная advisory-блокировка PostgreSQL. Она держится, пока открыто
+// выделенное под неё соединение, и снимается сервером, если соединение оборвалось.
+type AdvisoryLock struct {
+	conn *pgxpool.Conn
+	key  int64
+}
+
+// TryAdvisoryLock пытается взять блокировку key, не дожидаясь её.
+// Если блокировку держит другая сессия, возвращает nil без ошибки.
+func (db *Database) TryAdvisoryLock(ctx context.Context, key int64) (*AdvisoryLock, error) {
+	conn, err := db.cluster.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&locked); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to take advisory lock: %w", err)
+	}
+	if !locked {
+		conn.Release()
+		return nil, nil
+	}
+	return &AdvisoryLock{conn: conn, key: key}, nil
+}
+
+// Alive проверяет, что соединение с блокировкой живо, а значит блокировка всё ещё наша.
+func (l *AdvisoryLock) Alive(ctx context.Context) error {
+	return l.conn.Ping(ctx)
+}
+
+// Release снимает блокировку и возвращает соединение в пул.
+// Если соединение уже оборвано, сервер снял блокировку сам, и соединение закрывается.
+func (l *AdvisoryLock) Release(ctx context.Context) {
+	if _, err := l.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		_ = l.conn.Conn().Close(ctx)
+	}
+	l.conn.Release()
+}
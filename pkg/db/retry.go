@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+)
+
+// TxOptions задаёт уровень изоляции и политику повторов транзакции.
+type TxOptions struct {
+	IsoLevel pgx.TxIsoLevel
+	// MaxRetries — число повторов после первой попытки.
+	MaxRetries int
+	// Backoff — задержка перед первым повтором, далее растёт экспоненциально.
+	Backoff time.Duration
+	// MaxBackoff ограничивает задержку между повторами.
+	MaxBackoff time.Duration
+}
+
+func txOptionsFromConfig(cfg Config) TxOptions {
+	return TxOptions{
+		IsoLevel:   pgx.TxIsoLevel(cfg.TxIsolation),
+		MaxRetries: cfg.TxMaxRetries,
+		Backoff:    cfg.TxRetryBackoff,
+		MaxBackoff: cfg.TxMaxRetryBackoff,
+	}
+}
+
+// IsRetryable сообщает, можно ли безопасно повторить транзакцию, завершившуюся ошибкой err.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == codeSerializationFailure || pgErr.Code == codeDeadlockDetected
+}
+
+// retry выполняет attempt, повторяя его при deadlock и serialization failure
+// с экспоненциальной задержкой и случайным разбросом.
+func retry(ctx context.Context, opts TxOptions, attempt func() error) error {
+	backoff := opts.Backoff
+	for i := 0; ; i++ {
+		err := attempt()
+		if err == nil || i >= opts.MaxRetries || !IsRetryable(err) {
+			return err
+		}
+
+		wait := backoff
+		if opts.MaxBackoff > 0 && wait > opts.MaxBackoff {
+			wait = opts.MaxBackoff
+		}
+		if wait > 0 {
+			// jitter в диапазоне [wait/2, wait) разводит конкурирующие транзакции.
+			wait = wait/2 + rand.N(wait/2+1) //nolint:gosec // криптостойкость не нужна
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
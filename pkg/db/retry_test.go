@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	errDeadlock      = &pgconn.PgError{Code: codeDeadlockDetected}
+	errSerialization = &pgconn.PgError{Code: codeSerializationFailure}
+)
+
+func testTxOptions() TxOptions {
+	return TxOptions{
+		MaxRetries: 5,
+		Backoff:    time.Microsecond,
+		MaxBackoff: 10 * time.Microsecond,
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"deadlock", errDeadlock, true},
+		{"serialization failure", errSerialization, true},
+		{"wrapped deadlock", fmt.Errorf("save: %w", errDeadlock), true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"plain error", errors.New("boom"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsRetryable(tc.err))
+		})
+	}
+}
+
+func TestRetry_SucceedsAfterRetryableErrors(t *testing.T) {
+	attempts := 0
+	err := retry(context.Background(), testTxOptions(), func() error {
+		attempts++
+		if attempts < 3 {
+			return errDeadlock
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestRetry_StopsOnNonRetryableError(t *testing.T) {
+	expected := errors.New("not enough coins")
+	attempts := 0
+	err := retry(context.Background(), testTxOptions(), func() error {
+		attempts++
+		return expected
+	})
+	assert.ErrorIs(t, err, expected)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetry_BoundedAttempts(t *testing.T) {
+	opts := testTxOptions()
+	attempts := 0
+	err := retry(context.Background(), opts, func() error {
+		attempts++
+		return errSerialization
+	})
+	assert.ErrorIs(t, err, errSerialization)
+	assert.Equal(t, opts.MaxRetries+1, attempts)
+}
+
+func TestRetry_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := testTxOptions()
+	opts.Backoff = time.Hour
+	opts.MaxBackoff = time.Hour
+
+	attempts := 0
+	err := retry(ctx, opts, func() error {
+		attempts++
+		cancel()
+		return errDeadlock
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, errDeadlock)
+	assert.Equal(t, 1, attempts)
+}
+
+// TestRetry_ConcurrentStress имитирует конкурирующие транзакции, которые
+// случайно получают deadlock и serialization failure, и проверяет,
+// что каждая из них в итоге применяется ровно один раз.
+func TestRetry_ConcurrentStress(t *testing.T) {
+	const workers = 64
+	opts := testTxOptions()
+	opts.MaxRetries = 50
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		balance   int
+		retries   atomic.Int64
+		succeeded atomic.Int64
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := retry(context.Background(), opts, func() error {
+				switch rand.IntN(3) { //nolint:gosec // тестовые данные
+				case 0:
+					retries.Add(1)
+					return errDeadlock
+				case 1:
+					retries.Add(1)
+					return errSerialization
+				}
+				mu.Lock()
+				balance++
+				mu.Unlock()
+				return nil
+			})
+			if err == nil {
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(workers), succeeded.Load())
+	assert.Equal(t, workers, balance)
+	assert.Positive(t, retries.Load())
+}
@@ -4,4 +4,10 @@ type Config struct {
 	Port         int    `env:"HTTP_PORT"`
 	AllowOrigins string `env:"HTTP_ORIGINS"`
 	AllowHeaders string `env:"HTTP_HEADERS"`
+	// ProxyHeader — заголовок с адресом клиента за балансировщиком, например X-Forwarded-For.
+	// Читается только в запросах от TrustedProxies, без них игнорируется: иначе клиент
+	// подставит любой адрес и обойдёт блокировки входа и регистрации по IP.
+	ProxyHeader string `env:"HTTP_PROXY_HEADER"`
+	// TrustedProxies — адреса и подсети балансировщиков через запятую, например 10.0.0.0/8.
+	TrustedProxies []string `env:"HTTP_TRUSTED_PROXIES" env-separator:","`
 }
@@ -24,12 +24,21 @@ func (r *Router) Add(module Module) {
 	module.Init(r.root)
 }
 
+// AddRoot монтирует модуль в корень приложения, вне префикса /api.
+func (r *Router) AddRoot(module Module) {
+	module.Init(r.app)
+}
+
 func (r *Router) Run() error {
 	return r.app.Listen(fmt.Sprintf(":%d", r.cfg.Port))
 }
 
 func New(cfg Config, readyFunc func() bool) *Router {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.TrustedProxies,
+	})
 
 	api := app.Group("/api")
 
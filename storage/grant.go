@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"context"
+	"fmt"
+	"time"
+)
+
+// ListGrantRecipients returns the employees that got a refresh token since activeSince,
+// all employees if activeSince is zero.
+func (r *PgRepository) ListGrantRecipients(ctx context.Context, activeSince time.Time) ([]*auth.User, error) {
+	var rows []pgUser
+	err := r.db.Select(ctx, &rows, `
+SELECT u.id, u.username, u.roles
+FROM users u
+WHERE $1 = ANY(u.roles) AND ($2::timestamptz IS NULL OR EXISTS (
+    SELECT 1 FROM sessions s
+    JOIN refresh_tokens rt ON rt.fk_session = s.id
+    WHERE s.fk_user = u.id AND rt.created_at >= $2
+))
+ORDER BY u.id`, auth.RoleEmployee, nullTime(activeSince))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list grant recipients: %w", err)
+	}
+	users := make([]*auth.User, len(rows))
+	for i := range rows {
+		users[i] = mapUser(&rows[i])
+	}
+	return users, nil
+}
+
+// SaveScheduledGrant posts the grant and records it for the job period. If the user already
+// got the grant for the period, the transaction is rolled back with ErrAlreadyGranted.
+func (r *PgRepository) SaveScheduledGrant(ctx context.Context, grant *coin.ScheduledGrant) error {
+	return r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		t, err := r.postTransaction(ctx, grant.Transaction)
+		if err != nil {
+			return err
+		}
+		tag, err := r.db.Exec(ctx, `
+INSERT INTO scheduled_grants (fk_job, period, fk_user, fk_transaction)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING`, grant.JobID, grant.Period, t.ToUser.ID, t.ID)
+		if err != nil {
+			return fmt.Errorf("failed to save scheduled grant: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return coin.ErrAlreadyGranted
+		}
+		return nil
+	})
+}
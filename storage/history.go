@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type pgHistoryEntry struct {
+	EntryID   int64          `db:"entry_id"`
+	ID        int64          `db:"id"`
+	Type      string         `db:"type"`
+	Amount    int            `db:"amount"`
+	Incoming  bool           `db:"incoming"`
+	BatchID   sql.NullInt64  `db:"fk_batch"`
+	PrevID    *int64         `db:"fk_prev_transaction"`
+	PoolID    sql.NullInt64  `db:"fk_pool"`
+	Status    string         `db:"status"`
+	FromUser  sql.NullString `db:"user_from_username"`
+	ToUser    sql.NullString `db:"user_to_username"`
+	Message   string         `db:"message"`
+	Tags      []string       `db:"tags"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+// ListHistory returns the ledger entries of the user matching the filter, using keyset pagination by entry ID.
+// Conditions are added only for the filter fields that are set, so the planner can use
+// ledger_entries_user_history_idx for the common case.
+func (r *PgRepository) ListHistory(ctx context.Context, userID auth.UserID, filter coin.HistoryFilter) ([]*coin.HistoryEntry, error) {
+	args := []any{int64(userID), coin.UserAccount}
+	conds := []string{"e.fk_user = $1", "e.account = $2"}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	switch filter.Direction {
+	case coin.Incoming:
+		conds = append(conds, "e.amount > 0")
+	case coin.Outgoing:
+		conds = append(conds, "e.amount < 0")
+	}
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		conds = append(conds, "t.type = ANY("+arg(types)+")")
+	}
+	if !filter.From.IsZero() {
+		conds = append(conds, "e.created_at >= "+arg(filter.From)+"::timestamptz")
+	}
+	if !filter.To.IsZero() {
+		conds = append(conds, "e.created_at < "+arg(filter.To)+"::timestamptz")
+	}
+	if filter.Counterparty != "" {
+		// сравнивается только противоположная сторона, иначе собственное имя совпало бы со всей историей.
+		conds = append(conds, "(SELECT id FROM users WHERE username = "+arg(filter.Counterparty)+
+			") = CASE WHEN t.fk_from_user = $1 THEN t.fk_to_user ELSE t.fk_from_user END")
+	}
+	order := "DESC"
+	if filter.Order == coin.OldestFirst {
+		order = "ASC"
+		if filter.After != 0 {
+			conds = append(conds, "e.id > "+arg(int64(filter.After)))
+		}
+	} else if filter.After != 0 {
+		conds = append(conds, "e.id < "+arg(int64(filter.After)))
+	}
+
+	query := `
+SELECT
+    e.id AS entry_id,
+    t.id,
+    t.type,
+    abs(e.amount) AS amount,
+    e.amount > 0 AS incoming,
+    f.username AS user_from_username,
+    u.username AS user_to_username,
+    t.message,
+    t.tags,
+    t.fk_batch,
+    t.fk_prev_transaction,
+    t.fk_pool,
+    t.status,
+    e.created_at
+FROM ledger_entries e
+JOIN transactions t ON t.id = e.fk_transaction
+LEFT JOIN users f ON f.id = t.fk_from_user
+LEFT JOIN users u ON u.id = t.fk_to_user
+WHERE ` + strings.Join(conds, " AND ") + `
+ORDER BY e.id ` + order + `
+LIMIT ` + arg(filter.Limit)
+
+	var rows []pgHistoryEntry
+	if err := r.db.Select(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+	entries := make([]*coin.HistoryEntry, len(rows))
+	for i, row := range rows {
+		entry := &coin.HistoryEntry{
+			EntryID: row.EntryID,
+			Transaction: coin.Transaction{
+				ID:              coin.TransactionID(row.ID),
+				Type:            coin.Type(row.Type),
+				Amount:          row.Amount,
+				BatchID:         coin.BatchID(row.BatchID.Int64),
+				PrevTransaction: row.PrevID,
+				PoolID:          coin.PoolID(row.PoolID.Int64),
+				Status:          coin.Status(row.Status),
+				CreatedAt:       row.CreatedAt,
+				Note:            coin.Note{Message: row.Message},
+			},
+		}
+		for _, tag := range row.Tags {
+			entry.Tags = append(entry.Tags, coin.Tag(tag))
+		}
+		if row.FromUser.Valid {
+			entry.FromUser = &auth.User{Username: row.FromUser.String}
+		}
+		if row.ToUser.Valid {
+			entry.ToUser = &auth.User{Username: row.ToUser.String}
+		}
+		entry.Direction = coin.Outgoing
+		if row.Incoming {
+			entry.Direction = coin.Incoming
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// SumHistory returns the sum of the user account entries made before the given time, the balance at that moment.
+func (r *PgRepository) SumHistory(ctx context.Context, userID auth.UserID, before time.Time) (int, error) {
+	var sum int
+	err := r.db.Get(ctx, &sum, `
+SELECT COALESCE(SUM(amount), 0)
+FROM ledger_entries
+WHERE fk_user = $1 AND account = $2 AND created_at < $3::timestamptz`, userID, coin.UserAccount, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum history: %w", err)
+	}
+	return sum, nil
+}
+
+// outgoingTransferTypes are the transaction types counted by the transfer limits:
+// a pool contribution is a transfer to the pool recipient.
+var outgoingTransferTypes = []string{string(coin.Transfer), string(coin.PoolContribution)}
+
+// SumOutgoingTransfers returns the total transferred by the user since the given time,
+// only to the given recipient when to is not nil.
+func (r *PgRepository) SumOutgoingTransfers(ctx context.Context, from auth.UserID, to *auth.UserID, since time.Time) (int, error) {
+	var sum int
+	err := r.db.Get(ctx, &sum, `
+SELECT COALESCE(SUM(amount), 0)
+FROM transactions
+WHERE fk_from_user = $1
+  AND type = ANY($2)
+  AND created_at >= $3::timestamptz
+  AND status NOT IN ('declined', 'expired')
+  AND ($4::integer IS NULL OR fk_to_user = $4)`, from, outgoingTransferTypes, since, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum outgoing transfers: %w", err)
+	}
+	return sum, nil
+}
+
+// LastOutgoingTransferAt returns the time of the latest transfer or pool contribution by the user,
+// zero if there are none.
+func (r *PgRepository) LastOutgoingTransferAt(ctx context.Context, from auth.UserID) (time.Time, error) {
+	var last sql.NullTime
+	err := r.db.Get(ctx, &last, `
+SELECT MAX(created_at)::timestamptz
+FROM transactions
+WHERE fk_from_user = $1 AND type = ANY($2)`, from, outgoingTransferTypes)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last outgoing transfer: %w", err)
+	}
+	return last.Time, nil
+}
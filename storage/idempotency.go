@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/idempotency"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgIdempotencyKey struct {
+	UserID      int64          `db:"fk_user"`
+	Key         string         `db:"key"`
+	Fingerprint string         `db:"fingerprint"`
+	StatusCode  sql.NullInt32  `db:"status_code"`
+	ContentType sql.NullString `db:"content_type"`
+	Body        []byte         `db:"body"`
+	ExpiresAt   time.Time      `db:"expires_at"`
+}
+
+func mapIdempotencyKey(k *pgIdempotencyKey) *idempotency.Record {
+	return &idempotency.Record{
+		UserID:      auth.UserID(k.UserID),
+		Key:         idempotency.Key(k.Key),
+		Fingerprint: k.Fingerprint,
+		StatusCode:  int(k.StatusCode.Int32),
+		ContentType: k.ContentType.String,
+		Body:        k.Body,
+		ExpiresAt:   k.ExpiresAt,
+	}
+}
+
+// ReserveIdempotencyKey inserts an in-progress record, taking over the key if it has expired.
+// It reports whether the key was reserved.
+func (r *PgRepository) ReserveIdempotencyKey(ctx context.Context, rec *idempotency.Record, now time.Time) (bool, error) {
+	query := `
+INSERT INTO idempotency_keys (fk_user, key, fingerprint, expires_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (fk_user, key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint,
+    status_code = NULL,
+    content_type = NULL,
+    body = NULL,
+    created_at = NOW(),
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at < $5
+RETURNING fk_user`
+	var userID int64
+	err := r.db.Get(ctx, &userID, query, rec.UserID, rec.Key, rec.Fingerprint, rec.ExpiresAt, now)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	return true, nil
+}
+
+// GetIdempotencyKey returns the stored record for the user key.
+func (r *PgRepository) GetIdempotencyKey(ctx context.Context, userID auth.UserID, key idempotency.Key) (*idempotency.Record, error) {
+	query := `
+SELECT fk_user, key, fingerprint, status_code, content_type, body, expires_at
+FROM idempotency_keys
+WHERE fk_user = $1 AND key = $2`
+	var k pgIdempotencyKey
+	if err := r.db.Get(ctx, &k, query, userID, key); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, idempotency.ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	return mapIdempotencyKey(&k), nil
+}
+
+// CompleteIdempotencyKey stores the response of the request that reserved the key.
+func (r *PgRepository) CompleteIdempotencyKey(ctx context.Context, rec *idempotency.Record) error {
+	query := `
+UPDATE idempotency_keys
+SET status_code = $4, content_type = $5, body = $6, expires_at = $7
+WHERE fk_user = $1 AND key = $2 AND fingerprint = $3 AND status_code IS NULL`
+	result, err := r.db.Exec(ctx, query,
+		rec.UserID, rec.Key, rec.Fingerprint, rec.StatusCode, rec.ContentType, rec.Body, rec.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return idempotency.ErrKeyNotFound
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes an in-progress record so the request can be retried.
+func (r *PgRepository) ReleaseIdempotencyKey(ctx context.Context, userID auth.UserID, key idempotency.Key) error {
+	query := `DELETE FROM idempotency_keys WHERE fk_user = $1 AND key = $2 AND status_code IS NULL`
+	if _, err := r.db.Exec(ctx, query, userID, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys removes records whose TTL has passed.
+func (r *PgRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
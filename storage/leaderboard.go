@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"avito-intern/internal/leaderboard"
+	"context"
+	"fmt"
+	"time"
+)
+
+// kudos is a change of the transfer aggregates: the giver sent and the receiver got
+// count transfers worth amount. Day is taken from the transfer transaction.
+type kudos struct {
+	transfer coin.TransactionID
+	giver    auth.UserID
+	receiver auth.UserID
+	count    int
+	amount   int
+}
+
+// transferKudos returns the aggregates change for the saved transaction: a completed transfer
+// adds to them, a reversal takes its amount off the reversed transfer.
+func transferKudos(t *coin.Transaction) (kudos, bool) {
+	switch {
+	case t.Type == coin.Transfer && (t.Status == coin.StatusCompleted || t.Status == coin.StatusAccepted):
+		return kudos{transfer: t.ID, giver: t.FromUser.ID, receiver: t.ToUser.ID, count: 1, amount: t.Amount}, true
+	case t.Type == coin.Reversal && t.PrevTransaction != nil:
+		// возврат идёт от получателя перевода к отправителю.
+		return kudos{
+			transfer: coin.TransactionID(*t.PrevTransaction),
+			giver:    t.ToUser.ID,
+			receiver: t.FromUser.ID,
+			amount:   -t.Amount,
+		}, true
+	default:
+		return kudos{}, false
+	}
+}
+
+// addKudos updates the daily and all-time transfer aggregates of the giver and the receiver.
+// It must be called inside RunInTransaction.
+func (r *PgRepository) addKudos(ctx context.Context, k kudos) error {
+	// одна строка на отправителя и одна на получателя. Строки пишутся по возрастанию fk_user,
+	// иначе встречные переводы A→B и B→A блокируют строки агрегатов в разном порядке.
+	const rows = `(VALUES ($1::int, $2::int, $3::int, 0, 0), ($4::int, 0, 0, $2::int, $3::int))
+    AS k(fk_user, given_count, given_amount, received_count, received_amount)`
+	_, err := r.db.Exec(ctx, `
+INSERT INTO kudos_daily (day, fk_user, given_count, given_amount, received_count, received_amount)
+SELECT (t.created_at::timestamptz AT TIME ZONE 'UTC')::date, k.fk_user,
+    k.given_count, k.given_amount, k.received_count, k.received_amount
+FROM transactions t, `+rows+`
+WHERE t.id = $5
+ORDER BY k.fk_user
+ON CONFLICT (day, fk_user) DO UPDATE SET
+    given_count = kudos_daily.given_count + EXCLUDED.given_count,
+    given_amount = kudos_daily.given_amount + EXCLUDED.given_amount,
+    received_count = kudos_daily.received_count + EXCLUDED.received_count,
+    received_amount = kudos_daily.received_amount + EXCLUDED.received_amount`,
+		k.giver, k.count, k.amount, k.receiver, k.transfer)
+	if err != nil {
+		return fmt.Errorf("failed to update daily kudos: %w", err)
+	}
+	_, err = r.db.Exec(ctx, `
+INSERT INTO kudos_totals (fk_user, given_count, given_amount, received_count, received_amount)
+SELECT k.fk_user, k.given_count, k.given_amount, k.received_count, k.received_amount
+FROM `+rows+`
+ORDER BY k.fk_user
+ON CONFLICT (fk_user) DO UPDATE SET
+    given_count = kudos_totals.given_count + EXCLUDED.given_count,
+    given_amount = kudos_totals.given_amount + EXCLUDED.given_amount,
+    received_count = kudos_totals.received_count + EXCLUDED.received_count,
+    received_amount = kudos_totals.received_amount + EXCLUDED.received_amount`,
+		k.giver, k.count, k.amount, k.receiver)
+	if err != nil {
+		return fmt.Errorf("failed to update kudos totals: %w", err)
+	}
+	return nil
+}
+
+// ListTop returns the top givers or receivers since the given time, all-time if since is zero.
+// Users that opted out of the leaderboard are skipped.
+func (r *PgRepository) ListTop(ctx context.Context, side leaderboard.Side, since time.Time, sort leaderboard.Sort, limit int) ([]*leaderboard.Entry, error) {
+	prefix := "given"
+	if side == leaderboard.Receivers {
+		prefix = "received"
+	}
+	count, amount := prefix+"_count", prefix+"_amount"
+	order := "total DESC, count DESC"
+	if sort == leaderboard.ByCount {
+		order = "count DESC, total DESC"
+	}
+
+	var (
+		query string
+		args  []any
+	)
+	if since.IsZero() {
+		query = fmt.Sprintf(`
+SELECT u.id, u.username, k.%[1]s AS count, k.%[2]s AS total
+FROM kudos_totals k
+JOIN users u ON u.id = k.fk_user
+WHERE k.%[1]s > 0 AND NOT u.leaderboard_opt_out
+ORDER BY %[3]s, u.id
+LIMIT $1`, count, amount, order)
+		args = []any{limit}
+	} else {
+		query = fmt.Sprintf(`
+SELECT u.id, u.username, SUM(k.%[1]s) AS count, SUM(k.%[2]s) AS total
+FROM kudos_daily k
+JOIN users u ON u.id = k.fk_user
+WHERE k.day >= $1::date AND NOT u.leaderboard_opt_out
+GROUP BY u.id, u.username
+HAVING SUM(k.%[1]s) > 0
+ORDER BY %[3]s, u.id
+LIMIT $2`, count, amount, order)
+		args = []any{since, limit}
+	}
+
+	var rows []struct {
+		ID       int64  `db:"id"`
+		Username string `db:"username"`
+		Count    int    `db:"count"`
+		Total    int    `db:"total"`
+	}
+	if err := r.db.Select(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list leaderboard: %w", err)
+	}
+	entries := make([]*leaderboard.Entry, len(rows))
+	for i, row := range rows {
+		entries[i] = &leaderboard.Entry{
+			UserID:   auth.UserID(row.ID),
+			Username: row.Username,
+			Count:    row.Count,
+			Total:    row.Total,
+		}
+	}
+	return entries, nil
+}
+
+// SetLeaderboardOptOut hides the user from the leaderboard or shows them again.
+func (r *PgRepository) SetLeaderboardOptOut(ctx context.Context, userID auth.UserID, optOut bool) error {
+	tag, err := r.db.Exec(ctx, `UPDATE users SET leaderboard_opt_out = $2 WHERE id = $1`, userID, optOut)
+	if err != nil {
+		return fmt.Errorf("failed to set leaderboard opt-out: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"context"
+	"fmt"
+	"time"
+)
+
+type pgLot struct {
+	ID            int64     `db:"id"`
+	UserID        int64     `db:"fk_user"`
+	TransactionID *int64    `db:"fk_transaction"`
+	Amount        int       `db:"amount"`
+	Remaining     int       `db:"remaining"`
+	ReceivedAt    time.Time `db:"received_at"`
+}
+
+func mapLot(row *pgLot) *coin.Lot {
+	lot := &coin.Lot{
+		ID:         row.ID,
+		UserID:     auth.UserID(row.UserID),
+		Amount:     row.Amount,
+		Remaining:  row.Remaining,
+		ReceivedAt: row.ReceivedAt,
+	}
+	if row.TransactionID != nil {
+		id := coin.TransactionID(*row.TransactionID)
+		lot.TransactionID = &id
+	}
+	return lot
+}
+
+// openLot records the coins credited to the user by the transaction as a new lot.
+// It must be called inside RunInTransaction.
+func (r *PgRepository) openLot(ctx context.Context, userID auth.UserID, id coin.TransactionID, amount int) error {
+	_, err := r.db.Exec(ctx, `
+INSERT INTO coin_lots (fk_user, fk_transaction, amount, remaining)
+VALUES ($1, $2, $3, $3)`, userID, id, amount)
+	if err != nil {
+		return fmt.Errorf("failed to open coin lot: %w", err)
+	}
+	return nil
+}
+
+// consumeLots takes amount out of the user's open lots, oldest first, and records which lots
+// the transaction consumed, so a refund can restore them. id = 0 consumes without a record.
+// It must be called inside RunInTransaction after the balance check.
+func (r *PgRepository) consumeLots(ctx context.Context, userID auth.UserID, id coin.TransactionID, amount int) error {
+	var lots []pgLot
+	err := r.db.Select(ctx, &lots, `
+SELECT id, fk_user, fk_transaction, amount, remaining, received_at
+FROM coin_lots
+WHERE fk_user = $1 AND remaining > 0
+ORDER BY received_at, id
+FOR UPDATE`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to lock coin lots: %w", err)
+	}
+	for _, lot := range lots {
+		if amount == 0 {
+			break
+		}
+		take := min(amount, lot.Remaining)
+		_, err := r.db.Exec(ctx, `UPDATE coin_lots SET remaining = remaining - $2 WHERE id = $1`, lot.ID, take)
+		if err != nil {
+			return fmt.Errorf("failed to consume coin lot: %w", err)
+		}
+		if id != 0 {
+			_, err = r.db.Exec(ctx, `
+INSERT INTO coin_lot_consumptions (fk_transaction, fk_lot, amount)
+VALUES ($1, $2, $3)
+ON CONFLICT (fk_transaction, fk_lot) DO UPDATE SET amount = coin_lot_consumptions.amount + EXCLUDED.amount`,
+				id, lot.ID, take)
+			if err != nil {
+				return fmt.Errorf("failed to record coin lot consumption: %w", err)
+			}
+		}
+		amount -= take
+	}
+	if amount > 0 {
+		return fmt.Errorf("coin lots of user %d are short of %d coins", userID, amount)
+	}
+	return nil
+}
+
+// creditLots returns amount to the user: first into the lots consumed by the refunded
+// transactions, keeping their received_at, the rest into a new lot of the transaction.
+// It must be called inside RunInTransaction.
+func (r *PgRepository) creditLots(ctx context.Context, userID auth.UserID, id coin.TransactionID, amount int, refunded []coin.TransactionID) error {
+	if len(refunded) > 0 {
+		ids := make([]int64, len(refunded))
+		for i, id := range refunded {
+			ids[i] = int64(id)
+		}
+		var consumed []struct {
+			TransactionID int64 `db:"fk_transaction"`
+			LotID         int64 `db:"fk_lot"`
+			Amount        int   `db:"amount"`
+		}
+		err := r.db.Select(ctx, &consumed, `
+SELECT c.fk_transaction, c.fk_lot, c.amount
+FROM coin_lot_consumptions c
+JOIN coin_lots l ON l.id = c.fk_lot
+WHERE c.fk_transaction = ANY($1) AND l.fk_user = $2 AND c.amount > 0
+ORDER BY l.received_at, l.id
+FOR UPDATE OF c`, ids, userID)
+		if err != nil {
+			return fmt.Errorf("failed to lock coin lot consumptions: %w", err)
+		}
+		for _, c := range consumed {
+			if amount == 0 {
+				break
+			}
+			give := min(amount, c.Amount)
+			_, err := r.db.Exec(ctx, `
+UPDATE coin_lot_consumptions SET amount = amount - $3
+WHERE fk_transaction = $1 AND fk_lot = $2`, c.TransactionID, c.LotID, give)
+			if err != nil {
+				return fmt.Errorf("failed to update coin lot consumption: %w", err)
+			}
+			_, err = r.db.Exec(ctx, `UPDATE coin_lots SET remaining = remaining + $2 WHERE id = $1`, c.LotID, give)
+			if err != nil {
+				return fmt.Errorf("failed to restore coin lot: %w", err)
+			}
+			amount -= give
+		}
+	}
+	if amount == 0 {
+		return nil
+	}
+	return r.openLot(ctx, userID, id, amount)
+}
+
+// refundedTransactions returns the transactions whose debits t gives back: a reversal refunds
+// the reversed transaction, a pool refund the user's contributions to the pool.
+// It must be called inside RunInTransaction.
+func (r *PgRepository) refundedTransactions(ctx context.Context, t *coin.Transaction) ([]coin.TransactionID, error) {
+	switch {
+	case t.Type == coin.Reversal && t.PrevTransaction != nil:
+		return []coin.TransactionID{coin.TransactionID(*t.PrevTransaction)}, nil
+	case t.Type == coin.PoolRefund && t.ToUser != nil:
+		var ids []coin.TransactionID
+		err := r.db.Select(ctx, &ids, `
+SELECT id FROM transactions
+WHERE fk_pool = $1 AND type = $2 AND fk_from_user = $3`, t.PoolID, coin.PoolContribution, t.ToUser.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list refunded contributions: %w", err)
+		}
+		return ids, nil
+	default:
+		return nil, nil
+	}
+}
+
+// ListOpenLots returns the user's lots with coins left received before receivedBefore, oldest first.
+func (r *PgRepository) ListOpenLots(ctx context.Context, userID auth.UserID, receivedBefore time.Time) ([]*coin.Lot, error) {
+	var rows []pgLot
+	err := r.db.Select(ctx, &rows, `
+SELECT id, fk_user, fk_transaction, amount, remaining, received_at
+FROM coin_lots
+WHERE fk_user = $1 AND remaining > 0 AND received_at < $2
+ORDER BY received_at, id`, userID, receivedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list coin lots: %w", err)
+	}
+	lots := make([]*coin.Lot, len(rows))
+	for i := range rows {
+		lots[i] = mapLot(&rows[i])
+	}
+	return lots, nil
+}
+
+// ListUsersWithExpiredLots returns up to limit users with coins left in lots received before receivedBefore.
+func (r *PgRepository) ListUsersWithExpiredLots(ctx context.Context, receivedBefore time.Time, limit int) ([]auth.UserID, error) {
+	var ids []auth.UserID
+	err := r.db.Select(ctx, &ids, `
+SELECT DISTINCT fk_user
+FROM coin_lots
+WHERE remaining > 0 AND received_at < $1
+ORDER BY fk_user
+LIMIT $2`, receivedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users with expired coins: %w", err)
+	}
+	return ids, nil
+}
+
+// ExpireLots posts an expiry transaction for the coins left in the user's lots received
+// before receivedBefore. Lots are consumed oldest first, so the expiry debit drains exactly
+// the expired lots. Returns nil if there is nothing to expire.
+func (r *PgRepository) ExpireLots(ctx context.Context, userID auth.UserID, receivedBefore time.Time) (*coin.Transaction, error) {
+	var expired *coin.Transaction
+	err := r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		if err := r.LockUsers(ctx, userID); err != nil {
+			return err
+		}
+		var amount int
+		err := r.db.Get(ctx, &amount, `
+SELECT COALESCE(SUM(remaining), 0)
+FROM coin_lots
+WHERE fk_user = $1 AND remaining > 0 AND received_at < $2`, userID, receivedBefore)
+		if err != nil {
+			return fmt.Errorf("failed to sum expired coins: %w", err)
+		}
+		if amount == 0 {
+			return nil
+		}
+		expired, err = r.postTransaction(ctx, &coin.Transaction{
+			FromUser: &auth.User{ID: userID},
+			Amount:   amount,
+			Type:     coin.Expiry,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"avito-intern/internal/coin"
+	"avito-intern/internal/outbox"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// saveEvent writes the domain event to the outbox and queues it for every registered consumer.
+// It must be called inside the transaction that makes the change described by the event,
+// so the event is recorded if and only if it commits.
+func (r *PgRepository) saveEvent(ctx context.Context, t outbox.EventType, payload any) error {
+	e, err := outbox.NewEvent(t, payload)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(ctx, `
+WITH e AS (
+    INSERT INTO outbox_events (type, payload) VALUES ($1, $2) RETURNING id
+)
+INSERT INTO outbox_deliveries (consumer, fk_event)
+SELECT c.name, e.id FROM outbox_consumers c CROSS JOIN e`, e.Type, e.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to save outbox event: %w", err)
+	}
+	return nil
+}
+
+// saveTransferEvent records TransferCompleted once the coins of the transfer reached the recipient.
+// Pending transfers are recorded when accepted.
+func (r *PgRepository) saveTransferEvent(ctx context.Context, t *coin.Transaction) error {
+	if t.Type != coin.Transfer || !t.Settled() || t.FromUser == nil || t.ToUser == nil {
+		return nil
+	}
+	payload := outbox.Transfer{
+		TransactionID: int64(t.ID),
+		FromUserID:    int64(t.FromUser.ID),
+		FromUser:      t.FromUser.Username,
+		ToUserID:      int64(t.ToUser.ID),
+		ToUser:        t.ToUser.Username,
+		Amount:        t.Amount,
+		BatchID:       int64(t.BatchID),
+		Message:       t.Message,
+	}
+	for _, tag := range t.Tags {
+		payload.Tags = append(payload.Tags, string(tag))
+	}
+	return r.saveEvent(ctx, outbox.TransferCompleted, payload)
+}
+
+// RegisterConsumers registers the consumers. Consumers registered earlier are kept with their queues.
+func (r *PgRepository) RegisterConsumers(ctx context.Context, names []string) error {
+	_, err := r.db.Exec(ctx, `
+INSERT INTO outbox_consumers (name)
+SELECT unnest($1::TEXT[])
+ON CONFLICT (name) DO NOTHING`, names)
+	if err != nil {
+		return fmt.Errorf("failed to register outbox consumers: %w", err)
+	}
+	return nil
+}
+
+// ListConsumers returns the names of all registered consumers.
+func (r *PgRepository) ListConsumers(ctx context.Context) ([]string, error) {
+	var names []string
+	if err := r.db.Select(ctx, &names, `SELECT name FROM outbox_consumers ORDER BY name`); err != nil {
+		return nil, fmt.Errorf("failed to list outbox consumers: %w", err)
+	}
+	return names, nil
+}
+
+// TryLockRelay takes the transaction-level advisory lock of the relay without waiting for it.
+func (r *PgRepository) TryLockRelay(ctx context.Context, key int64) (bool, error) {
+	var locked bool
+	if err := r.db.Get(ctx, &locked, `SELECT pg_try_advisory_xact_lock($1)`, key); err != nil {
+		return false, fmt.Errorf("failed to take relay lock: %w", err)
+	}
+	return locked, nil
+}
+
+type pgEvent struct {
+	ID         int64           `db:"id"`
+	Type       string          `db:"type"`
+	Payload    json.RawMessage `db:"payload"`
+	OccurredAt time.Time       `db:"created_at"`
+	Attempts   int             `db:"attempts"`
+}
+
+// ListUndelivered returns up to limit events not yet delivered to the consumer and not in its dead letter, in ID order.
+func (r *PgRepository) ListUndelivered(ctx context.Context, consumer string, limit int) ([]*outbox.Event, error) {
+	var rows []pgEvent
+	err := r.db.Select(ctx, &rows, `
+SELECT e.id, e.type, e.payload, e.created_at, d.attempts
+FROM outbox_deliveries d
+JOIN outbox_events e ON e.id = d.fk_event
+WHERE d.consumer = $1 AND d.delivered_at IS NULL AND d.dead_at IS NULL
+ORDER BY d.fk_event
+LIMIT $2`, consumer, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox events: %w", err)
+	}
+	res := make([]*outbox.Event, len(rows))
+	for i, row := range rows {
+		res[i] = &outbox.Event{
+			ID:         outbox.EventID(row.ID),
+			Type:       outbox.EventType(row.Type),
+			OccurredAt: row.OccurredAt,
+			Payload:    row.Payload,
+			Attempts:   row.Attempts,
+		}
+	}
+	return res, nil
+}
+
+func eventIDs(ids []outbox.EventID) []int64 {
+	res := make([]int64, len(ids))
+	for i, id := range ids {
+		res[i] = int64(id)
+	}
+	return res
+}
+
+// MarkDelivered marks the events as delivered to the consumer.
+func (r *PgRepository) MarkDelivered(ctx context.Context, consumer string, ids []outbox.EventID, at time.Time) error {
+	_, err := r.db.Exec(ctx, `
+UPDATE outbox_deliveries SET delivered_at = $3
+WHERE consumer = $1 AND fk_event = ANY($2)`, consumer, eventIDs(ids), at)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox events delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed attempt to deliver the events to the consumer.
+func (r *PgRepository) MarkFailed(ctx context.Context, consumer string, ids []outbox.EventID, reason string) error {
+	_, err := r.db.Exec(ctx, `
+UPDATE outbox_deliveries
+SET attempts = attempts + 1, last_error = $3, last_attempt_at = NOW()
+WHERE consumer = $1 AND fk_event = ANY($2)`, consumer, eventIDs(ids), reason)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox events failed: %w", err)
+	}
+	return nil
+}
+
+// MarkDead moves the events to the dead letter of the consumer, they are kept but no longer delivered to it.
+func (r *PgRepository) MarkDead(ctx context.Context, consumer string, ids []outbox.EventID, at time.Time) error {
+	_, err := r.db.Exec(ctx, `
+UPDATE outbox_deliveries SET dead_at = $3
+WHERE consumer = $1 AND fk_event = ANY($2)`, consumer, eventIDs(ids), at)
+	if err != nil {
+		return fmt.Errorf("failed to move outbox events to dead letter: %w", err)
+	}
+	return nil
+}
+
+// DeleteDelivered deletes the events delivered to every consumer before the given time.
+// Events in a dead letter are kept.
+func (r *PgRepository) DeleteDelivered(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+DELETE FROM outbox_events e
+WHERE e.created_at < $1
+  AND NOT EXISTS (
+    SELECT 1 FROM outbox_deliveries d
+    WHERE d.fk_event = e.id AND (d.delivered_at IS NULL OR d.delivered_at >= $1)
+  )`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete delivered outbox events: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
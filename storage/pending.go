@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"context"
+	"fmt"
+	"time"
+)
+
+// SettlePending closes the pending transfer with the given status and posts the entries moving
+// the coins out of escrow. The status update locks the row, so a transfer is settled only once.
+func (r *PgRepository) SettlePending(ctx context.Context, id coin.TransactionID, status coin.Status) (*coin.Transaction, error) {
+	var settled *coin.Transaction
+	err := r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		tag, err := r.db.Exec(ctx, `
+UPDATE transactions SET status = $2, settled_at = NOW()
+WHERE id = $1 AND status = $3`, id, status, coin.StatusPending)
+		if err != nil {
+			return fmt.Errorf("failed to settle transfer: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return coin.ErrNotPending
+		}
+		t, err := r.GetTransaction(ctx, id)
+		if err != nil {
+			return err
+		}
+		t.Status = coin.StatusPending
+		entries, err := t.SettlementEntries(status)
+		if err != nil {
+			return err
+		}
+		// возврат отправителю восстанавливает партии, которые перевод списал при создании.
+		if err := r.postEntries(ctx, id, entries, id); err != nil {
+			return err
+		}
+		t.Status = status
+		if k, ok := transferKudos(t); ok {
+			if err := r.addKudos(ctx, k); err != nil {
+				return err
+			}
+		}
+		if err := r.saveTransferEvent(ctx, t); err != nil {
+			return err
+		}
+		settled = t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return settled, nil
+}
+
+// ListPending returns the pending transfers sent or received by the user, oldest first.
+func (r *PgRepository) ListPending(ctx context.Context, userID auth.UserID) ([]*coin.Transaction, error) {
+	var rows []pgTransactionRow
+	err := r.db.Select(ctx, &rows, selectTransaction+`
+WHERE t.status = $2 AND (t.fk_from_user = $1 OR t.fk_to_user = $1)
+ORDER BY t.id`, userID, coin.StatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending transfers: %w", err)
+	}
+	res := make([]*coin.Transaction, len(rows))
+	for i := range rows {
+		res[i] = mapTransaction(&rows[i])
+	}
+	return res, nil
+}
+
+// ListExpiredPending returns up to limit pending transfers that expired by now.
+func (r *PgRepository) ListExpiredPending(ctx context.Context, now time.Time, limit int) ([]coin.TransactionID, error) {
+	var ids []coin.TransactionID
+	err := r.db.Select(ctx, &ids, `
+SELECT id FROM transactions
+WHERE status = $1 AND expires_at <= $2
+ORDER BY expires_at
+LIMIT $3`, coin.StatusPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired transfers: %w", err)
+	}
+	return ids, nil
+}
@@ -5,21 +5,28 @@ import (
 	"avito-intern/internal/coin"
 	"avito-intern/internal/common"
 	"avito-intern/internal/merch"
+	"avito-intern/internal/outbox"
 	"avito-intern/pkg/db"
 	"context"
 	"database/sql"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	"errors"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+const codeUniqueViolation = "23505"
+
 type pgUser struct {
 	ID           int64     `db:"id"`
 	Username     string    `db:"username"`
 	Password     string    `db:"password"`
+	Roles        []string  `db:"roles"`
 	CoinsBalance int       `db:"coin_balance"`
 	CreatedAt    time.Time `db:"created_at"`
 }
@@ -27,36 +34,77 @@ type pgUser struct {
 // PgRepository is a repository for PostgreSQL.
 type PgRepository struct {
 	db *db.Database
+	// identities is invalidated on writes to users, may be nil.
+	identities *auth.IdentityCache
 }
 
 // NewRepo creates a new PgUserRepo instance.
-func NewRepo(database *db.Database) *PgRepository {
+func NewRepo(database *db.Database, identities *auth.IdentityCache) *PgRepository {
 	return &PgRepository{
-		db: database,
+		db:         database,
+		identities: identities,
 	}
 }
 
 func mapUser(user *pgUser) *auth.User {
+	roles := make([]auth.Role, len(user.Roles))
+	for i, role := range user.Roles {
+		roles[i] = auth.Role(role)
+	}
 	return &auth.User{
 		ID:          auth.UserID(user.ID),
 		Username:    user.Username,
 		Password:    user.Password,
+		Roles:       roles,
 		CoinBalance: user.CoinsBalance,
 		CreatedAt:   user.CreatedAt,
 	}
 }
 
+// selectUser выбирает пользователя вместе с балансом, посчитанным по журналу проводок.
+const selectUser = `
+SELECT
+    u.id,
+    u.username,
+    u.password,
+    u.roles,
+    COALESCE((
+        SELECT SUM(e.amount) FROM ledger_entries e
+        WHERE e.account = 'user' AND e.fk_user = u.id
+    ), 0) AS coin_balance
+FROM users u`
+
 // CreateUser creates a new user and returns it.
-// It assumes a table "users" with columns id, username, and password.
+// Starting coins are posted to the ledger as a grant in the same transaction.
 func (r *PgRepository) CreateUser(ctx context.Context, username, password string, coins int) (*auth.User, error) {
-	query := `INSERT INTO users (username, password, coin_balance) VALUES ($1, $2, $3) RETURNING id, username, password, coin_balance`
+	query := `INSERT INTO users (username, password) VALUES ($1, $2) RETURNING id, username, password, roles`
 	var user pgUser
-	if err := r.db.Get(ctx, &user, query, username, password, coins); err != nil {
-		// if no row is returned, consider it as not found.
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, auth.ErrUserNotFound
+	err := r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		if err := r.db.Get(ctx, &user, query, username, password); err != nil {
+			// if no row is returned, consider it as not found.
+			if errors.Is(err, pgx.ErrNoRows) {
+				return auth.ErrUserNotFound
+			}
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == codeUniqueViolation {
+				return auth.ErrUserExists
+			}
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		err := r.saveEvent(ctx, outbox.UserRegistered, outbox.User{UserID: user.ID, Username: user.Username})
+		if err != nil || coins == 0 {
+			return err
 		}
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		user.CoinsBalance = coins
+		_, err = r.postTransaction(ctx, &coin.Transaction{
+			ToUser: mapUser(&user),
+			Amount: coins,
+			Type:   coin.Grant,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return mapUser(&user), nil
@@ -64,7 +112,7 @@ func (r *PgRepository) CreateUser(ctx context.Context, username, password string
 
 // GetUserByUsername returns the user matching the specified username.
 func (r *PgRepository) GetUserByUsername(ctx context.Context, username string) (*auth.User, error) {
-	query := `SELECT id, username, password, coin_balance FROM users WHERE username = $1`
+	query := selectUser + ` WHERE u.username = $1`
 	var user pgUser
 	if err := r.db.Get(ctx, &user, query, username); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -77,7 +125,7 @@ func (r *PgRepository) GetUserByUsername(ctx context.Context, username string) (
 
 // GetUserByID returns the user with the given ID.
 func (r *PgRepository) GetUserByID(ctx context.Context, userID auth.UserID) (*auth.User, error) {
-	query := `SELECT id, username, password, coin_balance FROM users WHERE id = $1`
+	query := selectUser + ` WHERE u.id = $1`
 	var user pgUser
 	if err := r.db.Get(ctx, &user, query, int64(userID)); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -88,65 +136,275 @@ func (r *PgRepository) GetUserByID(ctx context.Context, userID auth.UserID) (*au
 	return mapUser(&user), nil
 }
 
+// SetUserRoles replaces the roles of the user.
+func (r *PgRepository) SetUserRoles(ctx context.Context, userID auth.UserID, roles []auth.Role) error {
+	query := `UPDATE users SET roles = $2 WHERE id = $1`
+	raw := make([]string, len(roles))
+	for i, role := range roles {
+		raw[i] = string(role)
+	}
+	tag, err := r.db.Exec(ctx, query, int64(userID), raw)
+	if err != nil {
+		return fmt.Errorf("failed to set user roles: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return auth.ErrUserNotFound
+	}
+	db.AfterCommit(ctx, func() {
+		r.identities.Invalidate(userID)
+	})
+	return nil
+}
+
+// IsUsernameAllowed reports whether the username is in the registration allow list.
+func (r *PgRepository) IsUsernameAllowed(ctx context.Context, username string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM registration_allow_list WHERE username = $1)`
+	var allowed bool
+	if err := r.db.Get(ctx, &allowed, query, username); err != nil {
+		return false, fmt.Errorf("failed to check allow list: %w", err)
+	}
+	return allowed, nil
+}
+
+// GetBalance returns the user balance computed from the ledger.
+func (r *PgRepository) GetBalance(ctx context.Context, userID auth.UserID) (int, error) {
+	query := `
+SELECT COALESCE(SUM(amount), 0)
+FROM ledger_entries
+WHERE account = $1 AND fk_user = $2`
+	var balance int
+	if err := r.db.Get(ctx, &balance, query, coin.UserAccount, int64(userID)); err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+	return balance, nil
+}
+
+// SaveTransaction records the transaction and posts its ledger entries.
+// Debited users are locked so that concurrent debits can't overdraw the balance.
 func (r *PgRepository) SaveTransaction(ctx context.Context, t *coin.Transaction) (*coin.Transaction, error) {
 	if t == nil {
 		return nil, errors.New("invalid transaction")
 	}
-	err := r.db.RunInTransaction(ctx, func(ctx context.Context) error {
-		var currentBalance int
-		txErr := r.db.Get(ctx, &currentBalance, `
-            SELECT coin_balance 
-            FROM users 
-            WHERE id = $1 
-            FOR UPDATE`, t.FromUser.ID)
-		if txErr != nil {
-			return fmt.Errorf("failed to get user balance: %w", txErr)
+	entries, err := t.Entries()
+	if err != nil {
+		return nil, err
+	}
+	var saved *coin.Transaction
+	err = r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		if err := r.checkDebits(ctx, entries); err != nil {
+			return err
 		}
+		var err error
+		saved, err = r.postTransaction(ctx, t)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		if currentBalance < t.Amount {
-			return coin.ErrNotEnoughCoins
-		}
-		result, err := r.db.Exec(ctx, `
-            UPDATE users 
-            SET coin_balance = coin_balance - $2
-            WHERE id = $1`, t.FromUser.ID, t.Amount)
+	return saved, nil
+}
+
+// SaveBatch records all transfers of the batch in one transaction.
+// The sender is locked once and the batch total is checked against the balance.
+func (r *PgRepository) SaveBatch(ctx context.Context, b *coin.Batch) (*coin.Batch, error) {
+	if b == nil || b.From == nil || len(b.Transfers) == 0 {
+		return nil, errors.New("invalid batch")
+	}
+	var entries []coin.Entry
+	for _, t := range b.Transfers {
+		e, err := t.Entries()
 		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e...)
+	}
+	saved := &coin.Batch{From: b.From, Transfers: make([]*coin.Transaction, len(b.Transfers))}
+	err := r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		if err := r.checkDebits(ctx, entries); err != nil {
 			return err
 		}
-		rowsAffected := result.RowsAffected()
-		if rowsAffected == 0 {
-			return errors.New("failed to update sender balance")
+		var row struct {
+			ID        int64     `db:"id"`
+			CreatedAt time.Time `db:"created_at"`
 		}
-		var txID int64
-		if t.ToUser != nil {
-			// Lock recipient row
-			result, err = r.db.Exec(ctx, `
-                UPDATE users 
-                SET coin_balance = coin_balance + $2
-                WHERE id = $1`, t.ToUser.ID, t.Amount)
-			if err != nil {
+		err := r.db.Get(ctx, &row, `
+INSERT INTO transfer_batches (fk_from_user, transfers)
+VALUES ($1, $2)
+RETURNING id, created_at`, b.From.ID, len(b.Transfers))
+		if err != nil {
+			return fmt.Errorf("failed to save batch: %w", err)
+		}
+		saved.ID = coin.BatchID(row.ID)
+		saved.CreatedAt = row.CreatedAt
+		for i, t := range b.Transfers {
+			leg := *t
+			leg.BatchID = saved.ID
+			if saved.Transfers[i], err = r.postTransaction(ctx, &leg); err != nil {
 				return err
 			}
-			if result.RowsAffected() == 0 {
-				return errors.New("failed to update recipient balance")
-			}
-		} else {
-			txErr = r.db.Get(ctx, &txID, `
-	INSERT INTO transactions (fk_from_user, amount, type)
-	VALUES ($1, $2, $3) RETURNING id;
-	`, t.FromUser.ID, t.Amount, t.Type)
 		}
-		if txErr != nil {
-			return txErr
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// checkDebits locks the debited users and checks that each can afford the sum of its debits.
+// It must be called inside RunInTransaction.
+func (r *PgRepository) checkDebits(ctx context.Context, entries []coin.Entry) error {
+	debits := make(map[auth.UserID]int)
+	for _, e := range entries {
+		if e.Account == coin.UserAccount && e.Amount < 0 {
+			debits[e.UserID] -= e.Amount
+		}
+	}
+	if err := r.LockUsers(ctx, slices.Collect(maps.Keys(debits))...); err != nil {
+		return err
+	}
+	for userID, amount := range debits {
+		balance, err := r.GetBalance(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if balance < amount {
+			return coin.ErrNotEnoughCoins
 		}
+	}
+	return nil
+}
 
+// LockUsers locks user rows in ascending ID order, so transactions touching
+// the same users always acquire locks in the same order and can't deadlock.
+// FOR NO KEY UPDATE doesn't conflict with the KEY SHARE locks taken by foreign keys,
+// so crediting a locked user (e.g. a popular recipient) doesn't wait for the lock.
+// It must be called inside RunInTransaction.
+func (r *PgRepository) LockUsers(ctx context.Context, userIDs ...auth.UserID) error {
+	if len(userIDs) == 0 {
 		return nil
-	})
+	}
+	ids := make([]int64, len(userIDs))
+	for i, id := range userIDs {
+		ids[i] = int64(id)
+	}
+	slices.Sort(ids)
+	var locked []int64
+	err := r.db.Select(ctx, &locked, `
+SELECT id FROM users
+WHERE id = ANY($1)
+ORDER BY id
+FOR NO KEY UPDATE`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to lock users: %w", err)
+	}
+	if len(locked) != len(ids) {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
+
+// postTransaction inserts the transaction row and its balanced ledger entries,
+// updates the leaderboard aggregates and records the outbox event.
+// It must be called inside RunInTransaction.
+func (r *PgRepository) postTransaction(ctx context.Context, t *coin.Transaction) (*coin.Transaction, error) {
+	entries, err := t.Entries()
+	if err != nil {
+		return nil, err
+	}
+	var fromUser, toUser *auth.UserID
+	var batchID *coin.BatchID
+	if t.BatchID != 0 {
+		batchID = &t.BatchID
+	}
+	var poolID *coin.PoolID
+	if t.PoolID != 0 {
+		poolID = &t.PoolID
+	}
+	if t.FromUser != nil {
+		fromUser = &t.FromUser.ID
+	}
+	if t.ToUser != nil {
+		toUser = &t.ToUser.ID
+	}
+	status := t.Status
+	if status == "" {
+		status = coin.StatusCompleted
+	}
+	var expiresAt *time.Time
+	if !t.ExpiresAt.IsZero() {
+		expiresAt = &t.ExpiresAt
+	}
+
+	var row struct {
+		ID        int64     `db:"id"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+	tags := make([]string, len(t.Tags))
+	for i, tag := range t.Tags {
+		tags[i] = string(tag)
+	}
+	err = r.db.Get(ctx, &row, `
+INSERT INTO transactions (
+    fk_from_user, fk_to_user, amount, type, message, tags, fk_batch, fk_prev_transaction, status, expires_at, fk_pool
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING id, created_at`,
+		fromUser, toUser, t.Amount, t.Type, t.Message, tags, batchID, t.PrevTransaction, status, expiresAt, poolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save transaction: %w", err)
+	}
+	refunded, err := r.refundedTransactions(ctx, t)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.postEntries(ctx, coin.TransactionID(row.ID), entries, refunded...); err != nil {
+		return nil, err
+	}
+
+	saved := *t
+	saved.Status = status
+	saved.ID = coin.TransactionID(row.ID)
+	saved.CreatedAt = row.CreatedAt
+	if k, ok := transferKudos(&saved); ok {
+		if err := r.addKudos(ctx, k); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.saveTransferEvent(ctx, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
 
-	return t, nil
+// postEntries appends ledger entries to the transaction. Debits consume coin lots oldest first,
+// credits restore the lots consumed by the refunded transactions and open a new lot for the rest.
+// It must be called inside RunInTransaction.
+func (r *PgRepository) postEntries(ctx context.Context, id coin.TransactionID, entries []coin.Entry, refunded ...coin.TransactionID) error {
+	for _, e := range entries {
+		var userID *auth.UserID
+		if e.Account == coin.UserAccount {
+			userID = &e.UserID
+		}
+		_, err := r.db.Exec(ctx, `
+INSERT INTO ledger_entries (fk_transaction, account, fk_user, amount)
+VALUES ($1, $2, $3, $4)`, id, e.Account, userID, e.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to post ledger entry: %w", err)
+		}
+		switch {
+		case userID == nil:
+		case e.Amount > 0:
+			err = r.creditLots(ctx, e.UserID, id, e.Amount, refunded)
+		default:
+			err = r.consumeLots(ctx, e.UserID, id, -e.Amount)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type pgTransaction struct {
@@ -154,28 +412,46 @@ type pgTransaction struct {
 	Amount   int            `db:"amount"`
 	FromUser string         `db:"user_from_username"`
 	ToUser   sql.NullString `db:"user_to_username"`
+	Message  string         `db:"message"`
+	Tags     []string       `db:"tags"`
 }
 
-func (r *PgRepository) GetIncomingTransfers(ctx context.Context, userID auth.UserID) ([]*coin.Transaction, error) {
+func (t *pgTransaction) note() coin.Note {
+	note := coin.Note{Message: t.Message}
+	for _, tag := range t.Tags {
+		note.Tags = append(note.Tags, coin.Tag(tag))
+	}
+	return note
+}
+
+// GetIncomingTransfers returns the last limit transfers in ascending order, all if limit <= 0.
+func (r *PgRepository) GetIncomingTransfers(ctx context.Context, userID auth.UserID, limit int) ([]*coin.Transaction, error) {
 	query := `
 select
     t.id as id,
-    t.amount as amount,
+    e.amount as amount,
     f.username as user_from_username,
-    u.username as user_to_username
-from transactions t
+    u.username as user_to_username,
+    t.message as message,
+    t.tags as tags
+from ledger_entries e
+join transactions t on t.id = e.fk_transaction
 join users f on f.id = t.fk_from_user
-left join users u on u.id = t.fk_to_user
-where t.fk_to_user = $1;
+join users u on u.id = e.fk_user
+where e.account = $2 and e.fk_user = $1 and e.amount > 0 and t.type = $3
+  and t.status in ('completed', 'accepted')
+order by t.id desc
+limit $4;
 `
 	var res []pgTransaction
-	err := r.db.Select(ctx, &res, query, userID)
+	err := r.db.Select(ctx, &res, query, userID, coin.UserAccount, coin.Transfer, limitOrAll(limit))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, common.ErrNotFound
 		}
 		return nil, fmt.Errorf("GetIncomingTransfers: %v", err)
 	}
+	slices.Reverse(res)
 	result := make([]*coin.Transaction, len(res))
 	for id, row := range res {
 		result[id] = &coin.Transaction{
@@ -185,6 +461,7 @@ where t.fk_to_user = $1;
 			},
 
 			Amount: row.Amount,
+			Note:   row.note(),
 		}
 		if row.ToUser.Valid {
 			result[id].ToUser = &auth.User{
@@ -195,26 +472,34 @@ where t.fk_to_user = $1;
 	return result, nil
 }
 
-func (r *PgRepository) GetOutgoingTransfers(ctx context.Context, userID auth.UserID) ([]*coin.Transaction, error) {
+// GetOutgoingTransfers returns the last limit transfers in ascending order, all if limit <= 0.
+func (r *PgRepository) GetOutgoingTransfers(ctx context.Context, userID auth.UserID, limit int) ([]*coin.Transaction, error) {
 	query := `
 select
     t.id as id,
-    t.amount as amount,
+    -e.amount as amount,
     f.username as user_from_username,
-    u.username as user_to_username
-from transactions t
-join users f on f.id = t.fk_from_user
+    u.username as user_to_username,
+    t.message as message,
+    t.tags as tags
+from ledger_entries e
+join transactions t on t.id = e.fk_transaction
+join users f on f.id = e.fk_user
 left join users u on u.id = t.fk_to_user
-where t.fk_from_user = $1 and t.type = $2;
+where e.account = $2 and e.fk_user = $1 and e.amount < 0 and t.type = $3
+  and t.status in ('completed', 'accepted')
+order by t.id desc
+limit $4;
 `
 	var res []pgTransaction
-	err := r.db.Select(ctx, &res, query, userID, coin.Transfer)
+	err := r.db.Select(ctx, &res, query, userID, coin.UserAccount, coin.Transfer, limitOrAll(limit))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, common.ErrNotFound
 		}
 		return nil, fmt.Errorf("GetOutgoingTransfers: %v", err)
 	}
+	slices.Reverse(res)
 	result := make([]*coin.Transaction, len(res))
 	for id, row := range res {
 		var toUser *auth.User
@@ -230,48 +515,65 @@ where t.fk_from_user = $1 and t.type = $2;
 			},
 			ToUser: toUser,
 			Amount: row.Amount,
+			Note:   row.note(),
 		}
 	}
 	return result, nil
 }
 
+// limitOrAll maps a non-positive limit to LIMIT NULL, which returns all rows.
+func limitOrAll(limit int) *int {
+	if limit <= 0 {
+		return nil
+	}
+	return &limit
+}
+
 type pgMerch struct {
-	ID    int64  `db:"id"`
-	Name  string `db:"name"`
-	Price int    `db:"price"`
+	ID          int64  `db:"id"`
+	Name        string `db:"name"`
+	Price       int    `db:"price"`
+	Description string `db:"description"`
+	Available   bool   `db:"available"`
 }
 
+const merchColumns = `id, name, price, description, available`
+
 type pgPurchase struct {
-	ID          int64     `db:"id"`
-	UserID      int64     `db:"fk_user"`
-	MerchID     int64     `db:"fk_merch"`
-	MerchName   string    `db:"merch_name"` // Add this field
-	Quantity    int       `db:"quantity"`
-	PurchasedAt time.Time `db:"purchased_at"`
+	ID            int64         `db:"id"`
+	UserID        int64         `db:"fk_user"`
+	TransactionID sql.NullInt64 `db:"fk_transaction"`
+	MerchID       int64         `db:"fk_merch"`
+	MerchName     string        `db:"merch_name"` // Add this field
+	Quantity      int           `db:"quantity"`
+	PurchasedAt   time.Time     `db:"purchased_at"`
 }
 
 func mapMerch(m *pgMerch) *merch.Merch {
 	return &merch.Merch{
-		ID:    m.ID,
-		Name:  m.Name,
-		Price: m.Price,
+		ID:          m.ID,
+		Name:        m.Name,
+		Price:       m.Price,
+		Description: m.Description,
+		Available:   m.Available,
 	}
 }
 
 func mapPurchase(p *pgPurchase) *merch.Purchase {
 	return &merch.Purchase{
-		ID:          int(p.ID),
-		UserID:      auth.UserID(p.UserID),
-		MerchID:     p.MerchID,
-		MerchName:   p.MerchName, // Map the new field
-		Quantity:    p.Quantity,
-		PurchasedAt: p.PurchasedAt,
+		ID:            int(p.ID),
+		UserID:        auth.UserID(p.UserID),
+		TransactionID: coin.TransactionID(p.TransactionID.Int64),
+		MerchID:       p.MerchID,
+		MerchName:     p.MerchName, // Map the new field
+		Quantity:      p.Quantity,
+		PurchasedAt:   p.PurchasedAt,
 	}
 }
 
 // GetMerchByID returns the merch with the given ID.
 func (r *PgRepository) GetMerchByID(ctx context.Context, merchName string) (*merch.Merch, error) {
-	query := `SELECT id, name, price FROM merch WHERE name = $1`
+	query := `SELECT ` + merchColumns + ` FROM merch WHERE name = $1`
 	var m pgMerch
 	if err := r.db.Get(ctx, &m, query, merchName); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -282,15 +584,93 @@ func (r *PgRepository) GetMerchByID(ctx context.Context, merchName string) (*mer
 	return mapMerch(&m), nil
 }
 
-// SavePurchase saves a purchase record.
+// GetMerch returns the merch by ID.
+func (r *PgRepository) GetMerch(ctx context.Context, id int64) (*merch.Merch, error) {
+	var m pgMerch
+	if err := r.db.Get(ctx, &m, `SELECT `+merchColumns+` FROM merch WHERE id = $1`, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, merch.ErrMerchNotFound
+		}
+		return nil, fmt.Errorf("failed to get merch: %w", err)
+	}
+	return mapMerch(&m), nil
+}
+
+// ListMerch returns the whole catalog ordered by price and name.
+func (r *PgRepository) ListMerch(ctx context.Context) ([]*merch.Merch, error) {
+	var rows []pgMerch
+	if err := r.db.Select(ctx, &rows, `SELECT `+merchColumns+` FROM merch ORDER BY price, name`); err != nil {
+		return nil, fmt.Errorf("failed to list merch: %w", err)
+	}
+	res := make([]*merch.Merch, len(rows))
+	for i := range rows {
+		res[i] = mapMerch(&rows[i])
+	}
+	return res, nil
+}
+
+// UpdateMerch sets the non-nil fields of the update in a single statement, so concurrent updates
+// of different fields don't overwrite each other. The merch_catalog_bump trigger bumps the catalog version.
+func (r *PgRepository) UpdateMerch(ctx context.Context, id int64, upd merch.MerchUpdate) (*merch.Merch, error) {
+	var row pgMerch
+	err := r.db.Get(ctx, &row, `
+UPDATE merch SET
+    price = COALESCE($2::INTEGER, price),
+    description = COALESCE($3::TEXT, description),
+    available = COALESCE($4::BOOLEAN, available)
+WHERE id = $1
+RETURNING `+merchColumns, id, upd.Price, upd.Description, upd.Available)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, merch.ErrMerchNotFound
+		}
+		return nil, fmt.Errorf("failed to update merch: %w", err)
+	}
+	return mapMerch(&row), nil
+}
+
+// CatalogVersion returns the catalog version bumped by every change of merch.
+func (r *PgRepository) CatalogVersion(ctx context.Context) (int64, error) {
+	var version int64
+	if err := r.db.Get(ctx, &version, `SELECT version FROM merch_catalog`); err != nil {
+		return 0, fmt.Errorf("failed to get catalog version: %w", err)
+	}
+	return version, nil
+}
+
+// SavePurchase saves a purchase record and records PurchaseCompleted in the outbox.
 func (r *PgRepository) SavePurchase(ctx context.Context, p *merch.Purchase) error {
-	query := `INSERT INTO purchases (fk_user, fk_merch, quantity, purchased_at) VALUES ($1, $2, $3, $4) RETURNING id`
-	var id int64
-	if err := r.db.Get(ctx, &id, query, p.UserID, p.MerchID, p.Quantity, p.PurchasedAt); err != nil {
-		return fmt.Errorf("failed to save purchase: %w", err)
+	query := `
+INSERT INTO purchases (fk_user, fk_transaction, fk_merch, quantity, purchased_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING
+    id,
+    (SELECT u.username FROM users u WHERE u.id = purchases.fk_user) AS username,
+    (SELECT m.name FROM merch m WHERE m.id = purchases.fk_merch) AS merch_name,
+    (SELECT t.amount FROM transactions t WHERE t.id = purchases.fk_transaction) AS amount`
+	var row struct {
+		ID        int64  `db:"id"`
+		Username  string `db:"username"`
+		MerchName string `db:"merch_name"`
+		Amount    int    `db:"amount"`
 	}
-	p.ID = int(id)
-	return nil
+	return r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		err := r.db.Get(ctx, &row, query, p.UserID, p.TransactionID, p.MerchID, p.Quantity, p.PurchasedAt)
+		if err != nil {
+			return fmt.Errorf("failed to save purchase: %w", err)
+		}
+		p.ID = int(row.ID)
+		p.MerchName = row.MerchName
+		return r.saveEvent(ctx, outbox.PurchaseCompleted, outbox.Purchase{
+			PurchaseID:    row.ID,
+			TransactionID: int64(p.TransactionID),
+			UserID:        int64(p.UserID),
+			Username:      row.Username,
+			Item:          row.MerchName,
+			Quantity:      p.Quantity,
+			Amount:        row.Amount,
+		})
+	})
 }
 
 // ListPurchasesByUserID lists all purchases made by a user.
@@ -299,6 +679,7 @@ func (r *PgRepository) ListPurchasesByUserID(ctx context.Context, userID auth.Us
         SELECT
             p.id,
             p.fk_user,
+            p.fk_transaction,
             p.fk_merch,
             m.name as merch_name,
             p.quantity,
@@ -319,3 +700,25 @@ func (r *PgRepository) ListPurchasesByUserID(ctx context.Context, userID auth.Us
 	}
 	return result, nil
 }
+
+// ListPurchasesByTransactions lists the purchases paid by the given transactions.
+func (r *PgRepository) ListPurchasesByTransactions(ctx context.Context, ids []coin.TransactionID) ([]*merch.Purchase, error) {
+	raw := make([]int64, len(ids))
+	for i, id := range ids {
+		raw[i] = int64(id)
+	}
+	var purchases []pgPurchase
+	err := r.db.Select(ctx, &purchases, `
+SELECT p.id, p.fk_user, p.fk_transaction, p.fk_merch, m.name AS merch_name, p.quantity, p.purchased_at
+FROM purchases p
+JOIN merch m ON m.id = p.fk_merch
+WHERE p.fk_transaction = ANY($1)`, raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list purchases by transactions: %w", err)
+	}
+	result := make([]*merch.Purchase, len(purchases))
+	for i := range purchases {
+		result[i] = mapPurchase(&purchases[i])
+	}
+	return result, nil
+}
@@ -0,0 +1,994 @@
+//go:build integration
+
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"avito-intern/internal/leaderboard"
+	"avito-intern/internal/merch"
+	migration "avito-intern/internal/migrations"
+	"avito-intern/internal/outbox"
+	"avito-intern/internal/reconcile"
+	"avito-intern/internal/scheduler"
+	"avito-intern/internal/webhook"
+	"avito-intern/pkg/db"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Интеграционные тесты запускаются против реального PostgreSQL,
+// параметры подключения читаются из тех же переменных POSTGRES_*, что и у сервиса:
+//
+//	go test -tags integration ./storage/...
+func newTestRepo(t *testing.T) (*PgRepository, *db.Database) {
+	t.Helper()
+	var cfg db.Config
+	require.NoError(t, cleanenv.ReadEnv(&cfg))
+
+	ctx := context.Background()
+	database, err := db.NewDB(ctx, cfg)
+	require.NoError(t, err)
+
+	ready := migration.Migrate(database)
+	require.Eventually(t, ready, time.Minute, 100*time.Millisecond, "migrations did not finish")
+
+	return NewRepo(database, nil), database
+}
+
+func createTestUsers(t *testing.T, repo *PgRepository, n, coins int) []*auth.User {
+	t.Helper()
+	users := make([]*auth.User, n)
+	prefix := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+	for i := range users {
+		u, err := repo.CreateUser(context.Background(), fmt.Sprintf("%s-%d", prefix, i), "hash", coins)
+		require.NoError(t, err)
+		users[i] = u
+	}
+	return users
+}
+
+func totalBalance(t *testing.T, repo *PgRepository, users []*auth.User) int {
+	t.Helper()
+	total := 0
+	for _, u := range users {
+		balance, err := repo.GetBalance(context.Background(), u.ID)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, balance, 0, "balance of %s went negative", u.Username)
+		total += balance
+	}
+	return total
+}
+
+// TestSaveTransaction_ConcurrentTransfers гоняет встречные переводы между
+// небольшой группой пользователей: без упорядоченных блокировок A→B и B→A
+// взаимно блокируются, а на уровне serializable транзакции должны повторяться.
+func TestSaveTransaction_ConcurrentTransfers(t *testing.T) {
+	repo, database := newTestRepo(t)
+
+	isoLevels := []pgx.TxIsoLevel{pgx.ReadCommitted, pgx.RepeatableRead, pgx.Serializable}
+	for _, isoLevel := range isoLevels {
+		t.Run(string(isoLevel), func(t *testing.T) {
+			const (
+				usersCount = 4
+				coins      = 100
+				workers    = 32
+				transfers  = 25
+			)
+			users := createTestUsers(t, repo, usersCount, coins)
+			opts := db.TxOptions{
+				IsoLevel:   isoLevel,
+				MaxRetries: 50,
+				Backoff:    time.Millisecond,
+				MaxBackoff: 50 * time.Millisecond,
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, workers*transfers)
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < transfers; i++ {
+						from := users[(w+i)%usersCount]
+						to := users[(w+i+1+w%(usersCount-1))%usersCount]
+						if from.ID == to.ID {
+							continue
+						}
+						err := database.RunInTransactionWithOptions(context.Background(), opts, func(ctx context.Context) error {
+							_, err := repo.SaveTransaction(ctx, &coin.Transaction{
+								FromUser: from,
+								ToUser:   to,
+								Amount:   1 + i%7,
+								Type:     coin.Transfer,
+							})
+							return err
+						})
+						if err != nil && !errors.Is(err, coin.ErrNotEnoughCoins) {
+							errs <- err
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, usersCount*coins, totalBalance(t, repo, users), "coins must be conserved")
+		})
+	}
+}
+
+func TestSaveTransaction_NotEnoughCoins(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	users := createTestUsers(t, repo, 2, 10)
+
+	_, err := repo.SaveTransaction(context.Background(), &coin.Transaction{
+		FromUser: users[0],
+		ToUser:   users[1],
+		Amount:   11,
+		Type:     coin.Transfer,
+	})
+	assert.ErrorIs(t, err, coin.ErrNotEnoughCoins)
+	assert.Equal(t, 20, totalBalance(t, repo, users))
+}
+
+func TestSaveBatch_AllOrNothing(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	users := createTestUsers(t, repo, 3, 10)
+	batch := func(amounts ...int) *coin.Batch {
+		b := &coin.Batch{From: users[0]}
+		for i, amount := range amounts {
+			b.Transfers = append(b.Transfers, &coin.Transaction{
+				FromUser: users[0],
+				ToUser:   users[i+1],
+				Amount:   amount,
+				Type:     coin.Transfer,
+			})
+		}
+		return b
+	}
+
+	// каждый перевод по отдельности проходит, но сумма больше баланса.
+	_, err := repo.SaveBatch(context.Background(), batch(6, 6))
+	assert.ErrorIs(t, err, coin.ErrNotEnoughCoins)
+	for _, u := range users {
+		balance, err := repo.GetBalance(context.Background(), u.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 10, balance)
+	}
+
+	saved, err := repo.SaveBatch(context.Background(), batch(4, 6))
+	require.NoError(t, err)
+	assert.NotZero(t, saved.ID)
+	for _, tx := range saved.Transfers {
+		assert.Equal(t, saved.ID, tx.BatchID)
+	}
+	balance, err := repo.GetBalance(context.Background(), users[0].ID)
+	require.NoError(t, err)
+	assert.Zero(t, balance)
+}
+
+func TestSaveReversal_Partial(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	users := createTestUsers(t, repo, 3, 100)
+	sender, recipient, admin := users[0], users[1], users[2]
+	ctx := context.Background()
+
+	original, err := repo.SaveTransaction(ctx, &coin.Transaction{
+		FromUser: sender, ToUser: recipient, Amount: 100, Type: coin.Transfer,
+	})
+	require.NoError(t, err)
+
+	prev := int64(original.ID)
+	_, err = repo.SaveReversal(ctx, &coin.ReversalRecord{
+		Transaction: &coin.Transaction{
+			FromUser: recipient, ToUser: sender, Amount: 30, Type: coin.Reversal, PrevTransaction: &prev,
+		},
+		Original:  original,
+		Admin:     admin,
+		Reason:    "wrong recipient",
+		Requested: 30,
+	})
+	require.NoError(t, err)
+
+	reversed, err := repo.SumReversed(ctx, original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 30, reversed)
+
+	got, err := repo.GetTransaction(ctx, original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sender.ID, got.FromUser.ID)
+	assert.Equal(t, recipient.Username, got.ToUser.Username)
+
+	_, err = repo.GetTransaction(ctx, original.ID+1_000_000)
+	assert.ErrorIs(t, err, coin.ErrTransactionNotFound)
+	assert.Equal(t, 300, totalBalance(t, repo, users))
+}
+
+func TestSettlePending(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	users := createTestUsers(t, repo, 2, 100)
+	sender, recipient := users[0], users[1]
+	ctx := context.Background()
+
+	balanceOf := func(u *auth.User) int {
+		balance, err := repo.GetBalance(ctx, u.ID)
+		require.NoError(t, err)
+		return balance
+	}
+	send := func() *coin.Transaction {
+		tx, err := repo.SaveTransaction(ctx, &coin.Transaction{
+			FromUser: sender, ToUser: recipient, Amount: 30, Type: coin.Transfer,
+			Status: coin.StatusPending, ExpiresAt: time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+		return tx
+	}
+
+	accepted := send()
+	assert.Equal(t, 70, balanceOf(sender))
+	assert.Equal(t, 100, balanceOf(recipient))
+	settledFrom := time.Now()
+	_, err := repo.SettlePending(ctx, accepted.ID, coin.StatusAccepted)
+	require.NoError(t, err)
+	assert.Equal(t, 130, balanceOf(recipient))
+
+	// строка истории датируется проводкой, а не созданием перевода, как и границы периода.
+	history, err := repo.ListHistory(ctx, recipient.ID, coin.HistoryFilter{From: settledFrom, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.False(t, history[0].CreatedAt.Before(settledFrom))
+
+	_, err = repo.SettlePending(ctx, accepted.ID, coin.StatusDeclined)
+	assert.ErrorIs(t, err, coin.ErrNotPending)
+
+	expired := send()
+	ids, err := repo.ListExpiredPending(ctx, time.Now().Add(2*time.Hour), 100)
+	require.NoError(t, err)
+	assert.Contains(t, ids, expired.ID)
+	_, err = repo.SettlePending(ctx, expired.ID, coin.StatusExpired)
+	require.NoError(t, err)
+	assert.Equal(t, 70, balanceOf(sender))
+	assert.Equal(t, 200, totalBalance(t, repo, users))
+}
+
+func TestScheduledGrant_OncePerPeriod(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	users := createTestUsers(t, repo, 1, 0)
+	ctx := context.Background()
+
+	job, err := repo.CreateJob(ctx, &scheduler.Job{
+		Name:      fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano()),
+		Kind:      coin.GrantJob,
+		Schedule:  "@monthly",
+		Params:    []byte(`{"amount":200}`),
+		Enabled:   true,
+		NextRunAt: time.Now(),
+	})
+	require.NoError(t, err)
+	period := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	run, started, err := repo.StartRun(ctx, job.ID, period, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.True(t, started)
+	_, started, err = repo.StartRun(ctx, job.ID, period, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.False(t, started, "running run must not be started twice")
+
+	grant := func() error {
+		return repo.SaveScheduledGrant(ctx, &coin.ScheduledGrant{
+			JobID:       job.ID,
+			Period:      period,
+			Transaction: &coin.Transaction{ToUser: users[0], Amount: 200, Type: coin.Grant},
+		})
+	}
+	require.NoError(t, grant())
+	assert.ErrorIs(t, grant(), coin.ErrAlreadyGranted)
+	assert.Equal(t, 200, totalBalance(t, repo, users))
+
+	run.Status = scheduler.RunFailed
+	run.FinishedAt = time.Now()
+	require.NoError(t, repo.FinishRun(ctx, run))
+	_, started, err = repo.StartRun(ctx, job.ID, period, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.True(t, started, "failed run must be restarted")
+}
+
+func TestCoinLots_FIFO(t *testing.T) {
+	repo, database := newTestRepo(t)
+	users := createTestUsers(t, repo, 2, 100)
+	sender, recipient := users[0], users[1]
+	ctx := context.Background()
+
+	// стартовая партия отправителя получена год назад.
+	_, err := database.Exec(ctx, `UPDATE coin_lots SET received_at = NOW() - INTERVAL '1 year' WHERE fk_user = $1`, sender.ID)
+	require.NoError(t, err)
+	_, err = repo.SaveTransaction(ctx, &coin.Transaction{ToUser: sender, Amount: 50, Type: coin.Grant})
+	require.NoError(t, err)
+
+	// перевод расходует сначала старую партию.
+	_, err = repo.SaveTransaction(ctx, &coin.Transaction{FromUser: sender, ToUser: recipient, Amount: 70, Type: coin.Transfer})
+	require.NoError(t, err)
+	lots, err := repo.ListOpenLots(ctx, sender.ID, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, lots, 2)
+	assert.Equal(t, 30, lots[0].Remaining)
+	assert.Equal(t, 50, lots[1].Remaining)
+
+	cutoff := time.Now().AddDate(0, -6, 0)
+	ids, err := repo.ListUsersWithExpiredLots(ctx, cutoff, 1000)
+	require.NoError(t, err)
+	assert.Contains(t, ids, sender.ID)
+	assert.NotContains(t, ids, recipient.ID)
+
+	expired, err := repo.ExpireLots(ctx, sender.ID, cutoff)
+	require.NoError(t, err)
+	require.NotNil(t, expired)
+	assert.Equal(t, 30, expired.Amount)
+	balance, err := repo.GetBalance(ctx, sender.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 50, balance)
+
+	expired, err = repo.ExpireLots(ctx, sender.ID, cutoff)
+	require.NoError(t, err)
+	assert.Nil(t, expired)
+}
+
+// TestCoinLots_RefundRestoresLots проверяет, что возвраты не продлевают срок действия монет:
+// отклонённый перевод, reversal и отмена сбора возвращают монеты в исходную партию.
+func TestCoinLots_RefundRestoresLots(t *testing.T) {
+	repo, database := newTestRepo(t)
+	users := createTestUsers(t, repo, 3, 100)
+	sender, recipient, organizer := users[0], users[1], users[2]
+	ctx := context.Background()
+
+	_, err := database.Exec(ctx, `UPDATE coin_lots SET received_at = NOW() - INTERVAL '1 year' WHERE fk_user = $1`, sender.ID)
+	require.NoError(t, err)
+	assertSingleOldLot := func() {
+		t.Helper()
+		lots, err := repo.ListOpenLots(ctx, sender.ID, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, lots, 1)
+		assert.Equal(t, 100, lots[0].Remaining)
+		assert.True(t, lots[0].ReceivedAt.Before(time.Now().AddDate(0, -6, 0)))
+	}
+
+	pending, err := repo.SaveTransaction(ctx, &coin.Transaction{
+		FromUser: sender, ToUser: recipient, Amount: 30, Type: coin.Transfer,
+		Status: coin.StatusPending, ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	_, err = repo.SettlePending(ctx, pending.ID, coin.StatusDeclined)
+	require.NoError(t, err)
+	assertSingleOldLot()
+
+	transfer, err := repo.SaveTransaction(ctx, &coin.Transaction{FromUser: sender, ToUser: recipient, Amount: 40, Type: coin.Transfer})
+	require.NoError(t, err)
+	prev := int64(transfer.ID)
+	// частичные возвраты вместе не восстанавливают больше, чем было списано.
+	for _, amount := range []int{25, 15} {
+		_, err = repo.SaveTransaction(ctx, &coin.Transaction{
+			FromUser: recipient, ToUser: sender, Amount: amount, Type: coin.Reversal, PrevTransaction: &prev,
+		})
+		require.NoError(t, err)
+	}
+	assertSingleOldLot()
+
+	pool, err := repo.CreatePool(ctx, &coin.Pool{Organizer: organizer, Recipient: recipient, Title: "Отмена"})
+	require.NoError(t, err)
+	_, err = repo.SavePoolContribution(ctx, &coin.Transaction{
+		FromUser: sender, ToUser: recipient, Amount: 20, Type: coin.PoolContribution, PoolID: pool.ID,
+	})
+	require.NoError(t, err)
+	_, err = repo.ClosePool(ctx, pool.ID, coin.PoolCancelled)
+	require.NoError(t, err)
+	assertSingleOldLot()
+
+	expired, err := repo.ExpireLots(ctx, sender.ID, time.Now().AddDate(0, -6, 0))
+	require.NoError(t, err)
+	require.NotNil(t, expired)
+	assert.Equal(t, 100, expired.Amount)
+}
+
+func TestSaveCorrection(t *testing.T) {
+	repo, database := newTestRepo(t)
+	users := createTestUsers(t, repo, 2, 100)
+	admin, user := users[0], users[1]
+	ctx := context.Background()
+
+	// запись о начислении расходится с журналом, а партии — с балансом.
+	_, err := database.Exec(ctx, `UPDATE transactions SET amount = 130 WHERE fk_to_user = $1 AND type = 'grant'`, user.ID)
+	require.NoError(t, err)
+	_, err = database.Exec(ctx, `UPDATE coin_lots SET remaining = 90 WHERE fk_user = $1`, user.ID)
+	require.NoError(t, err)
+
+	balances, err := repo.ListBalances(ctx)
+	require.NoError(t, err)
+	var found *reconcile.Balance
+	for _, b := range balances {
+		if b.UserID == user.ID {
+			found = b
+		}
+	}
+	require.NotNil(t, found)
+	assert.Equal(t, reconcile.Balance{UserID: user.ID, Username: user.Username, Ledger: 100, History: 130, Lots: 90}, *found)
+
+	stale := *found
+	stale.Ledger = 99
+	_, err = repo.SaveCorrection(ctx, &reconcile.Correction{Balance: stale, Admin: admin, Reason: "сверка"})
+	assert.ErrorIs(t, err, reconcile.ErrStaleBalance)
+
+	c, err := repo.SaveCorrection(ctx, &reconcile.Correction{Balance: *found, Admin: admin, Reason: "сверка"})
+	require.NoError(t, err)
+	require.NotNil(t, c.Transaction)
+	assert.Equal(t, coin.Adjustment, c.Transaction.Type)
+	assert.Equal(t, 30, c.Transaction.Amount)
+
+	balances, err = repo.ListBalances(ctx)
+	require.NoError(t, err)
+	for _, b := range balances {
+		if b.UserID == user.ID {
+			assert.Equal(t, 130, b.Ledger)
+			assert.True(t, b.Consistent(), "balance is still inconsistent: %+v", b)
+		}
+	}
+}
+
+func TestKudosAggregates(t *testing.T) {
+	repo, database := newTestRepo(t)
+	users := createTestUsers(t, repo, 2, 100)
+	alice, bob := users[0], users[1]
+	ctx := context.Background()
+
+	transfer, err := repo.SaveTransaction(ctx, &coin.Transaction{FromUser: alice, ToUser: bob, Amount: 30, Type: coin.Transfer})
+	require.NoError(t, err)
+	_, err = repo.SaveBatch(ctx, &coin.Batch{From: alice, Transfers: []*coin.Transaction{
+		{FromUser: alice, ToUser: bob, Amount: 20, Type: coin.Transfer},
+	}})
+	require.NoError(t, err)
+	// перевод с подтверждением попадает в рейтинг, только когда его примут.
+	pending, err := repo.SaveTransaction(ctx, &coin.Transaction{
+		FromUser: alice, ToUser: bob, Amount: 10, Type: coin.Transfer,
+		Status: coin.StatusPending, ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	totals := func(u *auth.User) (givenCount, givenAmount, receivedCount, receivedAmount int) {
+		var row struct {
+			GivenCount     int `db:"given_count"`
+			GivenAmount    int `db:"given_amount"`
+			ReceivedCount  int `db:"received_count"`
+			ReceivedAmount int `db:"received_amount"`
+		}
+		require.NoError(t, database.Get(ctx, &row, `SELECT given_count, given_amount, received_count, received_amount FROM kudos_totals WHERE fk_user = $1`, u.ID))
+		return row.GivenCount, row.GivenAmount, row.ReceivedCount, row.ReceivedAmount
+	}
+	gc, ga, _, _ := totals(alice)
+	assert.Equal(t, []int{2, 50}, []int{gc, ga})
+
+	_, err = repo.SettlePending(ctx, pending.ID, coin.StatusAccepted)
+	require.NoError(t, err)
+	prev := int64(transfer.ID)
+	_, err = repo.SaveTransaction(ctx, &coin.Transaction{
+		FromUser: bob, ToUser: alice, Amount: 5, Type: coin.Reversal, PrevTransaction: &prev,
+	})
+	require.NoError(t, err)
+	_, _, rc, ra := totals(bob)
+	assert.Equal(t, []int{3, 55}, []int{rc, ra})
+
+	var daily int
+	require.NoError(t, database.Get(ctx, &daily, `
+SELECT received_amount FROM kudos_daily WHERE fk_user = $1 AND day = (NOW() AT TIME ZONE 'UTC')::date`, bob.ID))
+	assert.Equal(t, 55, daily)
+
+	require.NoError(t, repo.SetLeaderboardOptOut(ctx, bob.ID, true))
+	all, err := repo.ListTop(ctx, leaderboard.Receivers, time.Time{}, leaderboard.ByAmount, leaderboard.MaxLimit)
+	require.NoError(t, err)
+	for _, e := range all {
+		assert.NotEqual(t, bob.ID, e.UserID, "opted out user must not be listed")
+	}
+}
+
+// TestKudosAggregates_OpposingTransfers гоняет встречные переводы без повторов транзакций:
+// агрегаты обоих участников должны обновляться без deadlock.
+func TestKudosAggregates_OpposingTransfers(t *testing.T) {
+	repo, database := newTestRepo(t)
+	users := createTestUsers(t, repo, 2, 1000)
+	opts := db.TxOptions{IsoLevel: pgx.ReadCommitted}
+
+	const rounds = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*rounds)
+	for w := 0; w < 2; w++ {
+		from, to := users[w], users[1-w]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				errs <- database.RunInTransactionWithOptions(context.Background(), opts, func(ctx context.Context) error {
+					_, err := repo.SaveTransaction(ctx, &coin.Transaction{FromUser: from, ToUser: to, Amount: 1, Type: coin.Transfer})
+					return err
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2000, totalBalance(t, repo, users))
+}
+
+func TestPools(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	users := createTestUsers(t, repo, 3, 100)
+	alice, bob, carol := users[0], users[1], users[2]
+	ctx := context.Background()
+
+	contribute := func(pool *coin.Pool, from *auth.User, amount int) error {
+		_, err := repo.SavePoolContribution(ctx, &coin.Transaction{
+			FromUser: from, ToUser: pool.Recipient, Amount: amount, Type: coin.PoolContribution, PoolID: pool.ID,
+		})
+		return err
+	}
+
+	gift, err := repo.CreatePool(ctx, &coin.Pool{Organizer: alice, Recipient: carol, Title: "С днём рождения", Goal: 100})
+	require.NoError(t, err)
+	require.NoError(t, contribute(gift, alice, 30))
+	require.NoError(t, contribute(gift, bob, 20))
+	require.NoError(t, contribute(gift, bob, 10))
+	assert.ErrorIs(t, contribute(gift, bob, 1000), coin.ErrNotEnoughCoins)
+
+	// взносы учитываются лимитами переводов как переводы получателю сбора.
+	since := time.Now().Add(-time.Hour)
+	sent, err := repo.SumOutgoingTransfers(ctx, bob.ID, &carol.ID, since)
+	require.NoError(t, err)
+	assert.Equal(t, 30, sent)
+	last, err := repo.LastOutgoingTransferAt(ctx, bob.ID)
+	require.NoError(t, err)
+	assert.False(t, last.IsZero())
+
+	gift, err = repo.GetPool(ctx, gift.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 60, gift.Collected)
+	assert.Equal(t, 2, gift.Contributors)
+	// до закрытия монеты лежат в escrow.
+	balance, err := repo.GetBalance(ctx, carol.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 100, balance)
+
+	gift, err = repo.ClosePool(ctx, gift.ID, coin.PoolPaidOut)
+	require.NoError(t, err)
+	assert.Equal(t, coin.PoolPaidOut, gift.Status)
+	_, err = repo.ClosePool(ctx, gift.ID, coin.PoolCancelled)
+	assert.ErrorIs(t, err, coin.ErrPoolClosed)
+	assert.ErrorIs(t, contribute(gift, bob, 5), coin.ErrPoolClosed)
+
+	balance, err = repo.GetBalance(ctx, carol.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 160, balance)
+	history, err := repo.ListHistory(ctx, carol.ID, coin.HistoryFilter{Types: []coin.Type{coin.PoolPayout}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, 60, history[0].Amount)
+	assert.Equal(t, gift.ID, history[0].PoolID)
+
+	farewell, err := repo.CreatePool(ctx, &coin.Pool{
+		Organizer: bob, Recipient: alice, Title: "Проводы", Deadline: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.NoError(t, contribute(farewell, bob, 15))
+	require.NoError(t, contribute(farewell, carol, 25))
+	_, err = repo.ClosePool(ctx, farewell.ID, coin.PoolCancelled)
+	require.NoError(t, err)
+	for user, want := range map[*auth.User]int{alice: 70, bob: 70, carol: 160} {
+		balance, err := repo.GetBalance(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, want, balance, user.Username)
+	}
+
+	pools, err := repo.ListPools(ctx, bob.ID)
+	require.NoError(t, err)
+	require.Len(t, pools, 2)
+	assert.Equal(t, farewell.ID, pools[0].ID)
+
+	empty, err := repo.CreatePool(ctx, &coin.Pool{Organizer: alice, Recipient: bob, Title: "Пусто"})
+	require.NoError(t, err)
+	_, err = repo.ClosePool(ctx, empty.ID, coin.PoolPaidOut)
+	assert.ErrorIs(t, err, coin.ErrPoolEmpty)
+	empty, err = repo.GetPool(ctx, empty.ID)
+	require.NoError(t, err)
+	assert.Equal(t, coin.PoolOpen, empty.Status, "failed payout must not close the pool")
+}
+
+func TestOutboxEvents(t *testing.T) {
+	repo, database := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.RegisterConsumers(ctx, []string{webhook.ConsumerName, outbox.SinkConsumer}))
+	// регистрация одного получателя не удаляет остальных.
+	require.NoError(t, repo.RegisterConsumers(ctx, []string{webhook.ConsumerName}))
+	consumers, err := repo.ListConsumers(ctx)
+	require.NoError(t, err)
+	assert.Subset(t, consumers, []string{webhook.ConsumerName, outbox.SinkConsumer})
+	users := createTestUsers(t, repo, 2, 100)
+	alice, bob := users[0], users[1]
+
+	_, err = repo.SaveTransaction(ctx, &coin.Transaction{FromUser: alice, ToUser: bob, Amount: 30, Type: coin.Transfer})
+	require.NoError(t, err)
+	pending, err := repo.SaveTransaction(ctx, &coin.Transaction{
+		FromUser: alice, ToUser: bob, Amount: 10, Type: coin.Transfer,
+		Status: coin.StatusPending, ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	_, err = repo.SettlePending(ctx, pending.ID, coin.StatusAccepted)
+	require.NoError(t, err)
+	// откаченная транзакция не оставляет события.
+	_, err = repo.SaveTransaction(ctx, &coin.Transaction{FromUser: alice, ToUser: bob, Amount: 1000, Type: coin.Transfer})
+	require.ErrorIs(t, err, coin.ErrNotEnoughCoins)
+
+	item, err := repo.GetMerchByID(ctx, "cup")
+	require.NoError(t, err)
+	purchase := &merch.Purchase{UserID: bob.ID, MerchID: item.ID, Quantity: 1, PurchasedAt: time.Now()}
+	require.NoError(t, database.RunInTransaction(ctx, func(ctx context.Context) error {
+		tx, err := repo.SaveTransaction(ctx, &coin.Transaction{FromUser: bob, Amount: item.Price, Type: coin.Purchase})
+		if err != nil {
+			return err
+		}
+		purchase.TransactionID = tx.ID
+		return repo.SavePurchase(ctx, purchase)
+	}))
+
+	var rows []struct {
+		Type    string `db:"type"`
+		Payload string `db:"payload"`
+	}
+	require.NoError(t, database.Select(ctx, &rows, `
+SELECT type, payload::text AS payload FROM outbox_events
+WHERE (payload->>'userId')::bigint IN ($1, $2) OR (payload->>'fromUserId')::bigint = $1
+ORDER BY id`, alice.ID, bob.ID))
+	types := make([]outbox.EventType, len(rows))
+	for i, row := range rows {
+		types[i] = outbox.EventType(row.Type)
+	}
+	assert.Equal(t, []outbox.EventType{
+		outbox.UserRegistered, outbox.UserRegistered,
+		outbox.TransferCompleted, outbox.TransferCompleted,
+		outbox.PurchaseCompleted,
+	}, types)
+	assert.JSONEq(t, fmt.Sprintf(`{"purchaseId":%d,"transactionId":%d,"userId":%d,"username":%q,"item":"cup","quantity":1,"amount":%d}`,
+		purchase.ID, purchase.TransactionID, bob.ID, bob.Username, item.Price), rows[4].Payload)
+
+	events, err := repo.ListUndelivered(ctx, outbox.SinkConsumer, 1000)
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+	ids := []outbox.EventID{events[0].ID}
+	require.NoError(t, repo.MarkFailed(ctx, outbox.SinkConsumer, ids, "boom"))
+	failed, err := repo.ListUndelivered(ctx, outbox.SinkConsumer, 1)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, 1, failed[0].Attempts)
+	require.NoError(t, repo.MarkDelivered(ctx, outbox.SinkConsumer, ids, time.Now()))
+	rest, err := repo.ListUndelivered(ctx, outbox.SinkConsumer, 1000)
+	require.NoError(t, err)
+	for _, e := range rest {
+		assert.NotEqual(t, ids[0], e.ID)
+	}
+
+	// у подписок на вебхуки своя очередь: доставка и попытки приёмника её не меняют.
+	webhooks, err := repo.ListUndelivered(ctx, webhook.ConsumerName, 1000)
+	require.NoError(t, err)
+	var found bool
+	for _, e := range webhooks {
+		if e.ID == ids[0] {
+			found = true
+			assert.Zero(t, e.Attempts)
+		}
+	}
+	assert.True(t, found, "event delivered to the sink must stay queued for webhooks")
+
+	// отложенное в dead letter событие больше не доставляется.
+	require.NotEmpty(t, rest)
+	dead := []outbox.EventID{rest[0].ID}
+	require.NoError(t, repo.MarkDead(ctx, outbox.SinkConsumer, dead, time.Now()))
+	rest, err = repo.ListUndelivered(ctx, outbox.SinkConsumer, 1000)
+	require.NoError(t, err)
+	for _, e := range rest {
+		assert.NotEqual(t, dead[0], e.ID)
+	}
+
+	// событие удаляется, только когда его получили все получатели.
+	before := time.Now().Add(time.Hour)
+	require.NoError(t, repo.MarkDelivered(ctx, webhook.ConsumerName, ids, time.Now()))
+	_, err = repo.DeleteDelivered(ctx, before)
+	require.NoError(t, err)
+	var left []int64
+	require.NoError(t, database.Select(ctx, &left,
+		`SELECT id FROM outbox_events WHERE id = ANY($1) ORDER BY id`, []int64{int64(ids[0]), int64(dead[0])}))
+	assert.Equal(t, []int64{int64(dead[0])}, left)
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	users := createTestUsers(t, repo, 2, 100)
+	alice, bob := users[0], users[1]
+	ctx := context.Background()
+
+	sub, err := repo.CreateSubscription(ctx, &webhook.Subscription{
+		Owner: bob.ID, URL: "http://localhost:9000/hook", Events: []webhook.Event{webhook.CoinsReceived},
+		Secret: "0123456789abcdef", Active: true,
+	})
+	require.NoError(t, err)
+	_, err = repo.CreateSubscription(ctx, &webhook.Subscription{
+		Owner: alice.ID, URL: "http://localhost:9000/hook", Events: []webhook.Event{webhook.MerchPurchased},
+		Secret: "0123456789abcdef", Active: true,
+	})
+	require.NoError(t, err)
+
+	d := &webhook.Delivery{
+		EventID: 1 << 40, Event: webhook.CoinsReceived, Type: outbox.TransferCompleted,
+		Payload: []byte(`{"amount":10}`), OccurredAt: time.Now(),
+	}
+	n, err := repo.EnqueueDeliveries(ctx, bob.ID, d)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	// повторная публикация пачки не создаёт вторую доставку.
+	n, err = repo.EnqueueDeliveries(ctx, bob.ID, d)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	// подписка alice не на это событие.
+	d.Event = webhook.CoinsSent
+	n, err = repo.EnqueueDeliveries(ctx, alice.ID, d)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	claim := func() *webhook.Delivery {
+		now := time.Now().Add(time.Hour)
+		claimed, err := repo.ClaimDeliveries(ctx, now, now.Add(time.Hour), 1000)
+		require.NoError(t, err)
+		for _, c := range claimed {
+			if c.Subscription.ID == sub.ID {
+				return c
+			}
+		}
+		return nil
+	}
+	claimed := claim()
+	require.NotNil(t, claimed)
+	assert.Equal(t, sub.URL, claimed.Subscription.URL)
+	assert.Equal(t, sub.Secret, claimed.Subscription.Secret)
+	assert.JSONEq(t, `{"amount":10}`, string(claimed.Payload))
+	// взятая доставка отложена до конца аренды.
+	assert.Nil(t, claim())
+
+	claimed.Attempts, claimed.Status, claimed.ResponseStatus, claimed.Error = 1, webhook.DeliveryPending, 500, "boom"
+	claimed.NextAttemptAt = time.Now()
+	disabled, err := repo.SaveAttempt(ctx, claimed, 2)
+	require.NoError(t, err)
+	assert.False(t, disabled)
+	disabled, err = repo.SaveAttempt(ctx, claimed, 2)
+	require.NoError(t, err)
+	assert.True(t, disabled)
+
+	sub, err = repo.GetSubscription(ctx, sub.ID)
+	require.NoError(t, err)
+	assert.False(t, sub.Active)
+	assert.False(t, sub.DisabledAt.IsZero())
+	// доставки отключённой подписки не берутся в работу.
+	assert.Nil(t, claim())
+
+	sub.Active = true
+	sub, err = repo.UpdateSubscription(ctx, sub)
+	require.NoError(t, err)
+	assert.Zero(t, sub.ConsecutiveFailures)
+	assert.True(t, sub.DisabledAt.IsZero())
+
+	deliveries, err := repo.ListDeliveries(ctx, sub.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, webhook.DeliveryPending, deliveries[0].Status)
+	assert.Equal(t, 500, deliveries[0].ResponseStatus)
+	assert.Equal(t, "boom", deliveries[0].Error)
+
+	require.NoError(t, repo.DeleteSubscription(ctx, sub.ID))
+	_, err = repo.GetSubscription(ctx, sub.ID)
+	assert.ErrorIs(t, err, webhook.ErrSubscriptionNotFound)
+}
+
+func TestStatementQueries(t *testing.T) {
+	repo, database := newTestRepo(t)
+	before := time.Now().Add(-time.Second)
+	users := createTestUsers(t, repo, 2, 100)
+	alice, bob := users[0], users[1]
+	ctx := context.Background()
+
+	_, err := repo.SaveTransaction(ctx, &coin.Transaction{FromUser: alice, ToUser: bob, Amount: 30, Type: coin.Transfer})
+	require.NoError(t, err)
+	item, err := repo.GetMerchByID(ctx, "cup")
+	require.NoError(t, err)
+	purchase := &merch.Purchase{UserID: bob.ID, MerchID: item.ID, Quantity: 1, PurchasedAt: time.Now()}
+	require.NoError(t, database.RunInTransaction(ctx, func(ctx context.Context) error {
+		tx, err := repo.SaveTransaction(ctx, &coin.Transaction{FromUser: bob, Amount: item.Price, Type: coin.Purchase})
+		if err != nil {
+			return err
+		}
+		purchase.TransactionID = tx.ID
+		return repo.SavePurchase(ctx, purchase)
+	}))
+
+	// баланс по истории совпадает с текущим и нулевой до регистрации.
+	opening, err := repo.SumHistory(ctx, bob.ID, before)
+	require.NoError(t, err)
+	assert.Zero(t, opening)
+	balance, err := repo.GetBalance(ctx, bob.ID)
+	require.NoError(t, err)
+	sum, err := repo.SumHistory(ctx, bob.ID, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, balance, sum)
+	assert.Equal(t, 130-item.Price, sum)
+
+	purchases, err := repo.ListPurchasesByTransactions(ctx, []coin.TransactionID{purchase.TransactionID})
+	require.NoError(t, err)
+	require.Len(t, purchases, 1)
+	assert.Equal(t, "cup", purchases[0].MerchName)
+	assert.Equal(t, purchase.TransactionID, purchases[0].TransactionID)
+}
+
+func TestListHistory_Counterparty(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	users := createTestUsers(t, repo, 3, 100)
+	alice, bob, carol := users[0], users[1], users[2]
+	ctx := context.Background()
+
+	_, err := repo.SaveTransaction(ctx, &coin.Transaction{FromUser: alice, ToUser: bob, Amount: 10, Type: coin.Transfer})
+	require.NoError(t, err)
+	_, err = repo.SaveTransaction(ctx, &coin.Transaction{FromUser: carol, ToUser: alice, Amount: 5, Type: coin.Transfer})
+	require.NoError(t, err)
+
+	history, err := repo.ListHistory(ctx, alice.ID, coin.HistoryFilter{Counterparty: bob.Username, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, bob.Username, history[0].ToUser.Username)
+
+	history, err = repo.ListHistory(ctx, alice.ID, coin.HistoryFilter{Counterparty: carol.Username, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, carol.Username, history[0].FromUser.Username)
+
+	// собственное имя не является контрагентом.
+	history, err = repo.ListHistory(ctx, alice.ID, coin.HistoryFilter{Counterparty: alice.Username, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestMerchCatalog(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	ctx := context.Background()
+
+	items, err := repo.ListMerch(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, items)
+	for i := 1; i < len(items); i++ {
+		assert.LessOrEqual(t, items[i-1].Price, items[i].Price)
+	}
+	cup, err := repo.GetMerchByID(ctx, "cup")
+	require.NoError(t, err)
+	assert.True(t, cup.Available)
+	assert.NotEmpty(t, cup.Description)
+
+	original := *cup
+	t.Cleanup(func() {
+		_, err := repo.UpdateMerch(ctx, original.ID, merch.MerchUpdate{
+			Price: &original.Price, Description: &original.Description, Available: &original.Available,
+		})
+		assert.NoError(t, err)
+	})
+	version, err := repo.CatalogVersion(ctx)
+	require.NoError(t, err)
+	available, description := false, "Снята с продажи"
+	updated, err := repo.UpdateMerch(ctx, cup.ID, merch.MerchUpdate{Available: &available, Description: &description})
+	require.NoError(t, err)
+	cup.Available = false
+	cup.Description = description
+	assert.Equal(t, cup, updated, "fields missing from the update must be kept")
+	got, err := repo.GetMerch(ctx, cup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, cup, got)
+	bumped, err := repo.CatalogVersion(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, bumped, version)
+
+	_, err = repo.UpdateMerch(ctx, -1, merch.MerchUpdate{Available: &available})
+	assert.ErrorIs(t, err, merch.ErrMerchNotFound)
+
+	_, err = repo.GetMerch(ctx, -1)
+	assert.ErrorIs(t, err, merch.ErrMerchNotFound)
+}
+
+// TestPurchase_Rollback повторяет покупку из merch.Service: списание и запись покупки в одной
+// транзакции. Если покупку сохранить не удалось, откатываются и транзакция, и проводки, и баланс.
+func TestPurchase_Rollback(t *testing.T) {
+	repo, database := newTestRepo(t)
+	buyer := createTestUsers(t, repo, 1, 100)[0]
+	ctx := context.Background()
+
+	type snapshot struct {
+		Balance      int `db:"balance"`
+		Transactions int `db:"transactions"`
+		Entries      int `db:"entries"`
+		Purchases    int `db:"purchases"`
+		Lots         int `db:"lots"`
+		LotsLeft     int `db:"lots_left"`
+		Events       int `db:"events"`
+	}
+	take := func() snapshot {
+		var s snapshot
+		require.NoError(t, database.Get(ctx, &s, `
+SELECT
+    (SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE fk_user = $1 AND account = 'user') AS balance,
+    (SELECT COUNT(*) FROM transactions WHERE fk_from_user = $1) AS transactions,
+    (SELECT COUNT(*) FROM ledger_entries WHERE fk_user = $1) AS entries,
+    (SELECT COUNT(*) FROM purchases WHERE fk_user = $1) AS purchases,
+    (SELECT COUNT(*) FROM coin_lots WHERE fk_user = $1) AS lots,
+    (SELECT COALESCE(SUM(remaining), 0) FROM coin_lots WHERE fk_user = $1) AS lots_left,
+    (SELECT COUNT(*) FROM outbox_events WHERE (payload->>'userId')::bigint = $1) AS events`, buyer.ID))
+		return s
+	}
+	before := take()
+	require.Equal(t, 100, before.Balance)
+	require.Equal(t, 100, before.LotsLeft)
+
+	item, err := repo.GetMerchByID(ctx, "cup")
+	require.NoError(t, err)
+	var debited bool
+	err = database.RunInTransaction(ctx, func(ctx context.Context) error {
+		tx, err := repo.SaveTransaction(ctx, &coin.Transaction{FromUser: buyer, Amount: item.Price, Type: coin.Purchase})
+		if err != nil {
+			return err
+		}
+		debited = true
+		// несуществующий товар нарушает внешний ключ, и SavePurchase падает после списания.
+		return repo.SavePurchase(ctx, &merch.Purchase{
+			UserID: buyer.ID, TransactionID: tx.ID, MerchID: -1, Quantity: 1, PurchasedAt: time.Now(),
+		})
+	})
+	require.Error(t, err)
+	require.True(t, debited)
+
+	assert.Equal(t, before, take())
+	balance, err := repo.GetBalance(ctx, buyer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 100, balance)
+}
+
+func TestDeleteExpiredRevokedTokens(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	ctx := context.Background()
+	prefix := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+	now := time.Now()
+
+	require.NoError(t, repo.RevokeAccessToken(ctx, prefix+"-expired", now.Add(-time.Minute)))
+	require.NoError(t, repo.RevokeAccessToken(ctx, prefix+"-live", now.Add(time.Hour)))
+	deleted, err := repo.DeleteExpiredRevokedTokens(ctx, now)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(1))
+
+	revoked, err := repo.IsTokenRevoked(ctx, prefix+"-expired", "")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = repo.IsTokenRevoked(ctx, prefix+"-live", "")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgPool struct {
+	ID                int64        `db:"id"`
+	OrganizerID       int64        `db:"fk_organizer"`
+	OrganizerUsername string       `db:"organizer_username"`
+	RecipientID       int64        `db:"fk_recipient"`
+	RecipientUsername string       `db:"recipient_username"`
+	Title             string       `db:"title"`
+	Goal              int          `db:"goal"`
+	Deadline          sql.NullTime `db:"deadline"`
+	Status            string       `db:"status"`
+	Collected         int          `db:"collected"`
+	Contributors      int          `db:"contributors"`
+	CreatedAt         time.Time    `db:"created_at"`
+	ClosedAt          sql.NullTime `db:"closed_at"`
+}
+
+// selectPool selects pools with the sum and the number of distinct contributors of their contributions.
+const selectPool = `
+SELECT
+    p.id,
+    p.fk_organizer,
+    o.username AS organizer_username,
+    p.fk_recipient,
+    r.username AS recipient_username,
+    p.title,
+    p.goal,
+    p.deadline,
+    p.status,
+    COALESCE(c.collected, 0) AS collected,
+    COALESCE(c.contributors, 0) AS contributors,
+    p.created_at,
+    p.closed_at
+FROM pools p
+JOIN users o ON o.id = p.fk_organizer
+JOIN users r ON r.id = p.fk_recipient
+LEFT JOIN LATERAL (
+    SELECT SUM(amount) AS collected, COUNT(DISTINCT fk_from_user) AS contributors
+    FROM transactions
+    WHERE fk_pool = p.id AND type = 'pool_contribution'
+) c ON TRUE`
+
+func mapPool(row *pgPool) *coin.Pool {
+	return &coin.Pool{
+		ID:           coin.PoolID(row.ID),
+		Organizer:    &auth.User{ID: auth.UserID(row.OrganizerID), Username: row.OrganizerUsername},
+		Recipient:    &auth.User{ID: auth.UserID(row.RecipientID), Username: row.RecipientUsername},
+		Title:        row.Title,
+		Goal:         row.Goal,
+		Deadline:     row.Deadline.Time,
+		Status:       coin.PoolStatus(row.Status),
+		Collected:    row.Collected,
+		Contributors: row.Contributors,
+		CreatedAt:    row.CreatedAt,
+		ClosedAt:     row.ClosedAt.Time,
+	}
+}
+
+// CreatePool saves a new open pool.
+func (r *PgRepository) CreatePool(ctx context.Context, p *coin.Pool) (*coin.Pool, error) {
+	if p == nil || p.Organizer == nil || p.Recipient == nil {
+		return nil, errors.New("invalid pool")
+	}
+	var deadline *time.Time
+	if !p.Deadline.IsZero() {
+		deadline = &p.Deadline
+	}
+	var id coin.PoolID
+	err := r.db.Get(ctx, &id, `
+INSERT INTO pools (fk_organizer, fk_recipient, title, goal, deadline)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id`, p.Organizer.ID, p.Recipient.ID, p.Title, p.Goal, deadline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pool: %w", err)
+	}
+	return r.GetPool(ctx, id)
+}
+
+// GetPool returns the pool with the collected sum.
+func (r *PgRepository) GetPool(ctx context.Context, id coin.PoolID) (*coin.Pool, error) {
+	var row pgPool
+	err := r.db.Get(ctx, &row, selectPool+` WHERE p.id = $1`, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, coin.ErrPoolNotFound
+		}
+		return nil, fmt.Errorf("failed to get pool: %w", err)
+	}
+	return mapPool(&row), nil
+}
+
+// ListPools returns the pools organized by the user or that the user contributed to, newest first.
+func (r *PgRepository) ListPools(ctx context.Context, userID auth.UserID) ([]*coin.Pool, error) {
+	var rows []pgPool
+	err := r.db.Select(ctx, &rows, selectPool+`
+WHERE p.fk_organizer = $1 OR EXISTS (
+    SELECT 1 FROM transactions
+    WHERE fk_pool = p.id AND type = 'pool_contribution' AND fk_from_user = $1
+)
+ORDER BY p.id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pools: %w", err)
+	}
+	res := make([]*coin.Pool, len(rows))
+	for i := range rows {
+		res[i] = mapPool(&rows[i])
+	}
+	return res, nil
+}
+
+// ListPoolContributions returns the contributions to the pool in the order they were made.
+func (r *PgRepository) ListPoolContributions(ctx context.Context, id coin.PoolID) ([]*coin.Transaction, error) {
+	var rows []pgTransactionRow
+	err := r.db.Select(ctx, &rows, selectTransaction+`
+WHERE t.fk_pool = $1 AND t.type = $2
+ORDER BY t.id`, id, coin.PoolContribution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pool contributions: %w", err)
+	}
+	res := make([]*coin.Transaction, len(rows))
+	for i := range rows {
+		res[i] = mapTransaction(&rows[i])
+	}
+	return res, nil
+}
+
+// SavePoolContribution locks the pool and posts the contribution if the pool is open and its deadline
+// hasn't passed. The pool lock orders contributions with closing, so every contribution is either
+// settled by ClosePool or rejected.
+func (r *PgRepository) SavePoolContribution(ctx context.Context, t *coin.Transaction) (*coin.Transaction, error) {
+	if t == nil || t.Type != coin.PoolContribution {
+		return nil, errors.New("invalid contribution")
+	}
+	entries, err := t.Entries()
+	if err != nil {
+		return nil, err
+	}
+	var saved *coin.Transaction
+	err = r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		var open bool
+		err := r.db.Get(ctx, &open, `
+SELECT status = $2 AND (deadline IS NULL OR deadline > NOW())
+FROM pools
+WHERE id = $1
+FOR UPDATE`, t.PoolID, coin.PoolOpen)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return coin.ErrPoolNotFound
+			}
+			return fmt.Errorf("failed to lock pool: %w", err)
+		}
+		if !open {
+			return coin.ErrPoolClosed
+		}
+		if err := r.checkDebits(ctx, entries); err != nil {
+			return err
+		}
+		saved, err = r.postTransaction(ctx, t)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// ClosePool closes the open pool with the given status and posts the payout or the refunds
+// moving the contributions out of escrow. The status update locks the pool, so it is settled only once.
+func (r *PgRepository) ClosePool(ctx context.Context, id coin.PoolID, status coin.PoolStatus) (*coin.Pool, error) {
+	var closed *coin.Pool
+	err := r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		tag, err := r.db.Exec(ctx, `
+UPDATE pools SET status = $2, closed_at = NOW()
+WHERE id = $1 AND status = $3`, id, status, coin.PoolOpen)
+		if err != nil {
+			return fmt.Errorf("failed to close pool: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return coin.ErrPoolClosed
+		}
+		pool, err := r.GetPool(ctx, id)
+		if err != nil {
+			return err
+		}
+		contributions, err := r.ListPoolContributions(ctx, id)
+		if err != nil {
+			return err
+		}
+		settlement, err := pool.Settlement(status, contributions)
+		if err != nil {
+			return err
+		}
+		for _, t := range settlement {
+			if _, err := r.postTransaction(ctx, t); err != nil {
+				return err
+			}
+		}
+		closed = pool
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return closed, nil
+}
+
+// ListPoolsPastDeadline returns up to limit open pools whose deadline passed by now.
+func (r *PgRepository) ListPoolsPastDeadline(ctx context.Context, now time.Time, limit int) ([]coin.PoolID, error) {
+	var ids []coin.PoolID
+	err := r.db.Select(ctx, &ids, `
+SELECT id FROM pools
+WHERE status = $1 AND deadline <= $2
+ORDER BY deadline
+LIMIT $3`, coin.PoolOpen, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pools past deadline: %w", err)
+	}
+	return ids, nil
+}
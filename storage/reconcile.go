@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"avito-intern/internal/reconcile"
+	"context"
+	"fmt"
+)
+
+type pgBalance struct {
+	UserID   int64  `db:"id"`
+	Username string `db:"username"`
+	Ledger   int    `db:"ledger"`
+	History  int    `db:"history"`
+	Lots     int    `db:"lots"`
+}
+
+// selectBalances computes user balances from the ledger, from the transactions and from the coin lots.
+// By the transactions, a transfer is credited once it is completed or accepted and debited unless
+// it was declined or expired (the coins went back to the sender). Pool contributions are debited
+// and pool payouts and refunds credited right away. Adjustments are left out:
+// they bring the ledger in line with the transactions.
+const selectBalances = `
+WITH ledger AS (
+    SELECT fk_user, SUM(amount) AS balance
+    FROM ledger_entries
+    WHERE account = 'user'
+    GROUP BY fk_user
+), credits AS (
+    SELECT fk_to_user AS fk_user, SUM(amount) AS amount
+    FROM transactions
+    WHERE type IN ('grant', 'transfer', 'reversal', 'pool_payout', 'pool_refund')
+      AND status IN ('completed', 'accepted')
+    GROUP BY fk_to_user
+), debits AS (
+    SELECT fk_from_user AS fk_user, SUM(amount) AS amount
+    FROM transactions
+    WHERE type IN ('transfer', 'reversal', 'purchase', 'expiry', 'pool_contribution')
+      AND status NOT IN ('declined', 'expired')
+    GROUP BY fk_from_user
+), lots AS (
+    SELECT fk_user, SUM(remaining) AS remaining
+    FROM coin_lots
+    GROUP BY fk_user
+)
+SELECT
+    u.id,
+    u.username,
+    COALESCE(l.balance, 0) AS ledger,
+    COALESCE(c.amount, 0) - COALESCE(d.amount, 0) AS history,
+    COALESCE(lt.remaining, 0) AS lots
+FROM users u
+LEFT JOIN ledger l ON l.fk_user = u.id
+LEFT JOIN credits c ON c.fk_user = u.id
+LEFT JOIN debits d ON d.fk_user = u.id
+LEFT JOIN lots lt ON lt.fk_user = u.id`
+
+func mapBalance(row *pgBalance) *reconcile.Balance {
+	return &reconcile.Balance{
+		UserID:   auth.UserID(row.UserID),
+		Username: row.Username,
+		Ledger:   row.Ledger,
+		History:  row.History,
+		Lots:     row.Lots,
+	}
+}
+
+// ListBalances returns the balances of all users computed in three ways.
+func (r *PgRepository) ListBalances(ctx context.Context) ([]*reconcile.Balance, error) {
+	var rows []pgBalance
+	if err := r.db.Select(ctx, &rows, selectBalances+` ORDER BY u.id`); err != nil {
+		return nil, fmt.Errorf("failed to list balances: %w", err)
+	}
+	res := make([]*reconcile.Balance, len(rows))
+	for i := range rows {
+		res[i] = mapBalance(&rows[i])
+	}
+	return res, nil
+}
+
+// GetConservation sums issued, spent and held coins by the transactions and user balances by the ledger.
+func (r *PgRepository) GetConservation(ctx context.Context) (*reconcile.Conservation, error) {
+	var row struct {
+		Issued   int `db:"issued"`
+		Spent    int `db:"spent"`
+		Escrow   int `db:"escrow"`
+		Balances int `db:"balances"`
+	}
+	err := r.db.Get(ctx, &row, `
+SELECT
+    COALESCE(SUM(amount) FILTER (WHERE type = 'grant' OR type = 'adjustment' AND fk_to_user IS NOT NULL), 0)
+        - COALESCE(SUM(amount) FILTER (WHERE type = 'expiry' OR type = 'adjustment' AND fk_from_user IS NOT NULL), 0)
+        AS issued,
+    COALESCE(SUM(amount) FILTER (WHERE type = 'purchase'), 0) AS spent,
+    COALESCE(SUM(amount) FILTER (WHERE type = 'transfer' AND status = 'pending' OR type = 'pool_contribution'), 0)
+        - COALESCE(SUM(amount) FILTER (WHERE type IN ('pool_payout', 'pool_refund')), 0)
+        AS escrow,
+    (SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = 'user') AS balances
+FROM transactions`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conservation: %w", err)
+	}
+	return &reconcile.Conservation{
+		Issued:   row.Issued,
+		Spent:    row.Spent,
+		Escrow:   row.Escrow,
+		Balances: row.Balances,
+	}, nil
+}
+
+// SaveCorrection brings the user's coin lots in line with the ledger and then posts an adjustment
+// moving the ledger to the balance by the transactions. It's applied only if the balances are still
+// the ones the admin approved.
+func (r *PgRepository) SaveCorrection(ctx context.Context, c *reconcile.Correction) (*reconcile.Correction, error) {
+	saved := *c
+	err := r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		if err := r.LockUsers(ctx, c.Balance.UserID); err != nil {
+			return err
+		}
+		var row pgBalance
+		if err := r.db.Get(ctx, &row, selectBalances+` WHERE u.id = $1`, c.Balance.UserID); err != nil {
+			return fmt.Errorf("failed to get balance: %w", err)
+		}
+		current := mapBalance(&row)
+		if current.Ledger != c.Balance.Ledger || current.History != c.Balance.History || current.Lots != c.Balance.Lots {
+			return reconcile.ErrStaleBalance
+		}
+
+		switch {
+		case current.Lots < current.Ledger:
+			_, err := r.db.Exec(ctx, `
+INSERT INTO coin_lots (fk_user, amount, remaining) VALUES ($1, $2, $2)`, current.UserID, current.Ledger-current.Lots)
+			if err != nil {
+				return fmt.Errorf("failed to open coin lot: %w", err)
+			}
+		case current.Lots > current.Ledger:
+			if err := r.consumeLots(ctx, current.UserID, 0, current.Lots-current.Ledger); err != nil {
+				return err
+			}
+		}
+
+		if diff := current.Diff(); diff != 0 {
+			user := &auth.User{ID: current.UserID, Username: current.Username}
+			t := &coin.Transaction{Amount: diff, Type: coin.Adjustment, Note: coin.Note{Message: c.Reason}}
+			if diff > 0 {
+				t.ToUser = user
+			} else {
+				t.FromUser = user
+				t.Amount = -diff
+			}
+			var err error
+			if saved.Transaction, err = r.postTransaction(ctx, t); err != nil {
+				return err
+			}
+		}
+
+		var txID *coin.TransactionID
+		if saved.Transaction != nil {
+			txID = &saved.Transaction.ID
+		}
+		_, err := r.db.Exec(ctx, `
+INSERT INTO balance_corrections (fk_user, fk_admin, fk_transaction, reason, ledger_before, history, lots_before)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			current.UserID, c.Admin.ID, txID, c.Reason, current.Ledger, current.History, current.Lots)
+		if err != nil {
+			return fmt.Errorf("failed to save correction: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
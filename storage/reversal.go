@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/coin"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgTransactionRow struct {
+	ID           int64          `db:"id"`
+	Type         string         `db:"type"`
+	Amount       int            `db:"amount"`
+	FromUserID   sql.NullInt64  `db:"fk_from_user"`
+	FromUsername sql.NullString `db:"from_username"`
+	ToUserID     sql.NullInt64  `db:"fk_to_user"`
+	ToUsername   sql.NullString `db:"to_username"`
+	PrevID       *int64         `db:"fk_prev_transaction"`
+	PoolID       sql.NullInt64  `db:"fk_pool"`
+	Status       string         `db:"status"`
+	ExpiresAt    sql.NullTime   `db:"expires_at"`
+	Message      string         `db:"message"`
+	Tags         []string       `db:"tags"`
+	CreatedAt    time.Time      `db:"created_at"`
+}
+
+const selectTransaction = `
+SELECT
+    t.id,
+    t.type,
+    t.amount,
+    t.fk_from_user,
+    f.username AS from_username,
+    t.fk_to_user,
+    u.username AS to_username,
+    t.fk_prev_transaction,
+    t.fk_pool,
+    t.status,
+    t.expires_at,
+    t.message,
+    t.tags,
+    t.created_at
+FROM transactions t
+LEFT JOIN users f ON f.id = t.fk_from_user
+LEFT JOIN users u ON u.id = t.fk_to_user`
+
+func mapTransaction(row *pgTransactionRow) *coin.Transaction {
+	t := &coin.Transaction{
+		ID:              coin.TransactionID(row.ID),
+		Type:            coin.Type(row.Type),
+		Amount:          row.Amount,
+		PrevTransaction: row.PrevID,
+		PoolID:          coin.PoolID(row.PoolID.Int64),
+		Status:          coin.Status(row.Status),
+		ExpiresAt:       row.ExpiresAt.Time,
+		CreatedAt:       row.CreatedAt,
+		Note:            coin.Note{Message: row.Message},
+	}
+	for _, tag := range row.Tags {
+		t.Tags = append(t.Tags, coin.Tag(tag))
+	}
+	if row.FromUserID.Valid {
+		t.FromUser = &auth.User{ID: auth.UserID(row.FromUserID.Int64), Username: row.FromUsername.String}
+	}
+	if row.ToUserID.Valid {
+		t.ToUser = &auth.User{ID: auth.UserID(row.ToUserID.Int64), Username: row.ToUsername.String}
+	}
+	return t
+}
+
+// GetTransaction returns the transaction with its participants.
+func (r *PgRepository) GetTransaction(ctx context.Context, id coin.TransactionID) (*coin.Transaction, error) {
+	var row pgTransactionRow
+	err := r.db.Get(ctx, &row, selectTransaction+` WHERE t.id = $1`, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, coin.ErrTransactionNotFound
+		}
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	return mapTransaction(&row), nil
+}
+
+// SumReversed returns the total of reversals posted against the transaction.
+func (r *PgRepository) SumReversed(ctx context.Context, id coin.TransactionID) (int, error) {
+	var sum int
+	err := r.db.Get(ctx, &sum, `
+SELECT COALESCE(SUM(amount), 0)
+FROM transactions
+WHERE fk_prev_transaction = $1 AND type = $2`, id, coin.Reversal)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum reversals: %w", err)
+	}
+	return sum, nil
+}
+
+// SaveReversal posts the compensating transaction and records who reversed it and why.
+func (r *PgRepository) SaveReversal(ctx context.Context, rev *coin.ReversalRecord) (*coin.ReversalRecord, error) {
+	if rev == nil || rev.Transaction == nil || rev.Admin == nil {
+		return nil, errors.New("invalid reversal")
+	}
+	saved := *rev
+	err := r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		saved.Transaction, err = r.SaveTransaction(ctx, rev.Transaction)
+		if err != nil {
+			return err
+		}
+		_, err = r.db.Exec(ctx, `
+INSERT INTO reversals (fk_transaction, fk_admin, reason, requested)
+VALUES ($1, $2, $3, $4)`, saved.Transaction.ID, rev.Admin.ID, rev.Reason, rev.Requested)
+		if err != nil {
+			return fmt.Errorf("failed to save reversal: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"avito-intern/internal/scheduler"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type pgJob struct {
+	ID        int64        `db:"id"`
+	Name      string       `db:"name"`
+	Kind      string       `db:"kind"`
+	Schedule  string       `db:"schedule"`
+	Params    []byte       `db:"params"`
+	Enabled   bool         `db:"enabled"`
+	NextRunAt time.Time    `db:"next_run_at"`
+	RetryAt   sql.NullTime `db:"retry_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+const selectJob = `
+SELECT id, name, kind, schedule, params, enabled, next_run_at, retry_at, created_at
+FROM jobs`
+
+func mapJob(row *pgJob) *scheduler.Job {
+	return &scheduler.Job{
+		ID:        scheduler.JobID(row.ID),
+		Name:      row.Name,
+		Kind:      scheduler.Kind(row.Kind),
+		Schedule:  row.Schedule,
+		Params:    json.RawMessage(row.Params),
+		Enabled:   row.Enabled,
+		NextRunAt: row.NextRunAt,
+		RetryAt:   row.RetryAt.Time,
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+func mapJobs(rows []pgJob) []*scheduler.Job {
+	jobs := make([]*scheduler.Job, len(rows))
+	for i := range rows {
+		jobs[i] = mapJob(&rows[i])
+	}
+	return jobs
+}
+
+// nullTime maps the zero time to NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// CreateJob saves a new job, the name must be unique.
+func (r *PgRepository) CreateJob(ctx context.Context, job *scheduler.Job) (*scheduler.Job, error) {
+	var row pgJob
+	err := r.db.Get(ctx, &row, `
+INSERT INTO jobs (name, kind, schedule, params, enabled, next_run_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, name, kind, schedule, params, enabled, next_run_at, retry_at, created_at`,
+		job.Name, job.Kind, job.Schedule, []byte(job.Params), job.Enabled, job.NextRunAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == codeUniqueViolation {
+			return nil, scheduler.ErrJobExists
+		}
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	return mapJob(&row), nil
+}
+
+// UpdateJob saves the schedule, params and state of the job.
+func (r *PgRepository) UpdateJob(ctx context.Context, job *scheduler.Job) (*scheduler.Job, error) {
+	var row pgJob
+	err := r.db.Get(ctx, &row, `
+UPDATE jobs SET schedule = $2, params = $3, enabled = $4, next_run_at = $5, retry_at = $6
+WHERE id = $1
+RETURNING id, name, kind, schedule, params, enabled, next_run_at, retry_at, created_at`,
+		job.ID, job.Schedule, []byte(job.Params), job.Enabled, job.NextRunAt, nullTime(job.RetryAt))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, scheduler.ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to update job: %w", err)
+	}
+	return mapJob(&row), nil
+}
+
+// GetJob returns the job by ID.
+func (r *PgRepository) GetJob(ctx context.Context, id scheduler.JobID) (*scheduler.Job, error) {
+	var row pgJob
+	if err := r.db.Get(ctx, &row, selectJob+` WHERE id = $1`, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, scheduler.ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return mapJob(&row), nil
+}
+
+// ListJobs returns all jobs ordered by name.
+func (r *PgRepository) ListJobs(ctx context.Context) ([]*scheduler.Job, error) {
+	var rows []pgJob
+	if err := r.db.Select(ctx, &rows, selectJob+` ORDER BY name`); err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return mapJobs(rows), nil
+}
+
+// ListDueJobs returns the enabled jobs whose next run and retry are due by now.
+func (r *PgRepository) ListDueJobs(ctx context.Context, now time.Time) ([]*scheduler.Job, error) {
+	var rows []pgJob
+	err := r.db.Select(ctx, &rows, selectJob+`
+WHERE enabled AND next_run_at <= $1 AND (retry_at IS NULL OR retry_at <= $1)
+ORDER BY next_run_at`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due jobs: %w", err)
+	}
+	return mapJobs(rows), nil
+}
+
+// SetNextRun moves the next run of the job, the zero retryAt clears the retry.
+func (r *PgRepository) SetNextRun(ctx context.Context, id scheduler.JobID, nextRunAt, retryAt time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE jobs SET next_run_at = $2, retry_at = $3 WHERE id = $1`,
+		id, nextRunAt, nullTime(retryAt))
+	if err != nil {
+		return fmt.Errorf("failed to set next run: %w", err)
+	}
+	return nil
+}
+
+type pgRun struct {
+	ID         int64        `db:"id"`
+	JobID      int64        `db:"fk_job"`
+	Period     time.Time    `db:"period"`
+	Status     string       `db:"status"`
+	Result     string       `db:"result"`
+	Error      string       `db:"error"`
+	StartedAt  time.Time    `db:"started_at"`
+	FinishedAt sql.NullTime `db:"finished_at"`
+}
+
+const runColumns = `id, fk_job, period, status, result, error, started_at, finished_at`
+
+func mapRun(row *pgRun) *scheduler.Run {
+	return &scheduler.Run{
+		ID:         row.ID,
+		JobID:      scheduler.JobID(row.JobID),
+		Period:     row.Period,
+		Status:     scheduler.RunStatus(row.Status),
+		Result:     row.Result,
+		Error:      row.Error,
+		StartedAt:  row.StartedAt,
+		FinishedAt: row.FinishedAt.Time,
+	}
+}
+
+// StartRun claims the run of the job for the period. A failed run or a run left running
+// since before staleBefore is restarted in place; any other existing run is returned as is.
+func (r *PgRepository) StartRun(ctx context.Context, id scheduler.JobID, period, staleBefore time.Time) (*scheduler.Run, bool, error) {
+	var row pgRun
+	err := r.db.Get(ctx, &row, `
+INSERT INTO job_runs (fk_job, period, status)
+VALUES ($1, $2, $4)
+ON CONFLICT (fk_job, period) DO UPDATE
+SET status = $4, error = '', started_at = NOW(), finished_at = NULL
+WHERE job_runs.status = $5 OR (job_runs.status = $4 AND job_runs.started_at < $3)
+RETURNING `+runColumns,
+		id, period, staleBefore, scheduler.RunRunning, scheduler.RunFailed)
+	if err == nil {
+		return mapRun(&row), true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("failed to start run: %w", err)
+	}
+	err = r.db.Get(ctx, &row, `SELECT `+runColumns+` FROM job_runs WHERE fk_job = $1 AND period = $2`, id, period)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get run: %w", err)
+	}
+	return mapRun(&row), false, nil
+}
+
+// FinishRun saves the outcome of the run.
+func (r *PgRepository) FinishRun(ctx context.Context, run *scheduler.Run) error {
+	_, err := r.db.Exec(ctx, `
+UPDATE job_runs SET status = $2, result = $3, error = $4, finished_at = $5
+WHERE id = $1`, run.ID, run.Status, run.Result, run.Error, run.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to finish run: %w", err)
+	}
+	return nil
+}
+
+// ListRuns returns the last limit runs of the job, newest first.
+func (r *PgRepository) ListRuns(ctx context.Context, id scheduler.JobID, limit int) ([]*scheduler.Run, error) {
+	var rows []pgRun
+	err := r.db.Select(ctx, &rows, `SELECT `+runColumns+` FROM job_runs
+WHERE fk_job = $1
+ORDER BY period DESC
+LIMIT $2`, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+	res := make([]*scheduler.Run, len(rows))
+	for i := range rows {
+		res[i] = mapRun(&rows[i])
+	}
+	return res, nil
+}
+
+// TryLock takes the scheduler leader lock, nil if another replica holds it.
+func (r *PgRepository) TryLock(ctx context.Context, key int64) (scheduler.Lock, error) {
+	lock, err := r.db.TryAdvisoryLock(ctx, key)
+	if err != nil || lock == nil {
+		// явный nil, иначе интерфейс с nil-указателем не равен nil.
+		return nil, err
+	}
+	return lock, nil
+}
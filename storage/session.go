@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgRefreshToken struct {
+	Hash           string     `db:"token_hash"`
+	SessionID      string     `db:"fk_session"`
+	UserID         int64      `db:"fk_user"`
+	ExpiresAt      time.Time  `db:"expires_at"`
+	UsedAt         *time.Time `db:"used_at"`
+	SessionRevoked bool       `db:"session_revoked"`
+}
+
+// CreateSession saves a new login session.
+func (r *PgRepository) CreateSession(ctx context.Context, session *auth.Session) error {
+	query := `INSERT INTO sessions (id, fk_user, created_at) VALUES ($1, $2, $3)`
+	if _, err := r.db.Exec(ctx, query, session.ID, session.UserID, session.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// SaveRefreshToken saves the refresh token hash.
+func (r *PgRepository) SaveRefreshToken(ctx context.Context, rec *auth.RefreshTokenRecord) error {
+	query := `INSERT INTO refresh_tokens (token_hash, fk_session, expires_at) VALUES ($1, $2, $3)`
+	if _, err := r.db.Exec(ctx, query, rec.Hash, rec.SessionID, rec.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to save refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetRefreshTokenForUpdate returns the refresh token by hash and locks it until the transaction ends.
+func (r *PgRepository) GetRefreshTokenForUpdate(ctx context.Context, hash string) (*auth.RefreshTokenRecord, error) {
+	query := `
+SELECT
+    t.token_hash,
+    t.fk_session,
+    s.fk_user,
+    t.expires_at,
+    t.used_at,
+    s.revoked_at IS NOT NULL AS session_revoked
+FROM refresh_tokens t
+JOIN sessions s ON s.id = t.fk_session
+WHERE t.token_hash = $1
+FOR UPDATE OF t`
+	var t pgRefreshToken
+	if err := r.db.Get(ctx, &t, query, hash); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return &auth.RefreshTokenRecord{
+		Hash:           t.Hash,
+		SessionID:      auth.SessionID(t.SessionID),
+		UserID:         auth.UserID(t.UserID),
+		ExpiresAt:      t.ExpiresAt,
+		UsedAt:         t.UsedAt,
+		SessionRevoked: t.SessionRevoked,
+	}, nil
+}
+
+// MarkRefreshTokenUsed marks the refresh token as rotated.
+func (r *PgRepository) MarkRefreshTokenUsed(ctx context.Context, hash string, usedAt time.Time) error {
+	query := `UPDATE refresh_tokens SET used_at = $2 WHERE token_hash = $1 AND used_at IS NULL`
+	result, err := r.db.Exec(ctx, query, hash, usedAt)
+	if err != nil {
+		return fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return auth.ErrInvalidToken
+	}
+	return nil
+}
+
+// RevokeSession revokes the session and every token issued in it.
+func (r *PgRepository) RevokeSession(ctx context.Context, sessionID auth.SessionID, revokedAt time.Time) error {
+	query := `UPDATE sessions SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL`
+	if _, err := r.db.Exec(ctx, query, sessionID, revokedAt); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAccessToken adds the access token jti to the revocation list.
+func (r *PgRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`
+	if _, err := r.db.Exec(ctx, query, jti, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredRevokedTokens deletes the revoked access tokens that expired before the given time.
+func (r *PgRepository) DeleteExpiredRevokedTokens(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired revoked tokens: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// IsTokenRevoked reports whether the access token or its session has been revoked.
+func (r *PgRepository) IsTokenRevoked(ctx context.Context, jti string, sessionID auth.SessionID) (bool, error) {
+	query := `
+SELECT
+    EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+    OR EXISTS (SELECT 1 FROM sessions WHERE id = $2 AND revoked_at IS NOT NULL)`
+	var revoked bool
+	if err := r.db.Get(ctx, &revoked, query, jti, sessionID); err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return revoked, nil
+}
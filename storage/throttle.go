@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"context"
+	"fmt"
+	"time"
+)
+
+type pgThrottle struct {
+	Key         string     `db:"key"`
+	Attempts    int        `db:"attempts"`
+	WindowStart time.Time  `db:"window_start"`
+	LockedUntil *time.Time `db:"locked_until"`
+}
+
+// HitThrottle increments the attempt counter of the key, starting a new window if the current one has expired.
+func (r *PgRepository) HitThrottle(ctx context.Context, key string, now time.Time, window time.Duration) (*auth.Throttle, error) {
+	query := `
+INSERT INTO auth_throttle (key, attempts, window_start)
+VALUES ($1, 1, $2)
+ON CONFLICT (key) DO UPDATE SET
+    attempts = CASE WHEN auth_throttle.window_start > $3
+        THEN auth_throttle.attempts + 1 ELSE 1 END,
+    window_start = CASE WHEN auth_throttle.window_start > $3
+        THEN auth_throttle.window_start ELSE $2 END
+RETURNING key, attempts, window_start, locked_until`
+	var t pgThrottle
+	if err := r.db.Get(ctx, &t, query, key, now, now.Add(-window)); err != nil {
+		return nil, fmt.Errorf("failed to hit throttle: %w", err)
+	}
+	return &auth.Throttle{
+		Key:         t.Key,
+		Attempts:    t.Attempts,
+		WindowStart: t.WindowStart,
+		LockedUntil: t.LockedUntil,
+	}, nil
+}
+
+// LockThrottle blocks the key until the given time.
+func (r *PgRepository) LockThrottle(ctx context.Context, key string, until time.Time) error {
+	query := `UPDATE auth_throttle SET locked_until = $2 WHERE key = $1`
+	if _, err := r.db.Exec(ctx, query, key, until); err != nil {
+		return fmt.Errorf("failed to lock throttle: %w", err)
+	}
+	return nil
+}
+
+// ResetThrottle forgets the attempts of the key.
+func (r *PgRepository) ResetThrottle(ctx context.Context, key string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM auth_throttle WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("failed to reset throttle: %w", err)
+	}
+	return nil
+}
+
+// GetThrottleLockedUntil returns the latest lock among the keys or zero time.
+func (r *PgRepository) GetThrottleLockedUntil(ctx context.Context, keys []string) (time.Time, error) {
+	query := `SELECT MAX(locked_until) FROM auth_throttle WHERE key = ANY($1)`
+	var lockedUntil *time.Time
+	if err := r.db.Get(ctx, &lockedUntil, query, keys); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get throttle lock: %w", err)
+	}
+	if lockedUntil == nil {
+		return time.Time{}, nil
+	}
+	return *lockedUntil, nil
+}
@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"avito-intern/internal/auth"
+	"avito-intern/internal/outbox"
+	"avito-intern/internal/webhook"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgSubscription struct {
+	ID                  int64        `db:"id"`
+	UserID              int64        `db:"fk_user"`
+	URL                 string       `db:"url"`
+	Events              []string     `db:"events"`
+	Secret              string       `db:"secret"`
+	Active              bool         `db:"active"`
+	ConsecutiveFailures int          `db:"consecutive_failures"`
+	DisabledAt          sql.NullTime `db:"disabled_at"`
+	CreatedAt           time.Time    `db:"created_at"`
+}
+
+const subscriptionColumns = `id, fk_user, url, events, secret, active, consecutive_failures, disabled_at, created_at`
+
+func mapSubscription(row *pgSubscription) *webhook.Subscription {
+	events := make([]webhook.Event, len(row.Events))
+	for i, e := range row.Events {
+		events[i] = webhook.Event(e)
+	}
+	return &webhook.Subscription{
+		ID:                  webhook.SubscriptionID(row.ID),
+		Owner:               auth.UserID(row.UserID),
+		URL:                 row.URL,
+		Events:              events,
+		Secret:              row.Secret,
+		Active:              row.Active,
+		ConsecutiveFailures: row.ConsecutiveFailures,
+		DisabledAt:          row.DisabledAt.Time,
+		CreatedAt:           row.CreatedAt,
+	}
+}
+
+func subscriptionEvents(events []webhook.Event) []string {
+	res := make([]string, len(events))
+	for i, e := range events {
+		res[i] = string(e)
+	}
+	return res
+}
+
+// CreateSubscription saves a new subscription.
+func (r *PgRepository) CreateSubscription(ctx context.Context, s *webhook.Subscription) (*webhook.Subscription, error) {
+	var row pgSubscription
+	err := r.db.Get(ctx, &row, `
+INSERT INTO webhook_subscriptions (fk_user, url, events, secret, active)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING `+subscriptionColumns, s.Owner, s.URL, subscriptionEvents(s.Events), s.Secret, s.Active)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return mapSubscription(&row), nil
+}
+
+// GetSubscription returns the subscription by ID.
+func (r *PgRepository) GetSubscription(ctx context.Context, id webhook.SubscriptionID) (*webhook.Subscription, error) {
+	var row pgSubscription
+	err := r.db.Get(ctx, &row, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, webhook.ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	return mapSubscription(&row), nil
+}
+
+// ListSubscriptions returns the subscriptions of the user in the order they were created.
+func (r *PgRepository) ListSubscriptions(ctx context.Context, userID auth.UserID) ([]*webhook.Subscription, error) {
+	var rows []pgSubscription
+	err := r.db.Select(ctx, &rows, `
+SELECT `+subscriptionColumns+` FROM webhook_subscriptions
+WHERE fk_user = $1
+ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	res := make([]*webhook.Subscription, len(rows))
+	for i := range rows {
+		res[i] = mapSubscription(&rows[i])
+	}
+	return res, nil
+}
+
+// UpdateSubscription saves the URL, events and state of the subscription. Enabling the subscription
+// resets its consecutive failures.
+func (r *PgRepository) UpdateSubscription(ctx context.Context, s *webhook.Subscription) (*webhook.Subscription, error) {
+	var row pgSubscription
+	err := r.db.Get(ctx, &row, `
+UPDATE webhook_subscriptions SET
+    url = $2,
+    events = $3,
+    active = $4,
+    consecutive_failures = CASE WHEN $4 AND NOT active THEN 0 ELSE consecutive_failures END,
+    disabled_at = CASE WHEN $4 THEN NULL ELSE disabled_at END
+WHERE id = $1
+RETURNING `+subscriptionColumns, s.ID, s.URL, subscriptionEvents(s.Events), s.Active)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, webhook.ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+	return mapSubscription(&row), nil
+}
+
+// DeleteSubscription deletes the subscription, its deliveries are deleted by cascade.
+func (r *PgRepository) DeleteSubscription(ctx context.Context, id webhook.SubscriptionID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return webhook.ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+type pgDelivery struct {
+	ID             int64           `db:"id"`
+	SubscriptionID int64           `db:"fk_subscription"`
+	URL            sql.NullString  `db:"url"`
+	Secret         sql.NullString  `db:"secret"`
+	EventID        int64           `db:"event_id"`
+	Event          string          `db:"event"`
+	Type           string          `db:"type"`
+	Payload        json.RawMessage `db:"payload"`
+	OccurredAt     time.Time       `db:"occurred_at"`
+	Status         string          `db:"status"`
+	Attempts       int             `db:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at"`
+	ResponseStatus sql.NullInt32   `db:"response_status"`
+	LastError      sql.NullString  `db:"last_error"`
+	CreatedAt      time.Time       `db:"created_at"`
+	DeliveredAt    sql.NullTime    `db:"delivered_at"`
+}
+
+const deliveryColumns = `
+    d.id, d.fk_subscription, d.event_id, d.event, d.type, d.payload, d.occurred_at, d.status, d.attempts,
+    d.next_attempt_at, d.response_status, d.last_error, d.created_at, d.delivered_at`
+
+func mapDelivery(row *pgDelivery) *webhook.Delivery {
+	return &webhook.Delivery{
+		ID: webhook.DeliveryID(row.ID),
+		Subscription: &webhook.Subscription{
+			ID:     webhook.SubscriptionID(row.SubscriptionID),
+			URL:    row.URL.String,
+			Secret: row.Secret.String,
+		},
+		EventID:        outbox.EventID(row.EventID),
+		Event:          webhook.Event(row.Event),
+		Type:           outbox.EventType(row.Type),
+		Payload:        row.Payload,
+		OccurredAt:     row.OccurredAt,
+		Status:         webhook.DeliveryStatus(row.Status),
+		Attempts:       row.Attempts,
+		NextAttemptAt:  row.NextAttemptAt,
+		ResponseStatus: int(row.ResponseStatus.Int32),
+		Error:          row.LastError.String,
+		CreatedAt:      row.CreatedAt,
+		DeliveredAt:    row.DeliveredAt.Time,
+	}
+}
+
+func mapDeliveries(rows []pgDelivery) []*webhook.Delivery {
+	res := make([]*webhook.Delivery, len(rows))
+	for i := range rows {
+		res[i] = mapDelivery(&rows[i])
+	}
+	return res
+}
+
+// ListDeliveries returns the last limit deliveries of the subscription, newest first.
+func (r *PgRepository) ListDeliveries(ctx context.Context, id webhook.SubscriptionID, limit int) ([]*webhook.Delivery, error) {
+	var rows []pgDelivery
+	err := r.db.Select(ctx, &rows, `
+SELECT`+deliveryColumns+`
+FROM webhook_deliveries d
+WHERE d.fk_subscription = $1
+ORDER BY d.id DESC
+LIMIT $2`, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return mapDeliveries(rows), nil
+}
+
+// EnqueueDeliveries creates the delivery for every active subscription of the user to its event.
+// The unique key skips the deliveries already created when the outbox batch is published again.
+func (r *PgRepository) EnqueueDeliveries(ctx context.Context, userID auth.UserID, d *webhook.Delivery) (int, error) {
+	tag, err := r.db.Exec(ctx, `
+INSERT INTO webhook_deliveries (fk_subscription, event_id, event, type, payload, occurred_at)
+SELECT id, $2, $3, $4, $5, $6
+FROM webhook_subscriptions
+WHERE fk_user = $1 AND active AND $3 = ANY(events)
+ON CONFLICT (fk_subscription, event_id, event) DO NOTHING`,
+		userID, d.EventID, d.Event, d.Type, d.Payload, d.OccurredAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// ClaimDeliveries postpones the next attempt of up to limit due deliveries of active subscriptions
+// to leaseUntil and returns them with the URL and the secret of their subscriptions. Locked rows are skipped,
+// so concurrent replicas claim different deliveries.
+func (r *PgRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*webhook.Delivery, error) {
+	var rows []pgDelivery
+	err := r.db.Select(ctx, &rows, `
+UPDATE webhook_deliveries d SET next_attempt_at = $2
+FROM webhook_subscriptions s
+WHERE s.id = d.fk_subscription AND d.id IN (
+    SELECT dd.id
+    FROM webhook_deliveries dd
+    JOIN webhook_subscriptions ss ON ss.id = dd.fk_subscription
+    WHERE dd.status = $4 AND dd.next_attempt_at <= $1 AND ss.active
+    ORDER BY dd.next_attempt_at
+    LIMIT $3
+    FOR UPDATE OF dd SKIP LOCKED
+)
+RETURNING`+deliveryColumns+`, s.url, s.secret`, now, leaseUntil, limit, webhook.DeliveryPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	return mapDeliveries(rows), nil
+}
+
+// SaveAttempt saves the outcome of the delivery attempt and counts the consecutive failures of the subscription.
+// The subscription is disabled when they reach disableAfter.
+func (r *PgRepository) SaveAttempt(ctx context.Context, d *webhook.Delivery, disableAfter int) (bool, error) {
+	var disabled bool
+	err := r.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		_, err := r.db.Exec(ctx, `
+UPDATE webhook_deliveries SET
+    status = $2,
+    attempts = $3,
+    next_attempt_at = COALESCE($4, next_attempt_at),
+    response_status = NULLIF($5, 0),
+    last_error = NULLIF($6, ''),
+    delivered_at = $7
+WHERE id = $1`, d.ID, d.Status, d.Attempts, nullTime(d.NextAttemptAt), d.ResponseStatus, d.Error, nullTime(d.DeliveredAt))
+		if err != nil {
+			return fmt.Errorf("failed to save webhook delivery: %w", err)
+		}
+		if d.Status == webhook.DeliveryDelivered {
+			_, err := r.db.Exec(ctx, `UPDATE webhook_subscriptions SET consecutive_failures = 0 WHERE id = $1`, d.Subscription.ID)
+			if err != nil {
+				return fmt.Errorf("failed to reset webhook subscription failures: %w", err)
+			}
+			return nil
+		}
+		err = r.db.Get(ctx, &disabled, `
+UPDATE webhook_subscriptions s SET
+    consecutive_failures = s.consecutive_failures + 1,
+    active = s.active AND s.consecutive_failures + 1 < $2,
+    disabled_at = CASE WHEN s.active AND s.consecutive_failures + 1 >= $2 THEN NOW() ELSE s.disabled_at END
+FROM (SELECT id, active FROM webhook_subscriptions WHERE id = $1 FOR UPDATE) old
+WHERE s.id = old.id
+RETURNING old.active AND NOT s.active`, d.Subscription.ID, disableAfter)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to count webhook subscription failures: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return disabled, nil
+}